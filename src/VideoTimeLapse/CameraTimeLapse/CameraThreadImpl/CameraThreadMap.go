@@ -70,6 +70,17 @@ func(t *CameraThreadMap)PutCameraThreadObjInMap(cam *dataSet.Camera)(
     return nil,appErrors.INVALID_INPUT
 }
 
+//Return the camera thread obj of the camera, DATA_NOT_FOUND if the camera has
+// no thread in the map.
+func(t *CameraThreadMap)GetCameraThreadObjInMap(camName string)(
+                                 CameraTimeLapse.CameraThreadInterface, error) {
+    obj, ret := t.__isCameraThreadInMap(camName)
+    if !ret {
+        return nil, appErrors.DATA_NOT_FOUND
+    }
+    return obj, nil
+}
+
 func(t *CameraThreadMap)RemoveCameraThreadObjInMap(camName string) {
     t.__delCameraThreadInMap(camName)
 }
@@ -0,0 +1,100 @@
+package RTSPCameraImpl
+
+import (
+    "os"
+    "time"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+// On-demand actions on the running timelapse cycle of a camera. A render
+// stitches the snapshots captured so far into a partial timelapse and leaves
+// the cycle running. A rotate closes the running cycle before its end, so it
+// is rendered as usual and a new cycle is started.
+
+const (
+    //Partial timelapse directory is named as
+    // <cycle-dir>-upto-<render-time>, next to the cycle directory.
+    PARTIAL_RENDER_SEP = "-upto-"
+)
+
+//Mark the snapshot file as being written or completed.
+func (camThread *RTSPCameraThread)setSnapshotActive(snapshotFile string,
+                                                    active bool) {
+    camThread.snapshotLock.Lock()
+    defer camThread.snapshotLock.Unlock()
+    if camThread.activeSnapshots == nil {
+        camThread.activeSnapshots = make(map[string]bool)
+    }
+    if active {
+        camThread.activeSnapshots[snapshotFile] = true
+    } else {
+        delete(camThread.activeSnapshots, snapshotFile)
+    }
+}
+
+//Return a copy of the snapshot files that are being written.
+func (camThread *RTSPCameraThread)getActiveSnapshots() map[string]bool {
+    camThread.snapshotLock.Lock()
+    defer camThread.snapshotLock.Unlock()
+    files := make(map[string]bool, len(camThread.activeSnapshots))
+    for file := range camThread.activeSnapshots {
+        files[file] = true
+    }
+    return files
+}
+
+func (camThread *RTSPCameraThread)isRotateFired() bool {
+    select {
+        case <-camThread.rotateSignal:
+            return true
+        default:
+            return false
+    }
+}
+
+//Render the snapshots of the running cycle captured so far, without ending
+// the cycle. The rendering happens in a background job and the partial
+// timelapse is added to the video catalog like any other timelapse.
+func (camThread *RTSPCameraThread)RenderCameraTimelapse() error {
+    log := logging.GetLoggerInstance()
+    camThread.threadLock.RLock()
+    cycleDir := camThread.videoPath + "/" +
+                camThread.getCycleName__(camThread.startTime)
+    outputDir := cycleDir + PARTIAL_RENDER_SEP +
+                 camThread.getCycleName__(time.Now())
+    camThread.threadLock.RUnlock()
+    if _, err := os.Stat(cycleDir); os.IsNotExist(err) {
+        log.Error("No snapshots in the running cycle of %s to render",
+                    camThread.name)
+        return appErrors.DATA_NOT_FOUND
+    }
+    skipFiles := camThread.getActiveSnapshots()
+    log.Trace("Rendering the timelapse of %s so far in %s", camThread.name,
+                outputDir)
+    return camThread.submitCycleJob(dataSet.JOB_TYPE_EXPORT, cycleDir,
+                                    outputDir, skipFiles)
+}
+
+//Close the running cycle of the camera. The camera thread renders the cycle
+// and starts a new one at its next iteration.
+func (camThread *RTSPCameraThread)RotateCameraCycle() error {
+    log := logging.GetLoggerInstance()
+    camThread.threadLock.RLock()
+    status := camThread.status
+    camThread.threadLock.RUnlock()
+    if status != dataSet.CAMERA_STREAMING {
+        log.Error("Cannot rotate the cycle of %s, camera is not streaming",
+                    camThread.name)
+        return appErrors.INVALID_OP
+    }
+    select {
+        case camThread.rotateSignal <- true:
+            log.Trace("Rotate signal triggered to %s", camThread.name)
+        default:
+            //Rotate is already pending for the cycle.
+            log.Trace("Rotate is already pending on %s", camThread.name)
+    }
+    return nil
+}
@@ -0,0 +1,62 @@
+package RTSPCameraImpl
+
+// Test file for validating the on-demand actions on the running cycle.
+import (
+    "testing"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+func TestActiveSnapshots(t *testing.T) {
+    var camThread RTSPCameraThread
+    if files := camThread.getActiveSnapshots(); len(files) != 0 {
+        t.Errorf("got active snapshots %v, expected none", files)
+    }
+    camThread.setSnapshotActive("/cam1/1.mp4", true)
+    camThread.setSnapshotActive("/cam1/2.mp4", true)
+    camThread.setSnapshotActive("/cam1/1.mp4", false)
+    camThread.setSnapshotActive("/cam1/3.mp4", false)
+    files := camThread.getActiveSnapshots()
+    if len(files) != 1 || !files["/cam1/2.mp4"] {
+        t.Errorf("got active snapshots %v, expected only /cam1/2.mp4", files)
+    }
+    //Snapshots completed after the copy are still skipped by the caller.
+    camThread.setSnapshotActive("/cam1/2.mp4", false)
+    if !files["/cam1/2.mp4"] {
+        t.Errorf("copy of active snapshots is changed on completion")
+    }
+    if files := camThread.getActiveSnapshots(); len(files) != 0 {
+        t.Errorf("got active snapshots %v, expected none", files)
+    }
+}
+
+func TestRotateCameraCycle(t *testing.T) {
+    logger := new(logging.Logging)
+    logger.LogInitSingleton(logging.LogLeveltype(logging.Trace), "")
+    camThread := &RTSPCameraThread{rotateSignal: make(chan bool, 1)}
+    camThread.status = dataSet.CAMERA_STREAMING
+    if camThread.isRotateFired() {
+        t.Errorf("rotate fired before any request")
+    }
+    //Repeated requests before the thread picks the signal rotate once.
+    for i := 0; i < 2; i++ {
+        if err := camThread.RotateCameraCycle(); err != nil {
+            t.Errorf("rotate request %d failed, err: %s", i, err)
+        }
+    }
+    if !camThread.isRotateFired() {
+        t.Errorf("rotate is not fired after the request")
+    }
+    if camThread.isRotateFired() {
+        t.Errorf("rotate is fired more than once")
+    }
+    camThread.status = dataSet.CAMERA_ON
+    if err := camThread.RotateCameraCycle(); err != appErrors.INVALID_OP {
+        t.Errorf("got err %v on camera not streaming, expected %v", err,
+                 appErrors.INVALID_OP)
+    }
+    if camThread.isRotateFired() {
+        t.Errorf("rotate is fired on camera not streaming")
+    }
+}
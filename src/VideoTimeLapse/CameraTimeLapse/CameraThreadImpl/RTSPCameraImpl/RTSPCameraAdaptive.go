@@ -0,0 +1,197 @@
+package RTSPCameraImpl
+
+import (
+    "sync"
+    "time"
+    "unsafe"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+)
+
+// Adaptive capture checks the scene at every snapshot interval and keeps the
+// snapshot only when the scene is changed from the last kept snapshot, or the
+// maximum interval of the camera is elapsed. The first decodable frame of the
+// interval is scaled down to a small gray image and compared to the gray image
+// of the last kept snapshot. The same gray image is used by the quality check,
+// the tamper detection and the capture stats. The packets read for the check
+// are buffered, so a kept snapshot starts with the same frame that is
+// compared.
+
+// #include "videoimage.h"
+// #include <stdlib.h>
+import "C"
+
+const (
+    //Size of the gray images compared, small enough to ignore the noise and
+    // large enough to tell the blur of a defocused camera.
+    SCENE_GRAY_WIDTH = 128
+    SCENE_GRAY_HEIGHT = 72
+)
+
+//Result of checking the scene at a snapshot interval.
+type sceneCheck struct {
+    //Packets read to decode the compared frame, to start the snapshot with.
+    pkts []*C.AVPacket
+    //Gray image of the first frame, nil if the frame cannot be decoded.
+    gray []byte
+    //JPEG image of the first frame, if requested.
+    jpeg []byte
+    score float64
+    keep bool
+}
+
+func (check *sceneCheck)freePackets() {
+    if check == nil {
+        return
+    }
+    for i := range check.pkts {
+        C.av_packet_free(&check.pkts[i])
+    }
+    check.pkts = nil
+}
+
+//Return the change between the gray images in percent.
+func getChangeScore(gray []byte, ref []byte) float64 {
+    var diff uint64
+    for i := range gray {
+        if gray[i] > ref[i] {
+            diff += uint64(gray[i] - ref[i])
+        } else {
+            diff += uint64(ref[i] - gray[i])
+        }
+    }
+    return float64(diff) * 100 / (float64(len(gray)) * 255)
+}
+
+//Decode the first frame from the input as a small gray image, to check the
+// scene before writing the snapshot. The frame is masked before scaling.
+//The frame is also encoded as JPEG of 'jpegWidth' pixels if it is set.
+func (camThread *RTSPCameraThread)decodeScene(input *Input,
+                                    masks []dataSet.PrivacyMask,
+                                    jpegWidth int) *sceneCheck {
+    log := logging.GetLoggerInstance()
+    check := &sceneCheck{
+        score: dataSet.SNAPSHOT_NO_CHANGE_SCORE,
+        keep: true,
+    }
+    input.mutex.RLock()
+    decoder := C.vs_open_decoder(input.vsInput)
+    input.mutex.RUnlock()
+    if decoder == nil {
+        log.Error("Failed to open decoder to check the scene of %s",
+                  camThread.name)
+        return check
+    }
+    defer C.avcodec_free_context(&decoder)
+    mask := camThread.getPrivacyMask(masks)
+    defer mask.free()
+    grayC := C.malloc(C.size_t(SCENE_GRAY_WIDTH * SCENE_GRAY_HEIGHT))
+    defer C.free(grayC)
+
+    var jpegC *C.uint8_t
+    var jpegSizeC C.int
+    jpegOut := &jpegC
+    if jpegWidth == 0 {
+        jpegOut = nil
+    }
+    res := C.int(0)
+    for len(check.pkts) < STILL_IMAGE_MAX_PKTS && res == 0 {
+        var pkt C.AVPacket
+        readRes := C.vs_read_packet(input.vsInput, &pkt, C.bool(false))
+        if readRes == -1 {
+            break
+        }
+        if readRes == 0 {
+            continue
+        }
+        check.pkts = append(check.pkts, C.av_packet_clone(&pkt))
+        res = C.vs_decode_gray(decoder, &pkt, mask.getVSMask(),
+                               C.int(SCENE_GRAY_WIDTH),
+                               C.int(SCENE_GRAY_HEIGHT), (*C.uint8_t)(grayC),
+                               C.int(jpegWidth), jpegOut, &jpegSizeC)
+        C.av_packet_unref(&pkt)
+    }
+    if res != 1 {
+        log.Error("Failed to decode the scene of %s", camThread.name)
+        return check
+    }
+    check.gray = C.GoBytes(grayC,
+                           C.int(SCENE_GRAY_WIDTH * SCENE_GRAY_HEIGHT))
+    if jpegC != nil {
+        check.jpeg = C.GoBytes(unsafe.Pointer(jpegC), jpegSizeC)
+        C.av_free(unsafe.Pointer(jpegC))
+    }
+    return check
+}
+
+//Compare the decoded scene with the last kept snapshot. The masked regions
+// are left out of the comparison, so a change behind a mask never keeps a
+// snapshot.
+//The snapshot is kept when the scene cannot be decoded, as a missed snapshot
+// cannot be taken again.
+func (camThread *RTSPCameraThread)checkSceneChange(check *sceneCheck) {
+    log := logging.GetLoggerInstance()
+    camThread.threadLock.RLock()
+    ref := camThread.changeRef
+    refTime := camThread.changeRefTime
+    threshold := float64(camThread.changeThreshold)
+    maxInterval := time.Duration(camThread.maxSnapInterval) * time.Second
+    camThread.threadLock.RUnlock()
+    if check.gray == nil || ref == nil {
+        return
+    }
+    check.score = getChangeScore(check.gray, ref)
+    check.keep = check.score >= threshold ||
+                 (maxInterval != 0 && time.Since(refTime) >= maxInterval)
+    log.Trace("Scene change of %s is %.2f%%", camThread.name, check.score)
+}
+
+//Make the scene of the kept snapshot the reference for the next check.
+func (camThread *RTSPCameraThread)setChangeRef(gray []byte) {
+    camThread.threadLock.Lock()
+    defer camThread.threadLock.Unlock()
+    camThread.changeRef = gray
+    camThread.changeRefTime = time.Now()
+}
+
+//Forget the reference scene, the first snapshot of a cycle is always kept.
+func (camThread *RTSPCameraThread)resetChangeRef() {
+    camThread.setChangeRef(nil)
+}
+
+//Record the metadata of a kept snapshot.
+func (camThread *RTSPCameraThread)addSnapshotMeta(cycleDir string,
+                                    fileName string, score float64) {
+    log := logging.GetLoggerInstance()
+    snapshot := &dataSet.Snapshot{
+        CamName: camThread.name,
+        CycleDir: cycleDir,
+        File: fileName,
+        CaptureTime: time.Now().Unix(),
+        ChangeScore: score,
+    }
+    err := dataSetImpl.GetDataSetObj().AddSnapshot(snapshot)
+    if err != nil {
+        log.Error("Failed to record snapshot %s/%s, err: %s", cycleDir,
+                  fileName, err)
+    }
+}
+
+//Write the packets buffered by the scene check to the snapshot in order.
+func (camThread *RTSPCameraThread)writeScenePackets(check *sceneCheck,
+                                    input *Input, output *Output,
+                                    masked *maskedOutput,
+                                    waitWrite *sync.WaitGroup) {
+    if check == nil {
+        return
+    }
+    for _, pkt := range check.pkts {
+        if masked != nil {
+            masked.writePacket(pkt)
+        } else {
+            camThread.writePacket(input, output, pkt, waitWrite)
+        }
+    }
+    check.freePackets()
+}
@@ -0,0 +1,311 @@
+package RTSPCameraImpl
+
+import (
+    "fmt"
+    "os"
+    "sync"
+    "time"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+// Clips are full rate recordings of the stream around a trigger, such as a
+// site alarm. The recorder of the camera keeps the last seconds of the stream
+// in memory, so a clip starts before its trigger. The buffered packets are
+// written first, from the keyframe at or before the requested start, and the
+// clip is continued with the live packets till its end. The finished clip is
+// added to the video catalog of the camera.
+
+// #include "videomux.h"
+// #include <libavcodec/avcodec.h>
+import "C"
+
+const (
+    //Directory in the camera directory for the clips, every clip is in its
+    // own directory along with its thumbnails.
+    CLIP_DIR_NAME = "clips"
+    CLIP_FILE_NAME = "clip.mp4"
+    //Clips are named as clip-<trigger-time>.
+    CLIP_NAME_PREFIX = "clip-"
+    //Footage before and after the trigger when not set in request.
+    CLIP_DEFAULT_PRE_SEC = 10
+    CLIP_DEFAULT_POST_SEC = 30
+    CLIP_MAX_POST_SEC = 600
+    //Clips triggered and not yet started by the recorder.
+    CLIP_MAX_PENDING = 8
+)
+
+//Clip triggered on the camera.
+type clipRequest struct {
+    name string
+    dir string
+    trigger time.Time
+    pre time.Duration
+    post time.Duration
+}
+
+//Packet kept in the clip buffer with its arrival time.
+type bufferedPacket struct {
+    pkt *C.AVPacket
+    time time.Time
+    keyframe bool
+}
+
+//Clip being written by the recorder.
+type activeClip struct {
+    req *clipRequest
+    output *Output
+    waitWrite sync.WaitGroup
+    //Clip is waiting for a keyframe to start, as none is buffered.
+    waitKeyframe bool
+    start time.Time
+}
+
+//Packets of the stream kept in memory and the clips being written.
+type clipBuffer struct {
+    length time.Duration
+    pkts []bufferedPacket
+    clips []*activeClip
+}
+
+//Add the packet to the buffer and drop the packets older than the buffer
+// length. The buffer always starts with a keyframe, so a whole group of
+// pictures is dropped at a time.
+func (buffer *clipBuffer)push(pkt *C.AVPacket, keyframe bool, now time.Time) {
+    if buffer.length == 0 {
+        return
+    }
+    if len(buffer.pkts) == 0 && !keyframe {
+        return
+    }
+    buffer.pkts = append(buffer.pkts, bufferedPacket{
+                            pkt: C.av_packet_clone(pkt),
+                            time: now,
+                            keyframe: keyframe,
+                        })
+    buffer.freePackets(buffer.getExpired(now))
+}
+
+//Return the number of packets at the start of the buffer that are older than
+// the buffer length at 'now'. The packets are counted till the last expired
+// keyframe, so the buffer still starts with a keyframe once they are dropped.
+func (buffer *clipBuffer)getExpired(now time.Time) int {
+    cutoff := now.Add(-buffer.length)
+    expired := 0
+    for i := range buffer.pkts {
+        if buffer.pkts[i].time.After(cutoff) {
+            break
+        }
+        if buffer.pkts[i].keyframe {
+            expired = i
+        }
+    }
+    return expired
+}
+
+//Free the first 'count' packets of the buffer.
+func (buffer *clipBuffer)freePackets(count int) {
+    for i := 0; i < count; i++ {
+        C.av_packet_free(&buffer.pkts[i].pkt)
+    }
+    buffer.pkts = buffer.pkts[count:]
+}
+
+//Return the index of the buffered packet a clip starting at 'start' is
+// written from, the keyframe at or before the start. The first buffered
+// keyframe is used when the start is before the buffer.
+//Returns -1 if there are no packets buffered.
+func (buffer *clipBuffer)getClipStart(start time.Time) int {
+    index := -1
+    for i := range buffer.pkts {
+        if !buffer.pkts[i].keyframe {
+            continue
+        }
+        if index != -1 && buffer.pkts[i].time.After(start) {
+            break
+        }
+        index = i
+    }
+    return index
+}
+
+//Trigger a clip from 'preSec' seconds before till 'postSec' seconds after
+// now. The footage before the trigger is limited to the clip buffer of the
+// camera. The clip is added to the video catalog once it is written.
+func (camThread *RTSPCameraThread)CaptureCameraClip(preSec uint64,
+                                    postSec uint64) (string, error) {
+    log := logging.GetLoggerInstance()
+    if postSec > CLIP_MAX_POST_SEC {
+        log.Error("Clip of %s cannot be longer than %d seconds after the"+
+                  " trigger", camThread.name, CLIP_MAX_POST_SEC)
+        return "", appErrors.INVALID_INPUT
+    }
+    now := time.Now()
+    camThread.threadLock.RLock()
+    status := camThread.status
+    rec := camThread.recorder
+    bufferSec := camThread.clipBufferSec
+    clipDir := camThread.videoPath + "/" + CLIP_DIR_NAME
+    triggerName := now.In(camThread.location).Format(TIME_DIR_FORMAT)
+    camThread.threadLock.RUnlock()
+    if status != dataSet.CAMERA_STREAMING || rec == nil {
+        log.Error("Cannot capture clip of %s, camera is not recording",
+                  camThread.name)
+        return "", appErrors.INVALID_OP
+    }
+    if preSec > bufferSec {
+        log.Info("Clip of %s starts %d seconds before the trigger, as per"+
+                 " its buffer", camThread.name, bufferSec)
+        preSec = bufferSec
+    }
+    err := os.MkdirAll(clipDir, 0744)
+    if err != nil {
+        log.Error("Failed to create clip directory %s, err: %s", clipDir, err)
+        return "", err
+    }
+    //Clip directory is created with its name, so the clips triggered in the
+    // same second never get the same name.
+    name := CLIP_NAME_PREFIX + triggerName
+    for i := 2; ; i++ {
+        err = os.Mkdir(clipDir + "/" + name, 0744)
+        if !os.IsExist(err) {
+            break
+        }
+        name = fmt.Sprintf("%s%s-%d", CLIP_NAME_PREFIX, triggerName, i)
+    }
+    if err != nil {
+        log.Error("Failed to create clip directory %s, err: %s", name, err)
+        return "", err
+    }
+    req := &clipRequest{
+        name: name,
+        dir: clipDir + "/" + name,
+        trigger: now,
+        pre: time.Duration(preSec) * time.Second,
+        post: time.Duration(postSec) * time.Second,
+    }
+    select {
+        case rec.clips <- req:
+        default:
+            os.Remove(req.dir)
+            log.Error("Too many clips pending on %s", camThread.name)
+            return "", appErrors.INVALID_OP
+    }
+    log.Trace("Triggered clip %s of %s", name, camThread.name)
+    return name + "." + dataSet.VIDEO_FORMAT_MP4, nil
+}
+
+//Open the clip and write the buffered packets from its start.
+func (camThread *RTSPCameraThread)startClip(buffer *clipBuffer,
+                                    input *Input, req *clipRequest) {
+    log := logging.GetLoggerInstance()
+    clipFile := req.dir + "/" + CLIP_FILE_NAME
+    output := camThread.openMP4Output(clipFile, input)
+    if output == nil || output.vsOutput == nil {
+        log.Error("Failed to open clip %s of %s", req.name, camThread.name)
+        os.RemoveAll(req.dir)
+        return
+    }
+    clip := &activeClip{
+        req: req,
+        output: output,
+        waitKeyframe: true,
+        start: req.trigger,
+    }
+    index := buffer.getClipStart(req.trigger.Add(-req.pre))
+    if index != -1 {
+        clip.waitKeyframe = false
+        clip.start = buffer.pkts[index].time
+        for _, buffered := range buffer.pkts[index:] {
+            camThread.writePacket(input, output, buffered.pkt,
+                                  &clip.waitWrite)
+        }
+    }
+    buffer.clips = append(buffer.clips, clip)
+}
+
+//Buffer the packet and write it to the running clips. The clips past their
+// end are finished.
+func (camThread *RTSPCameraThread)addClipPacket(buffer *clipBuffer,
+                                    input *Input, pkt *C.AVPacket,
+                                    keyframe bool) {
+    now := time.Now()
+    buffer.push(pkt, keyframe, now)
+    running := buffer.clips[:0]
+    for _, clip := range buffer.clips {
+        if !now.Before(clip.req.trigger.Add(clip.req.post)) {
+            camThread.finishClip(clip, now)
+            continue
+        }
+        if clip.waitKeyframe && keyframe {
+            clip.waitKeyframe = false
+            clip.start = now
+        }
+        if !clip.waitKeyframe {
+            camThread.writePacket(input, clip.output, pkt, &clip.waitWrite)
+        }
+        running = append(running, clip)
+    }
+    buffer.clips = running
+}
+
+//Finish the running clips and free the buffered packets, as the stream is
+// closed.
+func (camThread *RTSPCameraThread)closeClips(buffer *clipBuffer) {
+    now := time.Now()
+    for _, clip := range buffer.clips {
+        camThread.finishClip(clip, now)
+    }
+    buffer.clips = nil
+    buffer.freePackets(len(buffer.pkts))
+}
+
+//Close the clip and add it to the catalog in background.
+func (camThread *RTSPCameraThread)finishClip(clip *activeClip, end time.Time) {
+    camThread.closeOutput(clip.output, &clip.waitWrite)
+    if clip.waitKeyframe {
+        logging.GetLoggerInstance().Error("No footage in clip %s of %s",
+                                          clip.req.name, camThread.name)
+        os.RemoveAll(clip.req.dir)
+        return
+    }
+    go camThread.addClipToCatalog(clip.req, clip.start, end)
+}
+
+//Record the clip in the video catalog along with its thumbnails.
+func (camThread *RTSPCameraThread)addClipToCatalog(req *clipRequest,
+                                    start time.Time, end time.Time) {
+    log := logging.GetLoggerInstance()
+    clipFile := req.dir + "/" + CLIP_FILE_NAME
+    fileInfo, err := os.Stat(clipFile)
+    if err != nil {
+        log.Error("Cannot add clip %s to catalog, err: %s", clipFile, err)
+        return
+    }
+    thumbs := camThread.createThumbnails(clipFile)
+    video := &dataSet.Video{
+        Name: req.name + "." + dataSet.VIDEO_FORMAT_MP4,
+        CamName: camThread.name,
+        Path: clipFile,
+        Format: dataSet.VIDEO_FORMAT_MP4,
+        Description: fmt.Sprintf("Clip triggered at %s, from %s to %s",
+                                 req.trigger.Format(time.RFC3339),
+                                 start.Format(time.RFC3339),
+                                 end.Format(time.RFC3339)),
+        StartTime: start.Unix(),
+        EndTime: end.Unix(),
+        Size: fileInfo.Size(),
+        PosterPath: thumbs.posterFile,
+        ThumbCount: int64(thumbs.count),
+    }
+    err = dataSetImpl.GetDataSetObj().AddNewVideo(video)
+    if err != nil {
+        log.Error("Failed to add clip %s to catalog, err: %s", video.Name,
+                  err)
+        return
+    }
+    log.Trace("Added clip %s of camera %s to catalog", video.Name,
+              camThread.name)
+}
@@ -0,0 +1,97 @@
+package RTSPCameraImpl
+
+// Test file for validating the clip buffer of the camera stream.
+import (
+    "time"
+    "testing"
+)
+
+//Return a buffer with a packet every second from 'base', keyframes at the
+// seconds in 'keyframes'. The packets are not allocated, so the buffer is
+// never freed.
+func newTestClipBuffer(base time.Time, length time.Duration, count int,
+                       keyframes ...int) *clipBuffer {
+    buffer := &clipBuffer{length: length}
+    for i := 0; i < count; i++ {
+        buffer.pkts = append(buffer.pkts, bufferedPacket{
+                                time: base.Add(time.Duration(i) * time.Second),
+                            })
+    }
+    for _, i := range keyframes {
+        buffer.pkts[i].keyframe = true
+    }
+    return buffer
+}
+
+func TestClipBufferPushDropped(t *testing.T) {
+    now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+    //Packets are not buffered when the buffer is disabled, and the buffer
+    // does not start without a keyframe.
+    disabled := &clipBuffer{}
+    disabled.push(nil, true, now)
+    empty := &clipBuffer{length: 10 * time.Second}
+    empty.push(nil, false, now)
+    if len(disabled.pkts) != 0 || len(empty.pkts) != 0 {
+        t.Errorf("Got %d packets in disabled and %d in empty buffer",
+                 len(disabled.pkts), len(empty.pkts))
+    }
+}
+
+func TestClipBufferGetExpired(t *testing.T) {
+    base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+    at := func(sec float64) time.Time {
+        return base.Add(time.Duration(sec * float64(time.Second)))
+    }
+    tests := []struct {
+        name string
+        length time.Duration
+        keyframes []int
+        now time.Time
+        expected int
+    }{
+        {"nothing expired", 10 * time.Second, []int{0, 4, 8}, at(9), 0},
+        {"first group expired", 5 * time.Second, []int{0, 4, 8}, at(9.5), 4},
+        {"expired till keyframe", 2 * time.Second, []int{0, 4, 8}, at(9.5),
+            4},
+        {"all groups but the last", 1 * time.Second, []int{0, 4, 8}, at(12),
+            8},
+        {"single group", 2 * time.Second, []int{0}, at(9.5), 0},
+    }
+    for _, test := range tests {
+        buffer := newTestClipBuffer(base, test.length, 10, test.keyframes...)
+        if expired := buffer.getExpired(test.now); expired != test.expected {
+            t.Errorf("%s: got %d expired, expected %d", test.name, expired,
+                     test.expected)
+        }
+    }
+}
+
+func TestClipBufferGetClipStart(t *testing.T) {
+    base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+    at := func(sec int) time.Time {
+        return base.Add(time.Duration(sec) * time.Second)
+    }
+    buffer := newTestClipBuffer(base, 10 * time.Second, 10, 0, 4, 8)
+    tests := []struct {
+        name string
+        start time.Time
+        expected int
+    }{
+        {"before the buffer", at(-5), 0},
+        {"at first keyframe", at(0), 0},
+        {"inside first group", at(3), 0},
+        {"at a keyframe", at(4), 4},
+        {"inside a group", at(6), 4},
+        {"after the buffer", at(20), 8},
+    }
+    for _, test := range tests {
+        if index := buffer.getClipStart(test.start); index != test.expected {
+            t.Errorf("%s: got start %d, expected %d", test.name, index,
+                     test.expected)
+        }
+    }
+    empty := &clipBuffer{}
+    if index := empty.getClipStart(at(0)); index != -1 {
+        t.Errorf("Empty buffer: got start %d, expected -1", index)
+    }
+}
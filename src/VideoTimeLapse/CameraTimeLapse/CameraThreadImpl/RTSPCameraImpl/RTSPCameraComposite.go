@@ -0,0 +1,394 @@
+package RTSPCameraImpl
+
+import (
+    "fmt"
+    "os"
+    "time"
+    "strings"
+    "encoding/json"
+    "path/filepath"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/jobQueue"
+)
+
+// Composite timelapses render the cycles of several cameras in one video.
+// When a member camera completes a cycle, the cycles of the other members
+// that overlap it are looked up in the catalog. The overlapping part of every
+// cycle timelapse is trimmed and stretched to a common length, so the tiles
+// show the same wall clock time, and laid out with libavfilter. The first
+// member is the input of the transcoder and the others are read by movie
+// sources in the filter graph. The videos of a composite are in the catalog
+// under the composite name, in the composites directory of the video path.
+
+// #include "videomux.h"
+import "C"
+
+const (
+    COMPOSITE_DIR_NAME = "composites"
+    //Minimum overlap of the member cycles to render a composite.
+    COMPOSITE_MIN_OVERLAP_SEC = 60
+    COMPOSITE_FPS = 25
+)
+
+//Cycle timelapse of a member camera in the composite.
+type compositeMember struct {
+    CamName   string `json:"CamName"`
+    VideoPath string `json:"VideoPath"`
+    StartTime int64  `json:"StartTime"`
+    EndTime   int64  `json:"EndTime"`
+}
+
+//Parameters of the composite job.
+type compositeJobParams struct {
+    Composite dataSet.Composite `json:"Composite"`
+    //Members in the order of the tiles.
+    Members   []compositeMember `json:"Members"`
+    //Overlap of the member cycles in unix seconds.
+    StartTime int64             `json:"StartTime"`
+    EndTime   int64             `json:"EndTime"`
+    OutputDir string            `json:"OutputDir"`
+}
+
+//Return the directory of the composite videos.
+func getCompositeDir(compositeName string) string {
+    videoDir, _ := filepath.Abs(jobVideoPath)
+    return videoDir + "/" + COMPOSITE_DIR_NAME + "/" + compositeName
+}
+
+//Escape the option value to use in a filter graph description. The value is
+// escaped for the option parser first and then for the graph parser.
+func escapeFilterValue(value string) string {
+    graphEscaper := strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`,
+                                        `]`, `\]`, `,`, `\,`, `;`, `\;`)
+    return graphEscaper.Replace(escapeOptionValue(value))
+}
+
+//Return the largest even number not above 'value'.
+func evenFloor(value int) int {
+    return value - value % 2
+}
+
+//Return the duration of the video file in seconds.
+func (camThread *RTSPCameraThread)getVideoDuration(
+                                    videoFile string) (float64, error) {
+    input := camThread.openInput("mp4", videoFile)
+    if input == nil || input.vsInput == nil {
+        return 0, fmt.Errorf("Failed to open video %s", videoFile)
+    }
+    var duration float64
+    for {
+        var pktIn C.AVPacket
+        input.mutex.RLock()
+        readRes := C.vs_read_packet(input.vsInput, &pktIn, C.bool(false))
+        if readRes == 1 {
+            endTime := float64(C.vs_packet_end_time(input.vsInput, &pktIn))
+            if endTime > duration {
+                duration = endTime
+            }
+            C.av_packet_unref(&pktIn)
+        }
+        input.mutex.RUnlock()
+        if readRes == -1 {
+            break
+        }
+    }
+    camThread.destroyInput(input)
+    return duration, nil
+}
+
+//Return the filter graph to render the overlap of the member videos of
+// 'durations' seconds in the layout of the composite.
+func getCompositeFilter(params *compositeJobParams,
+                        durations []float64) (string, error) {
+    composite := &params.Composite
+    width, height := int(composite.Width), int(composite.Height)
+    tileWidth, tileHeight := width, height
+    tiles := len(params.Members)
+    switch composite.Layout {
+    case dataSet.COMPOSITE_LAYOUT_2X1:
+        tileWidth = evenFloor(width / 2)
+    case dataSet.COMPOSITE_LAYOUT_2X2:
+        tileWidth = evenFloor(width / 2)
+        tileHeight = evenFloor(height / 2)
+        tiles = 4
+    }
+    //Overlap in the video time of every member. The members are stretched
+    // to the longest of them.
+    trimStart := make([]float64, len(params.Members))
+    trimEnd := make([]float64, len(params.Members))
+    var outDuration float64
+    for i, member := range params.Members {
+        cycleLen := float64(member.EndTime - member.StartTime)
+        if cycleLen <= 0 || durations[i] <= 0 {
+            return "", fmt.Errorf("Invalid member video %s", member.VideoPath)
+        }
+        trimStart[i] = float64(params.StartTime - member.StartTime) /
+                        cycleLen * durations[i]
+        trimEnd[i] = float64(params.EndTime - member.StartTime) /
+                        cycleLen * durations[i]
+        if trimEnd[i] - trimStart[i] > outDuration {
+            outDuration = trimEnd[i] - trimStart[i]
+        }
+    }
+    if outDuration < 1.0 / COMPOSITE_FPS {
+        return "", fmt.Errorf("Overlap of %s is too short", composite.Name)
+    }
+    chains := []string{}
+    for i, member := range params.Members {
+        source := "[in]"
+        if i != 0 {
+            source = "movie=" + escapeFilterValue(member.VideoPath) + ","
+        }
+        memberWidth, memberHeight := tileWidth, tileHeight
+        if composite.Layout == dataSet.COMPOSITE_LAYOUT_PIP && i != 0 {
+            memberWidth = evenFloor(width / 4)
+            memberHeight = evenFloor(height / 4)
+        }
+        chains = append(chains, fmt.Sprintf(
+                    "%strim=start=%.3f:end=%.3f," +
+                    "setpts=(PTS-STARTPTS)*%.6f," +
+                    "scale=%d:%d:force_original_aspect_ratio=decrease," +
+                    "pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1[v%d]",
+                    source, trimStart[i], trimEnd[i],
+                    outDuration / (trimEnd[i] - trimStart[i]),
+                    memberWidth, memberHeight, memberWidth, memberHeight, i))
+    }
+    //Empty tiles of the grid.
+    for i := len(params.Members); i < tiles; i++ {
+        chains = append(chains, fmt.Sprintf(
+                    "color=c=black:s=%dx%d:r=%d:d=%.3f[v%d]", tileWidth,
+                    tileHeight, COMPOSITE_FPS, outDuration, i))
+    }
+    inputs := ""
+    for i := 0; i < tiles; i++ {
+        inputs += fmt.Sprintf("[v%d]", i)
+    }
+    var layout string
+    switch composite.Layout {
+    case dataSet.COMPOSITE_LAYOUT_2X1:
+        layout = "hstack=inputs=2"
+    case dataSet.COMPOSITE_LAYOUT_2X2:
+        layout = "xstack=inputs=4:layout=0_0|w0_0|0_h0|w0_h0"
+    case dataSet.COMPOSITE_LAYOUT_PIP:
+        margin := evenFloor(width / 40)
+        layout = fmt.Sprintf("overlay=W-w-%d:H-h-%d", margin, margin)
+    }
+    chains = append(chains, fmt.Sprintf("%s%s,fps=%d,format=yuv420p[out]",
+                                         inputs, layout, COMPOSITE_FPS))
+    return strings.Join(chains, ";"), nil
+}
+
+//Render the composite video of the member cycles and add it to the catalog.
+// The thread is named after the composite.
+func (camThread *RTSPCameraThread)renderComposite(params *compositeJobParams,
+                                    progress jobQueue.JobProgress) error {
+    log := logging.GetLoggerInstance()
+    durations := []float64{}
+    for _, member := range params.Members {
+        duration, err := camThread.getVideoDuration(member.VideoPath)
+        if err != nil {
+            log.Error("Failed to read member video of composite %s, err: %s",
+                        params.Composite.Name, err)
+            return err
+        }
+        durations = append(durations, duration)
+    }
+    filter, err := getCompositeFilter(params, durations)
+    if err != nil {
+        return err
+    }
+    err = jobQueue.ReportProgress(progress, 10)
+    if err != nil {
+        return err
+    }
+    timeLapsePath, err := createTimeLapseDir(params.OutputDir)
+    if err != nil {
+        log.Error("Failed to create composite directory %s",
+                    params.OutputDir)
+        return err
+    }
+    outputFile := timeLapsePath + "/" + FINAL_TIMELAPSE_NAME + "." +
+                  dataSet.VIDEO_FORMAT_MP4
+    err = camThread.transcodeVideoFilter(params.Members[0].VideoPath,
+                                outputFile,
+                                videoFormatProfiles[dataSet.VIDEO_FORMAT_MP4],
+                                filter)
+    if err != nil {
+        log.Error("Failed to render composite %s, err: %s",
+                    params.Composite.Name, err)
+        os.Remove(outputFile)
+        return err
+    }
+    err = jobQueue.ReportProgress(progress, 80)
+    if err != nil {
+        return err
+    }
+    thumbs := camThread.createThumbnails(outputFile)
+    return camThread.addVideoToCatalog(params.OutputDir, outputFile,
+                                       dataSet.VIDEO_FORMAT_MP4,
+                                       time.Unix(params.StartTime, 0),
+                                       time.Unix(params.EndTime, 0), thumbs)
+}
+
+//Render the composite video of the job.
+func runCompositeJob(job *dataSet.Job, progress jobQueue.JobProgress) error {
+    var params compositeJobParams
+    err := json.Unmarshal([]byte(job.Params), &params)
+    if err != nil {
+        return err
+    }
+    if len(params.Members) == 0 {
+        return fmt.Errorf("No member videos for composite %s", job.CamName)
+    }
+    compositeThread := new(RTSPCameraThread)
+    compositeThread.name = job.CamName
+    return compositeThread.renderComposite(&params, progress)
+}
+
+//Return the cycle timelapse of the camera with the longest overlap with the
+// time range, nil if none of the cycles overlap it.
+func getOverlappingCycle(camName string, startTime int64,
+                         endTime int64) (*dataSet.Video, error) {
+    dataObj := dataSetImpl.GetDataSetObj()
+    videos, err := dataObj.GetAllVideos(camName)
+    if err != nil {
+        return nil, err
+    }
+    location := getCameraLocation(camName)
+    var cycle *dataSet.Video
+    var cycleOverlap int64
+    for i := range videos {
+        video := &videos[i]
+        if video.Format != dataSet.VIDEO_FORMAT_MP4 ||
+            !isCycleVideo(video, location) {
+            continue
+        }
+        overlap := endTime - startTime
+        if video.EndTime < endTime {
+            overlap -= endTime - video.EndTime
+        }
+        if video.StartTime > startTime {
+            overlap -= video.StartTime - startTime
+        }
+        if overlap > cycleOverlap {
+            cycle = video
+            cycleOverlap = overlap
+        }
+    }
+    return cycle, nil
+}
+
+//Return the parameters to render the composite for the last cycle of the
+// camera, nil if the member cameras have no overlapping cycles.
+func getCompositeCycles(composite *dataSet.Composite,
+                        camName string) (*compositeJobParams, error) {
+    dataObj := dataSetImpl.GetDataSetObj()
+    videos, err := dataObj.GetAllVideos(camName)
+    if err != nil {
+        return nil, err
+    }
+    location := getCameraLocation(camName)
+    var last *dataSet.Video
+    for i := range videos {
+        video := &videos[i]
+        if video.Format == dataSet.VIDEO_FORMAT_MP4 &&
+            isCycleVideo(video, location) &&
+            (last == nil || video.StartTime > last.StartTime) {
+            last = video
+        }
+    }
+    if last == nil {
+        return nil, nil
+    }
+    params := &compositeJobParams{
+        Composite: *composite,
+        Members: []compositeMember{},
+        StartTime: last.StartTime,
+        EndTime: last.EndTime,
+    }
+    for _, member := range composite.GetCameras() {
+        video := last
+        if member != camName {
+            video, err = getOverlappingCycle(member, last.StartTime,
+                                             last.EndTime)
+            if err != nil || video == nil {
+                return nil, err
+            }
+        }
+        params.Members = append(params.Members, compositeMember{
+                                    CamName: member,
+                                    VideoPath: video.Path,
+                                    StartTime: video.StartTime,
+                                    EndTime: video.EndTime,
+                                })
+        if video.StartTime > params.StartTime {
+            params.StartTime = video.StartTime
+        }
+        if video.EndTime < params.EndTime {
+            params.EndTime = video.EndTime
+        }
+    }
+    if params.EndTime - params.StartTime < COMPOSITE_MIN_OVERLAP_SEC {
+        return nil, nil
+    }
+    params.OutputDir = getCompositeDir(composite.Name) + "/" +
+                time.Unix(params.StartTime, 0).Format(TIME_DIR_FORMAT)
+    return params, nil
+}
+
+//Return true if the composite video of 'outputDir' is rendered or being
+// rendered.
+func isCompositeSubmitted(compositeName string, outputDir string) bool {
+    dataObj := dataSetImpl.GetDataSetObj()
+    _, err := dataObj.GetVideo(compositeName,
+                    filepath.Base(outputDir) + "." + dataSet.VIDEO_FORMAT_MP4)
+    if err == nil {
+        return true
+    }
+    jobs, err := dataObj.GetAllJobs()
+    if err != nil {
+        return false
+    }
+    for _, job := range jobs {
+        var params compositeJobParams
+        if job.Type != dataSet.JOB_TYPE_COMPOSITE || job.IsFinished() ||
+            job.CamName != compositeName ||
+            json.Unmarshal([]byte(job.Params), &params) != nil {
+            continue
+        }
+        if params.OutputDir == outputDir {
+            return true
+        }
+    }
+    return false
+}
+
+//Submit the jobs to render the composites of the camera, for the overlap of
+// its last cycle with the cycles of the other member cameras.
+func submitCompositeJobs(camName string) {
+    log := logging.GetLoggerInstance()
+    dataObj := dataSetImpl.GetDataSetObj()
+    composites, err := dataObj.GetAllComposites()
+    if err != nil {
+        return
+    }
+    for i := range composites {
+        composite := &composites[i]
+        if !composite.HasCamera(camName) {
+            continue
+        }
+        params, err := getCompositeCycles(composite, camName)
+        if err != nil || params == nil ||
+            isCompositeSubmitted(composite.Name, params.OutputDir) {
+            continue
+        }
+        _, err = jobQueue.GetJobQueueObj().SubmitJob(
+                            dataSet.JOB_TYPE_COMPOSITE, composite.Name, params)
+        if err != nil {
+            log.Error("Failed to submit composite %s, err: %s",
+                        composite.Name, err)
+        }
+    }
+}
@@ -0,0 +1,148 @@
+package RTSPCameraImpl
+
+// Test file for validating the filter graphs of the composite videos.
+import (
+    "strings"
+    "testing"
+    "VideoTimeLapse/dataSet"
+)
+
+func TestEvenFloor(t *testing.T) {
+    tests := []struct {
+        value int
+        expected int
+    }{
+        {0, 0},
+        {1, 0},
+        {2, 2},
+        {319, 318},
+        {320, 320},
+    }
+    for _, test := range tests {
+        value := evenFloor(test.value)
+        if value != test.expected {
+            t.Errorf("%d: got %d, expected %d", test.value, value,
+                     test.expected)
+        }
+    }
+}
+
+func TestEscapeFilterValue(t *testing.T) {
+    tests := []struct {
+        name string
+        value string
+        expected string
+    }{
+        {"plain path", "/videos/cam1/a.mp4", "/videos/cam1/a.mp4"},
+        {"option separator", "/videos/a:b.mp4", `/videos/a\\:b.mp4`},
+        {"filter separators", "/videos/a,b;c.mp4", `/videos/a\,b\;c.mp4`},
+        {"link labels", "/videos/[a].mp4", `/videos/\[a\].mp4`},
+        {"quote", "/videos/cam's.mp4", `/videos/cam\\\'s.mp4`},
+    }
+    for _, test := range tests {
+        value := escapeFilterValue(test.value)
+        if value != test.expected {
+            t.Errorf("%s: got %q, expected %q", test.name, value,
+                     test.expected)
+        }
+    }
+}
+
+func TestGetCompositeFilter(t *testing.T) {
+    //Members are one hour cycles, half an hour apart. The second member
+    // video is half as long, so it is slowed down to match the first.
+    members := []compositeMember{
+        {CamName: "cam1", VideoPath: "/videos/cam1/a.mp4",
+            StartTime: 0, EndTime: 3600},
+        {CamName: "cam2", VideoPath: "/videos/cam2/b.mp4",
+            StartTime: 1800, EndTime: 5400},
+    }
+    durations := []float64{60, 30}
+    getParams := func(layout string) *compositeJobParams {
+        return &compositeJobParams{
+            Composite: dataSet.Composite{Name: "yard", Layout: layout,
+                                         Width: 1280, Height: 720},
+            Members: members,
+            StartTime: 1800,
+            EndTime: 3600,
+        }
+    }
+    member := func(source string, start string, end string, speed string,
+                   size string, label string) string {
+        return source + "trim=start=" + start + ":end=" + end +
+                ",setpts=(PTS-STARTPTS)*" + speed + ",scale=" + size +
+                ":force_original_aspect_ratio=decrease,pad=" + size +
+                ":(ow-iw)/2:(oh-ih)/2,setsar=1" + label
+    }
+    first := "[in]"
+    second := "movie=/videos/cam2/b.mp4,"
+    tests := []struct {
+        name string
+        layout string
+        expected []string
+    }{
+        {"side by side", dataSet.COMPOSITE_LAYOUT_2X1, []string{
+            member(first, "30.000", "60.000", "1.000000", "640:720", "[v0]"),
+            member(second, "0.000", "15.000", "2.000000", "640:720", "[v1]"),
+            "[v0][v1]hstack=inputs=2,fps=25,format=yuv420p[out]"}},
+        {"grid", dataSet.COMPOSITE_LAYOUT_2X2, []string{
+            member(first, "30.000", "60.000", "1.000000", "640:360", "[v0]"),
+            member(second, "0.000", "15.000", "2.000000", "640:360", "[v1]"),
+            "color=c=black:s=640x360:r=25:d=30.000[v2]",
+            "color=c=black:s=640x360:r=25:d=30.000[v3]",
+            "[v0][v1][v2][v3]xstack=inputs=4:layout=0_0|w0_0|0_h0|w0_h0," +
+                "fps=25,format=yuv420p[out]"}},
+        {"picture in picture", dataSet.COMPOSITE_LAYOUT_PIP, []string{
+            member(first, "30.000", "60.000", "1.000000", "1280:720",
+                   "[v0]"),
+            member(second, "0.000", "15.000", "2.000000", "320:180", "[v1]"),
+            "[v0][v1]overlay=W-w-32:H-h-32,fps=25,format=yuv420p[out]"}},
+    }
+    for _, test := range tests {
+        filter, err := getCompositeFilter(getParams(test.layout), durations)
+        if err != nil {
+            t.Errorf("%s: failed to get filter, err: %s", test.name, err)
+            continue
+        }
+        expected := strings.Join(test.expected, ";")
+        if filter != expected {
+            t.Errorf("%s: got filter\n%s\nexpected\n%s", test.name, filter,
+                     expected)
+        }
+    }
+}
+
+func TestGetCompositeFilterInvalid(t *testing.T) {
+    members := []compositeMember{
+        {CamName: "cam1", VideoPath: "/videos/cam1/a.mp4",
+            StartTime: 0, EndTime: 3600},
+        {CamName: "cam2", VideoPath: "/videos/cam2/b.mp4",
+            StartTime: 0, EndTime: 3600},
+    }
+    emptyCycle := []compositeMember{members[0], members[1]}
+    emptyCycle[1].EndTime = emptyCycle[1].StartTime
+    tests := []struct {
+        name string
+        members []compositeMember
+        durations []float64
+        endTime int64
+    }{
+        {"empty member video", members, []float64{60, 0}, 3600},
+        {"empty member cycle", emptyCycle, []float64{60, 60}, 3600},
+        //One second of the cycle is a millisecond of the videos.
+        {"overlap below a frame", members, []float64{3.6, 3.6}, 1},
+    }
+    for _, test := range tests {
+        params := &compositeJobParams{
+            Composite: dataSet.Composite{Name: "yard",
+                                         Layout: dataSet.COMPOSITE_LAYOUT_2X1,
+                                         Width: 1280, Height: 720},
+            Members: test.members,
+            EndTime: test.endTime,
+        }
+        _, err := getCompositeFilter(params, test.durations)
+        if err == nil {
+            t.Errorf("%s: filter is created, expected error", test.name)
+        }
+    }
+}
@@ -0,0 +1,149 @@
+package RTSPCameraImpl
+
+import (
+    "os"
+    "time"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+)
+
+// State of the running timelapse cycle is kept in datastore, so the cycle
+// interrupted by an application restart is not lost.
+
+//Save the state of running cycle after a snapshot.
+func (camThread *RTSPCameraThread)saveCycleState(numSnapshots uint64,
+                                                 fileIndex uint64) {
+    log := logging.GetLoggerInstance()
+    camThread.threadLock.RLock()
+    state := &dataSet.CameraCycleState{
+        CamName: camThread.name,
+        Profile: camThread.profile,
+        CycleDir: camThread.getCycleName__(camThread.startTime),
+        CycleStart: camThread.startTime.Unix(),
+        NumSnapshots: numSnapshots,
+        FileIndex: fileIndex,
+    }
+    camThread.threadLock.RUnlock()
+    dataObj := dataSetImpl.GetDataSetObj()
+    err := dataObj.UpdateCameraCycleState(state)
+    if err != nil {
+        log.Error("Failed to save cycle state of %s, err: %s", state.CamName,
+                    err)
+    }
+}
+
+//Start the timelapse cycle of camera thread. The cycle left by the previous
+// run is either resumed or rendered right away as per the resume policy.
+//Returns the number of snapshots and the last snapshot file index of the
+// cycle.
+func (camThread *RTSPCameraThread)startCycle() (uint64, uint64) {
+    log := logging.GetLoggerInstance()
+    camThread.threadLock.Lock()
+    camThread.setCycle__(time.Now())
+    camName := camThread.name
+    profile := camThread.profile
+    videoPath := camThread.videoPath
+    resumePolicy := camThread.resumePolicy
+    camThread.threadLock.Unlock()
+    dataObj := dataSetImpl.GetDataSetObj()
+    state, err := dataObj.GetCameraCycleState(camName, profile)
+    if err != nil || state == nil {
+        return 0, 0
+    }
+    cycleDir := videoPath + "/" + state.CycleDir
+    if _, err = os.Stat(cycleDir); err != nil {
+        log.Info("No snapshots left in cycle %s of %s", state.CycleDir,
+                    camName)
+        return 0, 0
+    }
+    //Snapshots are stored in the directory named after the cycle start
+    // time, so the start time is restored from the directory name.
+    cycleStart, err := camThread.parseCycleName(state.CycleDir)
+    if err != nil || resumePolicy == dataSet.CAMERA_RESUME_RENDER {
+        log.Info("Rendering timelapse of interrupted cycle %s of %s",
+                    state.CycleDir, camName)
+        camThread.submitCycleJob(camThread.getCycleJobType(), cycleDir,
+                                 cycleDir, nil)
+        return 0, 0
+    }
+    log.Info("Resuming cycle %s of %s from snapshot %d", state.CycleDir,
+                camName, state.NumSnapshots)
+    camThread.threadLock.Lock()
+    //Cycle keeps its directory even when the alignment of the camera is
+    // changed since, and ends as per the current alignment.
+    camThread.setCycle__(cycleStart)
+    camThread.startTime = cycleStart
+    camThread.threadLock.Unlock()
+    return state.NumSnapshots, state.FileIndex
+}
+
+//Return the name of the directory of the cycle started at 'start', the start
+// time in the timezone of the camera.
+//MUST HOLD threadlock before calling this function.
+func (camThread *RTSPCameraThread)getCycleName__(start time.Time) string {
+    return start.In(camThread.location).Format(TIME_DIR_FORMAT)
+}
+
+//Return the start time of the cycle from its directory name.
+func (camThread *RTSPCameraThread)parseCycleName(name string) (time.Time,
+                                                               error) {
+    camThread.threadLock.RLock()
+    location := camThread.location
+    camThread.threadLock.RUnlock()
+    return time.ParseInLocation(TIME_DIR_FORMAT, name, location)
+}
+
+//Set the running cycle to the cycle at 'now'. An unaligned cycle starts at
+// 'now' and runs for the video length.
+//MUST HOLD threadlock before calling this function.
+func (camThread *RTSPCameraThread)setCycle__(now time.Time) {
+    length := camThread.videoLen
+    if camThread.cycleAlign == dataSet.CAMERA_CYCLE_ALIGN_HOUR ||
+        camThread.cycleAlign == dataSet.CAMERA_CYCLE_ALIGN_DAY ||
+        camThread.cycleAlign == dataSet.CAMERA_CYCLE_ALIGN_WEEK {
+        camThread.startTime, camThread.cycleEnd = getAlignedCycle(now,
+                                camThread.cycleAlign, length,
+                                camThread.location)
+        return
+    }
+    camThread.startTime = now
+    camThread.cycleEnd = now.Add(time.Duration(length) * time.Second)
+}
+
+//Return the start and end of the aligned cycle running at 'now'. The cycles
+// start at every 'length' seconds of wall clock time from the boundary, so
+// they stay aligned over the daylight saving changes. The last cycle before
+// the next boundary is cut short.
+func getAlignedCycle(now time.Time, align string, length uint64,
+                     location *time.Location) (time.Time, time.Time) {
+    now = now.In(location)
+    year, month, day := now.Date()
+    hour := 0
+    //Seconds of wall clock time from the boundary, and to the next boundary.
+    offset := uint64(now.Minute() * 60 + now.Second())
+    var period uint64
+    switch align {
+    case dataSet.CAMERA_CYCLE_ALIGN_HOUR:
+        hour = now.Hour()
+        period = 3600
+    case dataSet.CAMERA_CYCLE_ALIGN_WEEK:
+        weekday := (int(now.Weekday()) + 6) % 7
+        day -= weekday
+        offset += uint64(weekday * 86400 + now.Hour() * 3600)
+        period = 7 * 86400
+    default:
+        offset += uint64(now.Hour() * 3600)
+        period = 86400
+    }
+    if length == 0 {
+        length = period
+    }
+    start := offset / length * length
+    end := start + length
+    if end > period {
+        end = period
+    }
+    return time.Date(year, month, day, hour, 0, int(start), 0, location),
+            time.Date(year, month, day, hour, 0, int(end), 0, location)
+}
@@ -0,0 +1,104 @@
+package RTSPCameraImpl
+
+// Test file for validating the alignment of the timelapse cycles.
+import (
+    "time"
+    "testing"
+    "VideoTimeLapse/dataSet"
+)
+
+func TestGetAlignedCycle(t *testing.T) {
+    london, err := time.LoadLocation("Europe/London")
+    if err != nil {
+        t.Fatalf("Failed to load timezone, err: %s", err)
+    }
+    kolkata, err := time.LoadLocation("Asia/Kolkata")
+    if err != nil {
+        t.Fatalf("Failed to load timezone, err: %s", err)
+    }
+    date := func(location *time.Location, month time.Month, day int,
+                 hour int, min int) time.Time {
+        return time.Date(2026, month, day, hour, min, 0, 0, location)
+    }
+    tests := []struct {
+        name string
+        now time.Time
+        align string
+        length uint64
+        location *time.Location
+        start time.Time
+        end time.Time
+        duration time.Duration
+    }{
+        {"quarter hour", date(time.UTC, 3, 1, 10, 37),
+            dataSet.CAMERA_CYCLE_ALIGN_HOUR, 900, time.UTC,
+            date(time.UTC, 3, 1, 10, 30), date(time.UTC, 3, 1, 10, 45),
+            15 * time.Minute},
+        {"hour cut short", date(time.UTC, 3, 1, 10, 50),
+            dataSet.CAMERA_CYCLE_ALIGN_HOUR, 2400, time.UTC,
+            date(time.UTC, 3, 1, 10, 40), date(time.UTC, 3, 1, 11, 0),
+            20 * time.Minute},
+        {"hour in camera timezone", date(time.UTC, 3, 1, 12, 10),
+            dataSet.CAMERA_CYCLE_ALIGN_HOUR, 0, kolkata,
+            date(kolkata, 3, 1, 17, 0), date(kolkata, 3, 1, 18, 0),
+            time.Hour},
+        {"whole day", date(time.UTC, 3, 1, 10, 37),
+            dataSet.CAMERA_CYCLE_ALIGN_DAY, 0, time.UTC,
+            date(time.UTC, 3, 1, 0, 0), date(time.UTC, 3, 2, 0, 0),
+            24 * time.Hour},
+        {"day cut short", date(time.UTC, 3, 1, 22, 0),
+            dataSet.CAMERA_CYCLE_ALIGN_DAY, 5 * 3600, time.UTC,
+            date(time.UTC, 3, 1, 20, 0), date(time.UTC, 3, 2, 0, 0),
+            4 * time.Hour},
+        //Clocks go forward at 01:00 GMT, the cycle is an hour short.
+        {"day at DST start", date(london, 3, 29, 3, 30),
+            dataSet.CAMERA_CYCLE_ALIGN_DAY, 6 * 3600, london,
+            date(london, 3, 29, 0, 0), date(london, 3, 29, 6, 0),
+            5 * time.Hour},
+        {"day after DST start", date(london, 3, 29, 8, 0),
+            dataSet.CAMERA_CYCLE_ALIGN_DAY, 6 * 3600, london,
+            date(london, 3, 29, 6, 0), date(london, 3, 29, 12, 0),
+            6 * time.Hour},
+        //Clocks go back at 02:00 BST, the cycle is an hour long.
+        {"day at DST end", date(london, 10, 25, 4, 0),
+            dataSet.CAMERA_CYCLE_ALIGN_DAY, 6 * 3600, london,
+            date(london, 10, 25, 0, 0), date(london, 10, 25, 6, 0),
+            7 * time.Hour},
+        {"week from Wednesday", date(time.UTC, 10, 21, 15, 0),
+            dataSet.CAMERA_CYCLE_ALIGN_WEEK, 0, time.UTC,
+            date(time.UTC, 10, 19, 0, 0), date(time.UTC, 10, 26, 0, 0),
+            7 * 24 * time.Hour},
+        {"week at Monday", date(time.UTC, 10, 19, 0, 0),
+            dataSet.CAMERA_CYCLE_ALIGN_WEEK, 0, time.UTC,
+            date(time.UTC, 10, 19, 0, 0), date(time.UTC, 10, 26, 0, 0),
+            7 * 24 * time.Hour},
+        {"week from Sunday", date(time.UTC, 10, 25, 23, 59),
+            dataSet.CAMERA_CYCLE_ALIGN_WEEK, 0, time.UTC,
+            date(time.UTC, 10, 19, 0, 0), date(time.UTC, 10, 26, 0, 0),
+            7 * 24 * time.Hour},
+        {"week cut short", date(time.UTC, 10, 25, 12, 0),
+            dataSet.CAMERA_CYCLE_ALIGN_WEEK, 2 * 86400, time.UTC,
+            date(time.UTC, 10, 25, 0, 0), date(time.UTC, 10, 26, 0, 0),
+            24 * time.Hour},
+        {"week at DST start", date(london, 3, 25, 12, 0),
+            dataSet.CAMERA_CYCLE_ALIGN_WEEK, 0, london,
+            date(london, 3, 23, 0, 0), date(london, 3, 30, 0, 0),
+            7 * 24 * time.Hour - time.Hour},
+        {"week at DST end", date(london, 10, 25, 12, 0),
+            dataSet.CAMERA_CYCLE_ALIGN_WEEK, 0, london,
+            date(london, 10, 19, 0, 0), date(london, 10, 26, 0, 0),
+            7 * 24 * time.Hour + time.Hour},
+    }
+    for _, test := range tests {
+        start, end := getAlignedCycle(test.now, test.align, test.length,
+                                      test.location)
+        if !start.Equal(test.start) || !end.Equal(test.end) {
+            t.Errorf("%s: got %s to %s, expected %s to %s", test.name, start,
+                     end, test.start, test.end)
+        }
+        if end.Sub(start) != test.duration {
+            t.Errorf("%s: got cycle of %s, expected %s", test.name,
+                     end.Sub(start), test.duration)
+        }
+    }
+}
@@ -0,0 +1,429 @@
+package RTSPCameraImpl
+
+import (
+    "os"
+    "time"
+    "unsafe"
+    "io/ioutil"
+    "path/filepath"
+    "VideoTimeLapse/config"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+)
+
+// Consistency check of the camera video directories against the video
+// catalog. Every camera directory has a directory per timelapse cycle, named
+// after the cycle start time, that holds the snapshots of a running cycle.
+// The final timelapse videos are in the timeLapse directory of the cycle once
+// it is rendered.
+
+// #include "videoprobe.h"
+// #include <stdlib.h>
+import "C"
+
+const (
+    //Directory in video path where the broken files are moved to.
+    QUARANTINE_DIR_NAME = "quarantine"
+    //Snapshot directories modified recently can still be in use by the
+    // camera thread, hence not checked.
+    FSCK_IDLE_DIR_SEC = 600
+)
+
+//Issues found in the check.
+const (
+    //Video on disk is not in the catalog.
+    FSCK_ISSUE_UNREGISTERED = "unregistered"
+    //Video in the catalog is not on disk.
+    FSCK_ISSUE_MISSING = "missing"
+    //Snapshots of a cycle that is no longer running.
+    FSCK_ISSUE_ORPHAN = "orphan"
+    //MP4 file that cannot be read till the end.
+    FSCK_ISSUE_TRUNCATED = "truncated"
+    //Directory with timelapse cycles of a camera not in the datastore.
+    FSCK_ISSUE_UNKNOWN_CAMERA = "unknowncamera"
+)
+
+//Actions taken on the issues.
+const (
+    FSCK_ACTION_REGISTERED = "registered"
+    FSCK_ACTION_REMOVED = "removed"
+    FSCK_ACTION_RENDERED = "rendered"
+    //Stitch job of the orphaned snapshots is submitted.
+    FSCK_ACTION_QUEUED = "queued"
+    FSCK_ACTION_QUARANTINED = "quarantined"
+    FSCK_ACTION_FAILED = "failed"
+)
+
+//Repair fixes the catalog and queues the stitch jobs of the orphaned
+// snapshots. Quarantine moves the broken files and snapshots that cannot be
+// rendered to the quarantine directory. The issues are only reported when
+// both are unset. Inline renders the orphaned snapshots right away instead,
+// when there are no job workers to run the jobs.
+type FsckOptions struct {
+    Repair bool
+    Quarantine bool
+    Inline bool
+}
+
+type FsckIssue struct {
+    Type    string `json:"Type"`
+    CamName string `json:"CamName"`
+    Path    string `json:"Path"`
+    //Empty when no action is taken.
+    Action  string `json:"Action"`
+}
+
+type FsckReport struct {
+    CheckedCameras int         `json:"CheckedCameras"`
+    CheckedCycles  int         `json:"CheckedCycles"`
+    CheckedVideos  int         `json:"CheckedVideos"`
+    Issues         []FsckIssue `json:"Issues"`
+}
+
+type fsckChecker struct {
+    videoPath string
+    opts FsckOptions
+    report *FsckReport
+}
+
+//Return true if the mp4 file can be read till the end.
+func isVideoFileComplete(videoFile string) bool {
+    inputFormatC := C.CString("mp4")
+    inputURLC := C.CString(videoFile)
+    defer C.free(unsafe.Pointer(inputFormatC))
+    defer C.free(unsafe.Pointer(inputURLC))
+    return C.vs_check_input(inputFormatC, inputURLC) == 0
+}
+
+//Return true if the directory is named as a timelapse cycle.
+func isCycleDir(dir os.FileInfo) bool {
+    if !dir.IsDir() {
+        return false
+    }
+    _, err := time.ParseInLocation(TIME_DIR_FORMAT, dir.Name(), time.Local)
+    return err == nil
+}
+
+func (checker *fsckChecker)addIssue(issueType string, camName string,
+                                    path string, action string) {
+    log := logging.GetLoggerInstance()
+    log.Info("fsck: %s %s of camera %s %s", issueType, path, camName, action)
+    checker.report.Issues = append(checker.report.Issues, FsckIssue{
+        Type: issueType,
+        CamName: camName,
+        Path: path,
+        Action: action,
+    })
+}
+
+//Move the file/directory to quarantine directory, at the same path relative
+// to the video path.
+func (checker *fsckChecker)quarantine(path string) string {
+    log := logging.GetLoggerInstance()
+    relPath, err := filepath.Rel(checker.videoPath, path)
+    if err != nil {
+        log.Error("Cannot quarantine %s, err: %s", path, err)
+        return FSCK_ACTION_FAILED
+    }
+    dest := checker.videoPath + "/" + QUARANTINE_DIR_NAME + "/" + relPath
+    err = os.MkdirAll(filepath.Dir(dest), 0744)
+    if err == nil {
+        err = os.Rename(path, dest)
+    }
+    if err != nil {
+        log.Error("Failed to move %s to quarantine, err: %s", path, err)
+        return FSCK_ACTION_FAILED
+    }
+    return FSCK_ACTION_QUARANTINED
+}
+
+//Flag the catalog entries of camera whose files are gone. Returns the set of
+// video files in the catalog.
+func (checker *fsckChecker)checkCatalog(camName string) map[string]bool {
+    log := logging.GetLoggerInstance()
+    catalog := make(map[string]bool)
+    dataObj := dataSetImpl.GetDataSetObj()
+    videos, err := dataObj.GetAllVideos(camName)
+    if err != nil {
+        log.Error("Failed to get the videos of %s, err: %s", camName, err)
+        return catalog
+    }
+    for _, video := range videos {
+        if _, err = os.Stat(video.Path); err == nil {
+            catalog[video.Path] = true
+            continue
+        }
+        action := ""
+        if checker.opts.Repair {
+            action = FSCK_ACTION_REMOVED
+            if dataObj.DeleteVideo(camName, video.Name) != nil {
+                action = FSCK_ACTION_FAILED
+            }
+        }
+        checker.addIssue(FSCK_ISSUE_MISSING, camName, video.Path, action)
+    }
+    return catalog
+}
+
+//Register the final timelapse video in catalog, along with its thumbnails.
+func (checker *fsckChecker)registerVideo(camThread *RTSPCameraThread,
+                                         cycleDir string, videoFile string,
+                                         format string) string {
+    var thumbs videoThumbnails
+    mp4File := getFinalVideoFile(cycleDir, dataSet.VIDEO_FORMAT_MP4)
+    posterFile := filepath.Dir(mp4File) + "/" + THUMBNAIL_DIR_NAME + "/" +
+                    POSTER_FILE_NAME
+    if _, err := os.Stat(posterFile); err == nil {
+        thumbs.posterFile = posterFile
+        for ; thumbs.count < THUMBNAIL_COUNT; thumbs.count++ {
+            _, err = os.Stat(GetThumbnailFile(posterFile, thumbs.count))
+            if err != nil {
+                break
+            }
+        }
+    } else if _, err = os.Stat(mp4File); err == nil {
+        thumbs = camThread.createThumbnails(mp4File)
+    }
+    fileInfo, err := os.Stat(videoFile)
+    if err != nil {
+        return FSCK_ACTION_FAILED
+    }
+    startTime, _ := camThread.parseCycleName(filepath.Base(cycleDir))
+    err = camThread.addVideoToCatalog(cycleDir, videoFile, format, startTime,
+                                      fileInfo.ModTime(), thumbs)
+    if err != nil {
+        return FSCK_ACTION_FAILED
+    }
+    return FSCK_ACTION_REGISTERED
+}
+
+//Render the snapshots left in a cycle directory, in a stitch job unless the
+// rendering is inline.
+func (checker *fsckChecker)renderOrphanCycle(camThread *RTSPCameraThread,
+                                             cycleDir string) string {
+    log := logging.GetLoggerInstance()
+    if !checker.opts.Inline {
+        err := camThread.submitCycleJob(dataSet.JOB_TYPE_STITCH, cycleDir,
+                                        cycleDir, nil)
+        if err != nil {
+            log.Error("Failed to queue the rendering of %s, err: %s",
+                      cycleDir, err)
+            return FSCK_ACTION_FAILED
+        }
+        return FSCK_ACTION_QUEUED
+    }
+    camThread.createTimelapseWithSnapshots(cycleDir)
+    finalFile := getFinalVideoFile(cycleDir, dataSet.VIDEO_FORMAT_MP4)
+    if _, err := os.Stat(finalFile); err != nil {
+        return FSCK_ACTION_FAILED
+    }
+    return FSCK_ACTION_RENDERED
+}
+
+//Check the final videos and the snapshots left in a cycle directory.
+func (checker *fsckChecker)checkCycleDir(camThread *RTSPCameraThread,
+                                         cycleDir string,
+                                         catalog map[string]bool,
+                                         isRunning bool) {
+    log := logging.GetLoggerInstance()
+    camName := camThread.name
+    checker.report.CheckedCycles++
+    finalExists := false
+    for _, format := range dataSet.GetVideoFormats() {
+        videoFile := getFinalVideoFile(cycleDir, format)
+        if _, err := os.Stat(videoFile); err != nil {
+            continue
+        }
+        checker.report.CheckedVideos++
+        if format == dataSet.VIDEO_FORMAT_MP4 {
+            if !isVideoFileComplete(videoFile) {
+                action := ""
+                if checker.opts.Quarantine {
+                    action = checker.quarantine(videoFile)
+                }
+                checker.addIssue(FSCK_ISSUE_TRUNCATED, camName, videoFile,
+                                 action)
+                continue
+            }
+            finalExists = true
+        }
+        if catalog[videoFile] {
+            continue
+        }
+        action := ""
+        if checker.opts.Repair {
+            action = checker.registerVideo(camThread, cycleDir, videoFile,
+                                           format)
+        }
+        checker.addIssue(FSCK_ISSUE_UNREGISTERED, camName, videoFile, action)
+    }
+
+    files, err := ioutil.ReadDir(cycleDir)
+    if err != nil {
+        log.Error("Failed to read cycle directory %s, err: %s", cycleDir, err)
+        return
+    }
+    snapshots := []string{}
+    var lastModified time.Time
+    for _, file := range files {
+        if file.ModTime().After(lastModified) {
+            lastModified = file.ModTime()
+        }
+        if !file.IsDir() && snapshotFileRegex.MatchString(file.Name()) {
+            snapshots = append(snapshots, cycleDir + "/" + file.Name())
+        }
+    }
+    if len(snapshots) == 0 || isRunning ||
+        time.Since(lastModified) <
+            time.Duration(FSCK_IDLE_DIR_SEC) * time.Second {
+        return
+    }
+    //Snapshots kept for re-rendering are not orphans until they expire.
+    if finalExists && time.Since(lastModified) <
+                        camThread.getSnapshotRetention() {
+        return
+    }
+    for _, snapshot := range snapshots {
+        if isVideoFileComplete(snapshot) {
+            continue
+        }
+        //Truncated snapshot is moved out before rendering the snapshots.
+        action := ""
+        if checker.opts.Quarantine {
+            action = checker.quarantine(snapshot)
+        }
+        checker.addIssue(FSCK_ISSUE_TRUNCATED, camName, snapshot, action)
+    }
+    action := ""
+    if checker.opts.Repair && !finalExists {
+        action = checker.renderOrphanCycle(camThread, cycleDir)
+    } else if checker.opts.Quarantine {
+        //Snapshots left after the cycle is rendered are not used anymore.
+        // The final videos of the cycle are left as is.
+        action = FSCK_ACTION_QUARANTINED
+        for _, snapshot := range snapshots {
+            if _, err = os.Stat(snapshot); err != nil {
+                //Truncated snapshot is already moved.
+                continue
+            }
+            if checker.quarantine(snapshot) == FSCK_ACTION_FAILED {
+                action = FSCK_ACTION_FAILED
+            }
+        }
+    }
+    checker.addIssue(FSCK_ISSUE_ORPHAN, camName, cycleDir, action)
+}
+
+//Check all the cycle directories of the camera.
+func (checker *fsckChecker)checkCamera(cam *dataSet.Camera) {
+    log := logging.GetLoggerInstance()
+    camDir := checker.videoPath + "/" + cam.Name
+    if _, err := os.Stat(camDir); err != nil {
+        return
+    }
+    checker.report.CheckedCameras++
+    catalog := checker.checkCatalog(cam.Name)
+    //Thread is used to render and catalog the videos of camera, its never
+    // run.
+    camThread := new(RTSPCameraThread)
+    err := camThread.InitCameraThread(cam,
+                                &config.AppConfig{VideoPath: checker.videoPath})
+    if err != nil {
+        log.Error("Failed to check camera %s, err: %s", cam.Name, err)
+        return
+    }
+    checker.checkCycleDirs(camThread, catalog)
+    for _, profileThread := range camThread.profiles {
+        checker.checkCycleDirs(profileThread, catalog)
+    }
+}
+
+//Check the cycle directories in the directory of the camera thread, the
+// directory of the camera or one of its profiles.
+func (checker *fsckChecker)checkCycleDirs(camThread *RTSPCameraThread,
+                                          catalog map[string]bool) {
+    log := logging.GetLoggerInstance()
+    runningCycle := ""
+    dataObj := dataSetImpl.GetDataSetObj()
+    state, err := dataObj.GetCameraCycleState(camThread.name,
+                                              camThread.profile)
+    if err == nil {
+        runningCycle = state.CycleDir
+    }
+    dirs, err := ioutil.ReadDir(camThread.videoPath)
+    if err != nil {
+        log.Error("Failed to read camera directory %s, err: %s",
+                  camThread.videoPath, err)
+        return
+    }
+    for _, dir := range dirs {
+        if !isCycleDir(dir) {
+            continue
+        }
+        checker.checkCycleDir(camThread, camThread.videoPath + "/" +
+                              dir.Name(), catalog,
+                              dir.Name() == runningCycle)
+    }
+}
+
+//Report the directories with timelapse cycles that don't belong to any
+// camera. These directories are never modified, as the video path can be
+// shared with other applications.
+func (checker *fsckChecker)checkUnknownCameras(cameras []dataSet.Camera) {
+    knownDirs := map[string]bool{QUARANTINE_DIR_NAME: true}
+    for _, cam := range cameras {
+        knownDirs[cam.Name] = true
+    }
+    dirs, err := ioutil.ReadDir(checker.videoPath)
+    if err != nil {
+        return
+    }
+    for _, dir := range dirs {
+        if !dir.IsDir() || knownDirs[dir.Name()] {
+            continue
+        }
+        camDir := checker.videoPath + "/" + dir.Name()
+        subDirs, err := ioutil.ReadDir(camDir)
+        if err != nil {
+            continue
+        }
+        for _, subDir := range subDirs {
+            if isCycleDir(subDir) {
+                checker.addIssue(FSCK_ISSUE_UNKNOWN_CAMERA, dir.Name(),
+                                 camDir, "")
+                break
+            }
+        }
+    }
+}
+
+//Reconcile the camera video directories in 'videoPath' with the video
+// catalog.
+func CheckVideoStore(videoPath string, opts FsckOptions) (*FsckReport,
+                                                           error) {
+    log := logging.GetLoggerInstance()
+    videoDir, err := filepath.Abs(videoPath)
+    if err != nil {
+        return nil, err
+    }
+    checker := &fsckChecker{
+        videoPath: videoDir,
+        opts: opts,
+        report: &FsckReport{Issues: []FsckIssue{}},
+    }
+    dataObj := dataSetImpl.GetDataSetObj()
+    cameras, err := dataObj.GetAllCameras()
+    if err != nil {
+        log.Error("Cannot check the video store, err: %s", err)
+        return nil, err
+    }
+    for i := range cameras {
+        checker.checkCamera(&cameras[i])
+    }
+    checker.checkUnknownCameras(cameras)
+    log.Info("fsck: checked %d cameras, %d cycles, %d videos, %d issues",
+                checker.report.CheckedCameras, checker.report.CheckedCycles,
+                checker.report.CheckedVideos, len(checker.report.Issues))
+    return checker.report, nil
+}
@@ -0,0 +1,100 @@
+package RTSPCameraImpl
+
+// Test file for validating the consistency check of the video directories.
+import (
+    "os"
+    "time"
+    "testing"
+    "io/ioutil"
+    "VideoTimeLapse/logging"
+)
+
+//Directory entry for the checks that only look at name and type.
+type fsckTestFileInfo struct {
+    name string
+    dir bool
+}
+
+func (info fsckTestFileInfo)Name() string {
+    return info.name
+}
+
+func (info fsckTestFileInfo)Size() int64 {
+    return 0
+}
+
+func (info fsckTestFileInfo)Mode() os.FileMode {
+    if info.dir {
+        return os.ModeDir | 0744
+    }
+    return 0644
+}
+
+func (info fsckTestFileInfo)ModTime() time.Time {
+    return time.Time{}
+}
+
+func (info fsckTestFileInfo)IsDir() bool {
+    return info.dir
+}
+
+func (info fsckTestFileInfo)Sys() interface{} {
+    return nil
+}
+
+func TestIsCycleDir(t *testing.T) {
+    tests := []struct {
+        name string
+        info fsckTestFileInfo
+        expected bool
+    }{
+        {"cycle dir", fsckTestFileInfo{"20240101120000", true}, true},
+        {"cycle named file", fsckTestFileInfo{"20240101120000", false},
+            false},
+        {"partial render dir", fsckTestFileInfo{
+            "20240101120000" + PARTIAL_RENDER_SEP + "20240101130000", true},
+            false},
+        {"invalid date", fsckTestFileInfo{"20241301120000", true}, false},
+        {"short name", fsckTestFileInfo{"202401011200", true}, false},
+        {"quarantine dir", fsckTestFileInfo{QUARANTINE_DIR_NAME, true},
+            false},
+        {"live dir", fsckTestFileInfo{LIVE_DIR_NAME, true}, false},
+    }
+    for _, test := range tests {
+        isCycle := isCycleDir(test.info)
+        if isCycle != test.expected {
+            t.Errorf("%s: got cycle dir %v, expected %v", test.name,
+                     isCycle, test.expected)
+        }
+    }
+}
+
+func TestFsckQuarantine(t *testing.T) {
+    logger := new(logging.Logging)
+    logger.LogInitSingleton(logging.LogLeveltype(logging.Trace), "")
+    videoPath, err := ioutil.TempDir("", "fsck")
+    if err != nil {
+        t.Fatalf("Failed to create video path, err: %s", err)
+    }
+    defer os.RemoveAll(videoPath)
+    cycleDir := videoPath + "/cam1/20240101120000"
+    err = os.MkdirAll(cycleDir, 0744)
+    if err != nil {
+        t.Fatalf("Failed to create cycle dir, err: %s", err)
+    }
+    checker := &fsckChecker{videoPath: videoPath,
+                            opts: FsckOptions{Quarantine: true},
+                            report: new(FsckReport)}
+    action := checker.quarantine(cycleDir)
+    if action != FSCK_ACTION_QUARANTINED {
+        t.Fatalf("got action %q, expected %q", action,
+                 FSCK_ACTION_QUARANTINED)
+    }
+    if _, err = os.Stat(cycleDir); !os.IsNotExist(err) {
+        t.Errorf("cycle dir %s is not moved", cycleDir)
+    }
+    dest := videoPath + "/" + QUARANTINE_DIR_NAME + "/cam1/20240101120000"
+    if info, err := os.Stat(dest); err != nil || !info.IsDir() {
+        t.Errorf("cycle dir is not in quarantine at %s", dest)
+    }
+}
@@ -0,0 +1,149 @@
+package RTSPCameraImpl
+
+import (
+    "fmt"
+    "os"
+    "sync"
+    "unsafe"
+    "path/filepath"
+    "VideoTimeLapse/logging"
+)
+
+// HLS packaging of the timelapse videos for in-browser playback. The final
+// timelapse is packaged as a VOD playlist next to the final video file. The
+// snapshots of the running cycle are appended to a live event playlist as
+// they are created, so the timelapse so far can be played.
+
+// #include "videomux.h"
+// #include <stdlib.h>
+import "C"
+
+const (
+    HLS_DIR_NAME = "hls"
+    HLS_PLAYLIST_NAME = "index.m3u8"
+    HLS_SEGMENT_NAME = "segment_%05d.m4s"
+    HLS_SEGMENT_SEC = 2
+    //Directory in camera video path for the running timelapse.
+    LIVE_DIR_NAME = "live"
+)
+
+//Return the directory of HLS package for a timelapse video file.
+func GetVideoHLSDir(videoFile string) string {
+    return filepath.Dir(videoFile) + "/" + HLS_DIR_NAME
+}
+
+//Return the directory of HLS package for the running timelapse of a camera.
+func GetLiveHLSDir(videoPath string, camName string) string {
+    videoDir, _ := filepath.Abs(videoPath)
+    return videoDir + "/" + camName + "/" + LIVE_DIR_NAME + "/" + HLS_DIR_NAME
+}
+
+//Copy all the packets from input to output.
+func (camThread *RTSPCameraThread)remuxPackets(input *Input,
+                                               output *Output) error {
+    var err error
+    for {
+        var pktIn C.AVPacket
+        readRes := C.int(0)
+        input.mutex.RLock()
+        readRes = C.vs_read_packet(input.vsInput, &pktIn, C.bool(false))
+        input.mutex.RUnlock()
+        if readRes == -1 {
+            break
+        }
+        if readRes == 0 {
+            continue
+        }
+        output.mutex.Lock()
+        writeRes := C.vs_write_packet(input.vsInput, output.vsOutput, &pktIn,
+                                      C.bool(false))
+        output.mutex.Unlock()
+        C.av_packet_unref(&pktIn)
+        if writeRes == -1 {
+            err = fmt.Errorf("Failed to write the packet to HLS output")
+            break
+        }
+    }
+    return err
+}
+
+//Package the mp4 video file as HLS in 'hlsDir'. The live package is appended
+// to the existing playlist in the directory.
+func (camThread *RTSPCameraThread)packageHLS(videoFile string, hlsDir string,
+                                             live bool) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    if _, err = os.Stat(hlsDir); os.IsNotExist(err) {
+        err = os.MkdirAll(hlsDir, 0744)
+        if err != nil {
+            log.Error("Failed to create HLS directory %s", hlsDir)
+            return err
+        }
+    }
+    input := camThread.openInput("mp4", videoFile)
+    if input == nil || input.vsInput == nil {
+        log.Error("Failed to open %s for HLS packaging", videoFile)
+        return fmt.Errorf("Failed to open %s", videoFile)
+    }
+    playlistC := C.CString(hlsDir + "/" + HLS_PLAYLIST_NAME)
+    segmentC := C.CString(hlsDir + "/" + HLS_SEGMENT_NAME)
+    input.mutex.RLock()
+    vsOutput := C.vs_open_hls_output(playlistC, segmentC, input.vsInput,
+                                     C.int(HLS_SEGMENT_SEC), C.bool(live),
+                                     C.bool(false))
+    input.mutex.RUnlock()
+    C.free(unsafe.Pointer(playlistC))
+    C.free(unsafe.Pointer(segmentC))
+    if vsOutput == nil {
+        log.Error("Failed to open HLS output in %s", hlsDir)
+        camThread.destroyInput(input)
+        return fmt.Errorf("Failed to open HLS output in %s", hlsDir)
+    }
+    output := &Output {
+        mutex: &sync.RWMutex{},
+        vsOutput: vsOutput,
+    }
+    err = camThread.remuxPackets(input, output)
+    camThread.destroyInput(input)
+    output.mutex.Lock()
+    C.vs_destroy_output(output.vsOutput)
+    output.vsOutput = nil
+    output.mutex.Unlock()
+    if err != nil {
+        log.Error("Failed to package %s as HLS, err: %s", videoFile, err)
+        return err
+    }
+    log.Trace("Packaged %s as HLS in %s", videoFile, hlsDir)
+    return nil
+}
+
+//Append a snapshot of the running cycle to the live HLS package. The live
+// package is started over when the snapshot is from a new cycle.
+func (camThread *RTSPCameraThread)appendLiveHLS(snapshotFile string) {
+    log := logging.GetLoggerInstance()
+    camThread.threadLock.RLock()
+    enableHLS := camThread.enableHLS
+    liveDir := camThread.videoPath + "/" + LIVE_DIR_NAME + "/" + HLS_DIR_NAME
+    camThread.threadLock.RUnlock()
+    if !enableHLS {
+        return
+    }
+    cycle := filepath.Base(filepath.Dir(snapshotFile))
+    camThread.liveLock.Lock()
+    defer camThread.liveLock.Unlock()
+    if cycle != camThread.liveCycle {
+        //Cycle directories are named by time, so the older snapshot that
+        // completed late is not added to the new live package.
+        if cycle < camThread.liveCycle {
+            return
+        }
+        err := os.RemoveAll(liveDir)
+        if err != nil {
+            log.Error("Failed to clear live HLS directory %s, err: %s",
+                        liveDir, err)
+            return
+        }
+        camThread.liveCycle = cycle
+    }
+    camThread.packageHLS(snapshotFile, liveDir, true)
+}
@@ -0,0 +1,51 @@
+package RTSPCameraImpl
+
+// Test file for validating the directories of the HLS packages.
+import (
+    "os"
+    "testing"
+)
+
+func TestGetVideoHLSDir(t *testing.T) {
+    tests := []struct {
+        name string
+        videoFile string
+        expected string
+    }{
+        {"cycle video", "/videos/cam1/20240101000000/video.mp4",
+            "/videos/cam1/20240101000000/hls"},
+        {"relative video", "cam1/video.mp4", "cam1/hls"},
+        {"bare file", "video.mp4", "./hls"},
+    }
+    for _, test := range tests {
+        dir := GetVideoHLSDir(test.videoFile)
+        if dir != test.expected {
+            t.Errorf("%s: got dir %q, expected %q", test.name, dir,
+                     test.expected)
+        }
+    }
+}
+
+func TestGetLiveHLSDir(t *testing.T) {
+    cwd, err := os.Getwd()
+    if err != nil {
+        t.Fatalf("Failed to get working directory, err: %s", err)
+    }
+    tests := []struct {
+        name string
+        videoPath string
+        camName string
+        expected string
+    }{
+        {"absolute path", "/videos", "cam1", "/videos/cam1/live/hls"},
+        {"trailing slash", "/videos/", "cam1", "/videos/cam1/live/hls"},
+        {"relative path", "videos", "cam1", cwd + "/videos/cam1/live/hls"},
+    }
+    for _, test := range tests {
+        dir := GetLiveHLSDir(test.videoPath, test.camName)
+        if dir != test.expected {
+            t.Errorf("%s: got dir %q, expected %q", test.name, dir,
+                     test.expected)
+        }
+    }
+}
@@ -0,0 +1,229 @@
+package RTSPCameraImpl
+
+import (
+    "time"
+    "encoding/json"
+    "VideoTimeLapse/config"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/jobQueue"
+    "VideoTimeLapse/appErrors"
+)
+
+// Rendering work of the cameras run as background jobs. A completed cycle is
+// stitched in a job that queues the compact job of the cycle on success. The
+// cycle of a recording camera is sampled from its segments before stitching.
+// The partial timelapse of a running cycle and the re-render of a past cycle
+// are single jobs. The jobs are run on a camera thread created from the camera
+// configuration, which is never run, same as fsck. The jobs of a capture
+// profile are run on the profile thread of the camera thread.
+
+//Parameters of the stitch and export jobs.
+type cycleJobParams struct {
+    //Capture profile of the cycle, empty for the cycle of the camera.
+    Profile   string   `json:"Profile,omitempty"`
+    CycleDir  string   `json:"CycleDir"`
+    OutputDir string   `json:"OutputDir"`
+    //Snapshots being written when the job is submitted, and the unix time
+    // of submission. Snapshots created after are not in the export.
+    SkipFiles []string `json:"SkipFiles"`
+    UpTo      int64    `json:"UpTo"`
+}
+
+//Parameters of the re-render job.
+type rerenderJobParams struct {
+    CycleDir  string          `json:"CycleDir"`
+    OutputDir string          `json:"OutputDir"`
+    Options   RerenderOptions `json:"Options"`
+}
+
+//Video path of the application, where the camera directories are.
+var jobVideoPath string
+
+//Submit the job to stitch or export the snapshots of a cycle.
+func (camThread *RTSPCameraThread)submitCycleJob(jobType string,
+                                    cycleDir string, outputDir string,
+                                    skipFiles map[string]bool) error {
+    params := cycleJobParams{
+        Profile: camThread.profile,
+        CycleDir: cycleDir,
+        OutputDir: outputDir,
+        SkipFiles: []string{},
+        UpTo: time.Now().Unix(),
+    }
+    for file := range skipFiles {
+        params.SkipFiles = append(params.SkipFiles, file)
+    }
+    _, err := jobQueue.GetJobQueueObj().SubmitJob(jobType, camThread.name,
+                                                  params)
+    return err
+}
+
+//Return the type of the job to render a completed cycle of the camera.
+func (camThread *RTSPCameraThread)getCycleJobType() string {
+    camThread.threadLock.RLock()
+    defer camThread.threadLock.RUnlock()
+    if camThread.recording {
+        return dataSet.JOB_TYPE_SAMPLE
+    }
+    return dataSet.JOB_TYPE_STITCH
+}
+
+//Return a camera thread to run the job of the camera, the thread of the
+// capture profile when 'profile' is set.
+func getJobCameraThread(camName string,
+                        profile string) (*RTSPCameraThread, error) {
+    dataObj := dataSetImpl.GetDataSetObj()
+    cam, err := dataObj.GetCamera(camName)
+    if err != nil {
+        return nil, err
+    }
+    camThread := new(RTSPCameraThread)
+    err = camThread.InitCameraThread(cam,
+                                     &config.AppConfig{VideoPath: jobVideoPath})
+    if err != nil {
+        return nil, err
+    }
+    if len(profile) == 0 {
+        return camThread, nil
+    }
+    for _, profileThread := range camThread.profiles {
+        if profileThread.profile == profile {
+            return profileThread, nil
+        }
+    }
+    //Profile is removed from the camera after the job is submitted.
+    return nil, appErrors.DATA_NOT_FOUND
+}
+
+//Stitch the snapshots of a completed cycle and queue its compaction.
+func runStitchJob(job *dataSet.Job, progress jobQueue.JobProgress) error {
+    var params cycleJobParams
+    err := json.Unmarshal([]byte(job.Params), &params)
+    if err != nil {
+        return err
+    }
+    camThread, err := getJobCameraThread(job.CamName, params.Profile)
+    if err != nil {
+        return err
+    }
+    cycle, err := camThread.stitchCycle(params.CycleDir, params.OutputDir, nil)
+    if err != nil || cycle == nil {
+        //Nothing to render when the cycle has no snapshots.
+        return err
+    }
+    _, err = jobQueue.GetJobQueueObj().SubmitJob(dataSet.JOB_TYPE_COMPACT,
+                                                 job.CamName, cycle)
+    return err
+}
+
+//Sample the snapshots of a completed cycle from the recorded segments, and
+// stitch them the same as a captured cycle.
+func runSampleJob(job *dataSet.Job, progress jobQueue.JobProgress) error {
+    var params cycleJobParams
+    err := json.Unmarshal([]byte(job.Params), &params)
+    if err != nil {
+        return err
+    }
+    camThread, err := getJobCameraThread(job.CamName, params.Profile)
+    if err != nil {
+        return err
+    }
+    err = camThread.sampleCycle(params.CycleDir, params.UpTo)
+    if err != nil {
+        return err
+    }
+    err = jobQueue.ReportProgress(progress, 50)
+    if err != nil {
+        return err
+    }
+    return runStitchJob(job, progress)
+}
+
+//Compact the stitched cycle and render its output formats.
+func runCompactJob(job *dataSet.Job, progress jobQueue.JobProgress) error {
+    var cycle stitchedCycle
+    err := json.Unmarshal([]byte(job.Params), &cycle)
+    if err != nil {
+        return err
+    }
+    camThread, err := getJobCameraThread(job.CamName, cycle.Profile)
+    if err != nil {
+        return err
+    }
+    return camThread.finishCycle(&cycle, progress)
+}
+
+//Render the snapshots of the running cycle captured till the job submission.
+func runExportJob(job *dataSet.Job, progress jobQueue.JobProgress) error {
+    var params cycleJobParams
+    err := json.Unmarshal([]byte(job.Params), &params)
+    if err != nil {
+        return err
+    }
+    camThread, err := getJobCameraThread(job.CamName, params.Profile)
+    if err != nil {
+        return err
+    }
+    if camThread.recording {
+        //Footage of the running segment is not in the export.
+        err = camThread.sampleCycle(params.CycleDir, params.UpTo)
+        if err != nil {
+            return err
+        }
+    }
+    skipFiles := make(map[string]bool)
+    for _, file := range params.SkipFiles {
+        skipFiles[file] = true
+    }
+    files, err := getCycleSnapshots(params.CycleDir, nil)
+    if err != nil {
+        return err
+    }
+    for _, file := range files {
+        if file.ModTime().Unix() > params.UpTo {
+            skipFiles[params.CycleDir + "/" + file.Name()] = true
+        }
+    }
+    cycle, err := camThread.stitchCycle(params.CycleDir, params.OutputDir,
+                                        skipFiles)
+    if err != nil || cycle == nil {
+        return err
+    }
+    err = jobQueue.ReportProgress(progress, 40)
+    if err != nil {
+        return err
+    }
+    return camThread.finishCycle(cycle, progress)
+}
+
+//Re-render a past cycle with the output parameters of the job.
+func runRerenderJob(job *dataSet.Job, progress jobQueue.JobProgress) error {
+    var params rerenderJobParams
+    err := json.Unmarshal([]byte(job.Params), &params)
+    if err != nil {
+        return err
+    }
+    camThread, err := getJobCameraThread(job.CamName, "")
+    if err != nil {
+        return err
+    }
+    return camThread.rerenderCycle(params.CycleDir, params.OutputDir,
+                                   params.Options, progress)
+}
+
+//Register the handlers of all the camera rendering jobs.
+func RegisterJobHandlers(conf *config.AppConfig) {
+    log := logging.GetLoggerInstance()
+    jobVideoPath = conf.VideoPath
+    queue := jobQueue.GetJobQueueObj()
+    queue.RegisterJobHandler(dataSet.JOB_TYPE_STITCH, runStitchJob)
+    queue.RegisterJobHandler(dataSet.JOB_TYPE_SAMPLE, runSampleJob)
+    queue.RegisterJobHandler(dataSet.JOB_TYPE_COMPACT, runCompactJob)
+    queue.RegisterJobHandler(dataSet.JOB_TYPE_EXPORT, runExportJob)
+    queue.RegisterJobHandler(dataSet.JOB_TYPE_RERENDER, runRerenderJob)
+    queue.RegisterJobHandler(dataSet.JOB_TYPE_ROLLUP, runRollupJob)
+    queue.RegisterJobHandler(dataSet.JOB_TYPE_COMPOSITE, runCompositeJob)
+    log.Trace("Registered the camera job handlers")
+}
@@ -0,0 +1,229 @@
+package RTSPCameraImpl
+
+import (
+    "os"
+    "math"
+    "sort"
+    "unsafe"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+)
+
+// Privacy masks of the camera are applied to the decoded frames in the
+// capture path. The snapshot clips of a masked camera are re-encoded through
+// the masks instead of the packet copy, so the unmasked frames are never
+// written to the video path. The timelapses, thumbnails and live HLS are made
+// from the snapshots and carry the masks as well.
+// The snapshots taken before the masks are set are left as is.
+
+// #include "videotranscode.h"
+// #include <stdlib.h>
+import "C"
+
+const (
+    //Mask resolution when the stream resolution is not known yet.
+    MASK_DEFAULT_WIDTH = 1920
+    MASK_DEFAULT_HEIGHT = 1080
+    //Masked snapshots are fragmented same as the copied snapshots, and start
+    // at 0 as the stream timestamps are not carried to the encoder.
+    MASK_SNAPSHOT_FORMAT_OPTS = "movflags=frag_keyframe+empty_moov"
+    MASK_SNAPSHOT_FILTER = "setpts=PTS-STARTPTS"
+    //Packets queued to the masking routine before the capture is blocked.
+    MASK_PKT_QUEUE_LEN = 256
+)
+
+//Privacy mask bitmap in C memory, as the transcoder keeps it while the
+// snapshot is written.
+type privacyMask struct {
+    bitmap unsafe.Pointer
+    vsMask *C.struct_VSMask
+}
+
+//Snapshot output re-encoded through the privacy mask. The packets are
+// transcoded in order by a single routine, as the frames depend on the
+// previous ones.
+type maskedOutput struct {
+    transcoder *C.struct_VSTranscoder
+    mask *privacyMask
+    pkts chan *C.AVPacket
+    done chan bool
+    //Set by the transcode routine when a packet cannot be masked or encoded.
+    failed bool
+}
+
+//Return the mode of the mask pixels.
+func getMaskPixelMode(mask *dataSet.PrivacyMask) byte {
+    if mask.GetMode() == dataSet.PRIVACY_MASK_PIXELATE {
+        return C.VS_MASK_PIXELATE
+    }
+    return C.VS_MASK_BLACK
+}
+
+//Draw the mask polygons on a bitmap of 'width' x 'height' pixels. Every row
+// is filled between the polygon edges at the pixel center by even-odd rule,
+// the pixels on the edges are masked.
+func rasterizePrivacyMasks(masks []dataSet.PrivacyMask, width int,
+                           height int) []byte {
+    bitmap := make([]byte, width * height)
+    for i := range masks {
+        mode := getMaskPixelMode(&masks[i])
+        points := masks[i].Points
+        for y := 0; y < height; y++ {
+            rowY := (float64(y) + 0.5) / float64(height)
+            edges := []float64{}
+            for j := range points {
+                a := points[j]
+                b := points[(j + 1) % len(points)]
+                if (a.Y <= rowY) == (b.Y <= rowY) {
+                    continue
+                }
+                edges = append(edges,
+                               a.X + (rowY - a.Y) * (b.X - a.X) / (b.Y - a.Y))
+            }
+            sort.Float64s(edges)
+            for j := 0; j + 1 < len(edges); j += 2 {
+                start := int(math.Floor(edges[j] * float64(width)))
+                end := int(math.Ceil(edges[j + 1] * float64(width)))
+                if start < 0 {
+                    start = 0
+                }
+                if end > width {
+                    end = width
+                }
+                for x := start; x < end; x++ {
+                    if bitmap[y * width + x] < mode {
+                        bitmap[y * width + x] = mode
+                    }
+                }
+            }
+        }
+    }
+    return bitmap
+}
+
+//Return the mask bitmap of the polygons, nil when there are no masks.
+func newPrivacyMask(masks []dataSet.PrivacyMask, width int,
+                    height int) *privacyMask {
+    if len(masks) == 0 {
+        return nil
+    }
+    if width <= 0 || height <= 0 {
+        width = MASK_DEFAULT_WIDTH
+        height = MASK_DEFAULT_HEIGHT
+    }
+    mask := &privacyMask{
+        bitmap: C.CBytes(rasterizePrivacyMasks(masks, width, height)),
+        vsMask: (*C.struct_VSMask)(C.malloc(
+                                C.size_t(unsafe.Sizeof(C.struct_VSMask{})))),
+    }
+    mask.vsMask.bitmap = (*C.uint8_t)(mask.bitmap)
+    mask.vsMask.width = C.int(width)
+    mask.vsMask.height = C.int(height)
+    return mask
+}
+
+//Return the mask to pass to C, nil when there is no mask.
+func (mask *privacyMask)getVSMask() *C.struct_VSMask {
+    if mask == nil {
+        return nil
+    }
+    return mask.vsMask
+}
+
+func (mask *privacyMask)free() {
+    if mask == nil {
+        return
+    }
+    C.free(unsafe.Pointer(mask.vsMask))
+    C.free(mask.bitmap)
+}
+
+//Return the privacy mask of the camera at the resolution of its stream, nil
+// when the camera has no masks. The mask must be freed by the caller.
+func (camThread *RTSPCameraThread)getPrivacyMask(
+                                masks []dataSet.PrivacyMask) *privacyMask {
+    var width, height int
+    camThread.streamLock.Lock()
+    if camThread.streamInfo != nil {
+        width = int(camThread.streamInfo.Width)
+        height = int(camThread.streamInfo.Height)
+    }
+    camThread.streamLock.Unlock()
+    return newPrivacyMask(masks, width, height)
+}
+
+//Open the snapshot output that re-encodes the input packets through 'mask'.
+// The mask is freed along with the output.
+func (camThread *RTSPCameraThread)openMaskedOutput(outputFile string,
+                                    input *Input,
+                                    mask *privacyMask) *maskedOutput {
+    log := logging.GetLoggerInstance()
+    profile := videoFormatProfiles[dataSet.VIDEO_FORMAT_MP4]
+    outputFormatC := C.CString(profile.muxer)
+    outputURLC := C.CString("file:" + outputFile)
+    encoderC := C.CString(profile.encoder)
+    encoderOptsC := C.CString(profile.encoderOpts)
+    formatOptsC := C.CString(MASK_SNAPSHOT_FORMAT_OPTS)
+    filterC := C.CString(MASK_SNAPSHOT_FILTER + "," + profile.filter)
+    input.mutex.RLock()
+    transcoder := C.vs_open_transcoder(input.vsInput, outputFormatC,
+                                       outputURLC, encoderC, encoderOptsC,
+                                       formatOptsC, filterC, mask.getVSMask())
+    input.mutex.RUnlock()
+    C.free(unsafe.Pointer(outputFormatC))
+    C.free(unsafe.Pointer(outputURLC))
+    C.free(unsafe.Pointer(encoderC))
+    C.free(unsafe.Pointer(encoderOptsC))
+    C.free(unsafe.Pointer(formatOptsC))
+    C.free(unsafe.Pointer(filterC))
+    if transcoder == nil {
+        log.Error("Failed to open masked output file %s", outputFile)
+        mask.free()
+        return nil
+    }
+    output := &maskedOutput{
+        transcoder: transcoder,
+        mask: mask,
+        pkts: make(chan *C.AVPacket, MASK_PKT_QUEUE_LEN),
+        done: make(chan bool),
+    }
+    go output.transcodePackets()
+    return output
+}
+
+func (output *maskedOutput)transcodePackets() {
+    for pkt := range output.pkts {
+        if !output.failed &&
+            C.vs_transcode_packet(output.transcoder, pkt) != 0 {
+            output.failed = true
+        }
+        C.av_packet_free(&pkt)
+    }
+    close(output.done)
+}
+
+//Queue the input packet to the output. The packet is copied.
+func (output *maskedOutput)writePacket(pkt *C.AVPacket) {
+    output.pkts <- C.av_packet_clone(pkt)
+}
+
+//Complete the masked snapshot once all the queued packets are transcoded and
+// add the snapshot to the running timelapse. The snapshot is deleted if any
+// frame could not be masked.
+func (camThread *RTSPCameraThread)finishMaskedSnapshot(output *maskedOutput,
+                                    snapshotFile string) {
+    log := logging.GetLoggerInstance()
+    close(output.pkts)
+    <-output.done
+    res := C.vs_close_transcoder(output.transcoder)
+    output.mask.free()
+    camThread.snapShotJoin.Done()
+    if output.failed || res != 0 {
+        log.Error("Failed to create masked snapshot %s", snapshotFile)
+        os.Remove(snapshotFile)
+        camThread.setSnapshotActive(snapshotFile, false)
+        return
+    }
+    camThread.setSnapshotActive(snapshotFile, false)
+    camThread.appendLiveHLS(snapshotFile)
+}
@@ -0,0 +1,78 @@
+package RTSPCameraImpl
+
+// Test file for validating the privacy mask bitmaps.
+import (
+    "strings"
+    "testing"
+    "VideoTimeLapse/dataSet"
+)
+
+func TestRasterizePrivacyMasks(t *testing.T) {
+    black := dataSet.PrivacyMask{Mode: dataSet.PRIVACY_MASK_BLACK}
+    pixelate := dataSet.PrivacyMask{Mode: dataSet.PRIVACY_MASK_PIXELATE}
+    modes := map[rune]byte{
+        '.': 0,
+        'B': getMaskPixelMode(&black),
+        'P': getMaskPixelMode(&pixelate),
+    }
+    getMask := func(mode string, points ...float64) dataSet.PrivacyMask {
+        mask := dataSet.PrivacyMask{Mode: mode}
+        for i := 0; i + 1 < len(points); i += 2 {
+            mask.Points = append(mask.Points,
+                                 dataSet.MaskPoint{X: points[i],
+                                                   Y: points[i + 1]})
+        }
+        return mask
+    }
+    topLeft := getMask(dataSet.PRIVACY_MASK_BLACK, 0, 0, 0.5, 0, 0.5, 0.5,
+                       0, 0.5)
+    tests := []struct {
+        name string
+        masks []dataSet.PrivacyMask
+        expected []string
+    }{
+        {"no masks", nil, []string{
+            "....",
+            "....",
+            "....",
+            "...."}},
+        {"quarter", []dataSet.PrivacyMask{topLeft}, []string{
+            "BB..",
+            "BB..",
+            "....",
+            "...."}},
+        {"triangle", []dataSet.PrivacyMask{
+            getMask(dataSet.PRIVACY_MASK_PIXELATE, 0, 0, 1, 0, 0, 1)},
+            []string{
+            "PPPP",
+            "PPP.",
+            "PP..",
+            "P..."}},
+        {"outside the frame", []dataSet.PrivacyMask{
+            getMask("", -0.5, 0.5, 0.25, 0.5, 0.25, 1.5, -0.5, 1.5)},
+            []string{
+            "....",
+            "....",
+            "B...",
+            "B..."}},
+        {"black over pixelate", []dataSet.PrivacyMask{topLeft,
+            getMask(dataSet.PRIVACY_MASK_PIXELATE, 0.25, 0.25, 0.75, 0.25,
+                    0.75, 0.75, 0.25, 0.75)},
+            []string{
+            "BB..",
+            "BBP.",
+            ".PP.",
+            "...."}},
+    }
+    for _, test := range tests {
+        bitmap := rasterizePrivacyMasks(test.masks, 4, 4)
+        expected := []byte{}
+        for _, pixel := range strings.Join(test.expected, "") {
+            expected = append(expected, modes[pixel])
+        }
+        if string(bitmap) != string(expected) {
+            t.Errorf("%s: got bitmap %v, expected %v", test.name, bitmap,
+                     expected)
+        }
+    }
+}
@@ -0,0 +1,223 @@
+package RTSPCameraImpl
+
+import (
+    "fmt"
+    "os"
+    "time"
+    "strings"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+)
+
+// Text overlay burned into the frames of the final timelapse. The compacted
+// timelapse is re-encoded through the drawtext filter when the overlay is
+// enabled for the camera, along with the other frame filters of the camera.
+// The capture time changes on every snapshot, which is not linear to the
+// video time as the snapshots are taken at intervals.
+// The capture time and the position of every snapshot in the stitched video
+// are recorded when stitching, and the text is switched at the start of every
+// snapshot by a sendcmd file.
+
+const (
+    OVERLAY_TIME_FORMAT = "2006-01-02 15:04:05 MST"
+    //Separator of the camera name, caption and capture time in the overlay.
+    OVERLAY_TEXT_SEP = " | "
+    //Commands to switch the overlay text, in the timelapse directory.
+    OVERLAY_CMD_FILE_NAME = "overlay.cmd"
+)
+
+//Text overlay parameters of the camera.
+type videoOverlay struct {
+    timestamp bool
+    //Empty when the camera name is not in the overlay.
+    camName string
+    caption string
+    position string
+    fontSize uint64
+    location *time.Location
+}
+
+//Snapshot in the stitched timelapse.
+type overlaySnapshot struct {
+    //End of the snapshot in the stitched video in seconds.
+    End  float64 `json:"End"`
+    //Unix time the snapshot is captured.
+    Time int64   `json:"Time"`
+}
+
+//Return the overlay parameters of the camera.
+func newVideoOverlay(cam *dataSet.Camera) videoOverlay {
+    overlay := videoOverlay{
+        timestamp: cam.OverlayTimestamp,
+        caption: cam.OverlayCaption,
+        position: cam.OverlayPosition,
+        fontSize: cam.OverlayFontSize,
+        location: cam.GetLocation(),
+    }
+    if cam.OverlayCamName {
+        overlay.camName = cam.Name
+    }
+    if overlay.fontSize == 0 {
+        overlay.fontSize = dataSet.CAMERA_DEFAULT_OVERLAY_FONT_SIZE
+    }
+    if len(cam.OverlayTimezone) != 0 {
+        location, err := time.LoadLocation(cam.OverlayTimezone)
+        if err == nil {
+            overlay.location = location
+        }
+    }
+    return overlay
+}
+
+func (overlay *videoOverlay)isEnabled() bool {
+    return overlay.timestamp || len(overlay.camName) != 0 ||
+            len(overlay.caption) != 0
+}
+
+//Return the overlay text of the frames captured at 'captureTime'.
+func (overlay *videoOverlay)getText(captureTime int64) string {
+    parts := []string{}
+    if len(overlay.camName) != 0 {
+        parts = append(parts, overlay.camName)
+    }
+    if len(overlay.caption) != 0 {
+        parts = append(parts, overlay.caption)
+    }
+    if overlay.timestamp {
+        parts = append(parts, time.Unix(captureTime, 0).In(
+                            overlay.location).Format(OVERLAY_TIME_FORMAT))
+    }
+    return strings.Join(parts, OVERLAY_TEXT_SEP)
+}
+
+//Return the drawtext position of the overlay text.
+func (overlay *videoOverlay)getPosition() string {
+    margin := overlay.fontSize / 2
+    x := fmt.Sprintf("w-tw-%d", margin)
+    if overlay.position == dataSet.OVERLAY_TOP_LEFT ||
+        overlay.position == dataSet.OVERLAY_BOTTOM_LEFT {
+        x = fmt.Sprintf("%d", margin)
+    }
+    y := fmt.Sprintf("h-th-%d", margin)
+    if overlay.position == dataSet.OVERLAY_TOP_LEFT ||
+        overlay.position == dataSet.OVERLAY_TOP_RIGHT {
+        y = fmt.Sprintf("%d", margin)
+    }
+    return "x=" + x + ":y=" + y
+}
+
+//Escape the value of a filter option for the option parser.
+func escapeOptionValue(value string) string {
+    return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(value)
+}
+
+//Escape the argument of a command in the sendcmd file.
+func escapeCommandArg(arg string) string {
+    return strings.NewReplacer(`\`, `\\`, `'`, `\'`, ` `, `\ `, `,`, `\,`,
+                               `;`, `\;`).Replace(arg)
+}
+
+//Write the commands to switch the overlay text at the start of every snapshot
+// in the timelapse sped up by 'speed'.
+func (overlay *videoOverlay)writeCommands(cmdFile string,
+                                          snapshots []overlaySnapshot,
+                                          speed float64) error {
+    file, err := os.Create(cmdFile)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    var start float64
+    for _, snapshot := range snapshots {
+        text := "text=" + escapeOptionValue(overlay.getText(snapshot.Time))
+        _, err = fmt.Fprintf(file, "%.3f drawtext reinit %s;\n", start / speed,
+                             escapeCommandArg(text))
+        if err != nil {
+            return err
+        }
+        start = snapshot.End
+    }
+    return nil
+}
+
+//Return the filter graph to burn the overlay into the timelapse. The
+// capture time is switched by the commands in 'cmdFile' when it is set.
+func (overlay *videoOverlay)getFilter(snapshots []overlaySnapshot,
+                                      cmdFile string) string {
+    var captureTime int64
+    if len(snapshots) != 0 {
+        captureTime = snapshots[0].Time
+    }
+    filter := fmt.Sprintf("drawtext=expansion=none:text=%s:fontsize=%d:" +
+                          "fontcolor=white:box=1:boxcolor=black@0.5:" +
+                          "boxborderw=%d:%s",
+                          escapeFilterValue(overlay.getText(captureTime)),
+                          overlay.fontSize, overlay.fontSize / 4,
+                          overlay.getPosition())
+    if len(cmdFile) != 0 {
+        filter = "sendcmd=f=" + escapeFilterValue(cmdFile) + "," + filter
+    }
+    return filter
+}
+
+//Return the snapshots in the order of stitching, with their end time in the
+// stitched video.
+func (camThread *RTSPCameraThread)getOverlaySnapshots(dir string,
+                                    files []os.FileInfo) []overlaySnapshot {
+    log := logging.GetLoggerInstance()
+    snapshots := []overlaySnapshot{}
+    var end float64
+    for _, file := range files {
+        duration, err := camThread.getVideoDuration(dir + "/" + file.Name())
+        if err != nil {
+            log.Error("Failed to read snapshot %s for overlay, err: %s",
+                        file.Name(), err)
+        }
+        end += duration
+        snapshots = append(snapshots, overlaySnapshot{
+                                End: end,
+                                Time: file.ModTime().Unix(),
+                            })
+    }
+    return snapshots
+}
+
+//Return true if the capture times of the snapshots are needed for the
+// overlay.
+func (camThread *RTSPCameraThread)isOverlayTimestamp() bool {
+    camThread.threadLock.RLock()
+    defer camThread.threadLock.RUnlock()
+    return camThread.overlay.timestamp
+}
+
+//Return the filter to burn the overlay into the final timelapse in 'dir'
+// sped up by 'speed', empty if the overlay is not enabled. The commands to
+// switch the capture time are written to 'cmdFile' in 'dir', which must be
+// removed by the caller once rendered.
+func (camThread *RTSPCameraThread)getOverlayFilter(dir string,
+                                    snapshots []overlaySnapshot,
+                                    speed float64) (filter string,
+                                    cmdFile string, err error) {
+    log := logging.GetLoggerInstance()
+    camThread.threadLock.RLock()
+    overlay := camThread.overlay
+    camThread.threadLock.RUnlock()
+    if !overlay.isEnabled() {
+        return "", "", nil
+    }
+    if overlay.timestamp {
+        if len(snapshots) == 0 {
+            return "", "", fmt.Errorf("No capture times to overlay in %s",
+                                      dir)
+        }
+        cmdFile = dir + "/" + OVERLAY_CMD_FILE_NAME
+        err = overlay.writeCommands(cmdFile, snapshots, speed)
+        if err != nil {
+            log.Error("Failed to write overlay commands %s, err: %s", cmdFile,
+                        err)
+            os.Remove(cmdFile)
+            return "", "", err
+        }
+    }
+    return overlay.getFilter(snapshots, cmdFile), cmdFile, nil
+}
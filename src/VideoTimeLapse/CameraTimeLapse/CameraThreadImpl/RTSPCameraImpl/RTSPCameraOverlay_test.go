@@ -0,0 +1,164 @@
+package RTSPCameraImpl
+
+// Test file for validating the text overlay of the timelapse.
+import (
+    "os"
+    "time"
+    "testing"
+    "io/ioutil"
+    "VideoTimeLapse/dataSet"
+)
+
+func TestEscapeOptionValue(t *testing.T) {
+    tests := []struct {
+        name string
+        value string
+        expected string
+    }{
+        {"plain text", "Front yard", "Front yard"},
+        {"time", "12:00:00", `12\:00\:00`},
+        {"quote", "cam's view", `cam\'s view`},
+        {"backslash", `C:\videos`, `C\:\\videos`},
+    }
+    for _, test := range tests {
+        value := escapeOptionValue(test.value)
+        if value != test.expected {
+            t.Errorf("%s: got %q, expected %q", test.name, value,
+                     test.expected)
+        }
+    }
+}
+
+func TestEscapeCommandArg(t *testing.T) {
+    tests := []struct {
+        name string
+        arg string
+        expected string
+    }{
+        {"plain text", "yard", "yard"},
+        {"spaces", "front yard", `front\ yard`},
+        {"separators", "a,b;c", `a\,b\;c`},
+        {"escaped value", `12\:00`, `12\\:00`},
+        {"quote", "cam's", `cam\'s`},
+    }
+    for _, test := range tests {
+        arg := escapeCommandArg(test.arg)
+        if arg != test.expected {
+            t.Errorf("%s: got %q, expected %q", test.name, arg,
+                     test.expected)
+        }
+    }
+}
+
+func TestVideoOverlayGetText(t *testing.T) {
+    kolkata, err := time.LoadLocation("Asia/Kolkata")
+    if err != nil {
+        t.Fatalf("Failed to load location, err: %s", err)
+    }
+    captureTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC).Unix()
+    tests := []struct {
+        name string
+        overlay videoOverlay
+        expected string
+    }{
+        {"timestamp", videoOverlay{timestamp: true, location: time.UTC},
+            "2024-01-01 12:00:00 UTC"},
+        {"timestamp in location", videoOverlay{timestamp: true,
+            location: kolkata}, "2024-01-01 17:30:00 IST"},
+        {"camera name", videoOverlay{camName: "cam1"}, "cam1"},
+        {"caption", videoOverlay{caption: "Front yard"}, "Front yard"},
+        {"all parts", videoOverlay{timestamp: true, camName: "cam1",
+            caption: "Front yard", location: time.UTC},
+            "cam1 | Front yard | 2024-01-01 12:00:00 UTC"},
+        {"nothing", videoOverlay{}, ""},
+    }
+    for _, test := range tests {
+        text := test.overlay.getText(captureTime)
+        if text != test.expected {
+            t.Errorf("%s: got text %q, expected %q", test.name, text,
+                     test.expected)
+        }
+    }
+}
+
+func TestVideoOverlayGetPosition(t *testing.T) {
+    tests := []struct {
+        position string
+        expected string
+    }{
+        {dataSet.OVERLAY_TOP_LEFT, "x=12:y=12"},
+        {dataSet.OVERLAY_TOP_RIGHT, "x=w-tw-12:y=12"},
+        {dataSet.OVERLAY_BOTTOM_LEFT, "x=12:y=h-th-12"},
+        {dataSet.OVERLAY_BOTTOM_RIGHT, "x=w-tw-12:y=h-th-12"},
+    }
+    for _, test := range tests {
+        overlay := videoOverlay{position: test.position, fontSize: 24}
+        position := overlay.getPosition()
+        if position != test.expected {
+            t.Errorf("%s: got position %q, expected %q", test.position,
+                     position, test.expected)
+        }
+    }
+}
+
+func TestVideoOverlayWriteCommands(t *testing.T) {
+    dir, err := ioutil.TempDir("", "overlay")
+    if err != nil {
+        t.Fatalf("Failed to create dir, err: %s", err)
+    }
+    defer os.RemoveAll(dir)
+    captureTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC).Unix()
+    overlay := videoOverlay{timestamp: true, camName: "cam1",
+                            location: time.UTC}
+    snapshots := []overlaySnapshot{
+        {End: 2, Time: captureTime},
+        {End: 4.5, Time: captureTime + 60},
+        {End: 7, Time: captureTime + 120},
+    }
+    //Every snapshot is switched at the end of the one before, in the time
+    // of the sped up video.
+    expected := `0.000 drawtext reinit text=cam1\ |\ ` +
+                    `2024-01-01\ 12\\:00\\:00\ UTC;` + "\n" +
+                `1.000 drawtext reinit text=cam1\ |\ ` +
+                    `2024-01-01\ 12\\:01\\:00\ UTC;` + "\n" +
+                `2.250 drawtext reinit text=cam1\ |\ ` +
+                    `2024-01-01\ 12\\:02\\:00\ UTC;` + "\n"
+    cmdFile := dir + "/" + OVERLAY_CMD_FILE_NAME
+    err = overlay.writeCommands(cmdFile, snapshots, 2)
+    if err != nil {
+        t.Fatalf("Failed to write commands, err: %s", err)
+    }
+    data, err := ioutil.ReadFile(cmdFile)
+    if err != nil {
+        t.Fatalf("Failed to read commands, err: %s", err)
+    }
+    if string(data) != expected {
+        t.Errorf("got commands\n%s\nexpected\n%s", data, expected)
+    }
+}
+
+func TestVideoOverlayGetFilter(t *testing.T) {
+    captureTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC).Unix()
+    overlay := videoOverlay{timestamp: true, location: time.UTC,
+                            position: dataSet.OVERLAY_TOP_LEFT, fontSize: 24}
+    snapshots := []overlaySnapshot{{End: 2, Time: captureTime}}
+    drawtext := `drawtext=expansion=none:text=2024-01-01 12\\:00\\:00 UTC:` +
+                "fontsize=24:fontcolor=white:box=1:boxcolor=black@0.5:" +
+                "boxborderw=6:x=12:y=12"
+    tests := []struct {
+        name string
+        cmdFile string
+        expected string
+    }{
+        {"no commands", "", drawtext},
+        {"commands", "/videos/cam1/overlay.cmd",
+            "sendcmd=f=/videos/cam1/overlay.cmd," + drawtext},
+    }
+    for _, test := range tests {
+        filter := overlay.getFilter(snapshots, test.cmdFile)
+        if filter != test.expected {
+            t.Errorf("%s: got filter %q, expected %q", test.name, filter,
+                     test.expected)
+        }
+    }
+}
@@ -0,0 +1,84 @@
+package RTSPCameraImpl
+
+import (
+    "strings"
+    "unsafe"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+)
+
+// Probe the camera settings by opening the RTSP stream, to catch the wrong
+// address/credentials before the timelapse runs on them.
+
+// #include "videoprobe.h"
+// #include <stdlib.h>
+import "C"
+
+const (
+    //Timeout for the probe to open the stream and receive a keyframe.
+    CAMERA_PROBE_TIMEOUT_SEC = 10
+)
+
+//Report of probing the camera stream.
+type CameraProbeReport struct {
+    Reachable   bool     `json:"Reachable"`
+    AuthOK      bool     `json:"AuthOK"`
+    Codecs      []string `json:"Codecs"`
+    Width       int      `json:"Width"`
+    Height      int      `json:"Height"`
+    FPS         float64  `json:"FPS"`
+    StreamCount int      `json:"StreamCount"`
+    //Time taken to open the stream and to get the first keyframe after
+    // that, -1 if no keyframe is received in timeout.
+    OpenSec     float64  `json:"OpenSec"`
+    FirstKeyframeSec float64 `json:"FirstKeyframeSec"`
+    Error       string   `json:"Error"`
+}
+
+//Return true if the timelapse can be created from the camera stream.
+func (report *CameraProbeReport)IsValid() bool {
+    return report.Reachable && report.AuthOK && report.FirstKeyframeSec >= 0
+}
+
+//Probe the RTSP stream of camera with its address and credentials.
+func ProbeCamera(cam *dataSet.Camera) *CameraProbeReport {
+    log := logging.GetLoggerInstance()
+    rtspOnce.Do(func() {
+        C.vs_setup()
+    })
+    url := getRTSPURL(cam.Ipaddr, cam.Port, cam.UserId, cam.Pwd)
+    inputFormatC := C.CString("rtsp")
+    inputURLC := C.CString(url)
+    defer C.free(unsafe.Pointer(inputFormatC))
+    defer C.free(unsafe.Pointer(inputURLC))
+    var info C.struct_VSProbeInfo
+    res := C.vs_probe_input(inputFormatC, inputURLC,
+                            C.int(CAMERA_PROBE_TIMEOUT_SEC), &info)
+    report := &CameraProbeReport{
+        Reachable: bool(info.reachable),
+        AuthOK: bool(info.auth_ok),
+        Codecs: []string{},
+        Width: int(info.stream.width),
+        Height: int(info.stream.height),
+        FPS: float64(info.stream.fps),
+        StreamCount: int(info.stream_count),
+        OpenSec: float64(info.open_sec),
+        FirstKeyframeSec: float64(info.keyframe_sec),
+    }
+    if codecs := C.GoString(&info.codecs[0]); len(codecs) != 0 {
+        report.Codecs = strings.Split(codecs, ",")
+    }
+    switch {
+    case !report.Reachable:
+        report.Error = "Camera is not reachable"
+    case !report.AuthOK:
+        report.Error = "Camera rejected the credentials"
+    case res != 0:
+        report.Error = "No video stream in camera"
+    case report.FirstKeyframeSec < 0:
+        report.Error = "No keyframe received from camera"
+    }
+    log.Trace("Probed camera %s at %s:%s, valid: %t", cam.Name, cam.Ipaddr,
+                cam.Port, report.IsValid())
+    return report
+}
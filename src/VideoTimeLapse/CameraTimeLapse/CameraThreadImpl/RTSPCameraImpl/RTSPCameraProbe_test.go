@@ -0,0 +1,33 @@
+package RTSPCameraImpl
+
+// Test file for validating the reports of the camera probe.
+import (
+    "testing"
+)
+
+func TestCameraProbeReportIsValid(t *testing.T) {
+    tests := []struct {
+        name string
+        report CameraProbeReport
+        expected bool
+    }{
+        {"keyframe received", CameraProbeReport{Reachable: true,
+            AuthOK: true, FirstKeyframeSec: 1.5}, true},
+        {"keyframe at open", CameraProbeReport{Reachable: true,
+            AuthOK: true, FirstKeyframeSec: 0}, true},
+        {"no keyframe", CameraProbeReport{Reachable: true, AuthOK: true,
+            FirstKeyframeSec: -1}, false},
+        {"auth failed", CameraProbeReport{Reachable: true,
+            FirstKeyframeSec: 1}, false},
+        {"unreachable", CameraProbeReport{AuthOK: true,
+            FirstKeyframeSec: 1}, false},
+        {"empty report", CameraProbeReport{}, false},
+    }
+    for _, test := range tests {
+        valid := test.report.IsValid()
+        if valid != test.expected {
+            t.Errorf("%s: got valid %v, expected %v", test.name, valid,
+                     test.expected)
+        }
+    }
+}
@@ -0,0 +1,290 @@
+package RTSPCameraImpl
+
+import (
+    "os"
+    "sync"
+    "time"
+    "errors"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+)
+
+// Capture profiles render more timelapses of a camera at their own interval,
+// cycle length, output formats and retention. Every profile runs its cycles
+// in a thread of its own, in a directory of the profile in the camera
+// directory. The camera keeps a single stream open in its recorder, and the
+// snapshots of the camera and its profiles are copied from it, instead of
+// connecting to the camera for every snapshot. A recording camera samples the
+// snapshots of all the cycles from the same segments. The scene checks of the
+// live capture are not applied to the snapshots of the shared stream.
+
+// #include "videomux.h"
+// #include <libavcodec/avcodec.h>
+import "C"
+
+const (
+    //Directory in the camera directory for the profile directories.
+    PROFILE_DIR_NAME = "profiles"
+    //Separator of the profile name in the video names of the profile. It is
+    // not in the profile names and in the other video names of the camera,
+    // like the clips and the rollups, so the names cannot collide.
+    PROFILE_VIDEO_SEP = "@"
+    //Time for the recorder to start a snapshot, before it is dropped.
+    STREAM_SNAPSHOT_START_TIMEOUT = 10 * time.Second
+    //Snapshots requested and not yet started by the recorder.
+    STREAM_SNAPSHOT_MAX_PENDING = 16
+)
+
+var errStreamSnapshotDropped = errors.New(
+                                    "Snapshot not started by the recorder")
+
+//Snapshot requested from the shared stream. The error of the snapshot is
+// sent on 'done' once it is written.
+type snapshotRequest struct {
+    file string
+    pkts uint64
+    length time.Duration
+    deadline time.Time
+    done chan error
+}
+
+//Snapshot being written by the recorder.
+type streamSnapshot struct {
+    req *snapshotRequest
+    output *Output
+    waitWrite sync.WaitGroup
+    //Snapshot starts at a keyframe.
+    started bool
+    start time.Time
+    pkts uint64
+}
+
+//Create the threads of the capture profiles of the camera. The threads are
+// run and stopped along with the camera thread.
+//MUST HOLD threadlock before calling this function.
+func (camThread *RTSPCameraThread)initProfileThreads__(
+                                    cam *dataSet.Camera) error {
+    camThread.profiles = nil
+    profiles, err := cam.GetProfiles()
+    if err != nil {
+        return err
+    }
+    for i := range profiles {
+        //Thread is not shared till it is added to the camera thread.
+        profileThread := &RTSPCameraThread{
+            profile: profiles[i].Name,
+            parent: camThread,
+            videoPath: camThread.videoPath + "/" + PROFILE_DIR_NAME + "/" +
+                       profiles[i].Name,
+        }
+        err = profileThread.initCameraThread__(
+                                cam.GetProfileCamera(&profiles[i]), "")
+        if err != nil {
+            return err
+        }
+        profileThread.streamCapture = true
+        camThread.profiles = append(camThread.profiles, profileThread)
+    }
+    return nil
+}
+
+//Return the thread keeping the stream of the camera open, the camera thread
+// of a profile thread.
+func (camThread *RTSPCameraThread)getStreamThread() *RTSPCameraThread {
+    if camThread.parent != nil {
+        return camThread.parent
+    }
+    return camThread
+}
+
+//Return the threads of the capture profiles.
+func (camThread *RTSPCameraThread)getProfileThreads() []*RTSPCameraThread {
+    camThread.threadLock.RLock()
+    defer camThread.threadLock.RUnlock()
+    return camThread.profiles
+}
+
+//Return the earliest start of the running cycles of the camera and its
+// profiles.
+func (camThread *RTSPCameraThread)getFirstCycleStart() time.Time {
+    camThread.threadLock.RLock()
+    first := camThread.startTime
+    camThread.threadLock.RUnlock()
+    for _, profileThread := range camThread.getProfileThreads() {
+        profileThread.threadLock.RLock()
+        if profileThread.startTime.Before(first) {
+            first = profileThread.startTime
+        }
+        profileThread.threadLock.RUnlock()
+    }
+    return first
+}
+
+//Capture a snapshot with specific name from the shared stream of the camera.
+//Returns false if the snapshot is not written.
+func (camThread *RTSPCameraThread)captureStreamSnapshot(
+                                    fileName string) (bool, error) {
+    log := logging.GetLoggerInstance()
+    camThread.threadLock.RLock()
+    cycleName := camThread.getCycleName__(camThread.startTime)
+    cycleDir := camThread.videoPath + "/" + cycleName
+    //Snapshot in packets is not longer than the interval, same as the
+    // snapshot in seconds.
+    maxLength := time.Duration(camThread.videoInterval) * time.Second
+    req := &snapshotRequest{
+        file: cycleDir + "/" + fileName,
+        pkts: camThread.snapshotPkts,
+        length: time.Duration(camThread.snapshotSec) * time.Second,
+        deadline: time.Now().Add(STREAM_SNAPSHOT_START_TIMEOUT),
+        done: make(chan error, 1),
+    }
+    //Snapshots of the profiles are recorded by their path in the camera
+    // directory, as the cycles of the camera are named the same way.
+    metaDir := cycleName
+    if camThread.parent != nil {
+        metaDir = PROFILE_DIR_NAME + "/" + camThread.profile + "/" + cycleName
+    }
+    camThread.threadLock.RUnlock()
+    streamThread := camThread.getStreamThread()
+    streamThread.threadLock.RLock()
+    rec := streamThread.recorder
+    streamThread.threadLock.RUnlock()
+    if rec == nil {
+        log.Error("No stream of %s to capture the snapshot", camThread.name)
+        return false, nil
+    }
+    err := os.MkdirAll(cycleDir, 0744)
+    if err != nil {
+        log.Error("Failed to create directory %s", cycleDir)
+        return false, err
+    }
+    camThread.setSnapshotActive(req.file, true)
+    defer camThread.setSnapshotActive(req.file, false)
+    select {
+        case rec.snapshots <- req:
+        default:
+            log.Error("Too many snapshots pending on the stream of %s",
+                      camThread.name)
+            return false, nil
+    }
+    if req.length != 0 {
+        maxLength = req.length
+    }
+    //Recorder blocked on a stalled stream cannot answer the request, the
+    // snapshot is dropped once it should have been written, allowing as much
+    // time for the keyframe it starts at as for the recorder to start it.
+    timeout := time.After(time.Until(req.deadline) +
+                          STREAM_SNAPSHOT_START_TIMEOUT + maxLength)
+    select {
+        case err = <-req.done:
+        case <-timeout:
+            log.Error("Snapshot %s of %s is not written in time", req.file,
+                      camThread.name)
+            err = errStreamSnapshotDropped
+        case <-rec.stop:
+            //Recorder finishes the running snapshots before it stops.
+            select {
+                case err = <-req.done:
+                case <-time.After(RECORDER_CUT_TIMEOUT):
+                    err = errStreamSnapshotDropped
+            }
+    }
+    if err != nil {
+        os.Remove(req.file)
+        return false, err
+    }
+    if camThread.parent == nil {
+        camThread.appendLiveHLS(req.file)
+    }
+    camThread.addSnapshotMeta(metaDir, fileName,
+                              float64(dataSet.SNAPSHOT_NO_CHANGE_SCORE))
+    log.Trace("Created snapshot %s from the stream of %s", req.file,
+              camThread.name)
+    return true, nil
+}
+
+//Open the requested snapshot, it starts at the next keyframe of the stream.
+//Returns nil if the snapshot is not started.
+func (camThread *RTSPCameraThread)startStreamSnapshot(input *Input,
+                                    req *snapshotRequest) *streamSnapshot {
+    if time.Now().After(req.deadline) {
+        //Snapshot is late for its interval, the stream was reconnecting.
+        req.done <- errStreamSnapshotDropped
+        return nil
+    }
+    output := camThread.openMP4Output(req.file, input)
+    if output == nil || output.vsOutput == nil {
+        req.done <- errStreamSnapshotDropped
+        return nil
+    }
+    return &streamSnapshot{
+        req: req,
+        output: output,
+    }
+}
+
+//Write the packet to the running snapshots. The snapshots of the requested
+// length are finished.
+//Returns the snapshots that are still running.
+func (camThread *RTSPCameraThread)addSnapshotPacket(
+                                    snapshots []*streamSnapshot,
+                                    input *Input, pkt *C.AVPacket,
+                                    keyframe bool) []*streamSnapshot {
+    running := snapshots[:0]
+    for _, snapshot := range snapshots {
+        if !snapshot.started {
+            if !keyframe {
+                running = append(running, snapshot)
+                continue
+            }
+            snapshot.started = true
+            snapshot.start = time.Now()
+        }
+        camThread.writePacket(input, snapshot.output, pkt,
+                              &snapshot.waitWrite)
+        snapshot.pkts++
+        if snapshot.req.length != 0 {
+            if time.Since(snapshot.start) >= snapshot.req.length {
+                camThread.finishStreamSnapshot(snapshot)
+                continue
+            }
+        } else if snapshot.pkts >= snapshot.req.pkts {
+            camThread.finishStreamSnapshot(snapshot)
+            continue
+        }
+        running = append(running, snapshot)
+    }
+    return running
+}
+
+//Close the snapshot and answer its request. The snapshot cut short by the
+// stream is kept, if it has any packets.
+func (camThread *RTSPCameraThread)finishStreamSnapshot(
+                                    snapshot *streamSnapshot) {
+    camThread.closeOutput(snapshot.output, &snapshot.waitWrite)
+    if snapshot.pkts == 0 {
+        snapshot.req.done <- errStreamSnapshotDropped
+        return
+    }
+    snapshot.req.done <- nil
+}
+
+//Finish the running snapshots, as the stream is closed.
+func (camThread *RTSPCameraThread)closeStreamSnapshots(
+                                    snapshots []*streamSnapshot) {
+    for _, snapshot := range snapshots {
+        camThread.finishStreamSnapshot(snapshot)
+    }
+}
+
+//Drop the snapshots requested while the stream is not open.
+func (camThread *RTSPCameraThread)dropStreamSnapshots(rec *segmentRecorder) {
+    for {
+        select {
+            case req := <-rec.snapshots:
+                req.done <- errStreamSnapshotDropped
+            default:
+                return
+        }
+    }
+}
@@ -0,0 +1,145 @@
+package RTSPCameraImpl
+
+import (
+    "math"
+    "time"
+    "errors"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+)
+
+// Quality check rejects the snapshots of blank, black, washed out and corrupt
+// frames before they are written. The first frame of the snapshot is decoded
+// the same as the adaptive capture, and its luma is checked against the
+// thresholds of the camera. A rejected snapshot can be retried in the same
+// interval, as the glitches in the stream are usually short.
+
+// #include "videomask.h"
+import "C"
+
+//Snapshot is not written as its first frame is rejected by the quality check.
+var errSnapshotRejected = errors.New("Snapshot rejected by quality check")
+
+//Thresholds of the quality check, read from the camera thread.
+type qualityParams struct {
+    minMeanLuma float64
+    maxMeanLuma float64
+    minLumaDeviation float64
+}
+
+//Return the mean and standard deviation of the gray pixels, leaving out the
+// masked pixels. 'ok' is false if all the pixels are masked.
+func getLumaStats(gray []byte, bitmap []byte) (mean float64,
+                                               deviation float64, ok bool) {
+    var sum, sumSquare float64
+    count := 0
+    for i := range gray {
+        if bitmap != nil && bitmap[i] != C.VS_MASK_NONE {
+            continue
+        }
+        value := float64(gray[i])
+        sum += value
+        sumSquare += value * value
+        count++
+    }
+    if count == 0 {
+        return 0, 0, false
+    }
+    mean = sum / float64(count)
+    variance := sumSquare / float64(count) - mean * mean
+    deviation = math.Sqrt(math.Max(variance, 0))
+    return mean, deviation, true
+}
+
+//Return the reason to reject the scene of the luma stats, empty if the scene
+// is good. A uniform scene is rejected as such even when it is dark or bright.
+func (params *qualityParams)getRejectReason(mean float64,
+                                            deviation float64) string {
+    if deviation < params.minLumaDeviation {
+        return dataSet.SNAPSHOT_REJECT_UNIFORM
+    }
+    if mean < params.minMeanLuma {
+        return dataSet.SNAPSHOT_REJECT_DARK
+    }
+    if mean > params.maxMeanLuma {
+        return dataSet.SNAPSHOT_REJECT_BRIGHT
+    }
+    return ""
+}
+
+//Return the reason to reject the decoded scene, empty if the scene is good.
+// The masked regions are left out, as they are blacked out or flattened.
+func (camThread *RTSPCameraThread)checkSceneQuality(check *sceneCheck,
+                                    masks []dataSet.PrivacyMask) string {
+    log := logging.GetLoggerInstance()
+    if check.gray == nil {
+        return dataSet.SNAPSHOT_REJECT_UNDECODABLE
+    }
+    camThread.threadLock.RLock()
+    params := qualityParams{
+        minMeanLuma: float64(camThread.minMeanLuma),
+        maxMeanLuma: float64(camThread.maxMeanLuma),
+        minLumaDeviation: float64(camThread.minLumaDeviation),
+    }
+    camThread.threadLock.RUnlock()
+    var bitmap []byte
+    if len(masks) != 0 {
+        bitmap = rasterizePrivacyMasks(masks, SCENE_GRAY_WIDTH,
+                                       SCENE_GRAY_HEIGHT)
+    }
+    mean, deviation, ok := getLumaStats(check.gray, bitmap)
+    if !ok {
+        //Nothing is visible to check.
+        return ""
+    }
+    log.Trace("Scene luma of %s, mean: %.1f, deviation: %.1f",
+              camThread.name, mean, deviation)
+    return params.getRejectReason(mean, deviation)
+}
+
+//Count the rejected snapshot in the capture status of the camera.
+func (camThread *RTSPCameraThread)addRejectedSnapshot(reason string) {
+    log := logging.GetLoggerInstance()
+    dataObj := dataSetImpl.GetDataSetObj()
+    status, err := dataObj.GetCameraCaptureStatus(camThread.name)
+    if err != nil {
+        status = &dataSet.CameraCaptureStatus{CamName: camThread.name}
+    }
+    status.RejectedSnapshots++
+    status.LastRejectReason = reason
+    status.LastRejectTime = time.Now().Unix()
+    err = dataObj.UpdateCameraCaptureStatus(status)
+    if err != nil {
+        log.Error("Failed to update capture status of %s, err: %s",
+                  camThread.name, err)
+    }
+}
+
+//Capture the snapshot, retrying it in the same interval when it is rejected
+// by the quality check. The retries are spread over the interval.
+//Returns false if the snapshot is not kept.
+func (camThread *RTSPCameraThread)captureSnapshot(fileName string) (bool,
+                                                  error) {
+    log := logging.GetLoggerInstance()
+    camThread.threadLock.RLock()
+    retries := camThread.qualityRetries
+    retryDelay := time.Duration(camThread.videoInterval) * time.Second /
+                  time.Duration(retries + 1)
+    camThread.threadLock.RUnlock()
+    if retryDelay < time.Second {
+        retryDelay = time.Second
+    }
+    for attempt := uint64(0); ; attempt++ {
+        kept, err := camThread.createVideoSnapshot(fileName)
+        if err != errSnapshotRejected {
+            return kept, err
+        }
+        if attempt >= retries {
+            return false, nil
+        }
+        log.Trace("Retrying the rejected snapshot of %s in %s",
+                  camThread.name, retryDelay)
+        time.Sleep(retryDelay)
+    }
+}
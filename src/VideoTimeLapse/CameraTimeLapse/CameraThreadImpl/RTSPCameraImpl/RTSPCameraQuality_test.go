@@ -0,0 +1,107 @@
+package RTSPCameraImpl
+
+// Test file for validating the quality check of the snapshots.
+import (
+    "testing"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+)
+
+func TestGetLumaStats(t *testing.T) {
+    black := dataSet.PrivacyMask{Mode: dataSet.PRIVACY_MASK_BLACK}
+    masked := getMaskPixelMode(&black)
+    tests := []struct {
+        name string
+        gray []byte
+        bitmap []byte
+        mean float64
+        deviation float64
+        ok bool
+    }{
+        {"uniform", []byte{100, 100, 100, 100}, nil, 100, 0, true},
+        {"contrast", []byte{0, 200, 0, 200}, nil, 100, 100, true},
+        {"masked bright pixels", []byte{0, 200, 0, 200},
+            []byte{0, masked, 0, masked}, 0, 0, true},
+        {"all masked", []byte{0, 200}, []byte{masked, masked}, 0, 0, false},
+        {"empty", []byte{}, nil, 0, 0, false},
+    }
+    for _, test := range tests {
+        mean, deviation, ok := getLumaStats(test.gray, test.bitmap)
+        if mean != test.mean || deviation != test.deviation ||
+            ok != test.ok {
+            t.Errorf("%s: got %.1f/%.1f/%v, expected %.1f/%.1f/%v",
+                     test.name, mean, deviation, ok, test.mean,
+                     test.deviation, test.ok)
+        }
+    }
+}
+
+func TestQualityParamsGetRejectReason(t *testing.T) {
+    params := qualityParams{
+        minMeanLuma: 20,
+        maxMeanLuma: 235,
+        minLumaDeviation: 5,
+    }
+    tests := []struct {
+        name string
+        mean float64
+        deviation float64
+        expected string
+    }{
+        {"good scene", 120, 40, ""},
+        {"at the limits", 20, 5, ""},
+        {"at the bright limit", 235, 5, ""},
+        {"uniform", 120, 4.9, dataSet.SNAPSHOT_REJECT_UNIFORM},
+        {"uniform black", 0, 0, dataSet.SNAPSHOT_REJECT_UNIFORM},
+        {"dark", 19.9, 10, dataSet.SNAPSHOT_REJECT_DARK},
+        {"bright", 235.1, 10, dataSet.SNAPSHOT_REJECT_BRIGHT},
+    }
+    for _, test := range tests {
+        reason := params.getRejectReason(test.mean, test.deviation)
+        if reason != test.expected {
+            t.Errorf("%s: got reason %q, expected %q", test.name, reason,
+                     test.expected)
+        }
+    }
+}
+
+func TestCheckSceneQuality(t *testing.T) {
+    logger := new(logging.Logging)
+    logger.LogInitSingleton(logging.LogLeveltype(logging.Trace), "")
+    camThread := &RTSPCameraThread{
+        minMeanLuma: 20,
+        maxMeanLuma: 235,
+        minLumaDeviation: 5,
+    }
+    //Left half of the scene is black and the right half is bright.
+    gray := make([]byte, SCENE_GRAY_WIDTH * SCENE_GRAY_HEIGHT)
+    for i := range gray {
+        if i % SCENE_GRAY_WIDTH >= SCENE_GRAY_WIDTH / 2 {
+            gray[i] = 240
+        }
+    }
+    getMask := func(x1 float64, x2 float64) []dataSet.PrivacyMask {
+        return []dataSet.PrivacyMask{{Points: []dataSet.MaskPoint{
+            {X: x1, Y: 0}, {X: x2, Y: 0}, {X: x2, Y: 1}, {X: x1, Y: 1}}}}
+    }
+    tests := []struct {
+        name string
+        gray []byte
+        masks []dataSet.PrivacyMask
+        expected string
+    }{
+        {"undecodable", nil, nil, dataSet.SNAPSHOT_REJECT_UNDECODABLE},
+        {"good scene", gray, nil, ""},
+        {"bright half masked", gray, getMask(0.5, 1),
+            dataSet.SNAPSHOT_REJECT_UNIFORM},
+        {"all masked", gray, getMask(0, 1), ""},
+    }
+    for _, test := range tests {
+        reason := camThread.checkSceneQuality(&sceneCheck{gray: test.gray},
+                                              test.masks)
+        if reason != test.expected {
+            t.Errorf("%s: got reason %q, expected %q", test.name, reason,
+                     test.expected)
+        }
+    }
+}
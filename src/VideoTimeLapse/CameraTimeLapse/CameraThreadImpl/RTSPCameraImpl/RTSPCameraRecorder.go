@@ -0,0 +1,488 @@
+package RTSPCameraImpl
+
+import (
+    "fmt"
+    "os"
+    "sort"
+    "sync"
+    "time"
+    "regexp"
+    "io/ioutil"
+    "path/filepath"
+    "VideoTimeLapse/logging"
+)
+
+// Continuous recording keeps the stream of the camera open and copies every
+// video packet to fixed length segments, the same as the snapshots are
+// written. A segment always starts with a keyframe, so it can be sampled from
+// any point. The segments are kept for the retention hours of the camera,
+// apart from the timelapse cycles.
+// The snapshots of a recording camera are sampled from the segments once the
+// cycle is complete, so a snapshot is never missed while the stream is
+// reconnected, as long as the footage of the interval is recorded. The scene
+// checks of the live capture are not applied to the sampled snapshots.
+// The same stream feeds the clip buffer and the snapshots of the camera and
+// its capture profiles, so the stream is kept open even if it is not
+// recording.
+
+// #include "videomux.h"
+// #include <libavcodec/avcodec.h>
+import "C"
+
+const (
+    //Directory in the camera directory for the recorded segments.
+    SEGMENT_DIR_NAME = "segments"
+    //Suffix of the segment that is being written.
+    SEGMENT_PART_SUFFIX = ".part"
+    //Delay before reconnecting to the stream after a failure.
+    RECORDER_RETRY_DELAY = 2 * time.Second
+    //Time to wait for the running segment to close at the end of a cycle.
+    RECORDER_CUT_TIMEOUT = 30 * time.Second
+)
+
+//Segments are named by the UTC time of their first packet, so the names are
+// unique when the local clock is set back.
+var segmentFileRegex = regexp.MustCompile(`^[0-9]{14}\.mp4$`)
+
+//Recorder of the continuous segments of a camera.
+type segmentRecorder struct {
+    stop chan bool
+    //Requests to close the running segment, answered once it is closed.
+    cut chan chan bool
+    //Clips triggered on the camera.
+    clips chan *clipRequest
+    //Snapshots of the camera and its profiles.
+    snapshots chan *snapshotRequest
+    join sync.WaitGroup
+}
+
+//Segment being written by the recorder.
+type recordedSegment struct {
+    output *Output
+    waitWrite sync.WaitGroup
+    file string
+    start time.Time
+}
+
+//Recorded segment available for sampling.
+type segmentFile struct {
+    path string
+    start time.Time
+    end time.Time
+}
+
+//Return the directory of the recorded segments, the segments of the camera
+// are shared by its profiles.
+func (camThread *RTSPCameraThread)getSegmentDir() string {
+    streamThread := camThread.getStreamThread()
+    streamThread.threadLock.RLock()
+    defer streamThread.threadLock.RUnlock()
+    return streamThread.videoPath + "/" + SEGMENT_DIR_NAME
+}
+
+//Start recording the segments in background.
+//MUST HOLD threadlock before calling this function.
+func (camThread *RTSPCameraThread)startRecorder__() {
+    rec := &segmentRecorder{
+        stop: make(chan bool),
+        cut: make(chan chan bool, 1),
+        clips: make(chan *clipRequest, CLIP_MAX_PENDING),
+        snapshots: make(chan *snapshotRequest, STREAM_SNAPSHOT_MAX_PENDING),
+    }
+    rec.join.Add(1)
+    camThread.recorder = rec
+    go camThread.runRecorder(rec)
+}
+
+//Stop the recorder and wait for its running segment to close.
+func (camThread *RTSPCameraThread)stopRecorder() {
+    camThread.threadLock.Lock()
+    rec := camThread.recorder
+    camThread.recorder = nil
+    camThread.threadLock.Unlock()
+    if rec == nil {
+        return
+    }
+    close(rec.stop)
+    rec.join.Wait()
+}
+
+//Close the running segment, so the footage till now can be sampled.
+//Returns false if the segment is not closed in time.
+func (camThread *RTSPCameraThread)cutSegment() bool {
+    streamThread := camThread.getStreamThread()
+    streamThread.threadLock.RLock()
+    rec := streamThread.recorder
+    streamThread.threadLock.RUnlock()
+    if rec == nil {
+        return false
+    }
+    done := make(chan bool)
+    select {
+        case rec.cut <- done:
+        default:
+            //A cut is pending already.
+            return false
+    }
+    select {
+        case <-done:
+            return true
+        case <-time.After(RECORDER_CUT_TIMEOUT):
+            return false
+    }
+}
+
+func (rec *segmentRecorder)isStopped() bool {
+    select {
+        case <-rec.stop:
+            return true
+        default:
+            return false
+    }
+}
+
+//Record the segments till the recorder is stopped, reconnecting to the
+// stream on failures.
+func (camThread *RTSPCameraThread)runRecorder(rec *segmentRecorder) {
+    log := logging.GetLoggerInstance()
+    defer rec.join.Done()
+    log.Trace("Starting the recorder of %s", camThread.name)
+    for !rec.isStopped() {
+        camThread.recordSegments(rec)
+        camThread.dropStreamSnapshots(rec)
+        select {
+            case <-rec.stop:
+            case <-time.After(RECORDER_RETRY_DELAY):
+        }
+    }
+    log.Trace("Stopped the recorder of %s", camThread.name)
+}
+
+//Return the file name of the segment starting at 'start'.
+func getSegmentName(start time.Time) string {
+    return start.UTC().Format(TIME_DIR_FORMAT) + ".mp4"
+}
+
+//Open the segment starting at 'start' to copy the packets of the input.
+func (camThread *RTSPCameraThread)openSegment(input *Input,
+                                    start time.Time) *recordedSegment {
+    log := logging.GetLoggerInstance()
+    segmentDir := camThread.getSegmentDir()
+    err := os.MkdirAll(segmentDir, 0744)
+    if err != nil {
+        log.Error("Failed to create segment directory %s, err: %s",
+                  segmentDir, err)
+        return nil
+    }
+    file := segmentDir + "/" + getSegmentName(start)
+    output := camThread.openMP4Output(file + SEGMENT_PART_SUFFIX, input)
+    if output == nil || output.vsOutput == nil {
+        return nil
+    }
+    return &recordedSegment{
+        output: output,
+        file: file,
+        start: start,
+    }
+}
+
+//Finish writing the segment and make it available for sampling.
+func (camThread *RTSPCameraThread)closeSegment(segment *recordedSegment) {
+    log := logging.GetLoggerInstance()
+    if segment == nil {
+        return
+    }
+    camThread.closeOutput(segment.output, &segment.waitWrite)
+    err := os.Rename(segment.file + SEGMENT_PART_SUFFIX, segment.file)
+    if err != nil {
+        log.Error("Failed to finish segment %s, err: %s", segment.file, err)
+        return
+    }
+    log.Trace("Recorded segment %s", segment.file)
+    camThread.purgeExpiredSegments()
+}
+
+//Copy the packets of the stream to the segments, the clips and the snapshots
+// till the stream fails or the recorder is stopped. The clip buffer is not
+// kept across the reconnects, as the packets of different sessions cannot be
+// mixed.
+func (camThread *RTSPCameraThread)recordSegments(rec *segmentRecorder) {
+    log := logging.GetLoggerInstance()
+    camThread.threadLock.RLock()
+    url := getRTSPURL(camThread.ip, camThread.port, camThread.uname,
+                      camThread.pwd)
+    recording := camThread.recording
+    segmentLen := time.Duration(camThread.segmentSec) * time.Second
+    clips := &clipBuffer{
+        length: time.Duration(camThread.clipBufferSec) * time.Second,
+    }
+    camThread.threadLock.RUnlock()
+    input := camThread.openInput("rtsp", url)
+    if input == nil || input.vsInput == nil {
+        log.Error("Failed to open the stream of %s to record",
+                  camThread.name)
+        return
+    }
+    defer camThread.destroyInput(input)
+    var segment *recordedSegment
+    cuts := []chan bool{}
+    snapshots := []*streamSnapshot{}
+    defer func() {
+        camThread.closeSegment(segment)
+        for _, done := range cuts {
+            close(done)
+        }
+        camThread.closeClips(clips)
+        camThread.closeStreamSnapshots(snapshots)
+    }()
+    for !rec.isStopped() {
+        select {
+            case done := <-rec.cut:
+                cuts = append(cuts, done)
+            case req := <-rec.clips:
+                camThread.startClip(clips, input, req)
+            case req := <-rec.snapshots:
+                snapshot := camThread.startStreamSnapshot(input, req)
+                if snapshot != nil {
+                    snapshots = append(snapshots, snapshot)
+                }
+            default:
+        }
+        var pkt C.AVPacket
+        readRes := C.vs_read_packet(input.vsInput, &pkt, C.bool(false))
+        if readRes == -1 {
+            log.Error("Failed to read the stream of %s, reconnecting",
+                      camThread.name)
+            return
+        }
+        if readRes == 0 {
+            continue
+        }
+        keyframe := pkt.flags & C.AV_PKT_FLAG_KEY != 0
+        camThread.addClipPacket(clips, input, &pkt, keyframe)
+        snapshots = camThread.addSnapshotPacket(snapshots, input, &pkt,
+                                                keyframe)
+        if !recording {
+            C.av_packet_unref(&pkt)
+            continue
+        }
+        //Segments are closed only at a keyframe, so the next one starts
+        // with it.
+        if segment != nil && keyframe &&
+            (time.Since(segment.start) >= segmentLen || len(cuts) != 0) {
+            camThread.closeSegment(segment)
+            segment = nil
+            for _, done := range cuts {
+                close(done)
+            }
+            cuts = []chan bool{}
+        }
+        if segment == nil && keyframe {
+            segment = camThread.openSegment(input, time.Now())
+            if segment == nil {
+                C.av_packet_unref(&pkt)
+                return
+            }
+        }
+        if segment != nil {
+            camThread.writePacket(input, segment.output, &pkt,
+                                  &segment.waitWrite)
+        }
+        C.av_packet_unref(&pkt)
+    }
+}
+
+//Return the recorded segments sorted by their start time. A segment ends at
+// its last write.
+func getRecordedSegments(segmentDir string) ([]segmentFile, error) {
+    files, err := ioutil.ReadDir(segmentDir)
+    if err != nil {
+        return nil, err
+    }
+    segments := []segmentFile{}
+    for _, file := range files {
+        if file.IsDir() || !segmentFileRegex.MatchString(file.Name()) {
+            continue
+        }
+        start, err := time.ParseInLocation(TIME_DIR_FORMAT,
+                            file.Name()[:len(TIME_DIR_FORMAT)], time.UTC)
+        if err != nil {
+            continue
+        }
+        segments = append(segments, segmentFile{
+                            path: segmentDir + "/" + file.Name(),
+                            start: start,
+                            end: file.ModTime(),
+                        })
+    }
+    sort.Slice(segments, func(i, j int) bool {
+        return segments[i].start.Before(segments[j].start)
+    })
+    return segments, nil
+}
+
+//Delete the segments older than the retention of the camera. The segments
+// of the running cycles of the camera and its profiles are kept, as they are
+// not sampled yet. The segments left unfinished by a failure cannot be read,
+// and are deleted as well.
+//Called by the recorder, when no segment is being written.
+func (camThread *RTSPCameraThread)purgeExpiredSegments() {
+    log := logging.GetLoggerInstance()
+    cycleStart := camThread.getFirstCycleStart()
+    camThread.threadLock.RLock()
+    segmentDir := camThread.videoPath + "/" + SEGMENT_DIR_NAME
+    cutoff := time.Now().Add(-time.Duration(camThread.segmentRetentionHours) *
+                             time.Hour)
+    if cycleStart.Before(cutoff) {
+        cutoff = cycleStart
+    }
+    camThread.threadLock.RUnlock()
+    files, err := ioutil.ReadDir(segmentDir)
+    if err != nil {
+        log.Error("Failed to read segment directory %s, err: %s",
+                  segmentDir, err)
+        return
+    }
+    for _, file := range files {
+        expired := filepath.Ext(file.Name()) == SEGMENT_PART_SUFFIX ||
+                   (segmentFileRegex.MatchString(file.Name()) &&
+                    file.ModTime().Before(cutoff))
+        if !expired {
+            continue
+        }
+        err = os.Remove(segmentDir + "/" + file.Name())
+        if err != nil {
+            log.Error("Failed to delete segment %s, err: %s", file.Name(),
+                      err)
+        }
+    }
+}
+
+//Return the segment and the offset in it to sample at 'sampleTime'. When the
+// time is not recorded, as the stream was reconnecting, the start of the
+// next segment is sampled if it is before 'limit'.
+func findSegmentSample(segments []segmentFile, sampleTime time.Time,
+                       limit time.Time) (*segmentFile, float64) {
+    for i := range segments {
+        segment := &segments[i]
+        if segment.start.After(sampleTime) {
+            if segment.start.Before(limit) {
+                return segment, 0
+            }
+            return nil, 0
+        }
+        if sampleTime.Before(segment.end) {
+            return segment, sampleTime.Sub(segment.start).Seconds()
+        }
+    }
+    return nil, 0
+}
+
+//Copy a snapshot clip from the segment at 'offset' seconds to 'outputFile'.
+func (camThread *RTSPCameraThread)sampleSegment(segment *segmentFile,
+                                    offset float64, outputFile string) error {
+    camThread.threadLock.RLock()
+    snapshotPkts := camThread.snapshotPkts
+    snapshotSec := float64(camThread.snapshotSec)
+    camThread.threadLock.RUnlock()
+    input := camThread.openInput("mp4", segment.path)
+    if input == nil {
+        return fmt.Errorf("Failed to open segment %s", segment.path)
+    }
+    defer camThread.destroyInput(input)
+    if C.vs_seek_input(input.vsInput, C.double(offset)) != 0 {
+        return fmt.Errorf("Failed to seek segment %s to %.1f", segment.path,
+                          offset)
+    }
+    output := camThread.openMP4Output(outputFile, input)
+    if output == nil || output.vsOutput == nil {
+        return fmt.Errorf("Failed to open snapshot %s", outputFile)
+    }
+    var waitWrite sync.WaitGroup
+    numPkts := uint64(0)
+    clipStart := float64(-1)
+    for {
+        var pkt C.AVPacket
+        readRes := C.vs_read_packet(input.vsInput, &pkt, C.bool(false))
+        if readRes == -1 {
+            //Clip ends with the segment.
+            break
+        }
+        if readRes == 0 {
+            continue
+        }
+        endTime := float64(C.vs_packet_end_time(input.vsInput, &pkt))
+        if clipStart < 0 {
+            clipStart = endTime
+        }
+        camThread.writePacket(input, output, &pkt, &waitWrite)
+        C.av_packet_unref(&pkt)
+        numPkts++
+        if snapshotSec != 0 {
+            if endTime - clipStart >= snapshotSec {
+                break
+            }
+        } else if numPkts >= snapshotPkts {
+            break
+        }
+    }
+    camThread.closeOutput(output, &waitWrite)
+    if numPkts == 0 {
+        os.Remove(outputFile)
+        return fmt.Errorf("No packets to sample in %s at %.1f", segment.path,
+                          offset)
+    }
+    return nil
+}
+
+//Sample the snapshots of the cycle in 'cycleDir' from the recorded segments,
+// one at every interval till unix time 'upTo'. The snapshots are named by
+// their interval and dated to their sample time, so sampling a cycle again
+// only adds the missing snapshots.
+func (camThread *RTSPCameraThread)sampleCycle(cycleDir string,
+                                              upTo int64) error {
+    log := logging.GetLoggerInstance()
+    cycleStart, err := camThread.parseCycleName(filepath.Base(cycleDir))
+    if err != nil {
+        return err
+    }
+    camThread.threadLock.RLock()
+    interval := time.Duration(camThread.videoInterval) * time.Second
+    camThread.threadLock.RUnlock()
+    if interval == 0 {
+        return fmt.Errorf("Invalid snapshot interval to sample %s", cycleDir)
+    }
+    segments, err := getRecordedSegments(camThread.getSegmentDir())
+    if err != nil {
+        return err
+    }
+    err = os.MkdirAll(cycleDir, 0744)
+    if err != nil {
+        return err
+    }
+    end := time.Unix(upTo, 0)
+    index := 1
+    for sampleTime := cycleStart.Add(interval); !sampleTime.After(end);
+        sampleTime = sampleTime.Add(interval) {
+        snapshotFile := fmt.Sprintf("%s/%d.mp4", cycleDir, index)
+        index++
+        if _, err = os.Stat(snapshotFile); err == nil {
+            continue
+        }
+        segment, offset := findSegmentSample(segments, sampleTime,
+                                             sampleTime.Add(interval))
+        if segment == nil {
+            log.Info("No footage of %s recorded at %s to sample",
+                     camThread.name, sampleTime.Format(time.RFC3339))
+            continue
+        }
+        err = camThread.sampleSegment(segment, offset, snapshotFile)
+        if err != nil {
+            log.Error("Failed to sample snapshot %s, err: %s", snapshotFile,
+                      err)
+            continue
+        }
+        os.Chtimes(snapshotFile, sampleTime, sampleTime)
+    }
+    return nil
+}
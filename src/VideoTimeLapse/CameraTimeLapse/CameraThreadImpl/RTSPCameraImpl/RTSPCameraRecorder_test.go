@@ -0,0 +1,115 @@
+package RTSPCameraImpl
+
+// Test file for validating the sampling of the recorded segments.
+import (
+    "os"
+    "time"
+    "testing"
+    "io/ioutil"
+)
+
+func TestFindSegmentSample(t *testing.T) {
+    base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+    at := func(sec int) time.Time {
+        return base.Add(time.Duration(sec) * time.Second)
+    }
+    //Stream was reconnecting from 120 to 150 seconds.
+    segments := []segmentFile{
+        {path: "seg0.mp4", start: at(0), end: at(60)},
+        {path: "seg1.mp4", start: at(60), end: at(120)},
+        {path: "seg2.mp4", start: at(150), end: at(210)},
+    }
+    tests := []struct {
+        name string
+        sampleTime time.Time
+        limit time.Time
+        path string
+        offset float64
+    }{
+        {"start of first segment", at(0), at(60), "seg0.mp4", 0},
+        {"inside a segment", at(75), at(135), "seg1.mp4", 15},
+        {"end of a segment", at(60), at(120), "seg1.mp4", 0},
+        {"gap, next segment in limit", at(130), at(190), "seg2.mp4", 0},
+        {"gap, next segment after limit", at(130), at(140), "", 0},
+        {"gap, next segment at limit", at(130), at(150), "", 0},
+        {"before the segments", at(-30), at(30), "seg0.mp4", 0},
+        {"after the segments", at(210), at(270), "", 0},
+    }
+    for _, test := range tests {
+        segment, offset := findSegmentSample(segments, test.sampleTime,
+                                             test.limit)
+        path := ""
+        if segment != nil {
+            path = segment.path
+        }
+        if path != test.path || offset != test.offset {
+            t.Errorf("%s: got %q at %f, expected %q at %f", test.name, path,
+                     offset, test.path, test.offset)
+        }
+    }
+    if segment, _ := findSegmentSample(nil, at(0), at(60)); segment != nil {
+        t.Errorf("No segments: got segment %s", segment.path)
+    }
+}
+
+func TestGetRecordedSegments(t *testing.T) {
+    london, err := time.LoadLocation("Europe/London")
+    if err != nil {
+        t.Fatalf("Failed to load location, err: %s", err)
+    }
+    segmentDir, err := ioutil.TempDir("", "segments")
+    if err != nil {
+        t.Fatalf("Failed to create segment dir, err: %s", err)
+    }
+    defer os.RemoveAll(segmentDir)
+    //Both segments start at 01:30 local time, before and after the clock is
+    // set back at the end of the summer time.
+    first := time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC).In(london)
+    second := first.Add(time.Hour)
+    if first.Format(TIME_DIR_FORMAT) != second.Format(TIME_DIR_FORMAT) {
+        t.Fatalf("Segments %s and %s are not in the same local time",
+                 first, second)
+    }
+    starts := []time.Time{second, first}
+    for _, start := range starts {
+        file := segmentDir + "/" + getSegmentName(start)
+        err = ioutil.WriteFile(file, []byte{}, 0644)
+        if err == nil {
+            end := start.Add(time.Hour)
+            err = os.Chtimes(file, end, end)
+        }
+        if err != nil {
+            t.Fatalf("Failed to create segment %s, err: %s", file, err)
+        }
+    }
+    //Unfinished segment and other files are not sampled.
+    part := segmentDir + "/" + getSegmentName(second.Add(time.Hour)) +
+            SEGMENT_PART_SUFFIX
+    for _, file := range []string{part, segmentDir + "/notes.txt"} {
+        err = ioutil.WriteFile(file, []byte{}, 0644)
+        if err != nil {
+            t.Fatalf("Failed to create %s, err: %s", file, err)
+        }
+    }
+    segments, err := getRecordedSegments(segmentDir)
+    if err != nil {
+        t.Fatalf("Failed to get segments, err: %s", err)
+    }
+    if len(segments) != 2 {
+        t.Fatalf("got %d segments, expected 2", len(segments))
+    }
+    for i, start := range []time.Time{first, second} {
+        segment := segments[i]
+        if !segment.start.Equal(start) {
+            t.Errorf("segment %d: got start %s, expected %s", i,
+                     segment.start, start)
+        }
+        if !segment.end.Equal(start.Add(time.Hour)) {
+            t.Errorf("segment %d: got end %s, expected %s", i, segment.end,
+                     start.Add(time.Hour))
+        }
+        if segment.path != segmentDir + "/" + getSegmentName(start) {
+            t.Errorf("segment %d: got path %s", i, segment.path)
+        }
+    }
+}
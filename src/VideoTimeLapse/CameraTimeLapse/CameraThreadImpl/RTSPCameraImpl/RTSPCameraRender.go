@@ -0,0 +1,285 @@
+package RTSPCameraImpl
+
+import (
+    "fmt"
+    "os"
+    "time"
+    "unsafe"
+    "strings"
+    "path/filepath"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+)
+
+// The final timelapse is always created as MP4 by packet copy. The other
+// output formats are re-encoded from the MP4 timelapse using libavfilter.
+// The frame filters of the camera, deflicker and the text overlay, are applied
+// to the MP4 timelapse in a single re-encoding before rendering the other
+// formats, so all the formats carry them.
+
+// #include "videotranscode.h"
+// #include <stdlib.h>
+import "C"
+
+const (
+    FINAL_TIMELAPSE_NAME = "FinalTimeLapse"
+    //Directory in the cycle directory for the stitched and final timelapse
+    // videos.
+    TIMELAPSE_DIR_NAME = "timeLapse"
+)
+
+//Return the final timelapse video file of the cycle in 'format'.
+func getFinalVideoFile(cycleDir string, format string) string {
+    return cycleDir + "/" + TIMELAPSE_DIR_NAME + "/" + FINAL_TIMELAPSE_NAME +
+            "." + format
+}
+
+//Muxer, encoder and filters to render a video format.
+type videoFormatProfile struct {
+    muxer string
+    encoder string
+    //Encoder options in "key=value:key=value" form.
+    encoderOpts string
+    //Default scaling of the frames, replaced by the requested resolution.
+    scale string
+    //Filters applied before encoding the frames.
+    filter string
+}
+
+var videoFormatProfiles = map[string]videoFormatProfile {
+    //MP4 is re-encoded for the frame filters, the snapshots of a masked
+    // camera and re-rendering a timelapse at another resolution.
+    dataSet.VIDEO_FORMAT_MP4 : {
+        muxer: "mp4",
+        encoder: "libx264",
+        encoderOpts: "crf=23:preset=veryfast",
+        scale: "",
+        filter: "format=yuv420p",
+    },
+    //GIF previews are scaled down and use a palette generated from the
+    // video itself for better colors.
+    dataSet.VIDEO_FORMAT_GIF : {
+        muxer: "gif",
+        encoder: "gif",
+        encoderOpts: "",
+        scale: "scale=480:-2:flags=lanczos",
+        filter: "fps=10," +
+                "split[gifa][gifb];[gifa]palettegen[gifp];" +
+                "[gifb][gifp]paletteuse",
+    },
+    dataSet.VIDEO_FORMAT_WEBM : {
+        muxer: "webm",
+        encoder: "libvpx-vp9",
+        encoderOpts: "crf=32:b=0:deadline=good:cpu-used=4",
+        scale: "",
+        filter: "format=yuv420p",
+    },
+}
+
+//Return the filter graph of the profile to render the video at
+// 'width'x'height'. The default scaling of profile is used when both are '0',
+// and the aspect ratio is kept when one of them is '0'.
+func (profile videoFormatProfile)getFilter(width int, height int) string {
+    scale := profile.scale
+    if width != 0 || height != 0 {
+        scaleWidth, scaleHeight := width, height
+        if scaleWidth == 0 {
+            scaleWidth = -2
+        }
+        if scaleHeight == 0 {
+            scaleHeight = -2
+        }
+        scale = fmt.Sprintf("scale=%d:%d:flags=lanczos", scaleWidth,
+                            scaleHeight)
+    }
+    if len(scale) == 0 {
+        return profile.filter
+    }
+    return scale + "," + profile.filter
+}
+
+//Re-encode the 'inputFile' to 'outputFile' as per the format profile, at
+// 'width'x'height' resolution if set.
+func (camThread *RTSPCameraThread)transcodeVideo(inputFile string,
+                                         outputFile string,
+                                         profile videoFormatProfile,
+                                         width int, height int) error {
+    return camThread.transcodeVideoFilter(inputFile, outputFile, profile,
+                                          profile.getFilter(width, height))
+}
+
+//Re-encode the 'inputFile' to 'outputFile' through the filter graph 'filter'
+// instead of the filters of the profile.
+func (camThread *RTSPCameraThread)transcodeVideoFilter(inputFile string,
+                                         outputFile string,
+                                         profile videoFormatProfile,
+                                         filter string) error {
+    inputFormatC := C.CString("mp4")
+    inputURLC := C.CString(inputFile)
+    outputFormatC := C.CString(profile.muxer)
+    outputURLC := C.CString("file:" + outputFile)
+    encoderC := C.CString(profile.encoder)
+    encoderOptsC := C.CString(profile.encoderOpts)
+    filterC := C.CString(filter)
+    res := C.vs_transcode(inputFormatC, inputURLC, outputFormatC, outputURLC,
+                          encoderC, encoderOptsC, filterC, C.bool(false))
+    C.free(unsafe.Pointer(inputFormatC))
+    C.free(unsafe.Pointer(inputURLC))
+    C.free(unsafe.Pointer(outputFormatC))
+    C.free(unsafe.Pointer(outputURLC))
+    C.free(unsafe.Pointer(encoderC))
+    C.free(unsafe.Pointer(encoderOptsC))
+    C.free(unsafe.Pointer(filterC))
+    if res != 0 {
+        return fmt.Errorf("Failed to transcode %s to %s", inputFile,
+                          outputFile)
+    }
+    return nil
+}
+
+//Return the frames to smooth the brightness over for the camera, 0 if the
+// deflicker is disabled.
+func getDeflickerWindow(cam *dataSet.Camera) uint64 {
+    if !cam.Deflicker {
+        return 0
+    }
+    if cam.DeflickerWindow == 0 {
+        return dataSet.CAMERA_DEFAULT_DEFLICKER_WINDOW
+    }
+    return cam.DeflickerWindow
+}
+
+//Return the filter to smooth the brightness of every frame to the average
+// of 'window' frames around it, empty if the deflicker is disabled.
+func (camThread *RTSPCameraThread)getDeflickerFilter() string {
+    camThread.threadLock.RLock()
+    window := camThread.deflickerWindow
+    camThread.threadLock.RUnlock()
+    if window == 0 {
+        return ""
+    }
+    return fmt.Sprintf("deflicker=size=%d:mode=am", window)
+}
+
+//Re-encode the final timelapse sped up by 'speed' through the frame filters
+// of the camera. The deflicker is applied before the overlay, so the overlay
+// text is not counted in the frame brightness.
+//The final timelapse is left as is when it cannot be filtered.
+func (camThread *RTSPCameraThread)filterFinalVideo(finalFile string,
+                                    snapshots []overlaySnapshot,
+                                    speed float64) error {
+    dir := filepath.Dir(finalFile)
+    filters := []string{}
+    if deflicker := camThread.getDeflickerFilter(); len(deflicker) != 0 {
+        filters = append(filters, deflicker)
+    }
+    overlay, cmdFile, err := camThread.getOverlayFilter(dir, snapshots, speed)
+    if len(cmdFile) != 0 {
+        defer os.Remove(cmdFile)
+    }
+    if err != nil {
+        return err
+    }
+    if len(overlay) != 0 {
+        filters = append(filters, overlay)
+    }
+    if len(filters) == 0 {
+        return nil
+    }
+    profile := videoFormatProfiles[dataSet.VIDEO_FORMAT_MP4]
+    filters = append(filters, profile.filter)
+    outputFile := dir + "/" + FINAL_TIMELAPSE_NAME + ".filtered.mp4"
+    err = camThread.transcodeVideoFilter(finalFile, outputFile, profile,
+                                         strings.Join(filters, ","))
+    if err != nil {
+        os.Remove(outputFile)
+        return err
+    }
+    return os.Rename(outputFile, finalFile)
+}
+
+//Return the catalog name of the video of the cycle in 'format'.
+//Videos are named after the timelapse cycle directory and the format, the
+// videos of a profile have the profile name in front, as <profile>@<cycle>.
+func (camThread *RTSPCameraThread)getVideoName(cycleDir string,
+                                               format string) string {
+    name := filepath.Base(cycleDir) + "." + format
+    if len(camThread.profile) != 0 {
+        name = camThread.profile + PROFILE_VIDEO_SEP + name
+    }
+    return name
+}
+
+//Record the rendered video file in the video catalog.
+func (camThread *RTSPCameraThread)addVideoToCatalog(cycleDir string,
+                                    videoFile string, format string,
+                                    startTime time.Time,
+                                    endTime time.Time,
+                                    thumbs videoThumbnails) error {
+    log := logging.GetLoggerInstance()
+    fileInfo, err := os.Stat(videoFile)
+    if err != nil {
+        log.Error("Cannot add video %s to catalog, err: %s", videoFile, err)
+        return err
+    }
+    video := &dataSet.Video{
+        Name: camThread.getVideoName(cycleDir, format),
+        CamName: camThread.name,
+        Path: videoFile,
+        Format: format,
+        Description: fmt.Sprintf("Timelapse from %s to %s",
+                                 startTime.Format(time.RFC3339),
+                                 endTime.Format(time.RFC3339)),
+        StartTime: startTime.Unix(),
+        EndTime: endTime.Unix(),
+        Size: fileInfo.Size(),
+        PosterPath: thumbs.posterFile,
+        ThumbCount: int64(thumbs.count),
+    }
+    dataObj := dataSetImpl.GetDataSetObj()
+    err = dataObj.AddNewVideo(video)
+    if err != nil {
+        log.Error("Failed to add video %s to catalog, err: %s",
+                    video.Name, err)
+        return err
+    }
+    log.Trace("Added video %s of camera %s to catalog", video.Name,
+                camThread.name)
+    return nil
+}
+
+//Render the final MP4 timelapse to all the output formats of the camera and
+// record every format in the video catalog. All the formats share the poster
+// and thumbnails created from the MP4 timelapse.
+func (camThread *RTSPCameraThread)renderOutputFormats(cycleDir string,
+                                    finalFile string, startTime time.Time,
+                                    endTime time.Time) {
+    log := logging.GetLoggerInstance()
+    camThread.threadLock.RLock()
+    formats := camThread.outputFormats
+    camThread.threadLock.RUnlock()
+    dir := filepath.Dir(finalFile)
+    thumbs := camThread.createThumbnails(finalFile)
+    for _, format := range formats {
+        outputFile := finalFile
+        if format != dataSet.VIDEO_FORMAT_MP4 {
+            profile, ok := videoFormatProfiles[format]
+            if !ok {
+                log.Error("Unsupported output format %s for %s", format,
+                            camThread.name)
+                continue
+            }
+            outputFile = dir + "/" + FINAL_TIMELAPSE_NAME + "." + format
+            err := camThread.transcodeVideo(finalFile, outputFile, profile,
+                                            0, 0)
+            if err != nil {
+                log.Error("Failed to render %s timelapse for %s, err: %s",
+                            format, camThread.name, err)
+                continue
+            }
+        }
+        camThread.addVideoToCatalog(cycleDir, outputFile, format, startTime,
+                                    endTime, thumbs)
+    }
+}
@@ -0,0 +1,98 @@
+package RTSPCameraImpl
+
+// Test file for validating the rendering of the output formats and filters.
+import (
+    "testing"
+    "VideoTimeLapse/dataSet"
+)
+
+func TestFormatProfileGetFilter(t *testing.T) {
+    profile := videoFormatProfile{
+        scale: "scale=480:-2:flags=lanczos",
+        filter: "fps=10",
+    }
+    unscaled := videoFormatProfile{
+        filter: "format=yuv420p",
+    }
+    tests := []struct {
+        name string
+        profile videoFormatProfile
+        width int
+        height int
+        expected string
+    }{
+        {"default scale", profile, 0, 0,
+            "scale=480:-2:flags=lanczos,fps=10"},
+        {"width and height", profile, 640, 360,
+            "scale=640:360:flags=lanczos,fps=10"},
+        {"width only", profile, 640, 0,
+            "scale=640:-2:flags=lanczos,fps=10"},
+        {"height only", profile, 0, 360,
+            "scale=-2:360:flags=lanczos,fps=10"},
+        {"no default scale", unscaled, 0, 0, "format=yuv420p"},
+        {"no default scale resized", unscaled, 1280, 0,
+            "scale=1280:-2:flags=lanczos,format=yuv420p"},
+    }
+    for _, test := range tests {
+        filter := test.profile.getFilter(test.width, test.height)
+        if filter != test.expected {
+            t.Errorf("%s: got filter %q, expected %q", test.name, filter,
+                     test.expected)
+        }
+    }
+}
+
+func TestGetVideoName(t *testing.T) {
+    var camThread RTSPCameraThread
+    cycleDir := "/videos/cam1/20260301120000"
+    name := camThread.getVideoName(cycleDir, "gif")
+    if name != "20260301120000.gif" {
+        t.Errorf("Camera video: got name %s", name)
+    }
+    //Profile named as a clip cannot collide with the clip videos.
+    camThread.profile = "clip"
+    name = camThread.getVideoName(cycleDir, "mp4")
+    if name != "clip" + PROFILE_VIDEO_SEP + "20260301120000.mp4" {
+        t.Errorf("Profile video: got name %s", name)
+    }
+}
+
+func TestGetDeflickerWindow(t *testing.T) {
+    tests := []struct {
+        name string
+        cam dataSet.Camera
+        expected uint64
+    }{
+        {"disabled", dataSet.Camera{DeflickerWindow: 9}, 0},
+        {"default window", dataSet.Camera{Deflicker: true},
+            dataSet.CAMERA_DEFAULT_DEFLICKER_WINDOW},
+        {"camera window", dataSet.Camera{Deflicker: true,
+            DeflickerWindow: 9}, 9},
+    }
+    for _, test := range tests {
+        window := getDeflickerWindow(&test.cam)
+        if window != test.expected {
+            t.Errorf("%s: got window %d, expected %d", test.name, window,
+                     test.expected)
+        }
+    }
+}
+
+func TestGetDeflickerFilter(t *testing.T) {
+    tests := []struct {
+        window uint64
+        expected string
+    }{
+        {0, ""},
+        {5, "deflicker=size=5:mode=am"},
+        {129, "deflicker=size=129:mode=am"},
+    }
+    for _, test := range tests {
+        camThread := RTSPCameraThread{deflickerWindow: test.window}
+        filter := camThread.getDeflickerFilter()
+        if filter != test.expected {
+            t.Errorf("%d: got filter %q, expected %q", test.window, filter,
+                     test.expected)
+        }
+    }
+}
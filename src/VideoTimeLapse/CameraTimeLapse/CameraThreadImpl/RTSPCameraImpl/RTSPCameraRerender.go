@@ -0,0 +1,262 @@
+package RTSPCameraImpl
+
+import (
+    "os"
+    "time"
+    "strings"
+    "io/ioutil"
+    "path/filepath"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/jobQueue"
+    "VideoTimeLapse/appErrors"
+)
+
+// Re-rendering of a past timelapse cycle with different output parameters.
+// The snapshots of a cycle are kept after rendering for the retention days of
+// the camera, and a re-render stitches the kept snapshots again. Every
+// re-render is a new video artefact in its own directory next to the cycle
+// directory, so the original timelapse is left as is.
+
+const (
+    //Re-rendered timelapse directory is named as
+    // <cycle-dir>-rerender-<render-time>, next to the cycle directory.
+    RERENDER_SEP = "-rerender-"
+    //Maximum width/height of a re-rendered timelapse.
+    RERENDER_MAX_RESOLUTION = 8192
+)
+
+//Output parameters of a re-rendered timelapse. The zero value of a field
+// keeps the parameter of the original timelapse.
+type RerenderOptions struct {
+    //Speed-up factor applied to the stitched snapshots.
+    SpeedFactor float64 `json:"SpeedFactor"`
+    Format      string  `json:"Format"`
+    //Aspect ratio is kept when only one of width/height is set.
+    Width       int     `json:"Width"`
+    Height      int     `json:"Height"`
+    //Trim range of the cycle in unix seconds.
+    From        int64   `json:"From"`
+    To          int64   `json:"To"`
+}
+
+//Return how long the snapshots are kept after rendering the timelapse.
+func (camThread *RTSPCameraThread)getSnapshotRetention() time.Duration {
+    camThread.threadLock.RLock()
+    defer camThread.threadLock.RUnlock()
+    return time.Duration(camThread.keepSnapshotDays) * 24 * time.Hour
+}
+
+//Delete the snapshots kept after rendering, once they are older than the
+// retention days of the camera. Snapshots of the running cycle and the cycles
+// that are not rendered yet are never deleted.
+func (camThread *RTSPCameraThread)purgeExpiredSnapshots() {
+    log := logging.GetLoggerInstance()
+    cutoff := time.Now().Add(-camThread.getSnapshotRetention())
+    camThread.threadLock.RLock()
+    camDir := camThread.videoPath
+    runningCycle := camThread.getCycleName__(camThread.startTime)
+    camThread.threadLock.RUnlock()
+    dirs, err := ioutil.ReadDir(camDir)
+    if err != nil {
+        log.Error("Failed to read camera directory %s, err: %s", camDir, err)
+        return
+    }
+    for _, dir := range dirs {
+        if !isCycleDir(dir) || dir.Name() == runningCycle {
+            continue
+        }
+        cycleDir := camDir + "/" + dir.Name()
+        finalFile := getFinalVideoFile(cycleDir, dataSet.VIDEO_FORMAT_MP4)
+        if _, err = os.Stat(finalFile); err != nil {
+            continue
+        }
+        files, err := getCycleSnapshots(cycleDir, nil)
+        if err != nil {
+            continue
+        }
+        expired := []os.FileInfo{}
+        for _, file := range files {
+            if file.ModTime().Before(cutoff) {
+                expired = append(expired, file)
+            }
+        }
+        if len(expired) != 0 {
+            log.Trace("Deleting %d expired snapshots in %s", len(expired),
+                        cycleDir)
+            camThread.deleteInputSnapshots(cycleDir, expired)
+        }
+    }
+}
+
+//Return the cycle directory the video is rendered from. Partial and
+// re-rendered timelapses are in directories prefixed with the cycle name.
+func getVideoCycleDir(video *dataSet.Video) string {
+    outputDir := filepath.Dir(filepath.Dir(video.Path))
+    cycle := strings.SplitN(filepath.Base(outputDir), "-", 2)[0]
+    return filepath.Dir(outputDir) + "/" + cycle
+}
+
+//Return the snapshots of the cycle in the trim range of 'opts'.
+func getRerenderSnapshots(cycleDir string,
+                          opts *RerenderOptions) ([]os.FileInfo, error) {
+    files, err := getCycleSnapshots(cycleDir, nil)
+    if err != nil {
+        return nil, err
+    }
+    trimmed := []os.FileInfo{}
+    for _, file := range files {
+        modTime := file.ModTime().Unix()
+        if (opts.From != 0 && modTime < opts.From) ||
+            (opts.To != 0 && modTime > opts.To) {
+            continue
+        }
+        trimmed = append(trimmed, file)
+    }
+    return trimmed, nil
+}
+
+//Validate the options and fill in the parameters of the original timelapse.
+func (opts *RerenderOptions)validate(cam *dataSet.Camera,
+                                     video *dataSet.Video) error {
+    if len(opts.Format) == 0 {
+        opts.Format = video.Format
+    }
+    if !dataSet.IsVideoFormatValid(opts.Format) {
+        return appErrors.INVALID_INPUT
+    }
+    if opts.SpeedFactor == 0 {
+        opts.SpeedFactor = float64(cam.SpeedFactor)
+    }
+    if opts.SpeedFactor < 1 ||
+        opts.SpeedFactor > dataSet.CAMERA_MAX_SPEED_FACTOR {
+        return appErrors.INVALID_INPUT
+    }
+    if opts.Width < 0 || opts.Width > RERENDER_MAX_RESOLUTION ||
+        opts.Height < 0 || opts.Height > RERENDER_MAX_RESOLUTION {
+        return appErrors.INVALID_INPUT
+    }
+    if opts.From != 0 && opts.To != 0 && opts.From > opts.To {
+        return appErrors.INVALID_INPUT
+    }
+    return nil
+}
+
+//Stitch the kept snapshots of the cycle and render them in 'outputDir' as
+// per the options.
+func (camThread *RTSPCameraThread)rerenderCycle(cycleDir string,
+                                    outputDir string, opts RerenderOptions,
+                                    progress jobQueue.JobProgress) error {
+    log := logging.GetLoggerInstance()
+    files, err := getRerenderSnapshots(cycleDir, &opts)
+    if err != nil {
+        return err
+    }
+    if len(files) == 0 {
+        //Snapshots are expired after submitting the job.
+        log.Error("No snapshots are kept to re-render %s", outputDir)
+        return appErrors.DATA_NOT_FOUND
+    }
+    timeLapsePath, err := createTimeLapseDir(outputDir)
+    if err != nil {
+        log.Error("Failed to create timelapse directory in %s", outputDir)
+        return err
+    }
+    listName := "timelapseList_" + filepath.Base(outputDir) + ".txt"
+    defer os.Remove(cycleDir + "/" + listName)
+    timeLapseFile := timeLapsePath + "/timeLapse.mp4"
+    _, err = camThread.stitchSnapshots(cycleDir, files, listName,
+                                       timeLapseFile)
+    if err != nil {
+        return err
+    }
+    err = jobQueue.ReportProgress(progress, 40)
+    if err != nil {
+        return err
+    }
+    finalFile := camThread.compactVideo(timeLapseFile, opts.SpeedFactor)
+    if len(finalFile) == 0 {
+        return appErrors.INVALID_OP
+    }
+    var snapshots []overlaySnapshot
+    if camThread.isOverlayTimestamp() {
+        snapshots = camThread.getOverlaySnapshots(cycleDir, files)
+    }
+    err = camThread.filterFinalVideo(finalFile, snapshots, opts.SpeedFactor)
+    if err != nil {
+        log.Error("Failed to filter %s, err: %s", finalFile, err)
+    }
+    err = jobQueue.ReportProgress(progress, 60)
+    if err != nil {
+        return err
+    }
+    thumbs := camThread.createThumbnails(finalFile)
+    outputFile := finalFile
+    if opts.Format != dataSet.VIDEO_FORMAT_MP4 || opts.Width != 0 ||
+        opts.Height != 0 {
+        outputFile = timeLapsePath + "/" + FINAL_TIMELAPSE_NAME + "." +
+                     opts.Format
+        if opts.Format == dataSet.VIDEO_FORMAT_MP4 {
+            //Scaled MP4 replaces the compacted timelapse.
+            outputFile = timeLapsePath + "/" + FINAL_TIMELAPSE_NAME +
+                         ".scaled.mp4"
+        }
+        err = camThread.transcodeVideo(finalFile, outputFile,
+                                       videoFormatProfiles[opts.Format],
+                                       opts.Width, opts.Height)
+        if err != nil {
+            log.Error("Failed to re-render %s, err: %s", outputDir, err)
+            return err
+        }
+        if opts.Format == dataSet.VIDEO_FORMAT_MP4 {
+            err = os.Rename(outputFile, finalFile)
+            if err != nil {
+                return err
+            }
+            outputFile = finalFile
+        }
+    }
+    startTime := files[0].ModTime()
+    if opts.From == 0 {
+        cycleStart, err := camThread.parseCycleName(filepath.Base(cycleDir))
+        if err == nil {
+            startTime = cycleStart
+        }
+    }
+    endTime := files[len(files) - 1].ModTime()
+    return camThread.addVideoToCatalog(outputDir, outputFile, opts.Format,
+                                       startTime, endTime, thumbs)
+}
+
+//Re-render the cycle of 'video' from its kept snapshots as per the options.
+// The rendering happens in a background job and the new video is added to
+// the catalog once it is done.
+//Returns the name of the new video and the job rendering it.
+func RerenderVideo(cam *dataSet.Camera, video *dataSet.Video,
+                   opts RerenderOptions) (string, *dataSet.Job, error) {
+    log := logging.GetLoggerInstance()
+    err := opts.validate(cam, video)
+    if err != nil {
+        log.Error("Invalid options to re-render %s", video.Name)
+        return "", nil, err
+    }
+    cycleDir := getVideoCycleDir(video)
+    files, err := getRerenderSnapshots(cycleDir, &opts)
+    if err != nil || len(files) == 0 {
+        log.Error("No snapshots are kept to re-render %s", video.Name)
+        return "", nil, appErrors.INVALID_OP
+    }
+    outputDir := cycleDir + RERENDER_SEP +
+                 time.Now().In(cam.GetLocation()).Format(TIME_DIR_FORMAT)
+    params := rerenderJobParams{
+        CycleDir: cycleDir,
+        OutputDir: outputDir,
+        Options: opts,
+    }
+    job, err := jobQueue.GetJobQueueObj().SubmitJob(dataSet.JOB_TYPE_RERENDER,
+                                                    cam.Name, params)
+    if err != nil {
+        return "", nil, err
+    }
+    return filepath.Base(outputDir) + "." + opts.Format, job, nil
+}
@@ -0,0 +1,69 @@
+package RTSPCameraImpl
+
+// Test file for validating the re-rendering of the timelapses.
+import (
+    "testing"
+    "VideoTimeLapse/dataSet"
+)
+
+func TestGetVideoCycleDir(t *testing.T) {
+    tests := []struct {
+        path string
+        expected string
+    }{
+        {"/videos/cam1/20260301120000/timeLapse/FinalTimeLapse.mp4",
+            "/videos/cam1/20260301120000"},
+        {"/videos/cam1/20260301120000-rerender-20260302080000/timeLapse/" +
+            "FinalTimeLapse.gif",
+            "/videos/cam1/20260301120000"},
+        {"/videos/cam1/profiles/daily/20260301000000/timeLapse/" +
+            "FinalTimeLapse.mp4",
+            "/videos/cam1/profiles/daily/20260301000000"},
+    }
+    for _, test := range tests {
+        video := &dataSet.Video{Path: test.path}
+        if dir := getVideoCycleDir(video); dir != test.expected {
+            t.Errorf("Cycle of %s: got %s, expected %s", test.path, dir,
+                     test.expected)
+        }
+    }
+}
+
+func TestRerenderOptionsValidate(t *testing.T) {
+    cam := &dataSet.Camera{SpeedFactor: 4}
+    video := &dataSet.Video{Format: dataSet.VIDEO_FORMAT_GIF}
+    tests := []struct {
+        name string
+        opts RerenderOptions
+        valid bool
+    }{
+        {"defaults", RerenderOptions{}, true},
+        {"all set", RerenderOptions{SpeedFactor: 2.5, Format: "webm",
+                                    Width: 1280, Height: 720, From: 100,
+                                    To: 200}, true},
+        {"unknown format", RerenderOptions{Format: "avi"}, false},
+        {"slow down", RerenderOptions{SpeedFactor: 0.5}, false},
+        {"speed above maximum",
+            RerenderOptions{SpeedFactor: dataSet.CAMERA_MAX_SPEED_FACTOR + 1},
+            false},
+        {"negative width", RerenderOptions{Width: -1}, false},
+        {"height above maximum",
+            RerenderOptions{Height: RERENDER_MAX_RESOLUTION + 1}, false},
+        {"from after to", RerenderOptions{From: 200, To: 100}, false},
+        {"from only", RerenderOptions{From: 200}, true},
+    }
+    for _, test := range tests {
+        opts := test.opts
+        err := opts.validate(cam, video)
+        if (err == nil) != test.valid {
+            t.Errorf("%s: got err %v, expected valid %t", test.name, err,
+                     test.valid)
+        }
+    }
+    opts := RerenderOptions{}
+    opts.validate(cam, video)
+    if opts.Format != video.Format || opts.SpeedFactor != 4 {
+        t.Errorf("Defaults: got format %s speed %f, expected %s speed 4",
+                 opts.Format, opts.SpeedFactor, video.Format)
+    }
+}
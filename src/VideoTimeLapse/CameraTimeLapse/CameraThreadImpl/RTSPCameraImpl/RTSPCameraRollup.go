@@ -0,0 +1,318 @@
+package RTSPCameraImpl
+
+import (
+    "fmt"
+    "os"
+    "sync"
+    "time"
+    "strings"
+    "encoding/json"
+    "path/filepath"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/jobQueue"
+)
+
+// Aggregate timelapse videos of a camera, built from the final timelapse
+// videos in the catalog for every period of the rollup. The source videos are
+// concatenated by packet copy same as the snapshots of a cycle, and the
+// result is sped up by the speed factor of the rollup. Every period is built
+// in its own directory in the rollups directory of the camera, named
+// <rollup-name>-<period-start>.
+
+const (
+    ROLLUP_DIR_NAME = "rollups"
+    //Interval to check for the rollups that are due.
+    ROLLUP_CHECK_SEC = 60
+    ROLLUP_SOURCE_NAME = "source_%05d.mp4"
+    ROLLUP_LIST_NAME = "rollupList.txt"
+)
+
+//Parameters of the rollup job.
+type rollupJobParams struct {
+    Rollup      dataSet.CameraRollup `json:"Rollup"`
+    //Unix time of the period to build.
+    PeriodStart int64                `json:"PeriodStart"`
+    PeriodEnd   int64                `json:"PeriodEnd"`
+}
+
+//Return the timezone of the camera, the local time of the system if the
+// camera is not found.
+func getCameraLocation(camName string) *time.Location {
+    cam, err := dataSetImpl.GetDataSetObj().GetCamera(camName)
+    if err != nil {
+        return time.Local
+    }
+    return cam.GetLocation()
+}
+
+//Return the directory of the rollup period, the period start is in the
+// timezone of the camera same as the cycle directories.
+func getRollupDir(camDir string, rollupName string, periodStart int64,
+                  location *time.Location) string {
+    return camDir + "/" + ROLLUP_DIR_NAME + "/" + rollupName + "-" +
+            time.Unix(periodStart, 0).In(location).Format(TIME_DIR_FORMAT)
+}
+
+//Return true if the video is the final timelapse of a cycle of the camera
+// in 'location'. The timelapses of the capture profiles are left out.
+func isCycleVideo(video *dataSet.Video, location *time.Location) bool {
+    outputDir := filepath.Dir(filepath.Dir(video.Path))
+    _, err := time.ParseInLocation(TIME_DIR_FORMAT, filepath.Base(outputDir),
+                                   location)
+    return err == nil &&
+            filepath.Base(filepath.Dir(filepath.Dir(outputDir))) !=
+                PROFILE_DIR_NAME
+}
+
+//Return true if the video is built by the rollup 'rollupName'.
+func isRollupVideo(video *dataSet.Video, rollupName string) bool {
+    outputDir := filepath.Dir(filepath.Dir(video.Path))
+    return filepath.Base(filepath.Dir(outputDir)) == ROLLUP_DIR_NAME &&
+            strings.HasPrefix(filepath.Base(outputDir), rollupName + "-")
+}
+
+//Return the MP4 videos of the rollup source that start in the period, in the
+// order of their start time.
+func getRollupSources(rollup *dataSet.CameraRollup, periodStart int64,
+                      periodEnd int64) ([]dataSet.Video, error) {
+    dataObj := dataSetImpl.GetDataSetObj()
+    videos, err := dataObj.GetAllVideos(rollup.CamName)
+    if err != nil {
+        return nil, err
+    }
+    location := getCameraLocation(rollup.CamName)
+    sources := []dataSet.Video{}
+    for i := range videos {
+        video := &videos[i]
+        if video.Format != dataSet.VIDEO_FORMAT_MP4 ||
+            video.StartTime < periodStart || video.StartTime >= periodEnd {
+            continue
+        }
+        if rollup.Source == dataSet.ROLLUP_SOURCE_CYCLE {
+            if !isCycleVideo(video, location) {
+                continue
+            }
+        } else if !isRollupVideo(video, rollup.Source) {
+            continue
+        }
+        sources = append(sources, *video)
+    }
+    return sources, nil
+}
+
+//Link the source videos in the rollup directory, as the concat input accepts
+// only plain file names.
+//Returns the links in the order of the sources.
+func linkRollupSources(outputDir string,
+                       sources []dataSet.Video) ([]os.FileInfo, error) {
+    links := []os.FileInfo{}
+    for i, video := range sources {
+        link := outputDir + "/" + fmt.Sprintf(ROLLUP_SOURCE_NAME, i)
+        os.Remove(link)
+        err := os.Symlink(video.Path, link)
+        if err != nil {
+            return links, err
+        }
+        info, err := os.Lstat(link)
+        if err != nil {
+            return links, err
+        }
+        links = append(links, info)
+    }
+    return links, nil
+}
+
+//Build the rollup video of the period.
+func (camThread *RTSPCameraThread)buildRollup(params *rollupJobParams,
+                                    progress jobQueue.JobProgress) error {
+    log := logging.GetLoggerInstance()
+    rollup := &params.Rollup
+    sources, err := getRollupSources(rollup, params.PeriodStart,
+                                     params.PeriodEnd)
+    if err != nil {
+        return err
+    }
+    if len(sources) == 0 {
+        log.Info("No videos to build rollup %s of %s", rollup.Name,
+                    rollup.CamName)
+        return nil
+    }
+    camThread.threadLock.RLock()
+    camDir := camThread.videoPath
+    enableHLS := camThread.enableHLS
+    location := camThread.location
+    camThread.threadLock.RUnlock()
+    outputDir := getRollupDir(camDir, rollup.Name, params.PeriodStart,
+                              location)
+    timeLapsePath, err := createTimeLapseDir(outputDir)
+    if err != nil {
+        log.Error("Failed to create rollup directory %s", outputDir)
+        return err
+    }
+    links, err := linkRollupSources(outputDir, sources)
+    defer camThread.deleteInputSnapshots(outputDir, links)
+    if err != nil {
+        log.Error("Failed to link the videos of rollup %s, err: %s",
+                    rollup.Name, err)
+        return err
+    }
+    defer os.Remove(outputDir + "/" + ROLLUP_LIST_NAME)
+    timeLapseFile := timeLapsePath + "/timeLapse.mp4"
+    _, err = camThread.stitchSnapshots(outputDir, links, ROLLUP_LIST_NAME,
+                                       timeLapseFile)
+    if err != nil {
+        return err
+    }
+    err = jobQueue.ReportProgress(progress, 40)
+    if err != nil {
+        return err
+    }
+    finalFile := camThread.compactVideo(timeLapseFile,
+                                        float64(rollup.SpeedFactor))
+    if len(finalFile) == 0 {
+        return fmt.Errorf("Failed to compact rollup %s", outputDir)
+    }
+    err = jobQueue.ReportProgress(progress, 60)
+    if err != nil {
+        return err
+    }
+    endTime := sources[0].EndTime
+    for _, video := range sources {
+        if video.EndTime > endTime {
+            endTime = video.EndTime
+        }
+    }
+    camThread.renderOutputFormats(outputDir, finalFile,
+                                  time.Unix(sources[0].StartTime, 0),
+                                  time.Unix(endTime, 0))
+    if enableHLS {
+        camThread.packageHLS(finalFile, GetVideoHLSDir(finalFile), false)
+    }
+    log.Trace("Built rollup %s of %s from %d videos", rollup.Name,
+                rollup.CamName, len(sources))
+    return nil
+}
+
+//Build the rollup video of the period and record the period as built.
+func runRollupJob(job *dataSet.Job, progress jobQueue.JobProgress) error {
+    var params rollupJobParams
+    err := json.Unmarshal([]byte(job.Params), &params)
+    if err != nil {
+        return err
+    }
+    camThread, err := getJobCameraThread(job.CamName, "")
+    if err != nil {
+        return err
+    }
+    err = camThread.buildRollup(&params, progress)
+    if err != nil {
+        return err
+    }
+    //Definition can be updated while the job is running.
+    dataObj := dataSetImpl.GetDataSetObj()
+    rollup, err := dataObj.GetCameraRollup(job.CamName, params.Rollup.Name)
+    if err != nil {
+        //Rollup is deleted.
+        return nil
+    }
+    if rollup.LastRun < params.PeriodEnd {
+        rollup.LastRun = params.PeriodEnd
+        return dataObj.UpdateCameraRollup(rollup)
+    }
+    return nil
+}
+
+//Return true if the source rollup has built all the videos needed for the
+// period ending at 'periodEnd'.
+func isRollupSourceReady(rollup *dataSet.CameraRollup,
+                         rollups []dataSet.CameraRollup, periodEnd time.Time,
+                         now time.Time) bool {
+    if rollup.Source == dataSet.ROLLUP_SOURCE_CYCLE {
+        return true
+    }
+    for i := range rollups {
+        source := &rollups[i]
+        if source.Name != rollup.Source {
+            continue
+        }
+        //Project is rebuilt every day, it needs the source to be up to date
+        // only.
+        if rollup.Period == dataSet.ROLLUP_PROJECT {
+            _, periodEnd = source.GetLastPeriod(now)
+        }
+        return source.LastRun >= periodEnd.Unix()
+    }
+    //Rollup is built from the source videos left in catalog.
+    return true
+}
+
+//Submit the jobs of the rollups that are due at 'now'. A period is submitted
+// only once, the failed periods are left to the user.
+func checkRollups(now time.Time) {
+    log := logging.GetLoggerInstance()
+    dataObj := dataSetImpl.GetDataSetObj()
+    jobs, err := dataObj.GetAllJobs()
+    if err != nil {
+        return
+    }
+    submitted := make(map[string]bool)
+    for _, job := range jobs {
+        var params rollupJobParams
+        if job.Type != dataSet.JOB_TYPE_ROLLUP ||
+            json.Unmarshal([]byte(job.Params), &params) != nil {
+            continue
+        }
+        submitted[fmt.Sprintf("%s/%s/%d", job.CamName, params.Rollup.Name,
+                              params.PeriodEnd)] = true
+    }
+    cameras, err := dataObj.GetAllCameras()
+    if err != nil {
+        return
+    }
+    for _, cam := range cameras {
+        rollups, err := dataObj.GetCameraRollups(cam.Name)
+        if err != nil {
+            continue
+        }
+        //Periods of the rollups are in the timezone of the camera.
+        camNow := now.In(cam.GetLocation())
+        for i := range rollups {
+            rollup := &rollups[i]
+            start, end, due := rollup.GetDuePeriod(camNow)
+            key := fmt.Sprintf("%s/%s/%d", cam.Name, rollup.Name, end.Unix())
+            if !due || submitted[key] ||
+                !isRollupSourceReady(rollup, rollups, end, camNow) {
+                continue
+            }
+            params := rollupJobParams{
+                Rollup: *rollup,
+                PeriodStart: start.Unix(),
+                PeriodEnd: end.Unix(),
+            }
+            _, err = jobQueue.GetJobQueueObj().SubmitJob(
+                                dataSet.JOB_TYPE_ROLLUP, cam.Name, params)
+            if err != nil {
+                log.Error("Failed to submit rollup %s of %s, err: %s",
+                            rollup.Name, cam.Name, err)
+            }
+        }
+    }
+}
+
+var rollupOnce sync.Once
+
+//Start the routine to build the rollups of all the cameras when they are
+// due. The routine is never added to the application waitgroup, as the
+// rollups are built in jobs that are resumed at the next start.
+func StartRollupScheduler() {
+    rollupOnce.Do(func() {
+        go func() {
+            for {
+                checkRollups(time.Now())
+                time.Sleep(time.Duration(ROLLUP_CHECK_SEC) * time.Second)
+            }
+        }()
+    })
+}
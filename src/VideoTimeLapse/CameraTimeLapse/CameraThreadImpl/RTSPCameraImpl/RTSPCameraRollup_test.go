@@ -0,0 +1,55 @@
+package RTSPCameraImpl
+
+// Test file for validating the rollups of the camera timelapses.
+import (
+    "time"
+    "testing"
+    "VideoTimeLapse/dataSet"
+)
+
+func TestGetRollupDir(t *testing.T) {
+    kolkata, err := time.LoadLocation("Asia/Kolkata")
+    if err != nil {
+        t.Fatalf("Failed to load timezone, err: %s", err)
+    }
+    //2026-03-01 00:00:00 UTC is 05:30 in Kolkata.
+    periodStart := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC).Unix()
+    tests := []struct {
+        location *time.Location
+        expected string
+    }{
+        {time.UTC, "/videos/cam1/rollups/weekly-20260301000000"},
+        {kolkata, "/videos/cam1/rollups/weekly-20260301053000"},
+    }
+    for _, test := range tests {
+        dir := getRollupDir("/videos/cam1", "weekly", periodStart,
+                            test.location)
+        if dir != test.expected {
+            t.Errorf("Rollup in %s: got %s, expected %s", test.location,
+                     dir, test.expected)
+        }
+    }
+}
+
+func TestIsCycleVideo(t *testing.T) {
+    tests := []struct {
+        path string
+        expected bool
+    }{
+        {"/videos/cam1/20260301120000/timeLapse/FinalTimeLapse.mp4", true},
+        {"/videos/cam1/20260301120000-rerender-20260302080000/timeLapse/" +
+            "FinalTimeLapse.mp4", false},
+        {"/videos/cam1/profiles/daily/20260301000000/timeLapse/" +
+            "FinalTimeLapse.mp4", false},
+        {"/videos/cam1/rollups/weekly-20260301000000/timeLapse/" +
+            "FinalTimeLapse.mp4", false},
+        {"/videos/cam1/clips/clip-20260301120000/clip.mp4", false},
+    }
+    for _, test := range tests {
+        video := &dataSet.Video{Path: test.path}
+        if res := isCycleVideo(video, time.UTC); res != test.expected {
+            t.Errorf("Cycle video %s: got %t, expected %t", test.path, res,
+                     test.expected)
+        }
+    }
+}
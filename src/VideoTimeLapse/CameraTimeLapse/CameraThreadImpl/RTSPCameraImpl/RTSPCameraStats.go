@@ -0,0 +1,131 @@
+package RTSPCameraImpl
+
+import (
+    "time"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+)
+
+// Every snapshot capture records the statistics of its scene and stream,
+// whether the snapshot is kept or not. The luma is taken from the same gray
+// image of the first frame that is used by the scene checks, so the stats cost
+// no more decoding. The stats are served as a time series to chart the
+// daylight and to spot the bad captures without opening the videos.
+
+// #include "videomask.h"
+// #include <libavcodec/avcodec.h>
+import "C"
+
+const (
+    //Percentiles of the luma histogram recorded in the stats.
+    LUMA_LOW_PERCENTILE = 5
+    LUMA_MEDIAN_PERCENTILE = 50
+    LUMA_HIGH_PERCENTILE = 95
+)
+
+//Return the stats of a capture started at 'start', before its scene is
+// checked.
+func (camThread *RTSPCameraThread)newSnapshotStats(
+                                    start time.Time) *dataSet.SnapshotStats {
+    return &dataSet.SnapshotStats{
+        CamName: camThread.name,
+        CaptureTime: start.Unix(),
+        Status: dataSet.SNAPSHOT_STATUS_FAILED,
+        MeanLuma: dataSet.SNAPSHOT_NO_LUMA,
+        LumaDeviation: dataSet.SNAPSHOT_NO_LUMA,
+        LumaLow: dataSet.SNAPSHOT_NO_LUMA,
+        LumaMedian: dataSet.SNAPSHOT_NO_LUMA,
+        LumaHigh: dataSet.SNAPSHOT_NO_LUMA,
+        ChangeScore: dataSet.SNAPSHOT_NO_CHANGE_SCORE,
+        KeyframeOffset: -1,
+    }
+}
+
+//Return the luma values at the percentiles of the gray pixels, leaving out
+// the masked pixels. 'ok' is false if all the pixels are masked.
+func getLumaPercentiles(gray []byte, bitmap []byte,
+                        percentiles ...int) (values []int64, ok bool) {
+    var histogram [256]uint64
+    count := uint64(0)
+    for i := range gray {
+        if bitmap != nil && bitmap[i] != C.VS_MASK_NONE {
+            continue
+        }
+        histogram[gray[i]]++
+        count++
+    }
+    if count == 0 {
+        return nil, false
+    }
+    values = make([]int64, len(percentiles))
+    for i, percentile := range percentiles {
+        rank := count * uint64(percentile) / 100
+        seen := uint64(0)
+        for luma := range histogram {
+            seen += histogram[luma]
+            if seen > rank {
+                values[i] = int64(luma)
+                break
+            }
+        }
+    }
+    return values, true
+}
+
+//Count a packet written to the snapshot.
+func addStatsPacket(stats *dataSet.SnapshotStats, pkt *C.AVPacket) {
+    if stats.KeyframeOffset < 0 && pkt.flags & C.AV_PKT_FLAG_KEY != 0 {
+        stats.KeyframeOffset = int64(stats.Packets)
+    }
+    stats.Packets++
+}
+
+//Record the stats of the decoded scene and the packets read to decode it.
+//The change score is against the scene of the previous capture, kept or not.
+func (camThread *RTSPCameraThread)setSceneStats(stats *dataSet.SnapshotStats,
+                                    check *sceneCheck,
+                                    masks []dataSet.PrivacyMask,
+                                    start time.Time) {
+    stats.CaptureLatency = int64(time.Since(start) / time.Millisecond)
+    for _, pkt := range check.pkts {
+        addStatsPacket(stats, pkt)
+    }
+    if check.gray == nil {
+        return
+    }
+    var bitmap []byte
+    if len(masks) != 0 {
+        bitmap = rasterizePrivacyMasks(masks, SCENE_GRAY_WIDTH,
+                                       SCENE_GRAY_HEIGHT)
+    }
+    mean, deviation, ok := getLumaStats(check.gray, bitmap)
+    if ok {
+        stats.MeanLuma = mean
+        stats.LumaDeviation = deviation
+        values, _ := getLumaPercentiles(check.gray, bitmap,
+                                        LUMA_LOW_PERCENTILE,
+                                        LUMA_MEDIAN_PERCENTILE,
+                                        LUMA_HIGH_PERCENTILE)
+        stats.LumaLow = values[0]
+        stats.LumaMedian = values[1]
+        stats.LumaHigh = values[2]
+    }
+    camThread.threadLock.Lock()
+    if camThread.lastScene != nil {
+        stats.ChangeScore = getChangeScore(check.gray, camThread.lastScene)
+    }
+    camThread.lastScene = check.gray
+    camThread.threadLock.Unlock()
+}
+
+//Record the stats of a finished capture.
+func (camThread *RTSPCameraThread)addSnapshotStats(
+                                    stats *dataSet.SnapshotStats) {
+    log := logging.GetLoggerInstance()
+    err := dataSetImpl.GetDataSetObj().AddSnapshotStats(stats)
+    if err != nil {
+        log.Error("Failed to record the snapshot stats of %s, err: %s",
+                  camThread.name, err)
+    }
+}
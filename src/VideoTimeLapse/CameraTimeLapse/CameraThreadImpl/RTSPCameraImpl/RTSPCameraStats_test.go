@@ -0,0 +1,97 @@
+package RTSPCameraImpl
+
+// Test file for validating the stats of the snapshot captures.
+import (
+    "time"
+    "reflect"
+    "testing"
+    "VideoTimeLapse/dataSet"
+)
+
+func TestGetLumaPercentiles(t *testing.T) {
+    black := dataSet.PrivacyMask{Mode: dataSet.PRIVACY_MASK_BLACK}
+    ramp := make([]byte, 100)
+    upperMasked := make([]byte, 100)
+    for i := range ramp {
+        ramp[i] = byte(i)
+        if i >= 50 {
+            upperMasked[i] = getMaskPixelMode(&black)
+        }
+    }
+    tests := []struct {
+        name string
+        gray []byte
+        bitmap []byte
+        expected []int64
+        ok bool
+    }{
+        {"ramp", ramp, nil, []int64{5, 50, 95}, true},
+        {"upper half masked", ramp, upperMasked, []int64{2, 25, 47}, true},
+        {"uniform", []byte{80, 80, 80}, nil, []int64{80, 80, 80}, true},
+        {"all masked", ramp[:1], upperMasked[50:51], nil, false},
+    }
+    for _, test := range tests {
+        values, ok := getLumaPercentiles(test.gray, test.bitmap,
+                                         LUMA_LOW_PERCENTILE,
+                                         LUMA_MEDIAN_PERCENTILE,
+                                         LUMA_HIGH_PERCENTILE)
+        if ok != test.ok || !reflect.DeepEqual(values, test.expected) {
+            t.Errorf("%s: got %v/%v, expected %v/%v", test.name, values, ok,
+                     test.expected, test.ok)
+        }
+    }
+}
+
+func TestSetSceneStats(t *testing.T) {
+    camThread := &RTSPCameraThread{}
+    camThread.name = "cam1"
+    //Left half of the scene is black and the right half is bright.
+    scene := make([]byte, SCENE_GRAY_WIDTH * SCENE_GRAY_HEIGHT)
+    for i := range scene {
+        if i % SCENE_GRAY_WIDTH >= SCENE_GRAY_WIDTH / 2 {
+            scene[i] = 200
+        }
+    }
+    start := time.Now()
+    stats := camThread.newSnapshotStats(start)
+    if stats.CaptureTime != start.Unix() ||
+        stats.Status != dataSet.SNAPSHOT_STATUS_FAILED ||
+        stats.MeanLuma != dataSet.SNAPSHOT_NO_LUMA ||
+        stats.KeyframeOffset != -1 {
+        t.Errorf("New stats: got %+v", stats)
+    }
+    //Luma is not known for an undecodable scene.
+    camThread.setSceneStats(stats, &sceneCheck{}, nil, start)
+    if stats.MeanLuma != dataSet.SNAPSHOT_NO_LUMA ||
+        stats.ChangeScore != dataSet.SNAPSHOT_NO_CHANGE_SCORE {
+        t.Errorf("Undecodable scene: got %+v", stats)
+    }
+
+    stats = camThread.newSnapshotStats(start)
+    camThread.setSceneStats(stats, &sceneCheck{gray: scene}, nil, start)
+    if stats.MeanLuma != 100 || stats.LumaDeviation != 100 ||
+        stats.LumaLow != 0 || stats.LumaMedian != 200 ||
+        stats.LumaHigh != 200 {
+        t.Errorf("Scene: got luma %+v", stats)
+    }
+    //First scene has nothing to compare with.
+    if stats.ChangeScore != dataSet.SNAPSHOT_NO_CHANGE_SCORE {
+        t.Errorf("First scene: got change score %f", stats.ChangeScore)
+    }
+
+    //Bright half is masked out of the luma, but not out of the change.
+    masks := []dataSet.PrivacyMask{{Points: []dataSet.MaskPoint{
+        {X: 0.5, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 0.5, Y: 1}}}}
+    dark := make([]byte, len(scene))
+    stats = camThread.newSnapshotStats(start)
+    camThread.setSceneStats(stats, &sceneCheck{gray: dark}, masks, start)
+    if stats.MeanLuma != 0 || stats.LumaDeviation != 0 ||
+        stats.LumaHigh != 0 {
+        t.Errorf("Masked scene: got luma %+v", stats)
+    }
+    expectedScore := float64(200) * 100 / (2 * 255)
+    if stats.ChangeScore != expectedScore {
+        t.Errorf("Masked scene: got change score %f, expected %f",
+                 stats.ChangeScore, expectedScore)
+    }
+}
@@ -0,0 +1,121 @@
+package RTSPCameraImpl
+
+import (
+    "fmt"
+    "sync"
+    "time"
+    "unsafe"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+)
+
+// Still images on demand from the live camera. Every request opens a new RTSP
+// session, hence the images are cached for a short while to serve repeated
+// requests.
+
+// #include "videoimage.h"
+// #include <stdlib.h>
+import "C"
+
+const (
+    STILL_IMAGE_CACHE_SEC = 10
+    //Video packets to read for a decodable keyframe before giving up.
+    STILL_IMAGE_MAX_PKTS = 600
+    //Timeout to open the stream and decode the keyframe, the requests for
+    // the image of the camera wait for the capture in progress.
+    STILL_IMAGE_TIMEOUT_SEC = 10
+)
+
+//Last still image captured from a camera.
+type stillImage struct {
+    //Held while capturing, so the concurrent requests wait for the image
+    // instead of opening more sessions.
+    lock sync.Mutex
+    url string
+    privacyMasks string
+    image []byte
+    captureTime time.Time
+}
+
+var stillImageCache = struct {
+    sync.Mutex
+    images map[string]*stillImage
+}{images: make(map[string]*stillImage)}
+
+//Check if the cached image can be served at 'now'. Image is not reused when
+// the camera address/credentials or the masks are changed.
+func (still *stillImage)isReusable(url string, privacyMasks string,
+                                   now time.Time) bool {
+    return still.url == url && still.privacyMasks == privacyMasks &&
+        still.image != nil &&
+        now.Sub(still.captureTime) <
+            time.Duration(STILL_IMAGE_CACHE_SEC) * time.Second
+}
+
+//Decode the first keyframe from the url and return it as JPEG in full
+// resolution. The frame is masked when 'mask' is set.
+func captureStillImage(url string, mask *privacyMask) ([]byte, error) {
+    rtspOnce.Do(func() {
+        C.vs_setup()
+    })
+    inputFormatC := C.CString("rtsp")
+    inputURLC := C.CString(url)
+    defer C.free(unsafe.Pointer(inputFormatC))
+    defer C.free(unsafe.Pointer(inputURLC))
+    var imageC *C.uint8_t
+    var sizeC C.int
+    res := C.vs_capture_jpeg(inputFormatC, inputURLC, C.int(0),
+                             C.int(STILL_IMAGE_MAX_PKTS),
+                             C.int(STILL_IMAGE_TIMEOUT_SEC),
+                             mask.getVSMask(), &imageC, &sizeC)
+    if res != 0 {
+        return nil, fmt.Errorf("Failed to capture still image")
+    }
+    image := C.GoBytes(unsafe.Pointer(imageC), sizeC)
+    C.av_free(unsafe.Pointer(imageC))
+    return image, nil
+}
+
+//Return a JPEG still image from the live camera. The image captured in last
+// STILL_IMAGE_CACHE_SEC seconds is returned if present.
+func GetCameraStillImage(cam *dataSet.Camera) ([]byte, error) {
+    log := logging.GetLoggerInstance()
+    url := getRTSPURL(cam.Ipaddr, cam.Port, cam.UserId, cam.Pwd)
+    stillImageCache.Lock()
+    still, ok := stillImageCache.images[cam.Name]
+    if !ok {
+        still = new(stillImage)
+        stillImageCache.images[cam.Name] = still
+    }
+    stillImageCache.Unlock()
+
+    still.lock.Lock()
+    defer still.lock.Unlock()
+    if still.isReusable(url, cam.PrivacyMasks, time.Now()) {
+        return still.image, nil
+    }
+    masks, err := cam.GetPrivacyMasks()
+    if err != nil {
+        log.Error("Invalid privacy masks of %s, err: %s", cam.Name, err)
+        return nil, err
+    }
+    var width, height int
+    info, err := dataSetImpl.GetDataSetObj().GetCameraStreamInfo(cam.Name)
+    if err == nil {
+        width, height = int(info.Width), int(info.Height)
+    }
+    mask := newPrivacyMask(masks, width, height)
+    defer mask.free()
+    image, err := captureStillImage(url, mask)
+    if err != nil {
+        log.Error("Failed to capture still image from %s, err: %s", cam.Name,
+                    err)
+        return nil, err
+    }
+    still.url = url
+    still.privacyMasks = cam.PrivacyMasks
+    still.image = image
+    still.captureTime = time.Now()
+    return image, nil
+}
@@ -0,0 +1,111 @@
+package RTSPCameraImpl
+
+import (
+    "time"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+)
+
+// Stream parameters of the camera are recorded on every connection, and used
+// to set up the MP4 outputs of the camera.
+
+// #include "videomux.h"
+import "C"
+
+//Return the part of the string that fits in a C char array of 'size' along
+// with the terminating NUL.
+func fitCString(src string, size int) string {
+    if size < 1 {
+        return ""
+    }
+    if len(src) > size - 1 {
+        return src[:size - 1]
+    }
+    return src
+}
+
+//Copy the string to C char array, truncating it to fit.
+func setCString(dst []C.char, src string) {
+    if len(dst) == 0 {
+        return
+    }
+    src = fitCString(src, len(dst))
+    for i := 0; i < len(src); i++ {
+        dst[i] = C.char(src[i])
+    }
+    dst[len(src)] = 0
+}
+
+//Load the stream parameters stored for the camera.
+func (camThread *RTSPCameraThread)loadStreamInfo() {
+    dataObj := dataSetImpl.GetDataSetObj()
+    info, err := dataObj.GetCameraStreamInfo(camThread.name)
+    if err != nil {
+        info = nil
+    }
+    camThread.streamLock.Lock()
+    camThread.streamInfo = info
+    camThread.streamLock.Unlock()
+}
+
+//Record the stream parameters of the camera input. The datastore is updated
+// only when the parameters are changed, e.g. codec switched on a firmware
+// upgrade.
+func (camThread *RTSPCameraThread)updateStreamInfo(input *Input) {
+    log := logging.GetLoggerInstance()
+    var infoC C.struct_VSStreamInfo
+    input.mutex.RLock()
+    res := C.vs_get_stream_info(input.vsInput, &infoC)
+    input.mutex.RUnlock()
+    if res != 0 {
+        log.Error("Failed to get stream info of camera %s", camThread.name)
+        return
+    }
+    info := &dataSet.CameraStreamInfo{
+        CamName: camThread.name,
+        Codec: C.GoString(&infoC.codec[0]),
+        Profile: C.GoString(&infoC.profile[0]),
+        Width: int64(infoC.width),
+        Height: int64(infoC.height),
+        FPS: float64(infoC.fps),
+        Bitrate: int64(infoC.bitrate),
+        HasAudio: bool(infoC.has_audio),
+        UpdateTime: time.Now().Unix(),
+    }
+    camThread.streamLock.Lock()
+    defer camThread.streamLock.Unlock()
+    if camThread.streamInfo != nil &&
+        camThread.streamInfo.IsSameStream(info) {
+        return
+    }
+    dataObj := dataSetImpl.GetDataSetObj()
+    err := dataObj.UpdateCameraStreamInfo(info)
+    if err != nil {
+        log.Error("Failed to store stream info of camera %s, err: %s",
+                    camThread.name, err)
+        return
+    }
+    log.Info("Camera %s streaming %s %s %dx%d@%.2f", camThread.name,
+                info.Codec, info.Profile, info.Width, info.Height, info.FPS)
+    camThread.streamInfo = info
+}
+
+//Return the stored stream parameters for the output, nil if not known.
+func (camThread *RTSPCameraThread)getOutputParams() *C.struct_VSStreamInfo {
+    camThread.streamLock.Lock()
+    defer camThread.streamLock.Unlock()
+    if camThread.streamInfo == nil {
+        return nil
+    }
+    info := camThread.streamInfo
+    params := new(C.struct_VSStreamInfo)
+    setCString(params.codec[:], info.Codec)
+    setCString(params.profile[:], info.Profile)
+    params.width = C.int(info.Width)
+    params.height = C.int(info.Height)
+    params.fps = C.double(info.FPS)
+    params.bitrate = C.int64_t(info.Bitrate)
+    params.has_audio = C.bool(info.HasAudio)
+    return params
+}
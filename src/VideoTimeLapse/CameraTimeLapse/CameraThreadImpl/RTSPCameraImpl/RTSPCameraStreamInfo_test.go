@@ -0,0 +1,29 @@
+package RTSPCameraImpl
+
+// Test file for validating the stream parameters passed to the outputs.
+import (
+    "testing"
+)
+
+func TestFitCString(t *testing.T) {
+    tests := []struct {
+        name string
+        src string
+        size int
+        expected string
+    }{
+        {"fits", "h264", 32, "h264"},
+        {"fits with nul", "h264", 5, "h264"},
+        {"truncated", "h264", 4, "h26"},
+        {"only nul", "h264", 1, ""},
+        {"no room", "h264", 0, ""},
+        {"empty string", "", 8, ""},
+    }
+    for _, test := range tests {
+        str := fitCString(test.src, test.size)
+        if str != test.expected {
+            t.Errorf("%s: got string %q, expected %q", test.name, str,
+                     test.expected)
+        }
+    }
+}
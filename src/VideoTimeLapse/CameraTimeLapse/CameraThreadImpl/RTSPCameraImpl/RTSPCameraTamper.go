@@ -0,0 +1,315 @@
+package RTSPCameraImpl
+
+import (
+    "fmt"
+    "os"
+    "math"
+    "time"
+    "io/ioutil"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+)
+
+// Tamper detection compares the scene of every snapshot interval with a
+// rolling reference of the camera view. The reference is a running average of
+// the scenes that are not tampered, so it follows the slow changes of the
+// light. A camera is tampered when the structural similarity(SSIM) of the
+// scene to the reference falls below the threshold of the camera, or the
+// scene loses most of its sharpness as the camera is covered or defocused.
+// The camera is marked degraded and a camera.tampered event is raised along
+// with the JPEG images of the reference and the tampered scene.
+
+// #include "videomask.h"
+import "C"
+
+const (
+    //Directory in the camera directory for the evidence of tampering.
+    TAMPER_DIR_NAME = "tamper"
+    TAMPER_REFERENCE_FILE_NAME = "reference.jpg"
+    TAMPER_SCENE_FILE_NAME = "tampered.jpg"
+    TAMPER_EVIDENCE_WIDTH = 640
+    //Weight of a new scene in the rolling reference.
+    TAMPER_REFERENCE_WEIGHT = 0.1
+    //Scene is covered or defocused when its sharpness falls below this
+    // ratio of the reference sharpness.
+    TAMPER_SHARPNESS_RATIO = 0.5
+    //Luma deviation below which a scene that lost its sharpness is covered.
+    TAMPER_COVERED_DEVIATION = 8
+    //Checks a camera stays tampered before its scene is taken as the new
+    // reference, as the camera is moved for good.
+    TAMPER_REFERENCE_RESET_CHECKS = 30
+    //Size of the blocks the SSIM is calculated on.
+    SSIM_BLOCK_SIZE = 8
+)
+
+//Reference of the camera view and the tampering detected against it.
+type tamperState struct {
+    //Running average of the gray scenes, nil until the first scene.
+    reference []float64
+    referenceJPEG []byte
+    tampered bool
+    //Checks the camera stayed tampered.
+    tamperedChecks int
+}
+
+//Return the mean SSIM of the blocks of the gray images. The blocks with any
+// masked pixel are left out, as they are same in both the images.
+//'ok' is false if all the blocks are masked.
+func getSSIM(gray []byte, ref []float64, bitmap []byte) (ssim float64,
+                                                         ok bool) {
+    const c1 = (0.01 * 255) * (0.01 * 255)
+    const c2 = (0.03 * 255) * (0.03 * 255)
+    var sum float64
+    blocks := 0
+    for by := 0; by + SSIM_BLOCK_SIZE <= SCENE_GRAY_HEIGHT;
+        by += SSIM_BLOCK_SIZE {
+        for bx := 0; bx + SSIM_BLOCK_SIZE <= SCENE_GRAY_WIDTH;
+            bx += SSIM_BLOCK_SIZE {
+            var sumX, sumY, sumXX, sumYY, sumXY float64
+            masked := false
+            for y := by; y < by + SSIM_BLOCK_SIZE && !masked; y++ {
+                for x := bx; x < bx + SSIM_BLOCK_SIZE; x++ {
+                    i := y * SCENE_GRAY_WIDTH + x
+                    if bitmap != nil && bitmap[i] != C.VS_MASK_NONE {
+                        masked = true
+                        break
+                    }
+                    valX := float64(gray[i])
+                    valY := ref[i]
+                    sumX += valX
+                    sumY += valY
+                    sumXX += valX * valX
+                    sumYY += valY * valY
+                    sumXY += valX * valY
+                }
+            }
+            if masked {
+                continue
+            }
+            n := float64(SSIM_BLOCK_SIZE * SSIM_BLOCK_SIZE)
+            meanX := sumX / n
+            meanY := sumY / n
+            varX := sumXX / n - meanX * meanX
+            varY := sumYY / n - meanY * meanY
+            covXY := sumXY / n - meanX * meanY
+            sum += ((2 * meanX * meanY + c1) * (2 * covXY + c2)) /
+                   ((meanX * meanX + meanY * meanY + c1) * (varX + varY + c2))
+            blocks++
+        }
+    }
+    if blocks == 0 {
+        return 0, false
+    }
+    return sum / float64(blocks), true
+}
+
+//Return the mean gradient of the unmasked pixels, 'value' returns the pixel
+// at the index.
+func getSharpness(value func(int) float64, bitmap []byte) float64 {
+    var sum float64
+    count := 0
+    for y := 0; y + 1 < SCENE_GRAY_HEIGHT; y++ {
+        for x := 0; x + 1 < SCENE_GRAY_WIDTH; x++ {
+            i := y * SCENE_GRAY_WIDTH + x
+            if bitmap != nil && bitmap[i] != C.VS_MASK_NONE {
+                continue
+            }
+            sum += math.Abs(value(i + 1) - value(i)) +
+                   math.Abs(value(i + SCENE_GRAY_WIDTH) - value(i))
+            count++
+        }
+    }
+    if count == 0 {
+        return 0
+    }
+    return sum / float64(count)
+}
+
+//Return the reason the scene is tampered against the reference, empty if it
+// is not tampered.
+func getTamperReason(gray []byte, ref []float64, bitmap []byte,
+                     threshold float64) string {
+    refSharpness := getSharpness(func(i int) float64 {
+                                    return ref[i]
+                                 }, bitmap)
+    sharpness := getSharpness(func(i int) float64 {
+                                  return float64(gray[i])
+                              }, bitmap)
+    if refSharpness > 0 && sharpness < refSharpness * TAMPER_SHARPNESS_RATIO {
+        _, deviation, ok := getLumaStats(gray, bitmap)
+        if ok && deviation < TAMPER_COVERED_DEVIATION {
+            return dataSet.CAMERA_TAMPER_COVERED
+        }
+        return dataSet.CAMERA_TAMPER_DEFOCUSED
+    }
+    ssim, ok := getSSIM(gray, ref, bitmap)
+    if ok && ssim * 100 < threshold {
+        return dataSet.CAMERA_TAMPER_MOVED
+    }
+    return ""
+}
+
+//Make the scene the reference of the camera view.
+func (state *tamperState)resetReference(check *sceneCheck) {
+    state.reference = make([]float64, len(check.gray))
+    for i := range check.gray {
+        state.reference[i] = float64(check.gray[i])
+    }
+    state.referenceJPEG = check.jpeg
+    state.tampered = false
+    state.tamperedChecks = 0
+}
+
+//Blend the scene that is not tampered into the rolling reference.
+func (state *tamperState)updateReference(check *sceneCheck) {
+    for i := range check.gray {
+        state.reference[i] += (float64(check.gray[i]) - state.reference[i]) *
+                              TAMPER_REFERENCE_WEIGHT
+    }
+    if check.jpeg != nil {
+        state.referenceJPEG = check.jpeg
+    }
+}
+
+//Update the state with the tamper reason of the scene, empty if the scene is
+// not tampered. 'raise' is true when the camera is newly tampered, and
+// 'restored' when the camera is back to the reference or its new view is
+// taken as the reference.
+func (state *tamperState)applyCheck(check *sceneCheck,
+                                    reason string) (raise bool,
+                                                    restored bool) {
+    if len(reason) == 0 {
+        restored = state.tampered
+        state.tampered = false
+        state.tamperedChecks = 0
+        state.updateReference(check)
+        return false, restored
+    }
+    if !state.tampered {
+        state.tampered = true
+        state.tamperedChecks = 1
+        return true, false
+    }
+    state.tamperedChecks++
+    if state.tamperedChecks >= TAMPER_REFERENCE_RESET_CHECKS {
+        //Camera is moved for good, its new view is the reference.
+        state.resetReference(check)
+        return false, true
+    }
+    return false, false
+}
+
+//Set or clear the degraded state of the camera in its capture status.
+func (camThread *RTSPCameraThread)setCameraDegraded(reason string) {
+    log := logging.GetLoggerInstance()
+    dataObj := dataSetImpl.GetDataSetObj()
+    status, err := dataObj.GetCameraCaptureStatus(camThread.name)
+    if err != nil {
+        status = &dataSet.CameraCaptureStatus{CamName: camThread.name}
+    }
+    status.Degraded = len(reason) != 0
+    status.DegradedReason = reason
+    status.DegradedTime = 0
+    if status.Degraded {
+        status.DegradedTime = time.Now().Unix()
+    }
+    err = dataObj.UpdateCameraCaptureStatus(status)
+    if err != nil {
+        log.Error("Failed to update capture status of %s, err: %s",
+                  camThread.name, err)
+    }
+}
+
+//Write the reference and the tampered scene as evidence of the tampering.
+//Returns the evidence directory.
+func (camThread *RTSPCameraThread)saveTamperEvidence(check *sceneCheck,
+                                    refJPEG []byte, now time.Time) string {
+    log := logging.GetLoggerInstance()
+    camThread.threadLock.RLock()
+    evidenceDir := camThread.videoPath + "/" + TAMPER_DIR_NAME + "/" +
+                   now.In(camThread.location).Format(TIME_DIR_FORMAT)
+    camThread.threadLock.RUnlock()
+    err := os.MkdirAll(evidenceDir, 0744)
+    if err != nil {
+        log.Error("Failed to create evidence directory %s, err: %s",
+                  evidenceDir, err)
+        return ""
+    }
+    images := map[string][]byte{
+        TAMPER_REFERENCE_FILE_NAME: refJPEG,
+        TAMPER_SCENE_FILE_NAME: check.jpeg,
+    }
+    for name, image := range images {
+        if image == nil {
+            continue
+        }
+        err = ioutil.WriteFile(evidenceDir + "/" + name, image, 0644)
+        if err != nil {
+            log.Error("Failed to write evidence %s/%s, err: %s", evidenceDir,
+                      name, err)
+        }
+    }
+    return evidenceDir
+}
+
+//Mark the camera degraded and raise the camera.tampered event.
+func (camThread *RTSPCameraThread)raiseTamperEvent(check *sceneCheck,
+                                    refJPEG []byte, reason string) {
+    log := logging.GetLoggerInstance()
+    now := time.Now()
+    log.Error("Camera %s is tampered, the view is %s", camThread.name, reason)
+    camThread.setCameraDegraded(reason)
+    event := &dataSet.Event{
+        Id: fmt.Sprintf("%s-%s-%d", dataSet.EVENT_CAMERA_TAMPERED,
+                        camThread.name, now.UnixNano()),
+        Type: dataSet.EVENT_CAMERA_TAMPERED,
+        CamName: camThread.name,
+        Time: now.Unix(),
+        Message: fmt.Sprintf("Camera view is %s", reason),
+        Evidence: camThread.saveTamperEvidence(check, refJPEG, now),
+    }
+    err := dataSetImpl.GetDataSetObj().AddEvent(event)
+    if err != nil {
+        log.Error("Failed to record event %s, err: %s", event.Id, err)
+    }
+}
+
+//Check the decoded scene against the reference of the camera view. The event
+// is raised once when the camera is tampered, and the camera stays degraded
+// until the scene is back to the reference. The masked regions are left out.
+func (camThread *RTSPCameraThread)checkTamper(check *sceneCheck,
+                                    masks []dataSet.PrivacyMask) {
+    log := logging.GetLoggerInstance()
+    if check.gray == nil {
+        //Undecodable scenes are handled by the quality check.
+        return
+    }
+    var bitmap []byte
+    if len(masks) != 0 {
+        bitmap = rasterizePrivacyMasks(masks, SCENE_GRAY_WIDTH,
+                                       SCENE_GRAY_HEIGHT)
+    }
+    camThread.threadLock.Lock()
+    state := &camThread.tamper
+    if state.reference == nil {
+        state.resetReference(check)
+        camThread.threadLock.Unlock()
+        return
+    }
+    reason := getTamperReason(check.gray, state.reference, bitmap,
+                              float64(camThread.tamperThreshold))
+    refJPEG := state.referenceJPEG
+    raise, restored := state.applyCheck(check, reason)
+    camThread.threadLock.Unlock()
+
+    if raise {
+        camThread.raiseTamperEvent(check, refJPEG, reason)
+    } else if restored {
+        log.Info("Camera %s view is restored", camThread.name)
+        camThread.setCameraDegraded("")
+    } else if len(reason) != 0 {
+        log.Trace("Camera %s is still tampered, the view is %s",
+                  camThread.name, reason)
+    }
+}
@@ -0,0 +1,142 @@
+package RTSPCameraImpl
+
+// Test file for validating the tamper detection of the cameras.
+import (
+    "testing"
+    "VideoTimeLapse/dataSet"
+)
+
+//Return a gray scene of the pixel values at every x, y.
+func getTamperTestScene(value func(x int, y int) byte) []byte {
+    gray := make([]byte, SCENE_GRAY_WIDTH * SCENE_GRAY_HEIGHT)
+    for y := 0; y < SCENE_GRAY_HEIGHT; y++ {
+        for x := 0; x < SCENE_GRAY_WIDTH; x++ {
+            gray[y * SCENE_GRAY_WIDTH + x] = value(x, y)
+        }
+    }
+    return gray
+}
+
+func getTamperTestReference(gray []byte) []float64 {
+    ref := make([]float64, len(gray))
+    for i := range gray {
+        ref[i] = float64(gray[i])
+    }
+    return ref
+}
+
+func TestGetTamperReason(t *testing.T) {
+    checker := getTamperTestScene(func(x int, y int) byte {
+        if (x + y) % 2 == 0 {
+            return 50
+        }
+        return 200
+    })
+    ref := getTamperTestReference(checker)
+    //Same sharpness and luma as the reference, but every pixel is flipped.
+    shifted := getTamperTestScene(func(x int, y int) byte {
+        if (x + y) % 2 == 0 {
+            return 200
+        }
+        return 50
+    })
+    covered := getTamperTestScene(func(x int, y int) byte {
+        return 30
+    })
+    //Bright and dark regions remain, without any detail.
+    defocused := getTamperTestScene(func(x int, y int) byte {
+        return byte(x * 2)
+    })
+    black := dataSet.PrivacyMask{Mode: dataSet.PRIVACY_MASK_BLACK}
+    allMasked := make([]byte, len(checker))
+    for i := range allMasked {
+        allMasked[i] = getMaskPixelMode(&black)
+    }
+    tests := []struct {
+        name string
+        gray []byte
+        bitmap []byte
+        expected string
+    }{
+        {"same view", checker, nil, ""},
+        {"moved", shifted, nil, dataSet.CAMERA_TAMPER_MOVED},
+        {"covered", covered, nil, dataSet.CAMERA_TAMPER_COVERED},
+        {"defocused", defocused, nil, dataSet.CAMERA_TAMPER_DEFOCUSED},
+        {"all masked", covered, allMasked, ""},
+    }
+    for _, test := range tests {
+        reason := getTamperReason(test.gray, ref, test.bitmap, 60)
+        if reason != test.expected {
+            t.Errorf("%s: got reason %q, expected %q", test.name, reason,
+                     test.expected)
+        }
+    }
+}
+
+func TestGetSSIM(t *testing.T) {
+    scene := getTamperTestScene(func(x int, y int) byte {
+        return byte((x * 7 + y * 13) % 256)
+    })
+    ssim, ok := getSSIM(scene, getTamperTestReference(scene), nil)
+    if !ok || ssim < 0.999 || ssim > 1.001 {
+        t.Errorf("Same scene: got ssim %f/%v, expected 1", ssim, ok)
+    }
+    black := dataSet.PrivacyMask{Mode: dataSet.PRIVACY_MASK_BLACK}
+    bitmap := make([]byte, len(scene))
+    for i := range bitmap {
+        bitmap[i] = getMaskPixelMode(&black)
+    }
+    _, ok = getSSIM(scene, getTamperTestReference(scene), bitmap)
+    if ok {
+        t.Errorf("All masked: got ssim, expected none")
+    }
+}
+
+func TestTamperStateApplyCheck(t *testing.T) {
+    scene := &sceneCheck{gray: getTamperTestScene(func(x int, y int) byte {
+        return 100
+    })}
+    moved := &sceneCheck{gray: getTamperTestScene(func(x int, y int) byte {
+        return 200
+    })}
+    var state tamperState
+    state.resetReference(scene)
+    raise, restored := state.applyCheck(moved, dataSet.CAMERA_TAMPER_MOVED)
+    if !raise || restored || !state.tampered {
+        t.Errorf("First tampered check: got raise %v, restored %v",
+                 raise, restored)
+    }
+    //Event is raised once while the camera stays tampered.
+    raise, restored = state.applyCheck(moved, dataSet.CAMERA_TAMPER_MOVED)
+    if raise || restored || state.tamperedChecks != 2 {
+        t.Errorf("Tampered again: got raise %v, restored %v, checks %d",
+                 raise, restored, state.tamperedChecks)
+    }
+    raise, restored = state.applyCheck(scene, "")
+    if raise || !restored || state.tampered || state.tamperedChecks != 0 {
+        t.Errorf("View restored: got raise %v, restored %v", raise,
+                 restored)
+    }
+    raise, restored = state.applyCheck(scene, "")
+    if raise || restored {
+        t.Errorf("View unchanged: got raise %v, restored %v", raise,
+                 restored)
+    }
+    //Reference follows the scenes that are not tampered.
+    state.applyCheck(moved, "")
+    expected := 100 + (200 - 100) * TAMPER_REFERENCE_WEIGHT
+    if state.reference[0] != expected {
+        t.Errorf("Reference update: got %f, expected %f",
+                 state.reference[0], expected)
+    }
+    //New view is taken as the reference once the camera stays moved.
+    state.resetReference(scene)
+    for i := 1; i < TAMPER_REFERENCE_RESET_CHECKS; i++ {
+        state.applyCheck(moved, dataSet.CAMERA_TAMPER_MOVED)
+    }
+    raise, restored = state.applyCheck(moved, dataSet.CAMERA_TAMPER_MOVED)
+    if raise || !restored || state.tampered || state.reference[0] != 200 {
+        t.Errorf("Moved for good: got raise %v, restored %v, reference %f",
+                 raise, restored, state.reference[0])
+    }
+}
@@ -6,6 +6,7 @@ import (
     "time"
     "sync"
     "sort"
+    "regexp"
     "unsafe"
     "io/ioutil"
     "path/filepath"
@@ -13,6 +14,7 @@ import (
     "VideoTimeLapse/appErrors"
     "VideoTimeLapse/config"
     "VideoTimeLapse/logging"
+    "VideoTimeLapse/jobQueue"
 
 )
 
@@ -22,7 +24,7 @@ import (
 
 // #include "videomux.h"
 // #include <stdlib.h>
-// #cgo LDFLAGS: -lavformat -lavdevice -lavcodec -lavutil
+// #cgo LDFLAGS: -lavformat -lavdevice -lavcodec -lavfilter -lavutil
 // #cgo CFLAGS: -std=c11
 // #cgo pkg-config: libavcodec
 import "C"
@@ -49,8 +51,52 @@ type RTSPCameraThread struct {
     videoPath string
     videoLen uint64 //Length of video to create timelapse.
     videoInterval uint64 //Interval between the video snapshots.
-    totOutFrames uint64 //Total number of frames in final timelapse video
+    snapshotPkts uint64 //Number of packets in a snapshot clip.
+    snapshotSec uint64 //Length of snapshot clip in seconds, if set.
+    speedFactor uint64 //Speed-up factor for the timelapse compaction.
+    outputLenSec uint64 //Target length of compacted timelapse, if set.
+    outputFormats []string //Formats the timelapse is rendered to.
+    enableHLS bool //Package the timelapse as HLS.
+    resumePolicy string //Policy for the cycle interrupted by a restart.
+    keepSnapshotDays uint64 //Days to keep the snapshots after rendering.
+    overlay videoOverlay //Text burned into the timelapse frames.
+    privacyMasks []dataSet.PrivacyMask //Regions hidden in the snapshots.
+    captureMode string //Snapshots at every interval or on scene change.
+    changeThreshold uint64 //Scene change in percent to keep a snapshot.
+    maxSnapInterval uint64 //Maximum seconds between the kept snapshots.
+    //Scene of the last kept snapshot in adaptive capture, nil at the start
+    // of a cycle.
+    changeRef []byte
+    changeRefTime time.Time
+    qualityCheck bool //Reject the snapshots of bad frames.
+    minMeanLuma uint64
+    maxMeanLuma uint64
+    minLumaDeviation uint64
+    qualityRetries uint64 //Captures retried after a rejected snapshot.
+    deflickerWindow uint64 //Frames to smooth the brightness, 0 if disabled.
+    tamperDetection bool //Detect the moved, covered and defocused camera.
+    tamperThreshold uint64 //Scene similarity in percent below which moved.
+    tamper tamperState
+    lastScene []byte //Scene of the previous capture, for the stats.
+    recording bool //Record the stream continuously to segments.
+    segmentSec uint64 //Length of a recorded segment in seconds.
+    segmentRetentionHours uint64 //Hours the recorded segments are kept.
+    clipBufferSec uint64 //Seconds of the stream buffered for the clips.
+    //Keep the stream open for the segments, the clips and the profiles.
+    keepStream bool
+    //Copy the snapshots from the kept stream instead of connecting to the
+    // camera for every snapshot.
+    streamCapture bool
+    //Recorder of the running thread, if the stream is kept open.
+    recorder *segmentRecorder
+    profile string //Capture profile of the thread, empty for the camera.
+    parent *RTSPCameraThread //Camera thread of a profile thread.
+    profiles []*RTSPCameraThread //Threads of the capture profiles.
+    location *time.Location //Timezone the cycles are named and aligned in.
+    cycleAlign string //Wall clock boundary the cycles are aligned to.
     startTime time.Time
+    //End of the running cycle, the cycle is rendered once it is passed.
+    cycleEnd time.Time
     threadLock sync.RWMutex
     // waitGroup for tracking the completion of snapshot generation.
     // The snapshots are generated at the predefined time intervals in go
@@ -58,13 +104,28 @@ type RTSPCameraThread struct {
     // The thread must wait for all the snapshot generation to
     // complete before concat/stitching them together as a single video.
     snapShotJoin sync.WaitGroup
+    //Lock to serialize the live HLS packaging of snapshots and the cycle
+    // that is currently packaged.
+    liveLock sync.Mutex
+    liveCycle string
+    //Stream parameters last detected from the camera.
+    streamLock sync.Mutex
+    streamInfo *dataSet.CameraStreamInfo
+    //Snapshot files that are still being written. These are left out of the
+    // timelapse rendered in the middle of a cycle.
+    snapshotLock sync.Mutex
+    activeSnapshots map[string]bool
+    //Signal to close the running cycle before its end.
+    rotateSignal chan bool
 }
 
 const (
     TIME_DIR_FORMAT = "20060102150405"
-    DEFAULT_SNAPSHOT_LEN = 48
 )
 
+//Snapshot clips in a cycle directory are named by their index in the cycle.
+var snapshotFileRegex = regexp.MustCompile(`^[0-9]+\.mp4$`)
+
 var rtspOnce sync.Once
 //Initialize the camera thread with all the relevant information.
 func (camThread *RTSPCameraThread)InitCameraThread(cam *dataSet.Camera,
@@ -93,6 +154,9 @@ func (camThread *RTSPCameraThread)initCameraThread__(cam *dataSet.Camera,
     camThread.pwd = cam.Pwd
     camThread.status = cam.Status
     camThread.exitSignal = make(chan bool)
+    if camThread.rotateSignal == nil {
+        camThread.rotateSignal = make(chan bool, 1)
+    }
     if videoPath != "" {
         videoDir, _ := filepath.Abs(videoPath)
         camThread.videoPath = videoDir + "/" + camThread.name
@@ -109,6 +173,62 @@ func (camThread *RTSPCameraThread)initCameraThread__(cam *dataSet.Camera,
         //Interval cannot be more than the total recording duratation.
         camThread.videoInterval = camThread.videoLen
     }
+    camThread.snapshotPkts = cam.SnapshotPkts
+    if camThread.snapshotPkts == 0 {
+        camThread.snapshotPkts = dataSet.CAMERA_DEFAULT_SNAPSHOT_PKTS
+    }
+    camThread.snapshotSec = cam.SnapshotSec
+    camThread.speedFactor = cam.SpeedFactor
+    if camThread.speedFactor == 0 {
+        camThread.speedFactor = dataSet.CAMERA_DEFAULT_SPEED_FACTOR
+    }
+    camThread.outputLenSec = cam.OutputLenSec
+    camThread.outputFormats = cam.GetOutputFormats()
+    camThread.enableHLS = cam.EnableHLS
+    camThread.resumePolicy = cam.ResumePolicy
+    camThread.keepSnapshotDays = cam.KeepSnapshotDays
+    camThread.overlay = newVideoOverlay(cam)
+    camThread.captureMode = cam.CaptureMode
+    camThread.changeThreshold = cam.ChangeThreshold
+    camThread.maxSnapInterval = cam.MaxSnapInterval
+    camThread.changeRef = nil
+    camThread.qualityCheck = cam.QualityCheck
+    camThread.minMeanLuma = cam.MinMeanLuma
+    camThread.maxMeanLuma = cam.MaxMeanLuma
+    camThread.minLumaDeviation = cam.MinLumaDeviation
+    camThread.qualityRetries = cam.QualityRetries
+    camThread.tamperDetection = cam.TamperDetection
+    camThread.tamperThreshold = cam.TamperThreshold
+    camThread.tamper = tamperState{}
+    camThread.lastScene = nil
+    camThread.recording = cam.Recording
+    camThread.clipBufferSec = cam.ClipBufferSec
+    camThread.location = cam.GetLocation()
+    camThread.cycleAlign = cam.CycleAlign
+    //Stream of a profile is kept open by its camera thread.
+    camThread.keepStream = cam.IsStreamShared() && camThread.parent == nil
+    camThread.streamCapture = len(cam.Profiles) != 0
+    camThread.segmentSec = cam.SegmentSec
+    if camThread.segmentSec == 0 {
+        camThread.segmentSec = dataSet.CAMERA_DEFAULT_SEGMENT_SEC
+    }
+    camThread.segmentRetentionHours = cam.SegmentRetentionHours
+    if camThread.segmentRetentionHours == 0 {
+        camThread.segmentRetentionHours =
+                            dataSet.CAMERA_DEFAULT_SEGMENT_RETENTION_HOURS
+    }
+    camThread.deflickerWindow = getDeflickerWindow(cam)
+    camThread.loadStreamInfo()
+    masks, maskErr := cam.GetPrivacyMasks()
+    if maskErr != nil {
+        //Camera must not record without its masks.
+        return maskErr
+    }
+    camThread.privacyMasks = masks
+    profileErr := camThread.initProfileThreads__(cam)
+    if profileErr != nil {
+        return profileErr
+    }
     return err
 }
 
@@ -127,22 +247,42 @@ func (camThread *RTSPCameraThread)destroyInput(input *Input) {
 
 func (camThread *RTSPCameraThread)destroyOutput(output *Output,
                                     waitWrite *sync.WaitGroup) {
+    //Snapshot generation is tracked in destroy function, as every snapshot
+    // generation must end with destroy output. So we are considering, a snapshot
+    // generation is complete, when destroy is finished.
+    if camThread.closeOutput(output, waitWrite) {
+        camThread.snapShotJoin.Done()
+    }
+}
+
+//Close the output once all the writes on it are done. The outputs that are
+// not tracked as snapshots, like the segments, are closed with this function.
+//Returns false if the output is already closed.
+func (camThread *RTSPCameraThread)closeOutput(output *Output,
+                                    waitWrite *sync.WaitGroup) bool {
     if output == nil {
-        return
+        return false
     }
     output.mutex.Lock()
     defer output.mutex.Unlock()
     if output.vsOutput == nil {
-        return
+        return false
     }
-    //Snapshot generation is tracked in destroy function, as every snapshot
-    // generation must end with destroy output. So we are considering, a snapshot
-    // generation is complete, when destroy is finished.
-    defer camThread.snapShotJoin.Done()
     //Wait for all writes to complete before triggering the destroy.
     waitWrite.Wait()
     C.vs_destroy_output(output.vsOutput)
     output.vsOutput = nil
+    return true
+}
+
+//Complete the snapshot generation once all the writes are done and add the
+// snapshot to the running timelapse.
+func (camThread *RTSPCameraThread)finishSnapshot(output *Output,
+                                    waitWrite *sync.WaitGroup,
+                                    snapshotFile string) {
+    camThread.destroyOutput(output, waitWrite)
+    camThread.setSnapshotActive(snapshotFile, false)
+    camThread.appendLiveHLS(snapshotFile)
 }
 
 func (camThread *RTSPCameraThread)openInput(inputFormat string,
@@ -173,8 +313,9 @@ func (camThread *RTSPCameraThread)openMP4Output(outputFile string,
     outputFormatC := C.CString("mp4")
     outputURLC := C.CString("file:" + outputFile)
     input.mutex.RLock()
-    output := C.vs_open_output(outputFormatC, outputURLC, input.vsInput,
-                               C.bool(false))
+    params := camThread.getOutputParams()
+    output := C.vs_open_output_params(outputFormatC, outputURLC,
+                                      input.vsInput, params, C.bool(false))
     input.mutex.RUnlock()
     if output == nil {
         log.Error("Failed to open MP4 output file %s", outputFile)
@@ -219,34 +360,87 @@ func (camThread *RTSPCameraThread)writePacket(input *Input,
     C.av_packet_free(&pkt)
 }
 
+//Return the RTSP URL of the camera, with the credentials if present.
+func getRTSPURL(ip string, port string, uname string, pwd string) string {
+    url := "rtsp://" + ip + ":" + port + "/"
+    if len(uname) != 0 {
+        url = "rtsp://" + uname + "@" + ip + ":" + port + "/"
+    }
+    if len(pwd) != 0 {
+        //if password is present, the username must be present.
+        url = "rtsp://" + uname + ":" + pwd + "@" + ip + ":" + port + "/"
+    }
+    return url
+}
+
 //Create a videosnapshot with specific name.
-func (camThread *RTSPCameraThread)createVideoSnapshot(fileName string) error {
+//Returns false if the snapshot is not kept, as the scene is not changed in
+// adaptive capture, or errSnapshotRejected if the scene is rejected by the
+// quality check. The stats of the capture are recorded in either case.
+func (camThread *RTSPCameraThread)createVideoSnapshot(fileName string) (bool,
+                                                      error) {
     var err error
     log := logging.GetLoggerInstance()
     log.Trace("Creating video snapshot %s", camThread.name)
+    captureStart := time.Now()
+    stats := camThread.newSnapshotStats(captureStart)
+    defer camThread.addSnapshotStats(stats)
     var input *Input
     camThread.threadLock.RLock()
-    videoPath := camThread.videoPath + "/" +
-                camThread.startTime.Format(TIME_DIR_FORMAT)
-    var url string
-    url = "rtsp://" + camThread.ip + ":" + camThread.port + "/"
-    if len(camThread.uname) != 0 {
-        url = "rtsp://" + camThread.uname + "@" +
-                       camThread.ip + ":" + camThread.port + "/"
-    }
-    if len(camThread.pwd) != 0 {
-        //if password is present, the username must be present.
-        url = "rtsp://" + camThread.uname + ":" + camThread.pwd + "@" +
-                   camThread.ip + ":" + camThread.port + "/"
-    }
+    cycleName := camThread.getCycleName__(camThread.startTime)
+    videoPath := camThread.videoPath + "/" + cycleName
+    url := getRTSPURL(camThread.ip, camThread.port, camThread.uname,
+                      camThread.pwd)
+    snapshotPkts := camThread.snapshotPkts
+    snapshotLen := time.Duration(camThread.snapshotSec) * time.Second
+    masks := camThread.privacyMasks
+    adaptive := camThread.captureMode == dataSet.CAMERA_CAPTURE_ADAPTIVE
+    qualityCheck := camThread.qualityCheck
+    tamperDetection := camThread.tamperDetection
     input = camThread.openInput("rtsp", url)
     if input == nil || input.vsInput == nil {
         log.Error("Failed to create Input handler %s", camThread.name)
         camThread.threadLock.RUnlock()
-        return nil
+        return false, nil
     }
 
     camThread.threadLock.RUnlock()
+    camThread.updateStreamInfo(input)
+    score := float64(dataSet.SNAPSHOT_NO_CHANGE_SCORE)
+    //Scene is always decoded for the stats of the capture.
+    jpegWidth := 0
+    if tamperDetection {
+        jpegWidth = TAMPER_EVIDENCE_WIDTH
+    }
+    check := camThread.decodeScene(input, masks, jpegWidth)
+    camThread.setSceneStats(stats, check, masks, captureStart)
+    if tamperDetection {
+        camThread.checkTamper(check, masks)
+    }
+    if qualityCheck {
+        reason := camThread.checkSceneQuality(check, masks)
+        if len(reason) != 0 {
+            log.Error("Rejected the snapshot of %s as the frame is %s",
+                      camThread.name, reason)
+            check.freePackets()
+            camThread.destroyInput(input)
+            camThread.addRejectedSnapshot(reason)
+            stats.Status = dataSet.SNAPSHOT_STATUS_REJECTED
+            return false, errSnapshotRejected
+        }
+    }
+    if adaptive {
+        camThread.checkSceneChange(check)
+        if !check.keep {
+            log.Trace("Scene of %s is not changed, skipping the snapshot",
+                      camThread.name)
+            check.freePackets()
+            camThread.destroyInput(input)
+            stats.Status = dataSet.SNAPSHOT_STATUS_SKIPPED
+            return false, nil
+        }
+        score = check.score
+    }
 
     //Create the output directory if not exists.
     //There is overhead of checking if a directory exists in
@@ -256,25 +450,57 @@ func (camThread *RTSPCameraThread)createVideoSnapshot(fileName string) error {
         err = os.MkdirAll(videoPath, 0744)
         if err != nil {
             log.Error("Failed to create directory %s", videoPath)
+            check.freePackets()
             camThread.destroyInput(input)
-            return err
+            return false, err
         }
     }
     videoPath = videoPath + "/" + fileName
-    output := camThread.openMP4Output(videoPath, input)
-    if output == nil || output.vsOutput == nil {
-        log.Trace("Empty Output for %s", videoPath)
-        return nil
+    var output *Output
+    var masked *maskedOutput
+    if len(masks) != 0 {
+        //Frames are masked before writing, packets are never copied.
+        masked = camThread.openMaskedOutput(videoPath, input,
+                                            camThread.getPrivacyMask(masks))
+        if masked == nil {
+            check.freePackets()
+            camThread.destroyInput(input)
+            return false, nil
+        }
+    } else {
+        output = camThread.openMP4Output(videoPath, input)
+        if output == nil || output.vsOutput == nil {
+            log.Trace("Empty Output for %s", videoPath)
+            check.freePackets()
+            camThread.destroyInput(input)
+            return false, nil
+        }
     }
+    camThread.setSnapshotActive(videoPath, true)
     //waitgroup for confirm all write complete before destroying the output.
     var waitWrite sync.WaitGroup
-    //Read the frames in the loop.
-    for numFrames := 0; numFrames<DEFAULT_SNAPSHOT_LEN; {
+    //Snapshot starts with the frame checked before writing.
+    numFrames := uint64(len(check.pkts))
+    camThread.writeScenePackets(check, input, output, masked, &waitWrite)
+    if check.gray != nil {
+        camThread.setChangeRef(check.gray)
+    }
+    //Read the frames in the loop. The clip length is either in seconds or
+    // in number of packets.
+    clipStart := time.Now()
+    for {
+        if snapshotLen != 0 {
+            if time.Since(clipStart) >= snapshotLen {
+                break
+            }
+        } else if numFrames >= snapshotPkts {
+            break
+        }
         var pkt C.AVPacket
         readRes := C.int(0)
         if input == nil || input.vsInput == nil {
             //Cannot read from a null input. return now.
-            return nil
+            return true, nil
         }
         readRes = C.vs_read_packet(input.vsInput, &pkt, C.bool(false))
         if readRes == -1 {
@@ -283,16 +509,29 @@ func (camThread *RTSPCameraThread)createVideoSnapshot(fileName string) error {
         if readRes == 0 {
             continue
         }
-        go camThread.writePacket(input, output, &pkt, &waitWrite)
+        addStatsPacket(stats, &pkt)
+        if masked != nil {
+            masked.writePacket(&pkt)
+        } else {
+            go camThread.writePacket(input, output, &pkt, &waitWrite)
+        }
         numFrames++
     }
     camThread.snapShotJoin.Add(1)
     // We dont wanted to block the orignal thread until the destroy finished.
     // destroy will happen only when all the output write completes.
-    go camThread.destroyOutput(output, &waitWrite)
+    if masked != nil {
+        go camThread.finishMaskedSnapshot(masked, videoPath)
+    } else {
+        go camThread.finishSnapshot(output, &waitWrite, videoPath)
+    }
     camThread.destroyInput(input)
+    camThread.addSnapshotMeta(cycleName, fileName, score)
+    stats.Status = dataSet.SNAPSHOT_STATUS_KEPT
+    stats.CycleDir = cycleName
+    stats.File = fileName
     log.Trace("Created camera thread snapshot %s", videoPath)
-    return nil
+    return true, nil
 }
 
 func (camThread *RTSPCameraThread)isExitFired() bool {
@@ -319,25 +558,56 @@ func (camThread *RTSPCameraThread)deleteInputSnapshots(dir string,
     }
 }
 
-func (camThread *RTSPCameraThread)compactTimeLapseVideo(videoPath string) {
+//Return the speed-up factor for compacting a timelapse video of 'duration'
+// seconds. The target output length takes precedence over the speed factor
+// when both are set.
+func (camThread *RTSPCameraThread)getCompactSpeed(duration float64) float64 {
+    camThread.threadLock.RLock()
+    defer camThread.threadLock.RUnlock()
+    speed := float64(camThread.speedFactor)
+    if camThread.outputLenSec != 0 && duration > 0 {
+        speed = duration / float64(camThread.outputLenSec)
+    }
+    if speed < 1 {
+        //Never slow down the timelapse video.
+        speed = 1
+    }
+    return speed
+}
+
+//Compact the stitched timelapse video of 'duration' seconds to the final
+// timelapse by speeding it up.
+//Returns the final timelapse file, empty string on failure.
+func (camThread *RTSPCameraThread)compactTimeLapseVideo(videoPath string,
+                                                duration float64) string {
+    return camThread.compactVideo(videoPath,
+                                  camThread.getCompactSpeed(duration))
+}
+
+//Speed up the stitched timelapse video by 'speed' to the final timelapse in
+// the same directory.
+//Returns the final timelapse file, empty string on failure.
+func (camThread *RTSPCameraThread)compactVideo(videoPath string,
+                                               speed float64) string {
     log := logging.GetLoggerInstance()
     input := camThread.openInput("mp4",videoPath)
     if input == nil || input.vsInput == nil {
         log.Error("Failed to compact the timelapse video")
-        return
+        return ""
     }
     dir, err := filepath.Abs(filepath.Dir(videoPath))
     if err != nil {
         log.Error("Failed to get the directory for compact output file")
-        return
+        camThread.destroyInput(input)
+        return ""
     }
-    outputFile := dir + "/FinalTimeLapse.mp4"
+    outputFile := dir + "/" + FINAL_TIMELAPSE_NAME + ".mp4"
     output := camThread.openMP4Output(outputFile, input)
     if output == nil || output.vsOutput == nil {
         log.Error("Failed to create timelapse output handler %s",
                         outputFile)
         camThread.destroyInput(input)
-        return
+        return ""
     }
     for {
         var pktIn C.AVPacket
@@ -360,7 +630,7 @@ func (camThread *RTSPCameraThread)compactTimeLapseVideo(videoPath string) {
         }
         output.mutex.Lock()
         writeRes := C.vs_write_packet_speed(input.vsInput,
-                                     output.vsOutput, pktOut, 4,
+                                     output.vsOutput, pktOut, C.float(speed),
                                      C.bool(false))
         output.mutex.Unlock()
         if writeRes == -1 {
@@ -376,46 +646,25 @@ func (camThread *RTSPCameraThread)compactTimeLapseVideo(videoPath string) {
         C.vs_destroy_output(output.vsOutput)
         output.mutex.Unlock()
     }
+    return outputFile
 }
 
-// Function to create timelapse video from snapshots.
-// This function go through every snapshot files and stitch together
-// to generate final snapshot video
-func (camThread *RTSPCameraThread)createTimelapseWithSnapshots(
-                                videoPath string) {
+//Stitch the snapshot 'files' in 'videoPath' together to 'timeLapseFile' by
+// packet copy. The concat list 'listName' is created in 'videoPath'.
+//Returns the duration of the stitched video in seconds.
+func (camThread *RTSPCameraThread)stitchSnapshots(videoPath string,
+                                    files []os.FileInfo, listName string,
+                                    timeLapseFile string) (float64, error) {
+    var err error
     log := logging.GetLoggerInstance()
-    files, err := ioutil.ReadDir(videoPath)
-    if err != nil {
-        log.Error("Failed to read directory, cannot create timelapse, err:%s",
-                    err)
-        return
-    }
-    if len(files) == 0 {
-        log.Info("Cannot create timelapse video from empty snapshots")
-        return
-    }
-    //Sort files based on its creation time.
-    sort.Slice(files, func(i,j int) bool{
-    return files[i].ModTime().Before(files[j].ModTime())
-    })
-    timeLapsePath := videoPath + "/timeLapse"
-    if _, err = os.Stat(timeLapsePath); os.IsNotExist(err) {
-        err = os.MkdirAll(timeLapsePath, 0744)
-        if err != nil {
-            log.Error("Failed to create timelapse directory %s",
-                        timeLapsePath)
-            return
-        }
-    }
-
     //Create a list files with all the snapshots.
     var listFile *os.File
-    timeLapseList := videoPath + "/timelapseList.txt"
+    timeLapseList := videoPath + "/" + listName
     listFile, err = os.Create(timeLapseList)
     if err != nil {
         log.Error("Failed to create the snapshot list file %s, err :%s",
                     timeLapseList, err)
-        return
+        return 0, err
     }
     for _, snapshot := range files {
        //Create a list file with all the snapshot file names.
@@ -425,23 +674,31 @@ func (camThread *RTSPCameraThread)createTimelapseWithSnapshots(
     concatInput := camThread.openInput("concat", timeLapseList)
     if concatInput == nil || concatInput.vsInput == nil {
         log.Error("Failed to open concat file %s", timeLapseList)
-        return
+        return 0, fmt.Errorf("Failed to open concat file %s", timeLapseList)
     }
-    timeLapseFile := timeLapsePath + "/timeLapse.mp4"
     timeLapseOutput := camThread.openMP4Output(timeLapseFile,
                                 concatInput)
     if timeLapseOutput == nil || timeLapseOutput.vsOutput == nil {
         log.Error("Failed to create timelapse output handler %s",
                         timeLapseFile)
         camThread.destroyInput(concatInput)
-        return
+        return 0, fmt.Errorf("Failed to create output %s", timeLapseFile)
     }
+    //Duration of the stitched video, used for compacting it later.
+    var duration float64
     for {
         var pktIn C.AVPacket
         readRes := C.int(0)
         concatInput.mutex.RLock()
         readRes = C.vs_read_packet(concatInput.vsInput, &pktIn,
                                        C.bool(false))
+        if readRes == 1 {
+            endTime := float64(C.vs_packet_end_time(concatInput.vsInput,
+                                                    &pktIn))
+            if endTime > duration {
+                duration = endTime
+            }
+        }
         concatInput.mutex.RUnlock()
         if readRes == -1  {
             break
@@ -469,16 +726,195 @@ func (camThread *RTSPCameraThread)createTimelapseWithSnapshots(
         C.vs_destroy_output(timeLapseOutput.vsOutput)
         timeLapseOutput.mutex.Unlock()
     }
-    camThread.deleteInputSnapshots(videoPath, files)
-    camThread.compactTimeLapseVideo(timeLapseFile)
+    return duration, nil
+}
+
+// Function to create timelapse video from snapshots.
+// This function go through every snapshot files and stitch together
+// to generate final snapshot video. The video is rendered right away, the
+// camera thread renders its cycles in background jobs instead.
+func (camThread *RTSPCameraThread)createTimelapseWithSnapshots(
+                                videoPath string) {
+    cycle, err := camThread.stitchCycle(videoPath, videoPath, nil)
+    if err != nil || cycle == nil {
+        return
+    }
+    camThread.finishCycle(cycle, nil)
+}
+
+//Return the snapshot files in 'videoPath' sorted by their creation time.
+// Snapshots in 'skipFiles' are left out.
+func getCycleSnapshots(videoPath string,
+                       skipFiles map[string]bool) ([]os.FileInfo, error) {
+    dirFiles, err := ioutil.ReadDir(videoPath)
+    if err != nil {
+        return nil, err
+    }
+    //Directory of an interrupted cycle may have files from a failed
+    // stitching as well.
+    files := []os.FileInfo{}
+    for _, file := range dirFiles {
+        if !file.IsDir() && snapshotFileRegex.MatchString(file.Name()) &&
+            !skipFiles[videoPath + "/" + file.Name()] {
+            files = append(files, file)
+        }
+    }
+    //Sort files based on its creation time.
+    sort.Slice(files, func(i,j int) bool{
+    return files[i].ModTime().Before(files[j].ModTime())
+    })
+    return files, nil
+}
+
+//Create the timelapse directory in 'outputDir' if not exists.
+func createTimeLapseDir(outputDir string) (string, error) {
+    var err error
+    timeLapsePath := outputDir + "/" + TIMELAPSE_DIR_NAME
+    if _, err = os.Stat(timeLapsePath); os.IsNotExist(err) {
+        err = os.MkdirAll(timeLapsePath, 0744)
+        if err != nil {
+            return "", err
+        }
+    }
+    return timeLapsePath, nil
+}
+
+//Stitched timelapse of a cycle, that is yet to be compacted and rendered.
+// It is persisted as the parameters of the compact job.
+type stitchedCycle struct {
+    OutputDir     string   `json:"OutputDir"`
+    TimeLapseFile string   `json:"TimeLapseFile"`
+    //Duration of the stitched video in seconds.
+    Duration      float64  `json:"Duration"`
+    //Unix time of the cycle start and the last snapshot.
+    StartTime     int64    `json:"StartTime"`
+    EndTime       int64    `json:"EndTime"`
+    //Partial timelapse of a running cycle.
+    Partial       bool     `json:"Partial"`
+    //Capture profile of the cycle, empty for the cycle of the camera.
+    Profile       string   `json:"Profile,omitempty"`
+    //Snapshots in the stitched video, only when the capture time is in the
+    // overlay.
+    Snapshots     []overlaySnapshot `json:"Snapshots,omitempty"`
+}
+
+// Stitch the snapshots in 'videoPath' to the timelapse directory in
+// 'outputDir'. The snapshots are deleted after stitching, unless the output
+// is a partial timelapse in another directory or the camera keeps the
+// snapshots. Snapshots in 'skipFiles' are left out, as they are still being
+// written.
+//Returns nil when there are no snapshots to stitch.
+func (camThread *RTSPCameraThread)stitchCycle(videoPath string,
+                                outputDir string,
+                                skipFiles map[string]bool) (*stitchedCycle,
+                                                            error) {
+    log := logging.GetLoggerInstance()
+    isPartial := outputDir != videoPath
+    files, err := getCycleSnapshots(videoPath, skipFiles)
+    if err != nil {
+        log.Error("Failed to read directory, cannot create timelapse, err:%s",
+                    err)
+        return nil, err
+    }
+    if len(files) == 0 {
+        log.Info("Cannot create timelapse video from empty snapshots")
+        return nil, nil
+    }
+    timeLapsePath, err := createTimeLapseDir(outputDir)
+    if err != nil {
+        log.Error("Failed to create timelapse directory in %s", outputDir)
+        return nil, err
+    }
+
+    //List must be next to the snapshots, as the concat input accepts only
+    // plain file names.
+    listName := "timelapseList.txt"
+    if isPartial {
+        listName = "timelapseList_" + filepath.Base(outputDir) + ".txt"
+        defer os.Remove(videoPath + "/" + listName)
+    }
+    timeLapseFile := timeLapsePath + "/timeLapse.mp4"
+    duration, err := camThread.stitchSnapshots(videoPath, files, listName,
+                                               timeLapseFile)
+    if err != nil {
+        return nil, err
+    }
+    var snapshots []overlaySnapshot
+    if camThread.isOverlayTimestamp() {
+        snapshots = camThread.getOverlaySnapshots(videoPath, files)
+    }
+    camThread.threadLock.RLock()
+    keepSnapshots := camThread.keepSnapshotDays != 0
+    camThread.threadLock.RUnlock()
+    if !isPartial && !keepSnapshots {
+        camThread.deleteInputSnapshots(videoPath, files)
+    }
+    //The cycle starts at the time in directory name and ends at the last
+    // snapshot.
+    startTime, err := camThread.parseCycleName(filepath.Base(videoPath))
+    if err != nil {
+        startTime = files[0].ModTime()
+    }
+    endTime := files[len(files) - 1].ModTime()
+    return &stitchedCycle{
+        OutputDir: outputDir,
+        TimeLapseFile: timeLapseFile,
+        Duration: duration,
+        StartTime: startTime.Unix(),
+        EndTime: endTime.Unix(),
+        Partial: isPartial,
+        Profile: camThread.profile,
+        Snapshots: snapshots,
+    }, nil
+}
+
+//Compact the stitched timelapse of the cycle and render it to all the output
+// formats of the camera.
+func (camThread *RTSPCameraThread)finishCycle(cycle *stitchedCycle,
+                                    progress jobQueue.JobProgress) error {
+    log := logging.GetLoggerInstance()
+    finalFile := camThread.compactTimeLapseVideo(cycle.TimeLapseFile,
+                                                 cycle.Duration)
+    if len(finalFile) == 0 {
+        return fmt.Errorf("Failed to compact %s", cycle.TimeLapseFile)
+    }
+    err := camThread.filterFinalVideo(finalFile, cycle.Snapshots,
+                                      camThread.getCompactSpeed(cycle.Duration))
+    if err != nil {
+        //Timelapse is rendered without the frame filters.
+        log.Error("Failed to filter %s, err: %s", finalFile, err)
+    }
+    err = jobQueue.ReportProgress(progress, 30)
+    if err != nil {
+        return err
+    }
+    camThread.renderOutputFormats(cycle.OutputDir, finalFile,
+                                  time.Unix(cycle.StartTime, 0),
+                                  time.Unix(cycle.EndTime, 0))
+    err = jobQueue.ReportProgress(progress, 80)
+    if err != nil {
+        return err
+    }
+    camThread.threadLock.RLock()
+    enableHLS := camThread.enableHLS
+    camThread.threadLock.RUnlock()
+    if enableHLS {
+        camThread.packageHLS(finalFile, GetVideoHLSDir(finalFile), false)
+    }
+    if !cycle.Partial {
+        camThread.purgeExpiredSnapshots()
+        //Composites are of the cycles of the cameras, not their profiles.
+        if len(camThread.profile) == 0 {
+            submitCompositeJobs(camThread.name)
+        }
+    }
+    return nil
 }
 
 // Goroutine to execute the camera thread function.
 func (camThread *RTSPCameraThread)executeCameraThreadRoutine() error {
     var err error
-    var numFramesCopied uint64 = 0
     var defaultSleep uint64
-    var elapsedTime uint64 = 0
     defaultSleep = uint64(time.Second.Nanoseconds())//1 second of sleep.
     camThread.threadLock.RLock()
     if (camThread.videoInterval * uint64(time.Second.Nanoseconds())) <
@@ -488,57 +924,87 @@ func (camThread *RTSPCameraThread)executeCameraThreadRoutine() error {
     }
     camThread.threadLock.RUnlock()
 
-    var startTime time.Time
     log := logging.GetLoggerInstance()
     log.Trace("Starting the camera thread instance %s", camThread.name)
-    camThread.threadLock.Lock()
-    camThread.startTime = time.Now()
-    camThread.threadLock.Unlock()
-    var fileNameInt uint64
+    numSnapshots, fileNameInt := camThread.startCycle()
+    camThread.saveCycleState(numSnapshots, fileNameInt)
     for {
         // We are bit lenient here to read these values onces and use later.
         camThread.threadLock.RLock()
-        totOutFrame := camThread.totOutFrames
-        vidInterval := camThread.videoInterval
+        interval := time.Duration(camThread.videoInterval) * time.Second
+        cycleStart := camThread.startTime
+        cycleEnd := camThread.cycleEnd
+        cycleDir := camThread.videoPath + "/" +
+                    camThread.getCycleName__(cycleStart)
+        recording := camThread.recording
+        streamCapture := camThread.streamCapture
         camThread.threadLock.RUnlock()
-        startTime = time.Now()
         //check if exit signal is triggered,
         if camThread.isExitFired() {
             //Exit the loop, as user wanted to kill the thread.
             break
         }
-        if numFramesCopied >= totOutFrame {
+        now := time.Now()
+        if !now.Before(cycleEnd) || camThread.isRotateFired() {
             //Create the timelapse video from the video snapshots now.
             //Reset the time to start over the timelapse video.
-            log.Trace(`Completed the snapshot generation as %d frames are
-                       created, Creating timelapse video`, numFramesCopied)
+            log.Trace(`Completed the snapshot generation as %d snapshots are
+                       kept in %d intervals, Creating timelapse video`,
+                       fileNameInt, numSnapshots)
             //Wait for all write to complete before stitching.
             camThread.snapShotJoin.Wait()
-            go camThread.createTimelapseWithSnapshots(
-                                   camThread.videoPath + "/" +
-                                   camThread.startTime.Format(TIME_DIR_FORMAT))
-            numFramesCopied = 0
+            if recording && !camThread.cutSegment() {
+                log.Error("Failed to close the segment of %s at cycle end",
+                          camThread.name)
+            }
+            camThread.submitCycleJob(camThread.getCycleJobType(), cycleDir,
+                                     cycleDir, nil)
+            numSnapshots = 0
             camThread.threadLock.Lock()
-            camThread.startTime = time.Now()
+            camThread.setCycle__(time.Now())
             camThread.threadLock.Unlock()
-            elapsedTime = 0
             fileNameInt = 0
+            camThread.resetChangeRef()
+            camThread.saveCycleState(numSnapshots, fileNameInt)
+            continue
         }
-        if elapsedTime >= vidInterval {
+        //Snapshots are due at every interval from the cycle start on the
+        // wall clock, so the time taken by the captures does not add up.
+        if interval == 0 || now.Before(cycleStart.Add(
+                            time.Duration(numSnapshots + 1) * interval)) {
+            time.Sleep(time.Duration(defaultSleep))
+            continue
+        }
+        if recording {
+            //Snapshots are sampled from the segments at the cycle end. The
+            // cycle directory marks the cycle to resume after a restart.
+            err = os.MkdirAll(cycleDir, 0744)
+            if err != nil {
+                log.Error("Failed to create cycle directory of %s, err: %s",
+                           camThread.name, err)
+            }
+        } else {
             //Only take snapshot at particular interval.
-            fileNameInt++
-            err = camThread.createVideoSnapshot(fmt.Sprintf("%d.mp4",
-                                                fileNameInt))
+            var kept bool
+            fileName := fmt.Sprintf("%d.mp4", fileNameInt + 1)
+            if streamCapture {
+                kept, err = camThread.captureStreamSnapshot(fileName)
+            } else {
+                kept, err = camThread.captureSnapshot(fileName)
+            }
             if err != nil {
                 log.Error("Failed to create snapshot for %s err: %s",
                            camThread.name, err)
             }
-            elapsedTime = 0
-            //Update the number of frames created so far
-            numFramesCopied = numFramesCopied + DEFAULT_SNAPSHOT_LEN
+            if kept {
+                fileNameInt++
+            }
         }
+        //Update the number of intervals elapsed so far. The intervals missed
+        // by a slow capture or a restart are skipped.
+        numSnapshots = uint64(time.Since(cycleStart) / interval)
+        camThread.saveCycleState(numSnapshots, fileNameInt)
         time.Sleep(time.Duration(defaultSleep))
-        elapsedTime = elapsedTime + uint64(time.Now().Sub(startTime).Seconds())
     }
     log.Trace("Exiting the RTSP camera thread for %s", camThread.name)
     return err
@@ -549,12 +1015,16 @@ func (camThread *RTSPCameraThread)executeCameraThreadRoutine() error {
 // Function to capture camera feed on specified interval. by default it set to
 // 1 minute.
 func(camThread *RTSPCameraThread)RunCameraThread() error {
-    //total number of frames in the final timelapse video.
     camThread.threadLock.Lock()
-    camThread.totOutFrames = (camThread.videoLen/camThread.videoInterval) *
-                                DEFAULT_SNAPSHOT_LEN
+    if camThread.keepStream {
+        camThread.startRecorder__()
+    }
+    profiles := camThread.profiles
     camThread.threadLock.Unlock()
     go camThread.executeCameraThreadRoutine()
+    for _, profileThread := range profiles {
+        profileThread.RunCameraThread()
+    }
     return nil
 }
 
@@ -571,6 +1041,11 @@ func(camThread *RTSPCameraThread)StopCameraThread() error {
     }
     camThread.status = dataSet.CAMERA_OFF
     camThread.exitSignal <- true
+    //Profiles are stopped before the stream they share.
+    for _, profileThread := range camThread.getProfileThreads() {
+        profileThread.StopCameraThread()
+    }
+    camThread.stopRecorder()
     log.Trace("Exit signal successfully triggered to %s", camThread.name)
     return nil
 }
@@ -20,6 +20,6 @@ func TestCompactTimeLapseVideo(t *testing.T) {
     var cam dataSet.Camera
     var conf config.AppConfig
     camThreadptr.InitCameraThread(&cam, &conf)
-    camThreadptr.compactTimeLapseVideo(fileName)
+    camThreadptr.compactTimeLapseVideo(fileName, 0)
     t.Log("Completed the videoTimeLapseCompact ")
 }
@@ -0,0 +1,112 @@
+package RTSPCameraImpl
+
+import (
+    "fmt"
+    "os"
+    "io/ioutil"
+    "unsafe"
+    "path/filepath"
+    "VideoTimeLapse/logging"
+)
+
+// Poster and thumbnail images of the timelapse videos. The images are stored
+// in a directory next to the final timelapse video.
+
+// #include "videoimage.h"
+// #include <stdlib.h>
+import "C"
+
+const (
+    THUMBNAIL_DIR_NAME = "thumbs"
+    POSTER_FILE_NAME = "poster.jpg"
+    THUMBNAIL_FILE_NAME = "thumb_%02d.jpg"
+    POSTER_WIDTH = 1280
+    THUMBNAIL_WIDTH = 160
+    //Number of evenly spaced thumbnails in the strip.
+    THUMBNAIL_COUNT = 10
+)
+
+//Poster and thumbnails created for a video.
+type videoThumbnails struct {
+    posterFile string
+    count int
+}
+
+//Return the thumbnail file at 'index' in the strip for the poster file.
+func GetThumbnailFile(posterFile string, index int) string {
+    return filepath.Dir(posterFile) + "/" +
+            fmt.Sprintf(THUMBNAIL_FILE_NAME, index)
+}
+
+//Extract 'count' evenly spaced JPEG images of 'width' pixels from the mp4
+// video file.
+func extractJPEGImages(videoFile string, count int,
+                       width int) ([][]byte, error) {
+    inputFormatC := C.CString("mp4")
+    inputURLC := C.CString(videoFile)
+    defer C.free(unsafe.Pointer(inputFormatC))
+    defer C.free(unsafe.Pointer(inputURLC))
+    imagesC := (**C.uint8_t)(C.calloc(C.size_t(count),
+                                      C.size_t(unsafe.Sizeof(uintptr(0)))))
+    sizesC := (*C.int)(C.calloc(C.size_t(count), C.size_t(C.sizeof_int)))
+    defer C.free(unsafe.Pointer(imagesC))
+    defer C.free(unsafe.Pointer(sizesC))
+    res := C.vs_extract_jpegs(inputFormatC, inputURLC, C.int(count),
+                              C.int(width), imagesC, sizesC)
+    if res <= 0 {
+        return nil, fmt.Errorf("Failed to extract images from %s", videoFile)
+    }
+    imageList := (*[1 << 16]*C.uint8_t)(unsafe.Pointer(imagesC))[:count:count]
+    sizeList := (*[1 << 16]C.int)(unsafe.Pointer(sizesC))[:count:count]
+    images := make([][]byte, 0, int(res))
+    for i := 0; i < int(res); i++ {
+        images = append(images, C.GoBytes(unsafe.Pointer(imageList[i]),
+                                          sizeList[i]))
+        C.av_free(unsafe.Pointer(imageList[i]))
+    }
+    return images, nil
+}
+
+//Create the poster and the thumbnail strip for the video file.
+func (camThread *RTSPCameraThread)createThumbnails(
+                                    videoFile string) videoThumbnails {
+    var thumbs videoThumbnails
+    log := logging.GetLoggerInstance()
+    thumbDir := filepath.Dir(videoFile) + "/" + THUMBNAIL_DIR_NAME
+    if _, err := os.Stat(thumbDir); os.IsNotExist(err) {
+        err = os.MkdirAll(thumbDir, 0744)
+        if err != nil {
+            log.Error("Failed to create thumbnail directory %s", thumbDir)
+            return thumbs
+        }
+    }
+    posters, err := extractJPEGImages(videoFile, 1, POSTER_WIDTH)
+    if err != nil {
+        log.Error("Failed to create poster for %s, err: %s", videoFile, err)
+        return thumbs
+    }
+    posterFile := thumbDir + "/" + POSTER_FILE_NAME
+    err = ioutil.WriteFile(posterFile, posters[0], 0644)
+    if err != nil {
+        log.Error("Failed to write poster %s, err: %s", posterFile, err)
+        return thumbs
+    }
+    thumbs.posterFile = posterFile
+    images, err := extractJPEGImages(videoFile, THUMBNAIL_COUNT,
+                                     THUMBNAIL_WIDTH)
+    if err != nil {
+        log.Error("Failed to create thumbnails for %s, err: %s", videoFile,
+                    err)
+        return thumbs
+    }
+    for i, image := range images {
+        thumbFile := GetThumbnailFile(posterFile, i)
+        err = ioutil.WriteFile(thumbFile, image, 0644)
+        if err != nil {
+            log.Error("Failed to write thumbnail %s, err: %s", thumbFile, err)
+            break
+        }
+        thumbs.count++
+    }
+    return thumbs
+}
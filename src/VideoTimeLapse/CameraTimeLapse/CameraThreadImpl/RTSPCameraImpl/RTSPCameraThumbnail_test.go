@@ -0,0 +1,31 @@
+package RTSPCameraImpl
+
+// Test file for validating the files of the thumbnail strip.
+import (
+    "testing"
+)
+
+func TestGetThumbnailFile(t *testing.T) {
+    tests := []struct {
+        name string
+        posterFile string
+        index int
+        expected string
+    }{
+        {"first thumbnail", "/videos/cam1/20240101000000/thumbs/poster.jpg",
+            0, "/videos/cam1/20240101000000/thumbs/thumb_00.jpg"},
+        {"last thumbnail", "/videos/cam1/20240101000000/thumbs/poster.jpg",
+            THUMBNAIL_COUNT - 1,
+            "/videos/cam1/20240101000000/thumbs/thumb_09.jpg"},
+        {"three digit index", "/thumbs/poster.jpg", 100,
+            "/thumbs/thumb_100.jpg"},
+        {"relative poster", "thumbs/poster.jpg", 5, "thumbs/thumb_05.jpg"},
+    }
+    for _, test := range tests {
+        file := GetThumbnailFile(test.posterFile, test.index)
+        if file != test.expected {
+            t.Errorf("%s: got file %q, expected %q", test.name, file,
+                     test.expected)
+        }
+    }
+}
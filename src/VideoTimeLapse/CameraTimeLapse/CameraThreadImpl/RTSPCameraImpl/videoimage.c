@@ -0,0 +1,408 @@
+//
+// This library provides extraction of still images from a video stream. The
+// frames are decoded, scaled through a libavfilter graph and encoded as JPEG,
+// or returned as small gray images to compare the scenes.
+
+#include <errno.h>
+#include <libavfilter/buffersink.h>
+#include <libavfilter/buffersrc.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include "videoimage.h"
+
+// JPEG quality on the MJPEG qscale, lower is better.
+#define VS_JPEG_QSCALE 3
+
+// Encode the decoded frame as JPEG of 'width' pixels, keeping the aspect
+// ratio. The image is returned in 'image' and must be freed with av_free().
+//
+// Returns:
+// -1 if error
+// 0 if the image is created
+int
+vs_frame_to_jpeg(const AVCodecContext * const dec_ctx, AVFrame * const frame,
+        const int width, uint8_t ** const image, int * const size)
+{
+    if (!dec_ctx || !frame || width <= 0 || !image || !size) {
+        printf("%s\n", strerror(EINVAL));
+        return -1;
+    }
+
+    int ret = -1;
+    struct VSFilter filter;
+    AVCodecContext * enc_ctx = NULL;
+    AVFrame * filt_frame = NULL;
+    AVPacket * pkt = NULL;
+
+    *image = NULL;
+    *size = 0;
+
+    char filter_desc[128];
+    snprintf(filter_desc, sizeof(filter_desc), "scale=%d:-2,format=yuvj420p",
+            width);
+    // Frame timestamps are not used for a single image.
+    const AVRational time_base = { 1, 25 };
+    if (vs_open_filter(&filter, dec_ctx, time_base, filter_desc) != 0) {
+        return -1;
+    }
+
+    filt_frame = av_frame_alloc();
+    pkt = av_packet_alloc();
+    if (!filt_frame || !pkt) {
+        printf("unable to allocate frame/packet\n");
+        goto end;
+    }
+
+    if (av_buffersrc_add_frame_flags(filter.src_ctx, frame,
+                AV_BUFFERSRC_FLAG_KEEP_REF) < 0 ||
+            av_buffersrc_add_frame_flags(filter.src_ctx, NULL, 0) < 0) {
+        printf("unable to feed filter graph\n");
+        goto end;
+    }
+
+    if (av_buffersink_get_frame(filter.sink_ctx, filt_frame) < 0) {
+        printf("unable to get scaled frame\n");
+        goto end;
+    }
+
+    const AVCodec * const encoder = avcodec_find_encoder(AV_CODEC_ID_MJPEG);
+    if (!encoder) {
+        printf("jpeg encoder not found\n");
+        goto end;
+    }
+
+    enc_ctx = avcodec_alloc_context3(encoder);
+    if (!enc_ctx) {
+        printf("unable to allocate jpeg encoder\n");
+        goto end;
+    }
+
+    enc_ctx->width = filt_frame->width;
+    enc_ctx->height = filt_frame->height;
+    enc_ctx->pix_fmt = AV_PIX_FMT_YUVJ420P;
+    enc_ctx->time_base = time_base;
+    enc_ctx->flags |= AV_CODEC_FLAG_QSCALE;
+    enc_ctx->global_quality = FF_QP2LAMBDA * VS_JPEG_QSCALE;
+
+    if (avcodec_open2(enc_ctx, encoder, NULL) < 0) {
+        printf("unable to open jpeg encoder\n");
+        goto end;
+    }
+
+    filt_frame->pts = 0;
+    filt_frame->quality = enc_ctx->global_quality;
+    filt_frame->pict_type = AV_PICTURE_TYPE_NONE;
+    if (avcodec_send_frame(enc_ctx, filt_frame) < 0 ||
+            avcodec_send_frame(enc_ctx, NULL) < 0) {
+        printf("unable to encode jpeg\n");
+        goto end;
+    }
+
+    if (avcodec_receive_packet(enc_ctx, pkt) < 0) {
+        printf("unable to get jpeg image\n");
+        goto end;
+    }
+
+    *image = av_malloc(pkt->size);
+    if (!*image) {
+        printf("%s\n", strerror(ENOMEM));
+        goto end;
+    }
+    memcpy(*image, pkt->data, pkt->size);
+    *size = pkt->size;
+    ret = 0;
+
+end:
+    av_packet_free(&pkt);
+    av_frame_free(&filt_frame);
+    avcodec_free_context(&enc_ctx);
+    vs_destroy_filter(&filter);
+    return ret;
+}
+
+// Count the video packets in the input file.
+static int64_t
+__vs_count_video_packets(const char * const input_format_name,
+        const char * const input_url)
+{
+    struct VSInput * const input = vs_open_input(input_format_name,
+            input_url, false);
+    if (!input) {
+        return -1;
+    }
+
+    int64_t count = 0;
+    while (true) {
+        AVPacket pkt;
+        const int read_res = vs_read_packet(input, &pkt, false);
+        if (read_res == -1) {
+            break;
+        }
+        if (read_res == 1) {
+            count++;
+            av_packet_unref(&pkt);
+        }
+    }
+
+    vs_destroy_input(input);
+    return count;
+}
+
+// Decode the video file and encode 'count' evenly spaced frames as JPEG
+// images of 'width' pixels. A single image is taken from the middle of the
+// video. 'images' and 'sizes' must have room for 'count' entries, every
+// image must be freed with av_free().
+//
+// Returns the number of images created, -1 on error.
+int
+vs_extract_jpegs(const char * const input_format_name,
+        const char * const input_url, const int count, const int width,
+        uint8_t ** const images, int * const sizes)
+{
+    if (count <= 0 || !images || !sizes) {
+        printf("%s\n", strerror(EINVAL));
+        return -1;
+    }
+
+    memset(images, 0, sizeof(uint8_t *) * count);
+    memset(sizes, 0, sizeof(int) * count);
+
+    // The fragmented MP4 doesn't carry the duration and cannot be seeked
+    // reliably, so the frames are picked by packet index.
+    const int64_t total = __vs_count_video_packets(input_format_name,
+            input_url);
+    if (total <= 0) {
+        printf("no video packets in %s\n", input_url);
+        return -1;
+    }
+
+    struct VSInput * const input = vs_open_input(input_format_name,
+            input_url, false);
+    if (!input) {
+        return -1;
+    }
+
+    AVCodecContext * dec_ctx = vs_open_decoder(input);
+    AVFrame * frame = av_frame_alloc();
+    if (!dec_ctx || !frame) {
+        av_frame_free(&frame);
+        avcodec_free_context(&dec_ctx);
+        vs_destroy_input(input);
+        return -1;
+    }
+
+    int created = 0;
+    int64_t decoded = 0;
+    bool flushed = false;
+    while (created < count && !flushed) {
+        AVPacket pkt;
+        const int read_res = vs_read_packet(input, &pkt, false);
+        if (read_res == 0) {
+            continue;
+        }
+        if (read_res == -1) {
+            // Drain the frames left in decoder.
+            avcodec_send_packet(dec_ctx, NULL);
+            flushed = true;
+        } else {
+            avcodec_send_packet(dec_ctx, &pkt);
+            av_packet_unref(&pkt);
+        }
+
+        while (created < count &&
+                avcodec_receive_frame(dec_ctx, frame) == 0) {
+            // Index of the frame wanted for the next image.
+            const int64_t wanted = (2 * created + 1) * total / (2 * count);
+            if (decoded++ >= wanted) {
+                if (vs_frame_to_jpeg(dec_ctx, frame, width,
+                            &images[created], &sizes[created]) == 0) {
+                    created++;
+                }
+            }
+            av_frame_unref(frame);
+        }
+    }
+
+    av_frame_free(&frame);
+    avcodec_free_context(&dec_ctx);
+    vs_destroy_input(input);
+    return created;
+}
+
+// Decode the first keyframe of the input stream and encode it as JPEG of
+// 'width' pixels, or of the stream width when 'width' is 0. The frame is
+// masked by 'mask' when set. Reading stops after 'max_packets' video packets
+// if no frame could be decoded. Opening the input and reading the frame fail
+// after 'timeout_sec' seconds. The image must be freed with av_free().
+//
+// Returns:
+// -1 if error
+// 0 if the image is created
+int
+vs_capture_jpeg(const char * const input_format_name,
+        const char * const input_url, const int width, const int max_packets,
+        const int timeout_sec, const struct VSMask * const mask,
+        uint8_t ** const image, int * const size)
+{
+    if (max_packets <= 0 || timeout_sec <= 0 || !image || !size) {
+        printf("%s\n", strerror(EINVAL));
+        return -1;
+    }
+
+    struct VSInput * const input = vs_open_input_timeout(input_format_name,
+            input_url, timeout_sec, NULL, false);
+    if (!input) {
+        return -1;
+    }
+
+    AVCodecContext * dec_ctx = vs_open_decoder(input);
+    AVFrame * frame = av_frame_alloc();
+    if (!dec_ctx || !frame) {
+        av_frame_free(&frame);
+        avcodec_free_context(&dec_ctx);
+        vs_destroy_input(input);
+        return -1;
+    }
+
+    int ret = -1;
+    bool keyframe_seen = false;
+    for (int num_pkts = 0; num_pkts < max_packets && ret != 0; ) {
+        AVPacket pkt;
+        const int read_res = vs_read_packet(input, &pkt, false);
+        if (read_res == -1) {
+            break;
+        }
+        if (read_res == 0) {
+            continue;
+        }
+        num_pkts++;
+        // Frames before the first keyframe cannot be decoded.
+        if (!keyframe_seen && !(pkt.flags & AV_PKT_FLAG_KEY)) {
+            av_packet_unref(&pkt);
+            continue;
+        }
+        keyframe_seen = true;
+        avcodec_send_packet(dec_ctx, &pkt);
+        av_packet_unref(&pkt);
+
+        if (avcodec_receive_frame(dec_ctx, frame) == 0) {
+            if (mask && vs_mask_frame(frame, mask) != 0) {
+                // Unmasked frame is never returned.
+                av_frame_unref(frame);
+                break;
+            }
+            const int image_width = width > 0 ? width : frame->width;
+            ret = vs_frame_to_jpeg(dec_ctx, frame, image_width, image, size);
+            av_frame_unref(frame);
+        }
+    }
+
+    if (ret != 0) {
+        printf("unable to capture image from %s\n", input_url);
+    }
+
+    av_frame_free(&frame);
+    avcodec_free_context(&dec_ctx);
+    vs_destroy_input(input);
+    return ret;
+}
+
+// Decode the video packet and scale the decoded frame to 'width' x 'height'
+// gray pixels in 'gray'. The frame is masked by 'mask' when set, so the
+// masked regions are left out of the comparison.
+// The masked frame is also encoded as JPEG of 'jpeg_width' pixels in 'jpeg'
+// when it is set, the image must be freed with av_free(). The gray image is
+// still created if the JPEG cannot be encoded.
+//
+// Returns:
+// -1 if error
+// 0 if the decoder needs more packets for a frame
+// 1 if the gray image is created
+int
+vs_decode_gray(AVCodecContext * const dec_ctx, const AVPacket * const pkt,
+        const struct VSMask * const mask, const int width, const int height,
+        uint8_t * const gray, const int jpeg_width, uint8_t ** const jpeg,
+        int * const jpeg_size)
+{
+    if (!dec_ctx || !pkt || width <= 0 || height <= 0 || !gray ||
+            (jpeg && (jpeg_width <= 0 || !jpeg_size))) {
+        printf("%s\n", strerror(EINVAL));
+        return -1;
+    }
+
+    if (jpeg) {
+        *jpeg = NULL;
+        *jpeg_size = 0;
+    }
+
+    if (avcodec_send_packet(dec_ctx, pkt) < 0) {
+        // Frames before the first keyframe cannot be decoded.
+        return 0;
+    }
+
+    AVFrame * frame = av_frame_alloc();
+    AVFrame * filt_frame = av_frame_alloc();
+    if (!frame || !filt_frame) {
+        printf("unable to allocate frames\n");
+        av_frame_free(&frame);
+        av_frame_free(&filt_frame);
+        return -1;
+    }
+
+    int ret = 0;
+    struct VSFilter filter;
+    memset(&filter, 0, sizeof(struct VSFilter));
+    const int res = avcodec_receive_frame(dec_ctx, frame);
+    if (res != 0) {
+        ret = res == AVERROR(EAGAIN) ? 0 : -1;
+        goto end;
+    }
+
+    ret = -1;
+    if (mask && vs_mask_frame(frame, mask) != 0) {
+        goto end;
+    }
+
+    if (jpeg && vs_frame_to_jpeg(dec_ctx, frame, jpeg_width, jpeg,
+                jpeg_size) != 0) {
+        printf("unable to encode jpeg of the scene\n");
+    }
+
+    char filter_desc[128];
+    snprintf(filter_desc, sizeof(filter_desc), "scale=%d:%d,format=gray",
+            width, height);
+    // Frame timestamps are not used for a single image.
+    const AVRational time_base = { 1, 25 };
+    if (vs_open_filter(&filter, dec_ctx, time_base, filter_desc) != 0) {
+        goto end;
+    }
+
+    if (av_buffersrc_add_frame_flags(filter.src_ctx, frame,
+                AV_BUFFERSRC_FLAG_KEEP_REF) < 0 ||
+            av_buffersrc_add_frame_flags(filter.src_ctx, NULL, 0) < 0) {
+        printf("unable to feed filter graph\n");
+        goto end;
+    }
+
+    if (av_buffersink_get_frame(filter.sink_ctx, filt_frame) < 0) {
+        printf("unable to get gray frame\n");
+        goto end;
+    }
+
+    for (int y = 0; y < height; y++) {
+        memcpy(gray + y * width, filt_frame->data[0] +
+                y * filt_frame->linesize[0], width);
+    }
+    ret = 1;
+
+end:
+    if (ret != 1 && jpeg) {
+        av_freep(jpeg);
+        *jpeg_size = 0;
+    }
+    vs_destroy_filter(&filter);
+    av_frame_free(&frame);
+    av_frame_free(&filt_frame);
+    return ret;
+}
@@ -0,0 +1,26 @@
+#ifndef _VIDEOIMAGE_H
+#define _VIDEOIMAGE_H
+
+#include <libavcodec/avcodec.h>
+#include <stdint.h>
+#include "videotranscode.h"
+
+int
+vs_frame_to_jpeg(const AVCodecContext * const, AVFrame * const,
+        const int, uint8_t ** const, int * const);
+
+int
+vs_extract_jpegs(const char * const, const char * const,
+        const int, const int, uint8_t ** const, int * const);
+
+int
+vs_capture_jpeg(const char * const, const char * const,
+        const int, const int, const int, const struct VSMask * const,
+        uint8_t ** const, int * const);
+
+int
+vs_decode_gray(AVCodecContext * const, const AVPacket * const,
+        const struct VSMask * const, const int, const int, uint8_t * const,
+        const int, uint8_t ** const, int * const);
+
+#endif
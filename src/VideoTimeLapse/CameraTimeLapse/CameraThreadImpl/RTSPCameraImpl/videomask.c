@@ -0,0 +1,143 @@
+//
+// This library provides the privacy masking of decoded video frames. The
+// masked regions are blacked out or pixelated in place, before the frames are
+// encoded to any output.
+//
+// Only the planar 8 bit YUV formats are masked, which covers the decoders of
+// the camera streams. The frames of any other format must be converted by a
+// filter before masking.
+
+#include <errno.h>
+#include <libavutil/common.h>
+#include <libavutil/pixdesc.h>
+#include <stdbool.h>
+#include <stdio.h>
+#include <string.h>
+#include "videomask.h"
+
+// Return true if the frame format can be masked in place.
+static bool
+__vs_is_mask_format(const AVPixFmtDescriptor * const desc)
+{
+    if (!desc || desc->nb_components < 3 ||
+            !(desc->flags & AV_PIX_FMT_FLAG_PLANAR) ||
+            (desc->flags & (AV_PIX_FMT_FLAG_RGB | AV_PIX_FMT_FLAG_PAL |
+                            AV_PIX_FMT_FLAG_BITSTREAM |
+                            AV_PIX_FMT_FLAG_HWACCEL))) {
+        return false;
+    }
+
+    for (int i = 0; i < 3; i++) {
+        if (desc->comp[i].plane != i || desc->comp[i].depth != 8 ||
+                desc->comp[i].step != 1) {
+            return false;
+        }
+    }
+    return true;
+}
+
+// Return the mask mode of the frame pixel at 'x', 'y'.
+static uint8_t
+__vs_mask_mode(const struct VSMask * const mask, const AVFrame * const frame,
+        const int x, const int y)
+{
+    const int mask_x = FFMIN((int64_t)x * mask->width / frame->width,
+            mask->width - 1);
+    const int mask_y = FFMIN((int64_t)y * mask->height / frame->height,
+            mask->height - 1);
+    return mask->bitmap[mask_y * mask->width + mask_x];
+}
+
+// Mask a plane of the frame. 'shift_x' and 'shift_y' are the chroma
+// subsampling of the plane.
+static void
+__vs_mask_plane(AVFrame * const frame, const struct VSMask * const mask,
+        const int plane, const int shift_x, const int shift_y,
+        const uint8_t black)
+{
+    const int width = AV_CEIL_RSHIFT(frame->width, shift_x);
+    const int height = AV_CEIL_RSHIFT(frame->height, shift_y);
+
+    // Blocks are aligned across the planes, so the pixelated chroma matches
+    // the luma.
+    const int block = FFMAX(frame->width / VS_MASK_PIXELATE_BLOCKS, 2) & ~1;
+    const int block_w = FFMAX(block >> shift_x, 1);
+    const int block_h = FFMAX(block >> shift_y, 1);
+
+    uint8_t * const data = frame->data[plane];
+    const int linesize = frame->linesize[plane];
+
+    for (int block_y = 0; block_y < height; block_y += block_h) {
+        const int end_y = FFMIN(block_y + block_h, height);
+        for (int block_x = 0; block_x < width; block_x += block_w) {
+            const int end_x = FFMIN(block_x + block_w, width);
+
+            bool masked = false;
+            int64_t sum = 0;
+            for (int y = block_y; y < end_y; y++) {
+                for (int x = block_x; x < end_x; x++) {
+                    sum += data[y * linesize + x];
+                    masked |= __vs_mask_mode(mask, frame, x << shift_x,
+                            y << shift_y) != VS_MASK_NONE;
+                }
+            }
+            if (!masked) {
+                continue;
+            }
+
+            const uint8_t average = sum /
+                ((end_y - block_y) * (end_x - block_x));
+            for (int y = block_y; y < end_y; y++) {
+                for (int x = block_x; x < end_x; x++) {
+                    const uint8_t mode = __vs_mask_mode(mask, frame,
+                            x << shift_x, y << shift_y);
+                    if (mode == VS_MASK_BLACK) {
+                        data[y * linesize + x] = black;
+                    } else if (mode == VS_MASK_PIXELATE) {
+                        data[y * linesize + x] = average;
+                    }
+                }
+            }
+        }
+    }
+}
+
+// Black out or pixelate the masked regions of the decoded frame in place.
+// The frame data is copied first if it is shared with the decoder.
+//
+// Returns:
+// -1 if error, the frame must not be written to any output
+// 0 if the frame is masked
+int
+vs_mask_frame(AVFrame * const frame, const struct VSMask * const mask)
+{
+    if (!frame || !mask || !mask->bitmap || mask->width <= 0 ||
+            mask->height <= 0) {
+        printf("%s\n", strerror(EINVAL));
+        return -1;
+    }
+
+    const AVPixFmtDescriptor * const desc = av_pix_fmt_desc_get(
+            frame->format);
+    if (!__vs_is_mask_format(desc)) {
+        printf("unable to mask frames of pixel format %s\n",
+                desc ? desc->name : "unknown");
+        return -1;
+    }
+
+    if (av_frame_make_writable(frame) < 0) {
+        printf("unable to make frame writable\n");
+        return -1;
+    }
+
+    const bool full_range = frame->color_range == AVCOL_RANGE_JPEG ||
+        frame->format == AV_PIX_FMT_YUVJ420P ||
+        frame->format == AV_PIX_FMT_YUVJ422P ||
+        frame->format == AV_PIX_FMT_YUVJ444P;
+    __vs_mask_plane(frame, mask, 0, 0, 0, full_range ? 0 : 16);
+    for (int plane = 1; plane < 3; plane++) {
+        __vs_mask_plane(frame, mask, plane, desc->log2_chroma_w,
+                desc->log2_chroma_h, 128);
+    }
+    return 0;
+}
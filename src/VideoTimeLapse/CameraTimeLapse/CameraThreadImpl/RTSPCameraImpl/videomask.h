@@ -0,0 +1,27 @@
+#ifndef _VIDEOMASK_H
+#define _VIDEOMASK_H
+
+#include <libavutil/frame.h>
+#include <stdint.h>
+
+// Mode of a pixel in the mask bitmap. Black is preferred where the masks
+// overlap, as it hides more.
+#define VS_MASK_NONE 0
+#define VS_MASK_PIXELATE 1
+#define VS_MASK_BLACK 2
+
+// Number of pixelate blocks across the frame width.
+#define VS_MASK_PIXELATE_BLOCKS 40
+
+// Privacy mask bitmap of 'width' x 'height' pixels, holding the mode of every
+// pixel. The bitmap is scaled to the size of the masked frames.
+struct VSMask {
+    const uint8_t * bitmap;
+    int width;
+    int height;
+};
+
+int
+vs_mask_frame(AVFrame * const, const struct VSMask * const);
+
+#endif
@@ -10,12 +10,39 @@
 
 #include <errno.h>
 #include <libavdevice/avdevice.h>
+#include <libavutil/time.h>
 #include <libavutil/timestamp.h>
 #include <stdio.h>
 #include <stdlib.h>
 #include <string.h>
 #include "videomux.h"
 
+/* Seek the video stream of the input to the keyframe at or before 'offset'
+ * seconds from the start of the stream.
+ * Returns 0 on success, -1 on error.
+ */
+int
+vs_seek_input(const struct VSInput * const input, const double offset)
+{
+    if (!input || offset < 0) {
+        printf("%s\n", strerror(EINVAL));
+        return -1;
+    }
+
+    const AVStream * const in_stream =
+        input->format_ctx->streams[input->video_stream_index];
+    int64_t ts = (int64_t) (offset / av_q2d(in_stream->time_base));
+    if (in_stream->start_time != AV_NOPTS_VALUE) {
+        ts += in_stream->start_time;
+    }
+    if (av_seek_frame(input->format_ctx, input->video_stream_index, ts,
+                AVSEEK_FLAG_BACKWARD) < 0) {
+        printf("unable to seek input to %f\n", offset);
+        return -1;
+    }
+    return 0;
+}
+
 static void
 __vs_log_packet(const AVFormatContext * const,
         const AVPacket * const, const char * const);
@@ -38,8 +65,32 @@ struct VSInput *
 vs_open_input(const char * const input_format_name,
         const char * const input_url, const bool verbose)
 {
+    return vs_open_input_timeout(input_format_name, input_url, 0, NULL,
+            verbose);
+}
+
+// Abort the blocking IO on the input once its deadline is passed.
+static int
+__vs_input_interrupt(void * const opaque)
+{
+    const struct VSInput * const input = opaque;
+    return input->deadline != 0 && av_gettime_relative() > input->deadline;
+}
+
+// Open the input, failing any IO on it after 'timeout_sec' seconds. No
+// timeout is applied when 'timeout_sec' is 0. The libav error of opening
+// the input is returned in 'open_err' if it is not NULL.
+struct VSInput *
+vs_open_input_timeout(const char * const input_format_name,
+        const char * const input_url, const int timeout_sec,
+        int * const open_err, const bool verbose)
+{
+    if (open_err) {
+        *open_err = 0;
+    }
+
     if (!input_format_name || strlen(input_format_name) == 0 ||
-            !input_url || strlen(input_url) == 0) {
+            !input_url || strlen(input_url) == 0 || timeout_sec < 0) {
         printf("%s\n", strerror(EINVAL));
         return NULL;
     }
@@ -58,9 +109,28 @@ vs_open_input(const char * const input_format_name,
         return NULL;
     }
 
-    if (avformat_open_input(&input->format_ctx, input_url, input_format,
-                NULL) != 0) {
+    input->format_ctx = avformat_alloc_context();
+    if (!input->format_ctx) {
+        printf("unable to allocate input context\n");
+        vs_destroy_input(input);
+        return NULL;
+    }
+
+    if (timeout_sec > 0) {
+        input->deadline = av_gettime_relative() +
+            (int64_t) timeout_sec * AV_TIME_BASE;
+        input->format_ctx->interrupt_callback.callback = __vs_input_interrupt;
+        input->format_ctx->interrupt_callback.opaque = input;
+    }
+
+    // The context is freed by avformat_open_input on failure.
+    const int res = avformat_open_input(&input->format_ctx, input_url,
+            input_format, NULL);
+    if (res != 0) {
         printf("unable to open input\n");
+        if (open_err) {
+            *open_err = res;
+        }
         vs_destroy_input(input);
         return NULL;
     }
@@ -120,10 +190,71 @@ vs_destroy_input(struct VSInput * const input)
     free(input);
 }
 
+// Fill the parameters of the input video stream in 'info'.
+//
+// Returns:
+// -1 if error
+// 0 if the stream info is filled
+int
+vs_get_stream_info(const struct VSInput * const input,
+        struct VSStreamInfo * const info)
+{
+    if (!input || !info) {
+        printf("%s\n", strerror(EINVAL));
+        return -1;
+    }
+
+    memset(info, 0, sizeof(struct VSStreamInfo));
+
+    const AVStream * const stream = input->format_ctx->streams[
+        input->video_stream_index];
+    const AVCodecParameters * const par = stream->codecpar;
+    snprintf(info->codec, sizeof(info->codec), "%s",
+            avcodec_get_name(par->codec_id));
+    const char * const profile = avcodec_profile_name(par->codec_id,
+            par->profile);
+    if (profile) {
+        snprintf(info->profile, sizeof(info->profile), "%s", profile);
+    }
+    info->width = par->width;
+    info->height = par->height;
+    const AVRational frame_rate = stream->avg_frame_rate.num ?
+        stream->avg_frame_rate : stream->r_frame_rate;
+    if (frame_rate.den) {
+        info->fps = av_q2d(frame_rate);
+    }
+    // RTSP cameras rarely report the stream bitrate.
+    info->bitrate = par->bit_rate ? par->bit_rate :
+        input->format_ctx->bit_rate;
+
+    for (unsigned int i = 0; i < input->format_ctx->nb_streams; i++) {
+        if (input->format_ctx->streams[i]->codecpar->codec_type ==
+                AVMEDIA_TYPE_AUDIO) {
+            info->has_audio = true;
+            break;
+        }
+    }
+
+    return 0;
+}
+
 struct VSOutput *
 vs_open_output(const char * const output_format_name,
         const char * const output_url, const struct VSInput * const input,
         const bool verbose)
+{
+    return vs_open_output_params(output_format_name, output_url, input, NULL,
+            verbose);
+}
+
+// Open the output to copy the video stream of input. The stream parameters
+// 'params' known for the input are used for the output stream when they are
+// of the same codec. The output is set up only from the input, if 'params'
+// is NULL.
+struct VSOutput *
+vs_open_output_params(const char * const output_format_name,
+        const char * const output_url, const struct VSInput * const input,
+        const struct VSStreamInfo * const params, const bool verbose)
 {
     if (!output_format_name || strlen(output_format_name) == 0 ||
             !output_url || strlen(output_url) == 0 ||
@@ -180,6 +311,26 @@ vs_open_output(const char * const output_format_name,
     }
 
     out_stream->codecpar->codec_tag = 0;
+
+    if (params && strcmp(params->codec,
+                avcodec_get_name(in_stream->codecpar->codec_id)) == 0) {
+        // Stream parameters are not always present before decoding.
+        if (out_stream->codecpar->width == 0 ||
+                out_stream->codecpar->height == 0) {
+            out_stream->codecpar->width = params->width;
+            out_stream->codecpar->height = params->height;
+        }
+        if (params->fps > 0) {
+            out_stream->avg_frame_rate = av_d2q(params->fps, 100000);
+        }
+        // Muxer picks 'hev1' for H.265 that most players cannot play in
+        // MP4.
+        if (in_stream->codecpar->codec_id == AV_CODEC_ID_HEVC &&
+                strcmp(output_format_name, "mp4") == 0) {
+            out_stream->codecpar->codec_tag = MKTAG('h', 'v', 'c', '1');
+        }
+    }
+
     // Open output file.
     if (avio_open(&output->format_ctx->pb, output_url, AVIO_FLAG_WRITE) < 0) {
         printf("unable to open output file\n");
@@ -244,6 +395,96 @@ vs_open_output(const char * const output_format_name,
     return output;
 }
 
+// Open an HLS output that packages the video stream of input as fragmented
+// MP4 segments of 'segment_sec' seconds. The segments are written next to
+// the playlist.
+//
+// A live output is appended to the existing playlist on every open, and the
+// playlist is left open for more segments.
+struct VSOutput *
+vs_open_hls_output(const char * const playlist_url,
+        const char * const segment_url, const struct VSInput * const input,
+        const int segment_sec, const bool live, const bool verbose)
+{
+    if (!playlist_url || strlen(playlist_url) == 0 ||
+            !segment_url || strlen(segment_url) == 0 ||
+            !input || segment_sec <= 0) {
+        printf("%s\n", strerror(EINVAL));
+        return NULL;
+    }
+
+    struct VSOutput * const output = calloc(1, sizeof(struct VSOutput));
+    if (!output) {
+        printf("%s\n", strerror(errno));
+        return NULL;
+    }
+
+    if (avformat_alloc_output_context2(&output->format_ctx, NULL, "hls",
+                playlist_url) < 0) {
+        printf("unable to create hls output context\n");
+        vs_destroy_output(output);
+        return NULL;
+    }
+
+    AVStream * const out_stream = avformat_new_stream(output->format_ctx, NULL);
+    if (!out_stream) {
+        printf("unable to add stream\n");
+        vs_destroy_output(output);
+        return NULL;
+    }
+
+    AVStream * const in_stream = input->format_ctx->streams[
+        input->video_stream_index];
+
+    if (avcodec_parameters_copy(out_stream->codecpar,
+                in_stream->codecpar) < 0) {
+        printf("unable to copy codec parameters\n");
+        vs_destroy_output(output);
+        return NULL;
+    }
+    out_stream->codecpar->codec_tag = 0;
+
+    if (verbose) {
+        av_dump_format(output->format_ctx, 0, playlist_url, 1);
+    }
+
+    // The hls muxer opens the playlist and segment files by itself.
+    AVDictionary * opts = NULL;
+    av_dict_set(&opts, "hls_segment_type", "fmp4", 0);
+    av_dict_set_int(&opts, "hls_time", segment_sec, 0);
+    av_dict_set_int(&opts, "hls_list_size", 0, 0);
+    av_dict_set(&opts, "hls_segment_filename", segment_url, 0);
+    if (live) {
+        // Keep adding the segments to the same playlist as an event stream,
+        // the player polls it for new segments.
+        av_dict_set(&opts, "hls_playlist_type", "event", 0);
+        av_dict_set(&opts, "hls_flags",
+                "append_list+omit_endlist+discont_start", 0);
+    } else {
+        av_dict_set(&opts, "hls_playlist_type", "vod", 0);
+    }
+
+    if (avformat_write_header(output->format_ctx, &opts) < 0) {
+        printf("unable to write hls header\n");
+        vs_destroy_output(output);
+        av_dict_free(&opts);
+        return NULL;
+    }
+
+    if (av_dict_count(opts) != 0) {
+        printf("some hls options not set\n");
+        vs_destroy_output(output);
+        av_dict_free(&opts);
+        return NULL;
+    }
+
+    av_dict_free(&opts);
+
+    output->last_dts = AV_NOPTS_VALUE;
+
+    return output;
+}
+
 void
 vs_destroy_output(struct VSOutput * const output)
 {
@@ -256,7 +497,8 @@ vs_destroy_output(struct VSOutput * const output)
             printf("unable to write trailer\n");
         }
 
-        if (avio_closep(&output->format_ctx->pb) != 0) {
+        if (output->format_ctx->pb &&
+                avio_closep(&output->format_ctx->pb) != 0) {
             printf("avio_closep failed\n");
         }
 
@@ -512,6 +754,22 @@ vs_write_packet_speed(const struct VSInput * const input,
     return __vs_write_packet(input, output, pkt, speed, verbose);
 }
 
+/* Return the presentation end time of the input packet in seconds.
+ * Returns -1 when the packet doesn't carry a valid timestamp.
+ */
+double
+vs_packet_end_time(const struct VSInput * const input,
+        const AVPacket * const pkt)
+{
+    if (!input || !pkt || pkt->pts == AV_NOPTS_VALUE) {
+        return -1;
+    }
+
+    const AVStream * const in_stream =
+        input->format_ctx->streams[pkt->stream_index];
+    return (pkt->pts + pkt->duration) * av_q2d(in_stream->time_base);
+}
+
 static void
 __vs_log_packet(const AVFormatContext * const format_ctx,
         const AVPacket * const pkt, const char * const tag)
@@ -8,6 +8,22 @@
 struct VSInput {
     AVFormatContext * format_ctx;
     int video_stream_index;
+    // Monotonic time in microseconds after which IO on the input is aborted,
+    // 0 for no timeout.
+    int64_t deadline;
+};
+
+#define VS_STREAM_NAME_LEN 32
+
+// Parameters of the video stream, and if the input has audio.
+struct VSStreamInfo {
+    char codec[VS_STREAM_NAME_LEN];
+    char profile[VS_STREAM_NAME_LEN];
+    int width;
+    int height;
+    double fps;
+    int64_t bitrate;
+    bool has_audio;
 };
 
 struct VSOutput {
@@ -28,14 +44,31 @@ struct VSInput *
 vs_open_input(const char * const,
         const char * const, const bool);
 
+struct VSInput *
+vs_open_input_timeout(const char * const,
+        const char * const, const int, int * const, const bool);
+
 void
 vs_destroy_input(struct VSInput * const);
 
+int
+vs_get_stream_info(const struct VSInput * const,
+        struct VSStreamInfo * const);
+
 struct VSOutput *
 vs_open_output(const char * const,
         const char * const, const struct VSInput * const,
         const bool);
 
+struct VSOutput *
+vs_open_output_params(const char * const,
+        const char * const, const struct VSInput * const,
+        const struct VSStreamInfo * const, const bool);
+
+struct VSOutput *
+vs_open_hls_output(const char * const, const char * const,
+        const struct VSInput * const, const int, const bool, const bool);
+
 void
 vs_destroy_output(struct VSOutput * const);
 
@@ -51,4 +84,11 @@ vs_write_packet_speed(const struct VSInput * const input,
         struct VSOutput * const, AVPacket * const, const float,
 		const bool);
 
+double
+vs_packet_end_time(const struct VSInput * const,
+        const AVPacket * const);
+
+int
+vs_seek_input(const struct VSInput * const, const double);
+
 #endif
@@ -0,0 +1,127 @@
+//
+// This library probes an input stream to validate the camera settings before
+// they are used for the timelapse.
+
+#include <errno.h>
+#include <libavutil/time.h>
+#include <stdio.h>
+#include <string.h>
+#include "videoprobe.h"
+
+// Return true if the open error is due to wrong/missing credentials.
+static bool
+__vs_is_auth_error(const int err)
+{
+    return err == AVERROR_HTTP_UNAUTHORIZED ||
+        err == AVERROR_HTTP_FORBIDDEN || err == AVERROR(EACCES);
+}
+
+// Open the input within 'timeout_sec' seconds and fill the stream details in
+// 'info'. The probe waits for the first video keyframe in the same timeout.
+//
+// Returns:
+// -1 if the input cannot be opened
+// 0 if the input is opened, 'info' has the details of the stream
+int
+vs_probe_input(const char * const input_format_name,
+        const char * const input_url, const int timeout_sec,
+        struct VSProbeInfo * const info)
+{
+    if (!info || timeout_sec <= 0) {
+        printf("%s\n", strerror(EINVAL));
+        return -1;
+    }
+
+    memset(info, 0, sizeof(struct VSProbeInfo));
+    info->keyframe_sec = -1;
+
+    const int64_t start = av_gettime_relative();
+    struct VSInput * const input = vs_open_input_timeout(input_format_name,
+            input_url, timeout_sec, &info->open_err, false);
+    info->open_sec = (double) (av_gettime_relative() - start) / AV_TIME_BASE;
+    if (!input) {
+        // Server responded to deny the access, so it is reachable.
+        if (__vs_is_auth_error(info->open_err)) {
+            info->reachable = true;
+        } else if (info->open_err == 0) {
+            // Opened, but no usable video stream in it.
+            info->reachable = true;
+            info->auth_ok = true;
+        }
+        return -1;
+    }
+
+    info->reachable = true;
+    info->auth_ok = true;
+    info->stream_count = (int) input->format_ctx->nb_streams;
+    for (unsigned int i = 0; i < input->format_ctx->nb_streams; i++) {
+        const AVCodecParameters * const par =
+            input->format_ctx->streams[i]->codecpar;
+        const size_t len = strlen(info->codecs);
+        snprintf(info->codecs + len, sizeof(info->codecs) - len, "%s%s",
+                len ? "," : "", avcodec_get_name(par->codec_id));
+    }
+
+    vs_get_stream_info(input, &info->stream);
+
+    const int64_t read_start = av_gettime_relative();
+    while (true) {
+        AVPacket pkt;
+        const int read_res = vs_read_packet(input, &pkt, false);
+        if (read_res == -1) {
+            break;
+        }
+        if (read_res == 0) {
+            continue;
+        }
+        const bool keyframe = pkt.flags & AV_PKT_FLAG_KEY;
+        av_packet_unref(&pkt);
+        if (keyframe) {
+            info->keyframe_sec =
+                (double) (av_gettime_relative() - read_start) / AV_TIME_BASE;
+            break;
+        }
+    }
+
+    vs_destroy_input(input);
+    return 0;
+}
+
+// Read the input till the end to check it is complete. A truncated file
+// fails with an error other than end of file, or has no video packets.
+//
+// Returns:
+// -1 if the input is incomplete
+// 0 if the input can be read till the end
+int
+vs_check_input(const char * const input_format_name,
+        const char * const input_url)
+{
+    struct VSInput * const input = vs_open_input(input_format_name,
+            input_url, false);
+    if (!input) {
+        return -1;
+    }
+
+    int64_t video_pkts = 0;
+    int res = 0;
+    while (true) {
+        AVPacket pkt;
+        memset(&pkt, 0, sizeof(AVPacket));
+        res = av_read_frame(input->format_ctx, &pkt);
+        if (res < 0) {
+            break;
+        }
+        if (pkt.stream_index == input->video_stream_index) {
+            video_pkts++;
+        }
+        av_packet_unref(&pkt);
+    }
+
+    vs_destroy_input(input);
+    if (res != AVERROR_EOF || video_pkts == 0) {
+        printf("incomplete input %s\n", input_url);
+        return -1;
+    }
+    return 0;
+}
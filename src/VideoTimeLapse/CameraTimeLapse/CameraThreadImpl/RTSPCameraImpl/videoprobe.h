@@ -0,0 +1,33 @@
+#ifndef _VIDEOPROBE_H
+#define _VIDEOPROBE_H
+
+#include <libavformat/avformat.h>
+#include <stdbool.h>
+#include "videomux.h"
+
+#define VS_PROBE_CODECS_LEN 128
+
+// Report of probing an input stream.
+struct VSProbeInfo {
+    bool reachable;
+    bool auth_ok;
+    // libav error when the input cannot be opened.
+    int open_err;
+    int stream_count;
+    // Comma separated codec names of all the streams.
+    char codecs[VS_PROBE_CODECS_LEN];
+    struct VSStreamInfo stream;
+    // Seconds taken to open the input and to receive the first video
+    // keyframe after that, -1 if no keyframe is received.
+    double open_sec;
+    double keyframe_sec;
+};
+
+int
+vs_probe_input(const char * const, const char * const, const int,
+        struct VSProbeInfo * const);
+
+int
+vs_check_input(const char * const, const char * const);
+
+#endif
@@ -0,0 +1,642 @@
+//
+// This library provides re-encoding of a video file through a libavfilter
+// graph. It is used for rendering the timelapse to the formats that cannot
+// be created by packet copy, such as animated GIF and WebM.
+//
+// The decode -> filter -> encode flow is based on the transcoding.c and
+// filtering_video.c examples of FFmpeg.
+
+#include <errno.h>
+#include <libavfilter/buffersink.h>
+#include <libavfilter/buffersrc.h>
+#include <libavutil/opt.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include "videotranscode.h"
+
+static void
+__vs_log_error(const char * const msg, const int err)
+{
+    char error_buf[256];
+    memset(error_buf, 0, sizeof(error_buf));
+    av_strerror(err, error_buf, sizeof(error_buf));
+    printf("%s: %s\n", msg, error_buf);
+}
+
+// Open the decoder for the video stream of the input.
+AVCodecContext *
+vs_open_decoder(const struct VSInput * const input)
+{
+    if (!input) {
+        printf("%s\n", strerror(EINVAL));
+        return NULL;
+    }
+
+    AVStream * const in_stream = input->format_ctx->streams[
+        input->video_stream_index];
+
+    const AVCodec * const decoder = avcodec_find_decoder(
+            in_stream->codecpar->codec_id);
+    if (!decoder) {
+        printf("decoder not found\n");
+        return NULL;
+    }
+
+    AVCodecContext * dec_ctx = avcodec_alloc_context3(decoder);
+    if (!dec_ctx) {
+        printf("unable to allocate decoder context\n");
+        return NULL;
+    }
+
+    if (avcodec_parameters_to_context(dec_ctx, in_stream->codecpar) < 0) {
+        printf("unable to copy decoder parameters\n");
+        avcodec_free_context(&dec_ctx);
+        return NULL;
+    }
+
+    dec_ctx->framerate = av_guess_frame_rate(input->format_ctx, in_stream,
+            NULL);
+    // Frames are always handled in the stream time base.
+    dec_ctx->pkt_timebase = in_stream->time_base;
+
+    if (avcodec_open2(dec_ctx, decoder, NULL) < 0) {
+        printf("unable to open decoder\n");
+        avcodec_free_context(&dec_ctx);
+        return NULL;
+    }
+
+    return dec_ctx;
+}
+
+// Create the filter graph 'filter_desc' that takes the decoded frames of
+// 'dec_ctx' as input. An empty description passes the frames as is.
+int
+vs_open_filter(struct VSFilter * const filter,
+        const AVCodecContext * const dec_ctx, const AVRational time_base,
+        const char * const filter_desc)
+{
+    if (!filter || !dec_ctx) {
+        printf("%s\n", strerror(EINVAL));
+        return -1;
+    }
+
+    memset(filter, 0, sizeof(struct VSFilter));
+
+    filter->graph = avfilter_graph_alloc();
+    if (!filter->graph) {
+        printf("unable to allocate filter graph\n");
+        return -1;
+    }
+
+    char args[512];
+    snprintf(args, sizeof(args),
+            "video_size=%dx%d:pix_fmt=%d:time_base=%d/%d:pixel_aspect=%d/%d",
+            dec_ctx->width, dec_ctx->height, dec_ctx->pix_fmt,
+            time_base.num, time_base.den,
+            dec_ctx->sample_aspect_ratio.num,
+            FFMAX(dec_ctx->sample_aspect_ratio.den, 1));
+
+    if (avfilter_graph_create_filter(&filter->src_ctx,
+                avfilter_get_by_name("buffer"), "in", args, NULL,
+                filter->graph) < 0) {
+        printf("unable to create buffer source\n");
+        vs_destroy_filter(filter);
+        return -1;
+    }
+
+    if (avfilter_graph_create_filter(&filter->sink_ctx,
+                avfilter_get_by_name("buffersink"), "out", NULL, NULL,
+                filter->graph) < 0) {
+        printf("unable to create buffer sink\n");
+        vs_destroy_filter(filter);
+        return -1;
+    }
+
+    // The open output of the graph description is linked to the source and
+    // the open input to the sink.
+    AVFilterInOut * outputs = avfilter_inout_alloc();
+    AVFilterInOut * inputs = avfilter_inout_alloc();
+    if (!outputs || !inputs) {
+        printf("unable to allocate filter in/out\n");
+        avfilter_inout_free(&outputs);
+        avfilter_inout_free(&inputs);
+        vs_destroy_filter(filter);
+        return -1;
+    }
+
+    outputs->name = av_strdup("in");
+    outputs->filter_ctx = filter->src_ctx;
+    outputs->pad_idx = 0;
+    outputs->next = NULL;
+
+    inputs->name = av_strdup("out");
+    inputs->filter_ctx = filter->sink_ctx;
+    inputs->pad_idx = 0;
+    inputs->next = NULL;
+
+    const char * const desc = (filter_desc && strlen(filter_desc) != 0) ?
+        filter_desc : "null";
+    int res = avfilter_graph_parse_ptr(filter->graph, desc, &inputs,
+            &outputs, NULL);
+    avfilter_inout_free(&outputs);
+    avfilter_inout_free(&inputs);
+    if (res < 0) {
+        __vs_log_error("unable to parse filter graph", res);
+        vs_destroy_filter(filter);
+        return -1;
+    }
+
+    res = avfilter_graph_config(filter->graph, NULL);
+    if (res < 0) {
+        __vs_log_error("unable to configure filter graph", res);
+        vs_destroy_filter(filter);
+        return -1;
+    }
+
+    return 0;
+}
+
+void
+vs_destroy_filter(struct VSFilter * const filter)
+{
+    if (!filter) {
+        return;
+    }
+
+    // Source and sink are freed along with the graph.
+    avfilter_graph_free(&filter->graph);
+    filter->src_ctx = NULL;
+    filter->sink_ctx = NULL;
+}
+
+// Allocate the output container. The encoder is opened on the first frame.
+struct VSEncOutput *
+vs_open_enc_output(const char * const output_format_name,
+        const char * const output_url, const char * const encoder_name,
+        const char * const encoder_opts, const AVRational framerate)
+{
+    if (!output_format_name || strlen(output_format_name) == 0 ||
+            !output_url || strlen(output_url) == 0 ||
+            !encoder_name || strlen(encoder_name) == 0) {
+        printf("%s\n", strerror(EINVAL));
+        return NULL;
+    }
+
+    struct VSEncOutput * const output = calloc(1, sizeof(struct VSEncOutput));
+    if (!output) {
+        printf("%s\n", strerror(errno));
+        return NULL;
+    }
+
+    output->encoder = avcodec_find_encoder_by_name(encoder_name);
+    if (!output->encoder) {
+        printf("encoder %s not found\n", encoder_name);
+        vs_destroy_enc_output(output);
+        return NULL;
+    }
+
+    AVOutputFormat * const output_format = av_guess_format(output_format_name,
+            NULL, NULL);
+    if (!output_format) {
+        printf("output format not found\n");
+        vs_destroy_enc_output(output);
+        return NULL;
+    }
+
+    if (avformat_alloc_output_context2(&output->format_ctx, output_format,
+                NULL, NULL) < 0) {
+        printf("unable to create output context\n");
+        vs_destroy_enc_output(output);
+        return NULL;
+    }
+
+    // Encoder options are in the "key=value:key=value" form.
+    if (encoder_opts && strlen(encoder_opts) != 0 &&
+            av_dict_parse_string(&output->enc_opts, encoder_opts, "=", ":",
+                0) < 0) {
+        printf("unable to parse encoder options %s\n", encoder_opts);
+        vs_destroy_enc_output(output);
+        return NULL;
+    }
+
+    output->output_url = av_strdup(output_url);
+    output->framerate = framerate;
+    return output;
+}
+
+// Open the encoder and write the file header using the properties of the
+// first filtered frame.
+static int
+__vs_open_encoder(struct VSEncOutput * const output,
+        const AVFrame * const frame, const AVRational time_base)
+{
+    output->enc_ctx = avcodec_alloc_context3(output->encoder);
+    if (!output->enc_ctx) {
+        printf("unable to allocate encoder context\n");
+        return -1;
+    }
+
+    output->enc_ctx->width = frame->width;
+    output->enc_ctx->height = frame->height;
+    output->enc_ctx->pix_fmt = frame->format;
+    output->enc_ctx->sample_aspect_ratio = frame->sample_aspect_ratio;
+    output->enc_ctx->time_base = time_base;
+    output->enc_ctx->framerate = output->framerate;
+
+    if (output->format_ctx->oformat->flags & AVFMT_GLOBALHEADER) {
+        output->enc_ctx->flags |= AV_CODEC_FLAG_GLOBAL_HEADER;
+    }
+
+    int res = avcodec_open2(output->enc_ctx, output->encoder,
+            &output->enc_opts);
+    if (res < 0) {
+        __vs_log_error("unable to open encoder", res);
+        return -1;
+    }
+
+    AVStream * const out_stream = avformat_new_stream(output->format_ctx,
+            NULL);
+    if (!out_stream) {
+        printf("unable to add stream\n");
+        return -1;
+    }
+
+    if (avcodec_parameters_from_context(out_stream->codecpar,
+                output->enc_ctx) < 0) {
+        printf("unable to copy encoder parameters\n");
+        return -1;
+    }
+    out_stream->time_base = output->enc_ctx->time_base;
+
+    if (!(output->format_ctx->oformat->flags & AVFMT_NOFILE) &&
+            avio_open(&output->format_ctx->pb, output->output_url,
+                AVIO_FLAG_WRITE) < 0) {
+        printf("unable to open output file %s\n", output->output_url);
+        return -1;
+    }
+
+    res = avformat_write_header(output->format_ctx, &output->format_opts);
+    if (res < 0) {
+        __vs_log_error("unable to write header", res);
+        return -1;
+    }
+
+    output->header_written = true;
+    return 0;
+}
+
+// Write all the packets available from the encoder.
+static int
+__vs_write_encoded(struct VSEncOutput * const output)
+{
+    AVPacket * pkt = av_packet_alloc();
+    if (!pkt) {
+        printf("unable to allocate packet\n");
+        return -1;
+    }
+
+    int res = 0;
+    while ((res = avcodec_receive_packet(output->enc_ctx, pkt)) == 0) {
+        pkt->stream_index = 0;
+        av_packet_rescale_ts(pkt, output->enc_ctx->time_base,
+                output->format_ctx->streams[0]->time_base);
+        res = av_interleaved_write_frame(output->format_ctx, pkt);
+        av_packet_unref(pkt);
+        if (res < 0) {
+            __vs_log_error("unable to write frame", res);
+            av_packet_free(&pkt);
+            return -1;
+        }
+    }
+
+    av_packet_free(&pkt);
+    if (res != AVERROR(EAGAIN) && res != AVERROR_EOF) {
+        __vs_log_error("unable to encode frame", res);
+        return -1;
+    }
+    return 0;
+}
+
+// Encode the frame to output. A NULL frame flushes the encoder.
+int
+vs_encode_frame(struct VSEncOutput * const output, AVFrame * const frame,
+        const AVRational time_base)
+{
+    if (!output) {
+        printf("%s\n", strerror(EINVAL));
+        return -1;
+    }
+
+    if (!output->enc_ctx) {
+        if (!frame) {
+            // Nothing is encoded so far.
+            return 0;
+        }
+        if (__vs_open_encoder(output, frame, time_base) != 0) {
+            return -1;
+        }
+    }
+
+    if (frame) {
+        frame->pict_type = AV_PICTURE_TYPE_NONE;
+    }
+
+    const int res = avcodec_send_frame(output->enc_ctx, frame);
+    if (res < 0 && res != AVERROR_EOF) {
+        __vs_log_error("unable to send frame to encoder", res);
+        return -1;
+    }
+
+    return __vs_write_encoded(output);
+}
+
+void
+vs_destroy_enc_output(struct VSEncOutput * const output)
+{
+    if (!output) {
+        return;
+    }
+
+    if (output->format_ctx) {
+        if (output->header_written &&
+                av_write_trailer(output->format_ctx) != 0) {
+            printf("unable to write trailer\n");
+        }
+
+        if (output->format_ctx->pb &&
+                avio_closep(&output->format_ctx->pb) != 0) {
+            printf("avio_closep failed\n");
+        }
+
+        avformat_free_context(output->format_ctx);
+    }
+
+    avcodec_free_context(&output->enc_ctx);
+    av_dict_free(&output->enc_opts);
+    av_dict_free(&output->format_opts);
+    av_free(output->output_url);
+    free(output);
+}
+
+// Pull all the frames from the filter graph and encode them.
+static int
+__vs_filter_encode(struct VSFilter * const filter,
+        struct VSEncOutput * const output, AVFrame * const filt_frame)
+{
+    const AVRational time_base = av_buffersink_get_time_base(
+            filter->sink_ctx);
+    int res = 0;
+    while ((res = av_buffersink_get_frame(filter->sink_ctx,
+                    filt_frame)) >= 0) {
+        res = vs_encode_frame(output, filt_frame, time_base);
+        av_frame_unref(filt_frame);
+        if (res != 0) {
+            return -1;
+        }
+    }
+
+    if (res != AVERROR(EAGAIN) && res != AVERROR_EOF) {
+        __vs_log_error("unable to get filtered frame", res);
+        return -1;
+    }
+    return 0;
+}
+
+// Send the decoded frames to filter graph and encode the filtered frames.
+// The frames are masked before filtering when 'mask' is set. A NULL packet
+// flushes the decoder.
+static int
+__vs_decode_filter_encode(AVCodecContext * const dec_ctx,
+        struct VSFilter * const filter, struct VSEncOutput * const output,
+        const AVPacket * const pkt, AVFrame * const frame,
+        AVFrame * const filt_frame, const struct VSMask * const mask)
+{
+    int res = avcodec_send_packet(dec_ctx, pkt);
+    if (res < 0 && res != AVERROR_EOF) {
+        // Corrupted packets are skipped, decoder recovers on next keyframe.
+        __vs_log_error("unable to decode packet", res);
+        return 0;
+    }
+
+    while ((res = avcodec_receive_frame(dec_ctx, frame)) == 0) {
+        frame->pts = frame->best_effort_timestamp;
+        if (mask && vs_mask_frame(frame, mask) != 0) {
+            av_frame_unref(frame);
+            return -1;
+        }
+        res = av_buffersrc_add_frame_flags(filter->src_ctx, frame,
+                AV_BUFFERSRC_FLAG_KEEP_REF);
+        av_frame_unref(frame);
+        if (res < 0) {
+            __vs_log_error("unable to feed filter graph", res);
+            return -1;
+        }
+        if (__vs_filter_encode(filter, output, filt_frame) != 0) {
+            return -1;
+        }
+    }
+
+    if (res != AVERROR(EAGAIN) && res != AVERROR_EOF) {
+        __vs_log_error("unable to receive decoded frame", res);
+        return -1;
+    }
+    return 0;
+}
+
+// Open the decoder, filter graph and the output to re-encode the video
+// packets of the input. The input is not used after opening, the packets
+// read from it are passed to vs_transcode_packet(). 'format_opts' are the
+// muxer options in the "key=value:key=value" form. The frames are masked by
+// 'mask' when set, it must be valid until the transcoder is closed.
+struct VSTranscoder *
+vs_open_transcoder(const struct VSInput * const input,
+        const char * const output_format_name, const char * const output_url,
+        const char * const encoder_name, const char * const encoder_opts,
+        const char * const format_opts, const char * const filter_desc,
+        const struct VSMask * const mask)
+{
+    if (!input) {
+        printf("%s\n", strerror(EINVAL));
+        return NULL;
+    }
+
+    struct VSTranscoder * const transcoder = calloc(1,
+            sizeof(struct VSTranscoder));
+    if (!transcoder) {
+        printf("%s\n", strerror(errno));
+        return NULL;
+    }
+    transcoder->mask = mask;
+
+    AVStream * const in_stream = input->format_ctx->streams[
+        input->video_stream_index];
+
+    transcoder->dec_ctx = vs_open_decoder(input);
+    if (!transcoder->dec_ctx) {
+        goto error;
+    }
+
+    if (vs_open_filter(&transcoder->filter, transcoder->dec_ctx,
+                in_stream->time_base, filter_desc) != 0) {
+        goto error;
+    }
+
+    transcoder->output = vs_open_enc_output(output_format_name, output_url,
+            encoder_name, encoder_opts, transcoder->dec_ctx->framerate);
+    if (!transcoder->output) {
+        goto error;
+    }
+
+    if (format_opts && strlen(format_opts) != 0 &&
+            av_dict_parse_string(&transcoder->output->format_opts,
+                format_opts, "=", ":", 0) < 0) {
+        printf("unable to parse muxer options %s\n", format_opts);
+        goto error;
+    }
+
+    transcoder->frame = av_frame_alloc();
+    transcoder->filt_frame = av_frame_alloc();
+    if (!transcoder->frame || !transcoder->filt_frame) {
+        printf("unable to allocate frames\n");
+        goto error;
+    }
+
+    return transcoder;
+
+error:
+    // Nothing is written to the output yet.
+    vs_close_transcoder(transcoder);
+    return NULL;
+}
+
+// Decode, filter and encode the video packet read from the input.
+//
+// Returns:
+// -1 if error
+// 0 if the packet is transcoded
+int
+vs_transcode_packet(struct VSTranscoder * const transcoder,
+        const AVPacket * const pkt)
+{
+    if (!transcoder || !pkt) {
+        printf("%s\n", strerror(EINVAL));
+        return -1;
+    }
+
+    return __vs_decode_filter_encode(transcoder->dec_ctx,
+            &transcoder->filter, transcoder->output, pkt, transcoder->frame,
+            transcoder->filt_frame, transcoder->mask);
+}
+
+// Flush the decoder, filter graph and encoder in order, and close the output.
+//
+// Returns:
+// -1 if error or no frames are transcoded
+// 0 if the output is complete
+int
+vs_close_transcoder(struct VSTranscoder * const transcoder)
+{
+    if (!transcoder) {
+        return -1;
+    }
+
+    int ret = -1;
+    if (!transcoder->output || !transcoder->frame ||
+            !transcoder->filt_frame) {
+        goto end;
+    }
+
+    if (__vs_decode_filter_encode(transcoder->dec_ctx, &transcoder->filter,
+                transcoder->output, NULL, transcoder->frame,
+                transcoder->filt_frame, transcoder->mask) != 0) {
+        goto end;
+    }
+
+    if (av_buffersrc_add_frame_flags(transcoder->filter.src_ctx, NULL,
+                0) < 0) {
+        printf("unable to flush filter graph\n");
+        goto end;
+    }
+
+    if (__vs_filter_encode(&transcoder->filter, transcoder->output,
+                transcoder->filt_frame) != 0) {
+        goto end;
+    }
+
+    if (vs_encode_frame(transcoder->output, NULL, av_buffersink_get_time_base(
+                    transcoder->filter.sink_ctx)) != 0) {
+        goto end;
+    }
+
+    if (!transcoder->output->header_written) {
+        printf("no frames are transcoded to %s\n",
+                transcoder->output->output_url);
+        goto end;
+    }
+
+    ret = 0;
+
+end:
+    av_frame_free(&transcoder->frame);
+    av_frame_free(&transcoder->filt_frame);
+    vs_destroy_enc_output(transcoder->output);
+    vs_destroy_filter(&transcoder->filter);
+    avcodec_free_context(&transcoder->dec_ctx);
+    free(transcoder);
+    return ret;
+}
+
+// Re-encode the video stream of the input to the output through the filter
+// graph 'filter_desc'.
+//
+// Returns:
+// -1 if error
+// 0 if the output is created
+int
+vs_transcode(const char * const input_format_name,
+        const char * const input_url, const char * const output_format_name,
+        const char * const output_url, const char * const encoder_name,
+        const char * const encoder_opts, const char * const filter_desc,
+        const bool verbose)
+{
+    struct VSInput * const input = vs_open_input(input_format_name,
+            input_url, verbose);
+    if (!input) {
+        return -1;
+    }
+
+    struct VSTranscoder * const transcoder = vs_open_transcoder(input,
+            output_format_name, output_url, encoder_name, encoder_opts, NULL,
+            filter_desc, NULL);
+    if (!transcoder) {
+        vs_destroy_input(input);
+        return -1;
+    }
+
+    int ret = 0;
+    while (true) {
+        AVPacket pkt;
+        const int read_res = vs_read_packet(input, &pkt, verbose);
+        if (read_res == -1) {
+            // End of the input.
+            break;
+        }
+        if (read_res == 0) {
+            continue;
+        }
+
+        const int res = vs_transcode_packet(transcoder, &pkt);
+        av_packet_unref(&pkt);
+        if (res != 0) {
+            ret = -1;
+            break;
+        }
+    }
+
+    if (vs_close_transcoder(transcoder) != 0) {
+        ret = -1;
+    }
+    vs_destroy_input(input);
+    return ret;
+}
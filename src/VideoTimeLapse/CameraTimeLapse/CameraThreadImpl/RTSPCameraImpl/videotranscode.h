@@ -0,0 +1,83 @@
+#ifndef _VIDEOTRANSCODE_H
+#define _VIDEOTRANSCODE_H
+
+#include <libavcodec/avcodec.h>
+#include <libavfilter/avfilter.h>
+#include <libavformat/avformat.h>
+#include <stdbool.h>
+#include "videomask.h"
+#include "videomux.h"
+
+// Filter graph with a single video source and sink.
+struct VSFilter {
+    AVFilterGraph * graph;
+    AVFilterContext * src_ctx;
+    AVFilterContext * sink_ctx;
+};
+
+// Encoder and output container for the transcoded video. The encoder is
+// opened only when the first filtered frame is available, as the filters
+// decide the frame size and pixel format.
+struct VSEncOutput {
+    AVFormatContext * format_ctx;
+    AVCodecContext * enc_ctx;
+    const AVCodec * encoder;
+    AVDictionary * enc_opts;
+    // Muxer options applied when writing the header.
+    AVDictionary * format_opts;
+    char * output_url;
+    AVRational framerate;
+    bool header_written;
+};
+
+// Re-encoding of the packets read from an input that is left open, such as
+// the snapshot clips of a camera. The decoded frames are masked before they
+// are filtered when the mask is set.
+struct VSTranscoder {
+    AVCodecContext * dec_ctx;
+    struct VSFilter filter;
+    struct VSEncOutput * output;
+    AVFrame * frame;
+    AVFrame * filt_frame;
+    const struct VSMask * mask;
+};
+
+AVCodecContext *
+vs_open_decoder(const struct VSInput * const);
+
+int
+vs_open_filter(struct VSFilter * const, const AVCodecContext * const,
+        const AVRational, const char * const);
+
+void
+vs_destroy_filter(struct VSFilter * const);
+
+struct VSEncOutput *
+vs_open_enc_output(const char * const, const char * const,
+        const char * const, const char * const, const AVRational);
+
+int
+vs_encode_frame(struct VSEncOutput * const, AVFrame * const,
+        const AVRational);
+
+void
+vs_destroy_enc_output(struct VSEncOutput * const);
+
+struct VSTranscoder *
+vs_open_transcoder(const struct VSInput * const, const char * const,
+        const char * const, const char * const, const char * const,
+        const char * const, const char * const, const struct VSMask * const);
+
+int
+vs_transcode_packet(struct VSTranscoder * const, const AVPacket * const);
+
+int
+vs_close_transcoder(struct VSTranscoder * const);
+
+int
+vs_transcode(const char * const, const char * const,
+        const char * const, const char * const,
+        const char * const, const char * const,
+        const char * const, const bool);
+
+#endif
@@ -12,4 +12,11 @@ type CameraThreadInterface interface {
     RunCameraThread() error
     UpdateCameraThread(*dataSet.Camera)(error)
     StopCameraThread() error
+    //Render the timelapse so far without ending the running cycle.
+    RenderCameraTimelapse() error
+    //End the running cycle, render it and start a new cycle.
+    RotateCameraCycle() error
+    //Capture a clip of the stream from 'preSec' seconds before till
+    // 'postSec' seconds after now. Returns the name of the clip video.
+    CaptureCameraClip(preSec uint64, postSec uint64) (string, error)
 }
@@ -15,6 +15,12 @@ type AppConfig struct {
     Loglevel int64
     Dbpath string
     VideoPath string
+    //Maintenance command to run instead of starting the application.
+    Command string
+    FsckRepair bool
+    FsckQuarantine bool
+    //Number of background rendering jobs run at once.
+    JobWorkers int
 }
 
 const (
@@ -22,12 +28,18 @@ const (
     DEFAULT_LOG_LEVEL = logging.Trace
     DEFAULT_PATH = "/tmp/"
     DEFAULT_DB_NAME = "timelapse.db"
+    DEFAULT_JOB_WORKERS = 2
+)
+
+//Maintenance commands
+const (
+    CMD_FSCK = "fsck"
 )
 
 func (config *AppConfig)printHelp() {
     helpstr := "\n\t Timelapse video from RTSP camera stream" +
         "\n\t An application to create timelapse video from rtsp cameras" +
-        "\n\t   USAGE: ./timelapse {ARGS}" +
+        "\n\t   USAGE: ./timelapse {ARGS} [COMMAND]" +
         "\n\t      ARGS:" +
         "\n\t      -help / -h                          :- Display help and exit." +
         "\n\t      -a <ipAddr> / -ipaddr <ipAddr>      :- Ip address to listen on(Default : localhost)" +
@@ -36,11 +48,16 @@ func (config *AppConfig)printHelp() {
         "\n\t      -D <path> / -dir <path>             :- Directory for Backend DB & videos(default : /tmp)" +
         "\n\t      -A <db ip> / -dbIp <db ip>          :- Ip address to reach DB server" +
         "\n\t      -P <db port> / -dbPort <dbport>     :- Port to reach DB server" +
+        "\n\t      -w <count> / -workers <count>       :- Rendering jobs run at once(Default : 2)" +
         "\n\t      -l <loglevel>/ -loglevel <loglevel> :- loglevel for the application(Default :2)" +
         "\n\t                                             1. Trace" +
         "\n\t                                             2. Info" +
         "\n\t                                             3. Warning" +
         "\n\t                                             4. Error" +
+        "\n\t      COMMAND:" +
+        "\n\t      fsck [-repair] [-quarantine]        :- Check videos on disk against the catalog and exit" +
+        "\n\t                                             -repair     : Fix the catalog, render orphaned snapshots" +
+        "\n\t                                             -quarantine : Move broken files to <dir>/quarantine" +
         "\n\n"
     fmt.Print(helpstr)
 }
@@ -59,6 +76,10 @@ func (config *AppConfig)InitConfig() {
     loglevellong := flag.Int64("loglevel", DEFAULT_LOG_LEVEL, "loglevel for the application")
     pathShort := flag.String("D",DEFAULT_PATH, "Backend DB")
     pathLong := flag.String("dir", DEFAULT_PATH, "Backend DB")
+    workersShort := flag.Int("w", DEFAULT_JOB_WORKERS,
+                             "Rendering jobs run at once")
+    workersLong := flag.Int("workers", DEFAULT_JOB_WORKERS,
+                            "Rendering jobs run at once")
     flag.Parse()
 
     config.Ip = *ipaddrShort
@@ -90,9 +111,41 @@ func (config *AppConfig)InitConfig() {
         //Directory not present in system.
         path = DEFAULT_PATH
     }
+    config.JobWorkers = *workersShort
+    if config.JobWorkers == DEFAULT_JOB_WORKERS {
+        config.JobWorkers = *workersLong
+    }
+    if config.JobWorkers < 1 {
+        config.JobWorkers = DEFAULT_JOB_WORKERS
+    }
     config.Dbpath = path + "/" + DEFAULT_DB_NAME
     config.VideoPath = path
+    config.parseCommand(flag.Args())
     //TODO :: Need to populate DB server IP and port when needed.
     // For now there is only one db backend is implemented, i.e sqlite.
     // it doesnt need any server ip or port.
 }
+
+//Read the maintenance command and its options following the ARGS.
+func (config *AppConfig)parseCommand(args []string) {
+    if len(args) == 0 {
+        return
+    }
+    switch args[0] {
+    case CMD_FSCK:
+        fsckFlags := flag.NewFlagSet(CMD_FSCK, flag.ExitOnError)
+        fsckFlags.Usage = config.printHelp
+        repair := fsckFlags.Bool("repair", false,
+                                 "Fix the catalog and render orphaned snapshots")
+        quarantine := fsckFlags.Bool("quarantine", false,
+                                     "Move broken files to quarantine")
+        fsckFlags.Parse(args[1:])
+        config.Command = CMD_FSCK
+        config.FsckRepair = *repair
+        config.FsckQuarantine = *quarantine
+    default:
+        fmt.Printf("\n\t Unknown command %s\n", args[0])
+        config.printHelp()
+        os.Exit(1)
+    }
+}
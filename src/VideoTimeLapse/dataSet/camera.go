@@ -16,6 +16,9 @@ package dataSet
 
 import (
     "math"
+    "time"
+    "strings"
+    "encoding/json"
 )
 
 const (
@@ -37,6 +40,116 @@ const (
     //default recording time for a camera.
     CAMERA_DEFAULT_TIMELAPSE_SEC = 3600 //(1 Hr)
     CAMERA_DEFAULT_SNAPSHOT_INTERVAL = 60 //60 seconds
+    //default number of packets copied in every snapshot clip.
+    CAMERA_DEFAULT_SNAPSHOT_PKTS = 48
+    //default speed-up factor applied when compacting the timelapse video.
+    CAMERA_DEFAULT_SPEED_FACTOR = 4
+)
+
+//Limits for the snapshot clip and compaction parameters.
+const (
+    CAMERA_MAX_SNAPSHOT_PKTS = 10000
+    CAMERA_MAX_SPEED_FACTOR = 1000
+    //Minimum length of the compacted timelapse video.
+    CAMERA_MIN_OUTPUT_LEN_SEC = 1
+    //Maximum number of days the snapshots are kept after rendering.
+    CAMERA_MAX_KEEP_SNAPSHOT_DAYS = 3650
+)
+
+//Corner of the frame the overlay text is placed.
+const (
+    OVERLAY_TOP_LEFT = "top-left"
+    OVERLAY_TOP_RIGHT = "top-right"
+    OVERLAY_BOTTOM_LEFT = "bottom-left"
+    OVERLAY_BOTTOM_RIGHT = "bottom-right"
+    CAMERA_DEFAULT_OVERLAY_POSITION = OVERLAY_BOTTOM_RIGHT
+    CAMERA_DEFAULT_OVERLAY_FONT_SIZE = 24
+    CAMERA_MIN_OVERLAY_FONT_SIZE = 8
+    CAMERA_MAX_OVERLAY_FONT_SIZE = 200
+    CAMERA_MAX_OVERLAY_CAPTION_LEN = 256
+)
+
+//Mode of capturing the snapshots.
+const (
+    //Snapshot is taken at every snapshot interval.
+    CAMERA_CAPTURE_INTERVAL = "interval"
+    //Scene is checked at every snapshot interval, and the snapshot is kept
+    // only when the scene is changed or the maximum interval is elapsed.
+    CAMERA_CAPTURE_ADAPTIVE = "adaptive"
+    CAMERA_DEFAULT_CAPTURE_MODE = CAMERA_CAPTURE_INTERVAL
+    //Scene change in percent to keep a snapshot in adaptive mode.
+    CAMERA_DEFAULT_CHANGE_THRESHOLD = 5
+    CAMERA_MIN_CHANGE_THRESHOLD = 1
+    CAMERA_MAX_CHANGE_THRESHOLD = 100
+)
+
+//Thresholds of the quality check on the first frame of a snapshot. The
+// frame luma is in 0-255, the snapshot is rejected when its mean is below the
+// minimum or above the maximum, or its standard deviation is below the
+// minimum as the frame is near uniform.
+const (
+    CAMERA_DEFAULT_MIN_MEAN_LUMA = 16
+    CAMERA_DEFAULT_MAX_MEAN_LUMA = 240
+    CAMERA_DEFAULT_MIN_LUMA_DEVIATION = 3
+    CAMERA_MAX_LUMA = 255
+    CAMERA_MAX_LUMA_DEVIATION = 128
+    //Maximum captures retried in an interval after a rejected snapshot.
+    CAMERA_MAX_QUALITY_RETRIES = 5
+)
+
+//Number of frames the brightness is averaged over when deflickering the
+// timelapse, same as the size limits of the libavfilter deflicker.
+const (
+    CAMERA_DEFAULT_DEFLICKER_WINDOW = 5
+    CAMERA_MIN_DEFLICKER_WINDOW = 2
+    CAMERA_MAX_DEFLICKER_WINDOW = 129
+)
+
+//Similarity of the scene to its reference in percent, below which the
+// camera is considered as moved or covered.
+const (
+    CAMERA_DEFAULT_TAMPER_THRESHOLD = 50
+    CAMERA_MIN_TAMPER_THRESHOLD = 1
+    CAMERA_MAX_TAMPER_THRESHOLD = 99
+)
+
+//Length of the segments in seconds and the hours they are kept, in the
+// continuous recording.
+const (
+    CAMERA_DEFAULT_SEGMENT_SEC = 60
+    CAMERA_MIN_SEGMENT_SEC = 10
+    CAMERA_MAX_SEGMENT_SEC = 3600
+    CAMERA_DEFAULT_SEGMENT_RETENTION_HOURS = 24
+    CAMERA_MAX_SEGMENT_RETENTION_HOURS = 8760
+)
+
+//Maximum seconds of the stream kept in memory for the footage before a clip
+// trigger.
+const (
+    CAMERA_MAX_CLIP_BUFFER_SEC = 60
+)
+
+//Policy to handle the timelapse cycle interrupted by an application restart.
+const (
+    //Continue capturing snapshots in the interrupted cycle.
+    CAMERA_RESUME_CONTINUE = "resume"
+    //Render the timelapse from the snapshots of interrupted cycle and start a
+    // new cycle.
+    CAMERA_RESUME_RENDER = "render"
+    CAMERA_DEFAULT_RESUME_POLICY = CAMERA_RESUME_CONTINUE
+)
+
+//Boundary of the wall clock in the timezone of the camera the timelapse cycles
+// are aligned to. The aligned cycles start at every cycle length from the
+// boundary, and the last cycle before the next boundary is cut short.
+const (
+    //Cycles start when the camera thread starts.
+    CAMERA_CYCLE_ALIGN_NONE = "none"
+    CAMERA_CYCLE_ALIGN_HOUR = "hour"
+    CAMERA_CYCLE_ALIGN_DAY = "day"
+    //Weeks start on Monday.
+    CAMERA_CYCLE_ALIGN_WEEK = "week"
+    CAMERA_DEFAULT_CYCLE_ALIGN = CAMERA_CYCLE_ALIGN_NONE
 )
 //Structure to hold all the information for the camera.
 //Must update JsonCameraInput when updating this structure.
@@ -53,6 +166,77 @@ type Camera struct {
     VideoLenSec uint64   `json:"VideoLenSec"`
     // Interval between videosnapshots
     SnapInterval uint64   `json:"VideoSnapInterval"`
+    //Number of packets copied in every snapshot clip.
+    SnapshotPkts uint64  `json:"SnapshotPkts"`
+    //Length of every snapshot clip in seconds. Overrides SnapshotPkts when
+    // set to non zero value.
+    SnapshotSec uint64   `json:"SnapshotSec"`
+    //Speed-up factor used when compacting the stitched timelapse video.
+    SpeedFactor uint64   `json:"SpeedFactor"`
+    //Target duration of the compacted timelapse video in seconds. Overrides
+    // SpeedFactor when set to non zero value.
+    OutputLenSec uint64  `json:"OutputLenSec"`
+    //Comma separated list of formats the timelapse is rendered to,
+    // eg: "mp4,gif,webm".
+    OutputFormats string `json:"OutputFormats"`
+    //Package the timelapse videos as HLS for in-browser playback.
+    EnableHLS bool       `json:"EnableHLS"`
+    //What to do with the cycle interrupted by a restart, "resume"/"render".
+    ResumePolicy string  `json:"ResumePolicy"`
+    //Number of days the snapshots are kept after rendering the timelapse, so
+    // the cycle can be re-rendered. '0' deletes them right after stitching.
+    KeepSnapshotDays uint64 `json:"KeepSnapshotDays"`
+    //Text burned into the timelapse frames, the capture time of the frame,
+    // the camera name and a caption. The final timelapse is re-encoded when
+    // any of them is set.
+    OverlayTimestamp bool   `json:"OverlayTimestamp"`
+    OverlayCamName bool     `json:"OverlayCamName"`
+    OverlayCaption string   `json:"OverlayCaption"`
+    //"top-left"/"top-right"/"bottom-left"/"bottom-right".
+    OverlayPosition string  `json:"OverlayPosition"`
+    OverlayFontSize uint64  `json:"OverlayFontSize"`
+    //IANA name of the timezone of the capture time, eg: "Europe/London".
+    // Empty is the timezone of the camera.
+    OverlayTimezone string  `json:"OverlayTimezone"`
+    //JSON list of the polygon regions hidden in every stored snapshot, the
+    // snapshots are re-encoded when set. Empty for no masks.
+    PrivacyMasks string     `json:"PrivacyMasks"`
+    //"interval"/"adaptive".
+    CaptureMode string      `json:"CaptureMode"`
+    //Scene change in percent from the last kept snapshot to keep a snapshot
+    // in adaptive mode.
+    ChangeThreshold uint64  `json:"ChangeThreshold"`
+    //Maximum seconds between the kept snapshots in adaptive mode. '0' keeps
+    // the snapshots only on a scene change.
+    MaxSnapInterval uint64  `json:"MaxSnapInterval"`
+    //Reject the snapshots of blank, dark, bright or undecodable frames.
+    QualityCheck bool       `json:"QualityCheck"`
+    MinMeanLuma uint64      `json:"MinMeanLuma"`
+    MaxMeanLuma uint64      `json:"MaxMeanLuma"`
+    MinLumaDeviation uint64 `json:"MinLumaDeviation"`
+    //Captures retried in the same interval after a rejected snapshot.
+    QualityRetries uint64   `json:"QualityRetries"`
+    //Smooth the brightness changes of the rendered timelapse.
+    Deflicker bool          `json:"Deflicker"`
+    DeflickerWindow uint64  `json:"DeflickerWindow"`
+    //Raise an event when the camera is moved, covered or defocused.
+    TamperDetection bool    `json:"TamperDetection"`
+    TamperThreshold uint64  `json:"TamperThreshold"`
+    //Record the stream continuously to segments, the snapshots are sampled
+    // from the segments.
+    Recording bool          `json:"Recording"`
+    SegmentSec uint64       `json:"SegmentSec"`
+    SegmentRetentionHours uint64 `json:"SegmentRetentionHours"`
+    //Seconds of the stream buffered for the clips, '0' disables the buffer.
+    ClipBufferSec uint64    `json:"ClipBufferSec"`
+    //JSON list of the capture profiles rendering more timelapses from the
+    // stream of the camera. Empty for no profiles.
+    Profiles string         `json:"Profiles"`
+    //IANA name of the timezone the cycles are named and aligned in. Empty is
+    // the local time of the system.
+    Timezone string         `json:"Timezone"`
+    //"none"/"hour"/"day"/"week".
+    CycleAlign string       `json:"CycleAlign"`
 }
 
 func (camObj *Camera) IsCameraStatusValid() (bool, error) {
@@ -79,3 +263,303 @@ func (camObj *Camera) IsSnapshotLenValid() (bool) {
     }
     return true
 }
+
+func (camObj *Camera) IsSnapshotPktsValid() (bool) {
+    if camObj.SnapshotPkts < 1 ||
+        camObj.SnapshotPkts > CAMERA_MAX_SNAPSHOT_PKTS {
+        return false
+    }
+    return true
+}
+
+//Snapshot interval must be set when checking the clip length, as a clip
+// cannot be longer than the interval between the snapshots.
+//'0' is valid and means the clip length is set in packets.
+func (camObj *Camera) IsSnapshotSecValid() (bool) {
+    if camObj.SnapshotSec != 0 && camObj.SnapshotSec >= camObj.SnapInterval {
+        return false
+    }
+    return true
+}
+
+func (camObj *Camera) IsSpeedFactorValid() (bool) {
+    if camObj.SpeedFactor < 1 ||
+        camObj.SpeedFactor > CAMERA_MAX_SPEED_FACTOR {
+        return false
+    }
+    return true
+}
+
+//Videolen must be set when checking the output length, the compacted video
+// cannot be longer than the recording time.
+//'0' is valid and means the compaction uses the speed factor.
+func (camObj *Camera) IsOutputLenValid() (bool) {
+    if camObj.OutputLenSec != 0 &&
+        (camObj.OutputLenSec < CAMERA_MIN_OUTPUT_LEN_SEC ||
+        camObj.OutputLenSec >= camObj.VideoLenSec) {
+        return false
+    }
+    return true
+}
+
+//Return the list of output formats configured for the camera. The mp4 format
+// is always in the list as other formats are rendered from the mp4 timelapse.
+func (camObj *Camera) GetOutputFormats() []string {
+    formats := []string{VIDEO_FORMAT_MP4}
+    for _, format := range strings.Split(camObj.OutputFormats, ",") {
+        format = strings.TrimSpace(format)
+        if len(format) == 0 || format == VIDEO_FORMAT_MP4 {
+            continue
+        }
+        formats = append(formats, format)
+    }
+    return formats
+}
+
+func (camObj *Camera) IsOutputFormatsValid() (bool) {
+    if len(strings.TrimSpace(camObj.OutputFormats)) == 0 {
+        return false
+    }
+    formats := make(map[string]bool)
+    for _, format := range strings.Split(camObj.OutputFormats, ",") {
+        format = strings.TrimSpace(format)
+        if !IsVideoFormatValid(format) || formats[format] {
+            //Unknown or duplicate format in the list.
+            return false
+        }
+        formats[format] = true
+    }
+    return true
+}
+
+func (camObj *Camera) IsKeepSnapshotDaysValid() (bool) {
+    return camObj.KeepSnapshotDays <= CAMERA_MAX_KEEP_SNAPSHOT_DAYS
+}
+
+//Return true if any text is burned into the timelapse frames.
+func (camObj *Camera) IsOverlayEnabled() (bool) {
+    return camObj.OverlayTimestamp || camObj.OverlayCamName ||
+            len(camObj.OverlayCaption) != 0
+}
+
+//Caption must be a single line of printable text.
+func (camObj *Camera) IsOverlayCaptionValid() (bool) {
+    if len(camObj.OverlayCaption) > CAMERA_MAX_OVERLAY_CAPTION_LEN {
+        return false
+    }
+    for _, c := range camObj.OverlayCaption {
+        if c < ' ' || c == 0x7f {
+            return false
+        }
+    }
+    return true
+}
+
+func (camObj *Camera) IsOverlayPositionValid() (bool) {
+    switch camObj.OverlayPosition {
+    case OVERLAY_TOP_LEFT, OVERLAY_TOP_RIGHT, OVERLAY_BOTTOM_LEFT,
+        OVERLAY_BOTTOM_RIGHT:
+        return true
+    }
+    return false
+}
+
+func (camObj *Camera) IsOverlayFontSizeValid() (bool) {
+    return camObj.OverlayFontSize >= CAMERA_MIN_OVERLAY_FONT_SIZE &&
+            camObj.OverlayFontSize <= CAMERA_MAX_OVERLAY_FONT_SIZE
+}
+
+func (camObj *Camera) IsOverlayTimezoneValid() (bool) {
+    _, err := time.LoadLocation(camObj.OverlayTimezone)
+    return err == nil
+}
+
+//Return the privacy masks of the camera, nil when there are no masks.
+func (camObj *Camera) GetPrivacyMasks() ([]PrivacyMask, error) {
+    if len(camObj.PrivacyMasks) == 0 {
+        return nil, nil
+    }
+    var masks []PrivacyMask
+    err := json.Unmarshal([]byte(camObj.PrivacyMasks), &masks)
+    if err != nil {
+        return nil, err
+    }
+    return masks, nil
+}
+
+func (camObj *Camera) IsPrivacyMasksValid() (bool) {
+    masks, err := camObj.GetPrivacyMasks()
+    if err != nil || len(masks) > CAMERA_MAX_PRIVACY_MASKS {
+        return false
+    }
+    for i := range masks {
+        if !masks[i].IsValid() {
+            return false
+        }
+    }
+    return true
+}
+
+func (camObj *Camera) IsCaptureModeValid() (bool) {
+    return camObj.CaptureMode == CAMERA_CAPTURE_INTERVAL ||
+            camObj.CaptureMode == CAMERA_CAPTURE_ADAPTIVE
+}
+
+func (camObj *Camera) IsChangeThresholdValid() (bool) {
+    return camObj.ChangeThreshold >= CAMERA_MIN_CHANGE_THRESHOLD &&
+            camObj.ChangeThreshold <= CAMERA_MAX_CHANGE_THRESHOLD
+}
+
+//Videolen and snapshot interval must be set when checking the maximum
+// interval, it cannot be shorter than the interval the scene is checked.
+//'0' is valid and means there is no maximum.
+func (camObj *Camera) IsMaxSnapIntervalValid() (bool) {
+    if camObj.MaxSnapInterval != 0 &&
+        (camObj.MaxSnapInterval < camObj.SnapInterval ||
+        camObj.MaxSnapInterval > camObj.VideoLenSec) {
+        return false
+    }
+    return true
+}
+
+//Luma thresholds are valid only when the maximum mean is above the minimum.
+func (camObj *Camera) IsMeanLumaValid() (bool) {
+    return camObj.MinMeanLuma >= 1 && camObj.MaxMeanLuma <= CAMERA_MAX_LUMA &&
+            camObj.MinMeanLuma < camObj.MaxMeanLuma
+}
+
+func (camObj *Camera) IsMinLumaDeviationValid() (bool) {
+    return camObj.MinLumaDeviation >= 1 &&
+            camObj.MinLumaDeviation <= CAMERA_MAX_LUMA_DEVIATION
+}
+
+func (camObj *Camera) IsQualityRetriesValid() (bool) {
+    return camObj.QualityRetries <= CAMERA_MAX_QUALITY_RETRIES
+}
+
+func (camObj *Camera) IsDeflickerWindowValid() (bool) {
+    return camObj.DeflickerWindow >= CAMERA_MIN_DEFLICKER_WINDOW &&
+            camObj.DeflickerWindow <= CAMERA_MAX_DEFLICKER_WINDOW
+}
+
+func (camObj *Camera) IsTamperThresholdValid() (bool) {
+    return camObj.TamperThreshold >= CAMERA_MIN_TAMPER_THRESHOLD &&
+            camObj.TamperThreshold <= CAMERA_MAX_TAMPER_THRESHOLD
+}
+
+func (camObj *Camera) IsSegmentSecValid() (bool) {
+    return camObj.SegmentSec >= CAMERA_MIN_SEGMENT_SEC &&
+            camObj.SegmentSec <= CAMERA_MAX_SEGMENT_SEC
+}
+
+func (camObj *Camera) IsSegmentRetentionValid() (bool) {
+    return camObj.SegmentRetentionHours >= 1 &&
+            camObj.SegmentRetentionHours <= CAMERA_MAX_SEGMENT_RETENTION_HOURS
+}
+
+func (camObj *Camera) IsClipBufferSecValid() (bool) {
+    return camObj.ClipBufferSec <= CAMERA_MAX_CLIP_BUFFER_SEC
+}
+
+//Return the capture profiles of the camera, nil when there are no profiles.
+func (camObj *Camera) GetProfiles() ([]CaptureProfile, error) {
+    if len(camObj.Profiles) == 0 {
+        return nil, nil
+    }
+    var profiles []CaptureProfile
+    err := json.Unmarshal([]byte(camObj.Profiles), &profiles)
+    if err != nil {
+        return nil, err
+    }
+    return profiles, nil
+}
+
+//Return the camera to capture the timelapse of the profile. The scene checks
+// and the clips of the camera are left out, as the profile snapshots are
+// copied from the shared stream.
+func (camObj *Camera) GetProfileCamera(profile *CaptureProfile) *Camera {
+    profileCam := *camObj
+    profileCam.VideoLenSec = profile.VideoLenSec
+    profileCam.SnapInterval = profile.SnapInterval
+    if len(profile.OutputFormats) != 0 {
+        profileCam.OutputFormats = profile.OutputFormats
+    }
+    profileCam.KeepSnapshotDays = profile.KeepSnapshotDays
+    profileCam.CaptureMode = CAMERA_CAPTURE_INTERVAL
+    profileCam.QualityCheck = false
+    profileCam.TamperDetection = false
+    profileCam.ClipBufferSec = 0
+    profileCam.Profiles = ""
+    return &profileCam
+}
+
+func (camObj *Camera) IsProfilesValid() (bool) {
+    profiles, err := camObj.GetProfiles()
+    if err != nil || len(profiles) > CAMERA_MAX_PROFILES {
+        return false
+    }
+    names := make(map[string]bool)
+    for i := range profiles {
+        if !profiles[i].IsNameValid() || names[profiles[i].Name] {
+            return false
+        }
+        names[profiles[i].Name] = true
+        profileCam := camObj.GetProfileCamera(&profiles[i])
+        if !profileCam.IsVideoLenValid() ||
+            !profileCam.IsSnapshotLenValid() ||
+            !profileCam.IsSnapshotSecValid() ||
+            !profileCam.IsOutputLenValid() ||
+            !profileCam.IsOutputFormatsValid() ||
+            !profileCam.IsKeepSnapshotDaysValid() {
+            return false
+        }
+    }
+    return true
+}
+
+//Return true if the camera keeps a single stream open for the recorded
+// segments, the clips or the capture profiles.
+func (camObj *Camera) IsStreamShared() (bool) {
+    return camObj.Recording || camObj.ClipBufferSec != 0 ||
+            len(camObj.Profiles) != 0
+}
+
+//Segments, clips and the snapshots of the shared stream are copied packets,
+// the masked regions cannot be hidden in them.
+func (camObj *Camera) IsRecordingValid() (bool) {
+    if !camObj.IsStreamShared() {
+        return true
+    }
+    masks, err := camObj.GetPrivacyMasks()
+    return err == nil && len(masks) == 0
+}
+
+func (camObj *Camera) IsTimezoneValid() (bool) {
+    _, err := time.LoadLocation(camObj.Timezone)
+    return err == nil
+}
+
+//Return the timezone of the camera, the local time of the system when not
+// set or invalid.
+func (camObj *Camera) GetLocation() *time.Location {
+    if len(camObj.Timezone) == 0 {
+        return time.Local
+    }
+    location, err := time.LoadLocation(camObj.Timezone)
+    if err != nil {
+        return time.Local
+    }
+    return location
+}
+
+func (camObj *Camera) IsCycleAlignValid() (bool) {
+    return camObj.CycleAlign == CAMERA_CYCLE_ALIGN_NONE ||
+            camObj.CycleAlign == CAMERA_CYCLE_ALIGN_HOUR ||
+            camObj.CycleAlign == CAMERA_CYCLE_ALIGN_DAY ||
+            camObj.CycleAlign == CAMERA_CYCLE_ALIGN_WEEK
+}
+
+func (camObj *Camera) IsResumePolicyValid() (bool) {
+    return camObj.ResumePolicy == CAMERA_RESUME_CONTINUE ||
+            camObj.ResumePolicy == CAMERA_RESUME_RENDER
+}
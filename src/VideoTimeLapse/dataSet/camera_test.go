@@ -0,0 +1,502 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataSet
+
+// Test file for validating the camera settings.
+import (
+    "fmt"
+    "reflect"
+    "strings"
+    "time"
+    "testing"
+)
+
+//Camera setting check and the expected result of the check.
+type cameraCheckTest struct {
+    name string
+    cam Camera
+    valid bool
+}
+
+//Run the check on the camera of every test and compare the result.
+func runCameraCheckTests(t *testing.T, tests []cameraCheckTest,
+                         check func(*Camera) bool) {
+    for _, test := range tests {
+        if valid := check(&test.cam); valid != test.valid {
+            t.Errorf("%s: got valid %t, expected %t", test.name, valid,
+                     test.valid)
+        }
+    }
+}
+
+func TestIsSnapshotPktsValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"zero", Camera{SnapshotPkts: 0}, false},
+        {"one", Camera{SnapshotPkts: 1}, true},
+        {"maximum", Camera{SnapshotPkts: CAMERA_MAX_SNAPSHOT_PKTS}, true},
+        {"above maximum",
+            Camera{SnapshotPkts: CAMERA_MAX_SNAPSHOT_PKTS + 1}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsSnapshotPktsValid)
+}
+
+func TestIsSnapshotSecValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"unset", Camera{SnapInterval: 60, SnapshotSec: 0}, true},
+        {"shorter than interval",
+            Camera{SnapInterval: 60, SnapshotSec: 59}, true},
+        {"same as interval",
+            Camera{SnapInterval: 60, SnapshotSec: 60}, false},
+        {"longer than interval",
+            Camera{SnapInterval: 60, SnapshotSec: 61}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsSnapshotSecValid)
+}
+
+func TestIsSpeedFactorValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"zero", Camera{SpeedFactor: 0}, false},
+        {"one", Camera{SpeedFactor: 1}, true},
+        {"maximum", Camera{SpeedFactor: CAMERA_MAX_SPEED_FACTOR}, true},
+        {"above maximum",
+            Camera{SpeedFactor: CAMERA_MAX_SPEED_FACTOR + 1}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsSpeedFactorValid)
+}
+
+func TestIsOutputLenValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"unset", Camera{VideoLenSec: 3600, OutputLenSec: 0}, true},
+        {"minimum", Camera{VideoLenSec: 3600,
+                           OutputLenSec: CAMERA_MIN_OUTPUT_LEN_SEC}, true},
+        {"shorter than video",
+            Camera{VideoLenSec: 3600, OutputLenSec: 3599}, true},
+        {"same as video",
+            Camera{VideoLenSec: 3600, OutputLenSec: 3600}, false},
+        {"longer than video",
+            Camera{VideoLenSec: 3600, OutputLenSec: 7200}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsOutputLenValid)
+}
+
+func TestGetOutputFormats(t *testing.T) {
+    tests := []struct {
+        formats string
+        expected []string
+    }{
+        {"", []string{VIDEO_FORMAT_MP4}},
+        {"mp4", []string{VIDEO_FORMAT_MP4}},
+        {"gif", []string{VIDEO_FORMAT_MP4, VIDEO_FORMAT_GIF}},
+        {"webm, mp4,gif",
+            []string{VIDEO_FORMAT_MP4, VIDEO_FORMAT_WEBM, VIDEO_FORMAT_GIF}},
+    }
+    for _, test := range tests {
+        cam := Camera{OutputFormats: test.formats}
+        formats := cam.GetOutputFormats()
+        if !reflect.DeepEqual(formats, test.expected) {
+            t.Errorf("Formats of %q: got %v, expected %v", test.formats,
+                     formats, test.expected)
+        }
+    }
+}
+
+func TestIsOutputFormatsValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"empty", Camera{OutputFormats: ""}, false},
+        {"blank", Camera{OutputFormats: " "}, false},
+        {"mp4", Camera{OutputFormats: "mp4"}, true},
+        {"all formats", Camera{OutputFormats: "mp4, gif,webm"}, true},
+        {"unknown format", Camera{OutputFormats: "mp4,avi"}, false},
+        {"duplicate format", Camera{OutputFormats: "gif,mp4,gif"}, false},
+        {"empty entry", Camera{OutputFormats: "mp4,,gif"}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsOutputFormatsValid)
+}
+
+func TestIsResumePolicyValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"resume", Camera{ResumePolicy: CAMERA_RESUME_CONTINUE}, true},
+        {"render", Camera{ResumePolicy: CAMERA_RESUME_RENDER}, true},
+        {"empty", Camera{ResumePolicy: ""}, false},
+        {"unknown", Camera{ResumePolicy: "restart"}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsResumePolicyValid)
+}
+
+func TestIsKeepSnapshotDaysValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"delete after stitching", Camera{KeepSnapshotDays: 0}, true},
+        {"maximum",
+            Camera{KeepSnapshotDays: CAMERA_MAX_KEEP_SNAPSHOT_DAYS}, true},
+        {"above maximum",
+            Camera{KeepSnapshotDays: CAMERA_MAX_KEEP_SNAPSHOT_DAYS + 1},
+            false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsKeepSnapshotDaysValid)
+}
+
+func TestIsOverlayCaptionValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"empty", Camera{OverlayCaption: ""}, true},
+        {"text", Camera{OverlayCaption: "Site 4 - North gate"}, true},
+        {"unicode", Camera{OverlayCaption: "Baustelle Süd"}, true},
+        {"new line", Camera{OverlayCaption: "line1\nline2"}, false},
+        {"tab", Camera{OverlayCaption: "a\tb"}, false},
+        {"delete", Camera{OverlayCaption: "a\x7fb"}, false},
+        {"maximum length", Camera{OverlayCaption: strings.Repeat("a",
+                                    CAMERA_MAX_OVERLAY_CAPTION_LEN)}, true},
+        {"too long", Camera{OverlayCaption: strings.Repeat("a",
+                                CAMERA_MAX_OVERLAY_CAPTION_LEN + 1)}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsOverlayCaptionValid)
+}
+
+func TestIsOverlayPositionValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"top left", Camera{OverlayPosition: OVERLAY_TOP_LEFT}, true},
+        {"top right", Camera{OverlayPosition: OVERLAY_TOP_RIGHT}, true},
+        {"bottom left", Camera{OverlayPosition: OVERLAY_BOTTOM_LEFT}, true},
+        {"bottom right", Camera{OverlayPosition: OVERLAY_BOTTOM_RIGHT}, true},
+        {"empty", Camera{OverlayPosition: ""}, false},
+        {"center", Camera{OverlayPosition: "center"}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsOverlayPositionValid)
+}
+
+func TestIsOverlayFontSizeValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"below minimum",
+            Camera{OverlayFontSize: CAMERA_MIN_OVERLAY_FONT_SIZE - 1}, false},
+        {"minimum", Camera{OverlayFontSize: CAMERA_MIN_OVERLAY_FONT_SIZE},
+            true},
+        {"maximum", Camera{OverlayFontSize: CAMERA_MAX_OVERLAY_FONT_SIZE},
+            true},
+        {"above maximum",
+            Camera{OverlayFontSize: CAMERA_MAX_OVERLAY_FONT_SIZE + 1}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsOverlayFontSizeValid)
+}
+
+func TestIsOverlayTimezoneValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"camera timezone", Camera{OverlayTimezone: ""}, true},
+        {"UTC", Camera{OverlayTimezone: "UTC"}, true},
+        {"IANA name", Camera{OverlayTimezone: "Europe/London"}, true},
+        {"unknown", Camera{OverlayTimezone: "Mars/Olympus"}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsOverlayTimezoneValid)
+}
+
+func TestIsPrivacyMasksValid(t *testing.T) {
+    square := `{"Points":[{"X":0,"Y":0},{"X":0.5,"Y":0},{"X":0.5,"Y":0.5},` +
+              `{"X":0,"Y":0.5}]}`
+    tests := []cameraCheckTest{
+        {"no masks", Camera{PrivacyMasks: ""}, true},
+        {"black", Camera{PrivacyMasks: "[" + square + "]"}, true},
+        {"pixelate", Camera{PrivacyMasks: `[{"Mode":"pixelate",` +
+            `"Points":[{"X":0,"Y":0},{"X":1,"Y":0},{"X":1,"Y":1}]}]`}, true},
+        {"unknown mode", Camera{PrivacyMasks: `[{"Mode":"blur",` +
+            `"Points":[{"X":0,"Y":0},{"X":1,"Y":0},{"X":1,"Y":1}]}]`}, false},
+        {"two points", Camera{PrivacyMasks: `[{"Points":[{"X":0,"Y":0},` +
+            `{"X":1,"Y":1}]}]`}, false},
+        {"point outside frame", Camera{PrivacyMasks: `[{"Points":[` +
+            `{"X":0,"Y":0},{"X":1.5,"Y":0},{"X":1,"Y":1}]}]`}, false},
+        {"invalid json", Camera{PrivacyMasks: "[" + square}, false},
+        {"too many masks", Camera{PrivacyMasks: "[" + strings.Repeat(
+            square + ",", CAMERA_MAX_PRIVACY_MASKS) + square + "]"}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsPrivacyMasksValid)
+}
+
+func TestIsCaptureModeValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"interval", Camera{CaptureMode: CAMERA_CAPTURE_INTERVAL}, true},
+        {"adaptive", Camera{CaptureMode: CAMERA_CAPTURE_ADAPTIVE}, true},
+        {"empty", Camera{CaptureMode: ""}, false},
+        {"unknown", Camera{CaptureMode: "motion"}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsCaptureModeValid)
+}
+
+func TestIsChangeThresholdValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"zero", Camera{ChangeThreshold: 0}, false},
+        {"minimum", Camera{ChangeThreshold: CAMERA_MIN_CHANGE_THRESHOLD},
+            true},
+        {"maximum", Camera{ChangeThreshold: CAMERA_MAX_CHANGE_THRESHOLD},
+            true},
+        {"above maximum",
+            Camera{ChangeThreshold: CAMERA_MAX_CHANGE_THRESHOLD + 1}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsChangeThresholdValid)
+}
+
+func TestIsMaxSnapIntervalValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"no maximum", Camera{VideoLenSec: 3600, SnapInterval: 60,
+                              MaxSnapInterval: 0}, true},
+        {"same as interval", Camera{VideoLenSec: 3600, SnapInterval: 60,
+                                    MaxSnapInterval: 60}, true},
+        {"shorter than interval", Camera{VideoLenSec: 3600, SnapInterval: 60,
+                                         MaxSnapInterval: 59}, false},
+        {"same as video", Camera{VideoLenSec: 3600, SnapInterval: 60,
+                                 MaxSnapInterval: 3600}, true},
+        {"longer than video", Camera{VideoLenSec: 3600, SnapInterval: 60,
+                                     MaxSnapInterval: 3601}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsMaxSnapIntervalValid)
+}
+
+func TestIsMeanLumaValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"defaults", Camera{MinMeanLuma: CAMERA_DEFAULT_MIN_MEAN_LUMA,
+                            MaxMeanLuma: CAMERA_DEFAULT_MAX_MEAN_LUMA}, true},
+        {"full range", Camera{MinMeanLuma: 1, MaxMeanLuma: CAMERA_MAX_LUMA},
+            true},
+        {"zero minimum", Camera{MinMeanLuma: 0, MaxMeanLuma: 240}, false},
+        {"maximum above range",
+            Camera{MinMeanLuma: 16, MaxMeanLuma: CAMERA_MAX_LUMA + 1}, false},
+        {"same minimum and maximum",
+            Camera{MinMeanLuma: 100, MaxMeanLuma: 100}, false},
+        {"minimum above maximum",
+            Camera{MinMeanLuma: 200, MaxMeanLuma: 100}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsMeanLumaValid)
+}
+
+func TestIsMinLumaDeviationValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"zero", Camera{MinLumaDeviation: 0}, false},
+        {"one", Camera{MinLumaDeviation: 1}, true},
+        {"maximum", Camera{MinLumaDeviation: CAMERA_MAX_LUMA_DEVIATION},
+            true},
+        {"above maximum",
+            Camera{MinLumaDeviation: CAMERA_MAX_LUMA_DEVIATION + 1}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsMinLumaDeviationValid)
+}
+
+func TestIsQualityRetriesValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"no retries", Camera{QualityRetries: 0}, true},
+        {"maximum", Camera{QualityRetries: CAMERA_MAX_QUALITY_RETRIES}, true},
+        {"above maximum",
+            Camera{QualityRetries: CAMERA_MAX_QUALITY_RETRIES + 1}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsQualityRetriesValid)
+}
+
+func TestIsDeflickerWindowValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"below minimum",
+            Camera{DeflickerWindow: CAMERA_MIN_DEFLICKER_WINDOW - 1}, false},
+        {"minimum", Camera{DeflickerWindow: CAMERA_MIN_DEFLICKER_WINDOW},
+            true},
+        {"maximum", Camera{DeflickerWindow: CAMERA_MAX_DEFLICKER_WINDOW},
+            true},
+        {"above maximum",
+            Camera{DeflickerWindow: CAMERA_MAX_DEFLICKER_WINDOW + 1}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsDeflickerWindowValid)
+}
+
+func TestIsTamperThresholdValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"zero", Camera{TamperThreshold: 0}, false},
+        {"minimum", Camera{TamperThreshold: CAMERA_MIN_TAMPER_THRESHOLD},
+            true},
+        {"maximum", Camera{TamperThreshold: CAMERA_MAX_TAMPER_THRESHOLD},
+            true},
+        {"above maximum",
+            Camera{TamperThreshold: CAMERA_MAX_TAMPER_THRESHOLD + 1}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsTamperThresholdValid)
+}
+
+func TestIsSegmentSecValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"below minimum", Camera{SegmentSec: CAMERA_MIN_SEGMENT_SEC - 1},
+            false},
+        {"minimum", Camera{SegmentSec: CAMERA_MIN_SEGMENT_SEC}, true},
+        {"maximum", Camera{SegmentSec: CAMERA_MAX_SEGMENT_SEC}, true},
+        {"above maximum", Camera{SegmentSec: CAMERA_MAX_SEGMENT_SEC + 1},
+            false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsSegmentSecValid)
+}
+
+func TestIsSegmentRetentionValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"zero", Camera{SegmentRetentionHours: 0}, false},
+        {"one hour", Camera{SegmentRetentionHours: 1}, true},
+        {"maximum", Camera{
+            SegmentRetentionHours: CAMERA_MAX_SEGMENT_RETENTION_HOURS}, true},
+        {"above maximum", Camera{
+            SegmentRetentionHours: CAMERA_MAX_SEGMENT_RETENTION_HOURS + 1},
+            false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsSegmentRetentionValid)
+}
+
+func TestIsClipBufferSecValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"disabled", Camera{ClipBufferSec: 0}, true},
+        {"maximum", Camera{ClipBufferSec: CAMERA_MAX_CLIP_BUFFER_SEC}, true},
+        {"above maximum",
+            Camera{ClipBufferSec: CAMERA_MAX_CLIP_BUFFER_SEC + 1}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsClipBufferSecValid)
+}
+
+func TestGetProfileCamera(t *testing.T) {
+    cam := Camera{
+        Name: "cam1",
+        VideoLenSec: 3600,
+        SnapInterval: 60,
+        SnapshotPkts: 48,
+        OutputFormats: "mp4,gif",
+        KeepSnapshotDays: 7,
+        CaptureMode: CAMERA_CAPTURE_ADAPTIVE,
+        QualityCheck: true,
+        TamperDetection: true,
+        ClipBufferSec: 10,
+        Profiles: `[{"Name":"daily"}]`,
+    }
+    profile := CaptureProfile{
+        Name: "daily",
+        VideoLenSec: 86400,
+        SnapInterval: 600,
+        KeepSnapshotDays: 30,
+    }
+    profileCam := cam.GetProfileCamera(&profile)
+    if profileCam.Name != cam.Name ||
+        profileCam.SnapshotPkts != cam.SnapshotPkts ||
+        profileCam.VideoLenSec != profile.VideoLenSec ||
+        profileCam.SnapInterval != profile.SnapInterval ||
+        profileCam.KeepSnapshotDays != profile.KeepSnapshotDays {
+        t.Errorf("Profile camera settings not taken from the profile: %+v",
+                 profileCam)
+    }
+    if profileCam.OutputFormats != cam.OutputFormats {
+        t.Errorf("Got formats %s, expected the camera formats %s",
+                 profileCam.OutputFormats, cam.OutputFormats)
+    }
+    if profileCam.CaptureMode != CAMERA_CAPTURE_INTERVAL ||
+        profileCam.QualityCheck || profileCam.TamperDetection ||
+        profileCam.ClipBufferSec != 0 || len(profileCam.Profiles) != 0 {
+        t.Errorf("Camera only settings kept in profile camera: %+v",
+                 profileCam)
+    }
+    profile.OutputFormats = "webm"
+    profileCam = cam.GetProfileCamera(&profile)
+    if profileCam.OutputFormats != "webm" {
+        t.Errorf("Got formats %s, expected the profile formats webm",
+                 profileCam.OutputFormats)
+    }
+    if cam.VideoLenSec != 3600 || cam.CaptureMode != CAMERA_CAPTURE_ADAPTIVE {
+        t.Errorf("Camera is changed by its profile camera")
+    }
+}
+
+func TestIsProfilesValid(t *testing.T) {
+    camWith := func(profiles string) Camera {
+        return Camera{VideoLenSec: 3600, SnapInterval: 60,
+                      OutputFormats: "mp4", Profiles: profiles}
+    }
+    daily := `{"Name":"daily","VideoLenSec":86400,"VideoSnapInterval":600}`
+    tooMany := []string{}
+    for i := 0; i <= CAMERA_MAX_PROFILES; i++ {
+        tooMany = append(tooMany, fmt.Sprintf(`{"Name":"p%d",` +
+                            `"VideoLenSec":86400,"VideoSnapInterval":600}`, i))
+    }
+    tests := []cameraCheckTest{
+        {"no profiles", camWith(""), true},
+        {"one profile", camWith("[" + daily + "]"), true},
+        {"profile formats", camWith(`[{"Name":"web","VideoLenSec":7200,` +
+            `"VideoSnapInterval":60,"OutputFormats":"mp4,webm"}]`), true},
+        {"invalid json", camWith("[" + daily), false},
+        {"empty name", camWith(`[{"Name":"","VideoLenSec":86400,` +
+            `"VideoSnapInterval":600}]`), false},
+        {"name with separator", camWith(`[{"Name":"a@b",` +
+            `"VideoLenSec":86400,"VideoSnapInterval":600}]`), false},
+        {"duplicate name", camWith("[" + daily + "," + daily + "]"), false},
+        {"short video", camWith(`[{"Name":"short","VideoLenSec":60,` +
+            `"VideoSnapInterval":10}]`), false},
+        {"interval longer than video", camWith(`[{"Name":"slow",` +
+            `"VideoLenSec":3600,"VideoSnapInterval":3600}]`), false},
+        {"unknown format", camWith(`[{"Name":"web","VideoLenSec":7200,` +
+            `"VideoSnapInterval":60,"OutputFormats":"avi"}]`), false},
+        {"too many profiles",
+            camWith("[" + strings.Join(tooMany, ",") + "]"), false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsProfilesValid)
+}
+
+func TestIsRecordingValid(t *testing.T) {
+    mask := `[{"Points":[{"X":0,"Y":0},{"X":1,"Y":0},{"X":1,"Y":1}]}]`
+    tests := []cameraCheckTest{
+        {"no shared stream", Camera{PrivacyMasks: mask}, true},
+        {"recording", Camera{Recording: true}, true},
+        {"recording masked", Camera{Recording: true, PrivacyMasks: mask},
+            false},
+        {"clips masked", Camera{ClipBufferSec: 10, PrivacyMasks: mask},
+            false},
+        {"profiles masked", Camera{Profiles: `[{"Name":"daily"}]`,
+                                   PrivacyMasks: mask}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsRecordingValid)
+}
+
+func TestIsTimezoneValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"system time", Camera{Timezone: ""}, true},
+        {"UTC", Camera{Timezone: "UTC"}, true},
+        {"IANA name", Camera{Timezone: "Asia/Kolkata"}, true},
+        {"unknown", Camera{Timezone: "Mars/Olympus"}, false},
+        {"offset", Camera{Timezone: "+05:30"}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsTimezoneValid)
+}
+
+func TestIsCycleAlignValid(t *testing.T) {
+    tests := []cameraCheckTest{
+        {"none", Camera{CycleAlign: CAMERA_CYCLE_ALIGN_NONE}, true},
+        {"hour", Camera{CycleAlign: CAMERA_CYCLE_ALIGN_HOUR}, true},
+        {"day", Camera{CycleAlign: CAMERA_CYCLE_ALIGN_DAY}, true},
+        {"week", Camera{CycleAlign: CAMERA_CYCLE_ALIGN_WEEK}, true},
+        {"empty", Camera{CycleAlign: ""}, false},
+        {"month", Camera{CycleAlign: "month"}, false},
+    }
+    runCameraCheckTests(t, tests, (*Camera).IsCycleAlignValid)
+}
+
+func TestGetLocation(t *testing.T) {
+    tests := []struct {
+        timezone string
+        expected string
+    }{
+        {"", time.Local.String()},
+        {"UTC", "UTC"},
+        {"Europe/London", "Europe/London"},
+        {"Mars/Olympus", time.Local.String()},
+    }
+    for _, test := range tests {
+        cam := Camera{Timezone: test.timezone}
+        if location := cam.GetLocation(); location.String() != test.expected {
+            t.Errorf("Location of %q: got %s, expected %s", test.timezone,
+                     location, test.expected)
+        }
+    }
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package dataSet
+
+import (
+    "regexp"
+    "encoding/json"
+)
+
+const (
+    CAMERA_MAX_PROFILES = 8
+)
+
+//Profiles are named in the video names and directories of their timelapses.
+var profileNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+//Named capture profile of a camera, a timelapse of its own next to the one
+// of the camera. The profiles share the stream of the camera, so the
+// snapshot clip and the rest of the capture settings are of the camera.
+type CaptureProfile struct {
+    Name             string `json:"Name"`
+    VideoLenSec      uint64 `json:"VideoLenSec"`
+    SnapInterval     uint64 `json:"VideoSnapInterval"`
+    //Formats the timelapse is rendered to, empty for the formats of the
+    // camera.
+    OutputFormats    string `json:"OutputFormats"`
+    //Number of days the snapshots are kept after rendering the timelapse.
+    KeepSnapshotDays uint64 `json:"KeepSnapshotDays"`
+}
+
+func (profile *CaptureProfile) IsNameValid() bool {
+    return profileNameRegex.MatchString(profile.Name)
+}
+
+//Return the profiles in the form stored in the camera, empty for no
+// profiles.
+func EncodeCaptureProfiles(profiles []CaptureProfile) (string, error) {
+    if len(profiles) == 0 {
+        return "", nil
+    }
+    data, err := json.Marshal(profiles)
+    if err != nil {
+        return "", err
+    }
+    return string(data), nil
+}
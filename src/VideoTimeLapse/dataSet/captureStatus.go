@@ -0,0 +1,47 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataSet
+
+//Reason a snapshot is rejected by the quality check.
+const (
+    SNAPSHOT_REJECT_UNDECODABLE = "undecodable"
+    SNAPSHOT_REJECT_UNIFORM = "uniform"
+    SNAPSHOT_REJECT_DARK = "dark"
+    SNAPSHOT_REJECT_BRIGHT = "bright"
+)
+
+//Reason a camera is degraded, as the scene is not same as its reference.
+const (
+    CAMERA_TAMPER_MOVED = "moved"
+    CAMERA_TAMPER_COVERED = "covered"
+    CAMERA_TAMPER_DEFOCUSED = "defocused"
+)
+
+//Health of the snapshot capture of a camera. There is only one entry for a
+// camera, updated by the camera thread.
+type CameraCaptureStatus struct {
+    CamName           string `json:"CamName"`
+    //Snapshots rejected by the quality check, including the retries.
+    RejectedSnapshots uint64 `json:"RejectedSnapshots"`
+    LastRejectReason  string `json:"LastRejectReason"`
+    //Unix time of the last rejected snapshot.
+    LastRejectTime    int64  `json:"LastRejectTime"`
+    //Set when the camera is detected as tampered, until its scene is back to
+    // the reference or a new reference is learnt.
+    Degraded          bool   `json:"Degraded"`
+    DegradedReason    string `json:"DegradedReason"`
+    //Unix time the camera is degraded.
+    DegradedTime      int64  `json:"DegradedTime"`
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package dataSet
+
+import (
+    "regexp"
+    "strings"
+)
+
+//Layouts of the composite video tiles.
+const (
+    //Two cameras side by side.
+    COMPOSITE_LAYOUT_2X1 = "2x1"
+    //Grid of up to four cameras, empty tiles are black.
+    COMPOSITE_LAYOUT_2X2 = "2x2"
+    //Second camera inset in the bottom right corner of the first.
+    COMPOSITE_LAYOUT_PIP = "pip"
+)
+
+const (
+    COMPOSITE_DEFAULT_WIDTH = 1920
+    COMPOSITE_DEFAULT_HEIGHT = 1080
+    COMPOSITE_MIN_RESOLUTION = 64
+    COMPOSITE_MAX_RESOLUTION = 8192
+)
+
+//Composite names are used in the directory and video names.
+var compositeNameRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+//Definition of a composite timelapse of several cameras. The timelapses of
+// the member cameras are time-aligned and rendered in the tiles of the layout
+// when they have overlapping cycles.
+type Composite struct {
+    Name    string `json:"Name"`
+    Desc    string `json:"Desc"`
+    //Comma separated names of the member cameras, in the order of the tiles.
+    Cameras string `json:"Cameras"`
+    Layout  string `json:"Layout"`
+    //Resolution of the composite video.
+    Width   uint64 `json:"Width"`
+    Height  uint64 `json:"Height"`
+}
+
+func IsCompositeLayoutValid(layout string) bool {
+    switch layout {
+    case COMPOSITE_LAYOUT_2X1, COMPOSITE_LAYOUT_2X2, COMPOSITE_LAYOUT_PIP:
+        return true
+    }
+    return false
+}
+
+//Return the minimum and maximum number of cameras in the layout.
+func GetCompositeLayoutCameras(layout string) (int, int) {
+    if layout == COMPOSITE_LAYOUT_2X2 {
+        return 2, 4
+    }
+    return 2, 2
+}
+
+//Return the member camera names of the composite.
+func (composite *Composite) GetCameras() []string {
+    cameras := []string{}
+    for _, camName := range strings.Split(composite.Cameras, ",") {
+        camName = strings.TrimSpace(camName)
+        if len(camName) != 0 {
+            cameras = append(cameras, camName)
+        }
+    }
+    return cameras
+}
+
+//Return true if the camera is a member of the composite.
+func (composite *Composite) HasCamera(camName string) bool {
+    for _, member := range composite.GetCameras() {
+        if member == camName {
+            return true
+        }
+    }
+    return false
+}
+
+func (composite *Composite) IsNameValid() bool {
+    return compositeNameRegex.MatchString(composite.Name)
+}
+
+//A camera can be in the composite only once.
+func (composite *Composite) IsCamerasValid() bool {
+    cameras := composite.GetCameras()
+    minCameras, maxCameras := GetCompositeLayoutCameras(composite.Layout)
+    if len(cameras) < minCameras || len(cameras) > maxCameras {
+        return false
+    }
+    members := make(map[string]bool)
+    for _, camName := range cameras {
+        if members[camName] {
+            return false
+        }
+        members[camName] = true
+    }
+    return true
+}
+
+//Width and height must be even for encoding.
+func (composite *Composite) IsResolutionValid() bool {
+    return composite.Width >= COMPOSITE_MIN_RESOLUTION &&
+            composite.Width <= COMPOSITE_MAX_RESOLUTION &&
+            composite.Width % 2 == 0 &&
+            composite.Height >= COMPOSITE_MIN_RESOLUTION &&
+            composite.Height <= COMPOSITE_MAX_RESOLUTION &&
+            composite.Height % 2 == 0
+}
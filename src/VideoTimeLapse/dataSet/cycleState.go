@@ -0,0 +1,32 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataSet
+
+//State of the running timelapse cycle of a camera. The state is updated on
+// every snapshot, so the cycle can be resumed after an application restart.
+type CameraCycleState struct {
+    CamName      string  `json:"CamName"`
+    //Capture profile of the cycle, empty for the cycle of the camera.
+    Profile      string  `json:"Profile"`
+    //Directory of the cycle snapshots, named after the cycle start time.
+    CycleDir     string  `json:"CycleDir"`
+    //Unix time the cycle started.
+    CycleStart   int64   `json:"CycleStart"`
+    //Snapshot intervals elapsed in the cycle, including the intervals with
+    // no snapshot kept in adaptive capture.
+    NumSnapshots uint64  `json:"NumSnapshots"`
+    //Index of the last snapshot file in the cycle.
+    FileIndex    uint64  `json:"FileIndex"`
+}
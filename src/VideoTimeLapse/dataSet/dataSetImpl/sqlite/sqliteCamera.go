@@ -3,6 +3,7 @@ package sqlite
 import (
     "fmt"
     "strconv"
+    "strings"
     "github.com/jmoiron/sqlx"
     "VideoTimeLapse/dataSet"
     "VideoTimeLapse/logging"
@@ -27,8 +28,107 @@ const (
     CAMERA_FIELD_PWD = "pwd"
     CAMERA_FIELD_VIDEOLEN = "videolensec"
     CAMERA_FIELD_VIDEOSNAPLEN = "snapinterval"
+    CAMERA_FIELD_SNAPSHOTPKTS = "snapshotpkts"
+    CAMERA_FIELD_SNAPSHOTSEC = "snapshotsec"
+    CAMERA_FIELD_SPEEDFACTOR = "speedfactor"
+    CAMERA_FIELD_OUTPUTLEN = "outputlensec"
+    CAMERA_FIELD_OUTPUTFORMATS = "outputformats"
+    CAMERA_FIELD_ENABLEHLS = "enablehls"
+    CAMERA_FIELD_RESUMEPOLICY = "resumepolicy"
+    CAMERA_FIELD_KEEPSNAPSHOTDAYS = "keepsnapshotdays"
+    CAMERA_FIELD_OVERLAYTIMESTAMP = "overlaytimestamp"
+    CAMERA_FIELD_OVERLAYCAMNAME = "overlaycamname"
+    CAMERA_FIELD_OVERLAYCAPTION = "overlaycaption"
+    CAMERA_FIELD_OVERLAYPOSITION = "overlayposition"
+    CAMERA_FIELD_OVERLAYFONTSIZE = "overlayfontsize"
+    CAMERA_FIELD_OVERLAYTIMEZONE = "overlaytimezone"
+    CAMERA_FIELD_PRIVACYMASKS = "privacymasks"
+    CAMERA_FIELD_CAPTUREMODE = "capturemode"
+    CAMERA_FIELD_CHANGETHRESHOLD = "changethreshold"
+    CAMERA_FIELD_MAXSNAPINTERVAL = "maxsnapinterval"
+    CAMERA_FIELD_QUALITYCHECK = "qualitycheck"
+    CAMERA_FIELD_MINMEANLUMA = "minmeanluma"
+    CAMERA_FIELD_MAXMEANLUMA = "maxmeanluma"
+    CAMERA_FIELD_MINLUMADEVIATION = "minlumadeviation"
+    CAMERA_FIELD_QUALITYRETRIES = "qualityretries"
+    CAMERA_FIELD_DEFLICKER = "deflicker"
+    CAMERA_FIELD_DEFLICKERWINDOW = "deflickerwindow"
+    CAMERA_FIELD_TAMPERDETECTION = "tamperdetection"
+    CAMERA_FIELD_TAMPERTHRESHOLD = "tamperthreshold"
+    CAMERA_FIELD_RECORDING = "recording"
+    CAMERA_FIELD_SEGMENTSEC = "segmentsec"
+    CAMERA_FIELD_SEGMENTRETENTION = "segmentretentionhours"
+    CAMERA_FIELD_CLIPBUFFERSEC = "clipbuffersec"
+    CAMERA_FIELD_PROFILES = "profiles"
+    CAMERA_FIELD_TIMEZONE = "timezone"
+    CAMERA_FIELD_CYCLEALIGN = "cyclealign"
 )
 
+//Columns added to the camera table after the initial schema. These columns
+// are added on startup when missing, so an existing DB file can be used
+// as is.
+var cameraExtColumns = []sqlColumn{
+    {CAMERA_FIELD_SNAPSHOTPKTS,
+        fmt.Sprintf("INTEGER DEFAULT %d", dataSet.CAMERA_DEFAULT_SNAPSHOT_PKTS)},
+    {CAMERA_FIELD_SNAPSHOTSEC, "INTEGER DEFAULT 0"},
+    {CAMERA_FIELD_SPEEDFACTOR,
+        fmt.Sprintf("INTEGER DEFAULT %d", dataSet.CAMERA_DEFAULT_SPEED_FACTOR)},
+    {CAMERA_FIELD_OUTPUTLEN, "INTEGER DEFAULT 0"},
+    {CAMERA_FIELD_OUTPUTFORMATS,
+        fmt.Sprintf("TEXT DEFAULT '%s'", dataSet.CAMERA_DEFAULT_OUTPUT_FORMATS)},
+    {CAMERA_FIELD_ENABLEHLS, "INTEGER DEFAULT 0"},
+    {CAMERA_FIELD_RESUMEPOLICY,
+        fmt.Sprintf("TEXT DEFAULT '%s'", dataSet.CAMERA_DEFAULT_RESUME_POLICY)},
+    {CAMERA_FIELD_KEEPSNAPSHOTDAYS, "INTEGER DEFAULT 0"},
+    {CAMERA_FIELD_OVERLAYTIMESTAMP, "INTEGER DEFAULT 0"},
+    {CAMERA_FIELD_OVERLAYCAMNAME, "INTEGER DEFAULT 0"},
+    {CAMERA_FIELD_OVERLAYCAPTION, "TEXT DEFAULT ''"},
+    {CAMERA_FIELD_OVERLAYPOSITION,
+        fmt.Sprintf("TEXT DEFAULT '%s'",
+                    dataSet.CAMERA_DEFAULT_OVERLAY_POSITION)},
+    {CAMERA_FIELD_OVERLAYFONTSIZE,
+        fmt.Sprintf("INTEGER DEFAULT %d",
+                    dataSet.CAMERA_DEFAULT_OVERLAY_FONT_SIZE)},
+    {CAMERA_FIELD_OVERLAYTIMEZONE, "TEXT DEFAULT ''"},
+    {CAMERA_FIELD_PRIVACYMASKS, "TEXT DEFAULT ''"},
+    {CAMERA_FIELD_CAPTUREMODE,
+        fmt.Sprintf("TEXT DEFAULT '%s'", dataSet.CAMERA_DEFAULT_CAPTURE_MODE)},
+    {CAMERA_FIELD_CHANGETHRESHOLD,
+        fmt.Sprintf("INTEGER DEFAULT %d",
+                    dataSet.CAMERA_DEFAULT_CHANGE_THRESHOLD)},
+    {CAMERA_FIELD_MAXSNAPINTERVAL, "INTEGER DEFAULT 0"},
+    {CAMERA_FIELD_QUALITYCHECK, "INTEGER DEFAULT 0"},
+    {CAMERA_FIELD_MINMEANLUMA,
+        fmt.Sprintf("INTEGER DEFAULT %d",
+                    dataSet.CAMERA_DEFAULT_MIN_MEAN_LUMA)},
+    {CAMERA_FIELD_MAXMEANLUMA,
+        fmt.Sprintf("INTEGER DEFAULT %d",
+                    dataSet.CAMERA_DEFAULT_MAX_MEAN_LUMA)},
+    {CAMERA_FIELD_MINLUMADEVIATION,
+        fmt.Sprintf("INTEGER DEFAULT %d",
+                    dataSet.CAMERA_DEFAULT_MIN_LUMA_DEVIATION)},
+    {CAMERA_FIELD_QUALITYRETRIES, "INTEGER DEFAULT 0"},
+    {CAMERA_FIELD_DEFLICKER, "INTEGER DEFAULT 0"},
+    {CAMERA_FIELD_DEFLICKERWINDOW,
+        fmt.Sprintf("INTEGER DEFAULT %d",
+                    dataSet.CAMERA_DEFAULT_DEFLICKER_WINDOW)},
+    {CAMERA_FIELD_TAMPERDETECTION, "INTEGER DEFAULT 0"},
+    {CAMERA_FIELD_TAMPERTHRESHOLD,
+        fmt.Sprintf("INTEGER DEFAULT %d",
+                    dataSet.CAMERA_DEFAULT_TAMPER_THRESHOLD)},
+    {CAMERA_FIELD_RECORDING, "INTEGER DEFAULT 0"},
+    {CAMERA_FIELD_SEGMENTSEC,
+        fmt.Sprintf("INTEGER DEFAULT %d",
+                    dataSet.CAMERA_DEFAULT_SEGMENT_SEC)},
+    {CAMERA_FIELD_SEGMENTRETENTION,
+        fmt.Sprintf("INTEGER DEFAULT %d",
+                    dataSet.CAMERA_DEFAULT_SEGMENT_RETENTION_HOURS)},
+    {CAMERA_FIELD_CLIPBUFFERSEC, "INTEGER DEFAULT 0"},
+    {CAMERA_FIELD_PROFILES, "TEXT DEFAULT ''"},
+    {CAMERA_FIELD_TIMEZONE, "TEXT DEFAULT ''"},
+    {CAMERA_FIELD_CYCLEALIGN, "TEXT DEFAULT 'none'"},
+}
+
 var (
     cameraSchema = fmt.Sprintf(
                 `CREATE TABLE IF NOT EXISTS %s (%s TEXT PRIMARY KEY,
@@ -54,8 +154,16 @@ var (
                  CAMERA_FIELD_VIDEOSNAPLEN, dataSet.CAMERA_DEFAULT_SNAPSHOT_INTERVAL)
     //Create a role entry in table roles
     cameraCreate = fmt.Sprintf(`INSERT INTO %s
-                                (%s, %s, %s, %s, %s, %s, %s, %s, %s)
-                                VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+                                (%s, %s, %s, %s, %s, %s, %s, %s, %s,
+                                 %s, %s, %s, %s, %s, %s, %s, %s,
+                                 %s, %s, %s, %s, %s, %s, %s, %s,
+                                 %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s,
+                                 %s, %s, %s, %s, %s, %s)
+                                VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?,
+                                        ?, ?, ?, ?, ?, ?, ?, ?,
+                                        ?, ?, ?, ?, ?, ?, ?, ?,
+                                        ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+                                        ?, ?, ?, ?, ?)`,
                                 CAMERA_TABLE,
                                 CAMERA_FIELD_NAME,
                                 CAMERA_FIELD_IPADDR,
@@ -65,7 +173,41 @@ var (
                                 CAMERA_FIELD_USERID,
                                 CAMERA_FIELD_PWD,
                                 CAMERA_FIELD_VIDEOLEN,
-                                CAMERA_FIELD_VIDEOSNAPLEN)
+                                CAMERA_FIELD_VIDEOSNAPLEN,
+                                CAMERA_FIELD_SNAPSHOTPKTS,
+                                CAMERA_FIELD_SNAPSHOTSEC,
+                                CAMERA_FIELD_SPEEDFACTOR,
+                                CAMERA_FIELD_OUTPUTLEN,
+                                CAMERA_FIELD_OUTPUTFORMATS,
+                                CAMERA_FIELD_ENABLEHLS,
+                                CAMERA_FIELD_RESUMEPOLICY,
+                                CAMERA_FIELD_KEEPSNAPSHOTDAYS,
+                                CAMERA_FIELD_OVERLAYTIMESTAMP,
+                                CAMERA_FIELD_OVERLAYCAMNAME,
+                                CAMERA_FIELD_OVERLAYCAPTION,
+                                CAMERA_FIELD_OVERLAYPOSITION,
+                                CAMERA_FIELD_OVERLAYFONTSIZE,
+                                CAMERA_FIELD_OVERLAYTIMEZONE,
+                                CAMERA_FIELD_PRIVACYMASKS,
+                                CAMERA_FIELD_CAPTUREMODE,
+                                CAMERA_FIELD_CHANGETHRESHOLD,
+                                CAMERA_FIELD_MAXSNAPINTERVAL,
+                                CAMERA_FIELD_QUALITYCHECK,
+                                CAMERA_FIELD_MINMEANLUMA,
+                                CAMERA_FIELD_MAXMEANLUMA,
+                                CAMERA_FIELD_MINLUMADEVIATION,
+                                CAMERA_FIELD_QUALITYRETRIES,
+                                CAMERA_FIELD_DEFLICKER,
+                                CAMERA_FIELD_DEFLICKERWINDOW,
+                                CAMERA_FIELD_TAMPERDETECTION,
+                                CAMERA_FIELD_TAMPERTHRESHOLD,
+                                CAMERA_FIELD_RECORDING,
+                                CAMERA_FIELD_SEGMENTSEC,
+                                CAMERA_FIELD_SEGMENTRETENTION,
+                                CAMERA_FIELD_CLIPBUFFERSEC,
+                                CAMERA_FIELD_PROFILES,
+                                CAMERA_FIELD_TIMEZONE,
+                                CAMERA_FIELD_CYCLEALIGN)
 
     cameraGet = fmt.Sprintf("SELECT * FROM %s WHERE %s=(?)",
                             CAMERA_TABLE,
@@ -77,7 +219,16 @@ var (
                                     CAMERA_FIELD_PORT)
     cameraGetAll = fmt.Sprintf("SELECT * FROM %s", CAMERA_TABLE)
     cameraUpdate = fmt.Sprintf(`UPDATE %s SET %s=(?),%s=(?),%s=(?),
-                                              %s=(?),%s=(?),%s=(?),%s=(?),%s=(?)
+                                              %s=(?),%s=(?),%s=(?),%s=(?),%s=(?),
+                                              %s=(?),%s=(?),%s=(?),%s=(?),
+                                              %s=(?),%s=(?),%s=(?),%s=(?),
+                                              %s=(?),%s=(?),%s=(?),%s=(?),
+                                              %s=(?),%s=(?),%s=(?),%s=(?),
+                                              %s=(?),%s=(?),%s=(?),%s=(?),
+                                              %s=(?),%s=(?),%s=(?),%s=(?),
+                                              %s=(?),%s=(?),%s=(?),%s=(?),
+                                              %s=(?),%s=(?),%s=(?),%s=(?),
+                                              %s=(?),%s=(?)
                                               WHERE %s=(?)`,
                                               CAMERA_TABLE,
                                               CAMERA_FIELD_IPADDR,
@@ -88,6 +239,40 @@ var (
                                               CAMERA_FIELD_PWD,
                                               CAMERA_FIELD_VIDEOLEN,
                                               CAMERA_FIELD_VIDEOSNAPLEN,
+                                              CAMERA_FIELD_SNAPSHOTPKTS,
+                                              CAMERA_FIELD_SNAPSHOTSEC,
+                                              CAMERA_FIELD_SPEEDFACTOR,
+                                              CAMERA_FIELD_OUTPUTLEN,
+                                              CAMERA_FIELD_OUTPUTFORMATS,
+                                              CAMERA_FIELD_ENABLEHLS,
+                                              CAMERA_FIELD_RESUMEPOLICY,
+                                              CAMERA_FIELD_KEEPSNAPSHOTDAYS,
+                                              CAMERA_FIELD_OVERLAYTIMESTAMP,
+                                              CAMERA_FIELD_OVERLAYCAMNAME,
+                                              CAMERA_FIELD_OVERLAYCAPTION,
+                                              CAMERA_FIELD_OVERLAYPOSITION,
+                                              CAMERA_FIELD_OVERLAYFONTSIZE,
+                                              CAMERA_FIELD_OVERLAYTIMEZONE,
+                                              CAMERA_FIELD_PRIVACYMASKS,
+                                              CAMERA_FIELD_CAPTUREMODE,
+                                              CAMERA_FIELD_CHANGETHRESHOLD,
+                                              CAMERA_FIELD_MAXSNAPINTERVAL,
+                                              CAMERA_FIELD_QUALITYCHECK,
+                                              CAMERA_FIELD_MINMEANLUMA,
+                                              CAMERA_FIELD_MAXMEANLUMA,
+                                              CAMERA_FIELD_MINLUMADEVIATION,
+                                              CAMERA_FIELD_QUALITYRETRIES,
+                                              CAMERA_FIELD_DEFLICKER,
+                                              CAMERA_FIELD_DEFLICKERWINDOW,
+                                              CAMERA_FIELD_TAMPERDETECTION,
+                                              CAMERA_FIELD_TAMPERTHRESHOLD,
+                                              CAMERA_FIELD_RECORDING,
+                                              CAMERA_FIELD_SEGMENTSEC,
+                                              CAMERA_FIELD_SEGMENTRETENTION,
+                                              CAMERA_FIELD_CLIPBUFFERSEC,
+                                              CAMERA_FIELD_PROFILES,
+                                              CAMERA_FIELD_TIMEZONE,
+                                              CAMERA_FIELD_CYCLEALIGN,
                                               CAMERA_FIELD_NAME)
     cameraDelete = fmt.Sprintf("DELETE FROM %s WHERE %s=(?)",
                                 CAMERA_TABLE, CAMERA_FIELD_NAME)
@@ -112,6 +297,11 @@ func(camObj *sqlCamera)CreateCameraTable(conn *sqlx.DB) error {
         log.Error("Failed to create Camera table %s", err)
         return err
     }
+    err = addMissingColumns(conn, CAMERA_TABLE, cameraExtColumns)
+    if err != nil {
+        log.Error("Failed to update Camera table %s", err)
+        return err
+    }
     log.Trace("Table %s created successfully", CAMERA_TABLE)
     return nil
 }
@@ -180,6 +370,136 @@ func(camObj *sqlCamera)GetCameraEntry(conn *sqlx.DB) (*dataSet.Camera, error) {
     return &rows[0], nil
 }
 
+//Set the camera parameters that are not set to their defaults. The invalid
+// parameters are checked in validateCameraParams.
+func(camObj *sqlCamera)setDefaultCameraParams() {
+    if camObj.SnapshotPkts == 0 {
+        camObj.SnapshotPkts = dataSet.CAMERA_DEFAULT_SNAPSHOT_PKTS
+    }
+    if camObj.SpeedFactor == 0 {
+        camObj.SpeedFactor = dataSet.CAMERA_DEFAULT_SPEED_FACTOR
+    }
+    if len(strings.TrimSpace(camObj.OutputFormats)) == 0 {
+        camObj.OutputFormats = dataSet.CAMERA_DEFAULT_OUTPUT_FORMATS
+    }
+    if len(camObj.ResumePolicy) == 0 {
+        camObj.ResumePolicy = dataSet.CAMERA_DEFAULT_RESUME_POLICY
+    }
+    if len(camObj.OverlayPosition) == 0 {
+        camObj.OverlayPosition = dataSet.CAMERA_DEFAULT_OVERLAY_POSITION
+    }
+    if camObj.OverlayFontSize == 0 {
+        camObj.OverlayFontSize = dataSet.CAMERA_DEFAULT_OVERLAY_FONT_SIZE
+    }
+    if len(camObj.CaptureMode) == 0 {
+        camObj.CaptureMode = dataSet.CAMERA_DEFAULT_CAPTURE_MODE
+    }
+    if camObj.ChangeThreshold == 0 {
+        camObj.ChangeThreshold = dataSet.CAMERA_DEFAULT_CHANGE_THRESHOLD
+    }
+    if camObj.MinMeanLuma == 0 {
+        camObj.MinMeanLuma = dataSet.CAMERA_DEFAULT_MIN_MEAN_LUMA
+    }
+    if camObj.MaxMeanLuma == 0 {
+        camObj.MaxMeanLuma = dataSet.CAMERA_DEFAULT_MAX_MEAN_LUMA
+    }
+    if camObj.MinLumaDeviation == 0 {
+        camObj.MinLumaDeviation = dataSet.CAMERA_DEFAULT_MIN_LUMA_DEVIATION
+    }
+    if camObj.DeflickerWindow == 0 {
+        camObj.DeflickerWindow = dataSet.CAMERA_DEFAULT_DEFLICKER_WINDOW
+    }
+    if camObj.TamperThreshold == 0 {
+        camObj.TamperThreshold = dataSet.CAMERA_DEFAULT_TAMPER_THRESHOLD
+    }
+    if camObj.SegmentSec == 0 {
+        camObj.SegmentSec = dataSet.CAMERA_DEFAULT_SEGMENT_SEC
+    }
+    if camObj.SegmentRetentionHours == 0 {
+        camObj.SegmentRetentionHours =
+                            dataSet.CAMERA_DEFAULT_SEGMENT_RETENTION_HOURS
+    }
+    if len(camObj.CycleAlign) == 0 {
+        camObj.CycleAlign = dataSet.CAMERA_DEFAULT_CYCLE_ALIGN
+    }
+}
+
+//Check the camera parameters, the request is rejected instead of storing
+// parameters other than requested. Video length and snapshot interval must be
+// validated and the defaults set before calling this function.
+func(camObj *sqlCamera)validateCameraParams() error {
+    log := logging.GetLoggerInstance()
+    if !camObj.IsSnapshotPktsValid() || !camObj.IsSnapshotSecValid() {
+        log.Error("Invalid snapshot clip length, cannot update %s",
+                  camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    if !camObj.IsSpeedFactorValid() || !camObj.IsOutputLenValid() {
+        log.Error("Invalid compaction speed/length, cannot update %s",
+                  camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    if !camObj.IsOutputFormatsValid() {
+        log.Error("Unknown or duplicate output formats %s, cannot update %s",
+                  camObj.OutputFormats, camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    if !camObj.IsResumePolicyValid() {
+        log.Error("Invalid resume policy %s, cannot update %s",
+                  camObj.ResumePolicy, camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    if !camObj.IsKeepSnapshotDaysValid() {
+        log.Error("Snapshots of %s cannot be kept for %d days", camObj.Name,
+                  camObj.KeepSnapshotDays)
+        return appErrors.INVALID_INPUT
+    }
+    if !camObj.IsOverlayCaptionValid() || !camObj.IsOverlayPositionValid() ||
+        !camObj.IsOverlayFontSizeValid() ||
+        !camObj.IsOverlayTimezoneValid() {
+        log.Error("Invalid overlay settings, cannot update %s", camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    if !camObj.IsCaptureModeValid() || !camObj.IsChangeThresholdValid() ||
+        !camObj.IsMaxSnapIntervalValid() {
+        log.Error("Invalid capture mode settings, cannot update %s",
+                  camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    if !camObj.IsMeanLumaValid() || !camObj.IsMinLumaDeviationValid() ||
+        !camObj.IsQualityRetriesValid() {
+        log.Error("Invalid quality check settings, cannot update %s",
+                  camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    if !camObj.IsDeflickerWindowValid() {
+        log.Error("Invalid deflicker window %d, cannot update %s",
+                  camObj.DeflickerWindow, camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    if !camObj.IsTamperThresholdValid() {
+        log.Error("Invalid tamper threshold %d, cannot update %s",
+                  camObj.TamperThreshold, camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    if !camObj.IsSegmentSecValid() || !camObj.IsSegmentRetentionValid() {
+        log.Error("Invalid segment length/retention, cannot update %s",
+                  camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    if !camObj.IsClipBufferSecValid() {
+        log.Error("Clip buffer of %s cannot be longer than %d seconds",
+                  camObj.Name, dataSet.CAMERA_MAX_CLIP_BUFFER_SEC)
+        return appErrors.INVALID_INPUT
+    }
+    if !camObj.IsTimezoneValid() || !camObj.IsCycleAlignValid() {
+        log.Error("Invalid timezone %s/cycle alignment %s, cannot update %s",
+                  camObj.Timezone, camObj.CycleAlign, camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    return nil
+}
+
 func(camObj *sqlCamera)InsertCameraEntry(conn *sqlx.DB) error {
     var err error
 
@@ -194,6 +514,21 @@ func(camObj *sqlCamera)InsertCameraEntry(conn *sqlx.DB) error {
         log.Error("Invalid username/pwd, cannot update %s", camObj.Name)
         return appErrors.INVALID_INPUT
     }
+    //Masks are never reset to default, as it would expose the regions.
+    if !camObj.IsPrivacyMasksValid() {
+        log.Error("Invalid privacy masks, cannot update %s", camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    //Profiles are never reset, as their timelapses would be dropped.
+    if !camObj.IsProfilesValid() {
+        log.Error("Invalid capture profiles, cannot update %s", camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    if !camObj.IsRecordingValid() {
+        log.Error("Cannot share the stream of %s with privacy masks",
+                  camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
     var row *dataSet.Camera
     row, err = camObj.GetCameraEntry(conn)
     if  err != nil  && err != appErrors.DATA_NOT_FOUND {
@@ -222,9 +557,31 @@ func(camObj *sqlCamera)InsertCameraEntry(conn *sqlx.DB) error {
     if !camObj.IsSnapshotLenValid() {
         camObj.SnapInterval = dataSet.CAMERA_DEFAULT_SNAPSHOT_INTERVAL
     }
+    camObj.setDefaultCameraParams()
+    err = camObj.validateCameraParams()
+    if err != nil {
+        return err
+    }
     _, err = conn.Exec(cameraCreate, camObj.Name, camObj.Ipaddr, camObj.Port,
                         camObj.Desc, camObj.Status, camObj.UserId, camObj.Pwd,
-                        camObj.VideoLenSec, camObj.SnapInterval)
+                        camObj.VideoLenSec, camObj.SnapInterval,
+                        camObj.SnapshotPkts, camObj.SnapshotSec,
+                        camObj.SpeedFactor, camObj.OutputLenSec,
+                        camObj.OutputFormats, camObj.EnableHLS,
+                        camObj.ResumePolicy, camObj.KeepSnapshotDays,
+                        camObj.OverlayTimestamp, camObj.OverlayCamName,
+                        camObj.OverlayCaption, camObj.OverlayPosition,
+                        camObj.OverlayFontSize, camObj.OverlayTimezone,
+                        camObj.PrivacyMasks, camObj.CaptureMode,
+                        camObj.ChangeThreshold, camObj.MaxSnapInterval,
+                        camObj.QualityCheck, camObj.MinMeanLuma,
+                        camObj.MaxMeanLuma, camObj.MinLumaDeviation,
+                        camObj.QualityRetries, camObj.Deflicker,
+                        camObj.DeflickerWindow, camObj.TamperDetection,
+                        camObj.TamperThreshold, camObj.Recording,
+                        camObj.SegmentSec, camObj.SegmentRetentionHours,
+                        camObj.ClipBufferSec, camObj.Profiles, camObj.Timezone,
+                        camObj.CycleAlign)
     if err != nil {
         log.Error("Failed to create the camera record %s, err :%s",
                             camObj.Name, err)
@@ -247,16 +604,67 @@ func(camObj *sqlCamera)UpdateCameraEntry(conn *sqlx.DB) error {
         log.Error("Invalid username/pwd, cannot update %s", camObj.Name)
         return appErrors.INVALID_INPUT
     }
+    //Masks are never reset to default, as it would expose the regions.
+    if !camObj.IsPrivacyMasksValid() {
+        log.Error("Invalid privacy masks, cannot update %s", camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    //Profiles are never reset, as their timelapses would be dropped.
+    if !camObj.IsProfilesValid() {
+        log.Error("Invalid capture profiles, cannot update %s", camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    if !camObj.IsRecordingValid() {
+        log.Error("Cannot share the stream of %s with privacy masks",
+                  camObj.Name)
+        return appErrors.INVALID_INPUT
+    }
     if !camObj.IsVideoLenValid() {
         camObj.VideoLenSec = dataSet.CAMERA_DEFAULT_TIMELAPSE_SEC
     }
     if !camObj.IsSnapshotLenValid() {
         camObj.SnapInterval = dataSet.CAMERA_DEFAULT_SNAPSHOT_INTERVAL
     }
+    camObj.setDefaultCameraParams()
+    err = camObj.validateCameraParams()
+    if err != nil {
+        return err
+    }
     _, err = conn.Exec(cameraUpdate, camObj.Camera.Ipaddr, camObj.Camera.Port,
                         camObj.Camera.Desc, camObj.Camera.Status,
                         camObj.Camera.UserId, camObj.Camera.Pwd,
                         camObj.Camera.VideoLenSec,camObj.SnapInterval,
+                        camObj.SnapshotPkts, camObj.SnapshotSec,
+                        camObj.SpeedFactor, camObj.OutputLenSec,
+                        camObj.OutputFormats, camObj.EnableHLS,
+                        camObj.ResumePolicy,
+                        camObj.KeepSnapshotDays,
+                        camObj.OverlayTimestamp,
+                        camObj.OverlayCamName,
+                        camObj.OverlayCaption,
+                        camObj.OverlayPosition,
+                        camObj.OverlayFontSize,
+                        camObj.OverlayTimezone,
+                        camObj.PrivacyMasks,
+                        camObj.CaptureMode,
+                        camObj.ChangeThreshold,
+                        camObj.MaxSnapInterval,
+                        camObj.QualityCheck,
+                        camObj.MinMeanLuma,
+                        camObj.MaxMeanLuma,
+                        camObj.MinLumaDeviation,
+                        camObj.QualityRetries,
+                        camObj.Deflicker,
+                        camObj.DeflickerWindow,
+                        camObj.TamperDetection,
+                        camObj.TamperThreshold,
+                        camObj.Recording,
+                        camObj.SegmentSec,
+                        camObj.SegmentRetentionHours,
+                        camObj.ClipBufferSec,
+                        camObj.Profiles,
+                        camObj.Timezone,
+                        camObj.CycleAlign,
                         camObj.Name)
     if err != nil {
         log.Error("Failed to update the camera record err :%s", err)
@@ -0,0 +1,133 @@
+package sqlite
+
+import (
+    "fmt"
+    "github.com/jmoiron/sqlx"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+//Field names are lower case of the CameraCaptureStatus struct field names.
+const (
+    CAPTURESTATUS_TABLE = "camera_capture_status"
+    CAPTURESTATUS_FIELD_CAMNAME = "camname"
+    CAPTURESTATUS_FIELD_REJECTEDSNAPSHOTS = "rejectedsnapshots"
+    CAPTURESTATUS_FIELD_LASTREJECTREASON = "lastrejectreason"
+    CAPTURESTATUS_FIELD_LASTREJECTTIME = "lastrejecttime"
+    CAPTURESTATUS_FIELD_DEGRADED = "degraded"
+    CAPTURESTATUS_FIELD_DEGRADEDREASON = "degradedreason"
+    CAPTURESTATUS_FIELD_DEGRADEDTIME = "degradedtime"
+)
+
+//Columns added to the capture status table after the initial schema.
+var captureStatusExtColumns = []sqlColumn{
+    {CAPTURESTATUS_FIELD_DEGRADED, "INTEGER DEFAULT 0"},
+    {CAPTURESTATUS_FIELD_DEGRADEDREASON, "TEXT DEFAULT ''"},
+    {CAPTURESTATUS_FIELD_DEGRADEDTIME, "INTEGER DEFAULT 0"},
+}
+
+var (
+    captureStatusSchema = fmt.Sprintf(
+                `CREATE TABLE IF NOT EXISTS %s (%s TEXT PRIMARY KEY,
+                 %s INTEGER DEFAULT 0,
+                 %s TEXT DEFAULT '',
+                 %s INTEGER DEFAULT 0)`,
+                 CAPTURESTATUS_TABLE,
+                 CAPTURESTATUS_FIELD_CAMNAME,
+                 CAPTURESTATUS_FIELD_REJECTEDSNAPSHOTS,
+                 CAPTURESTATUS_FIELD_LASTREJECTREASON,
+                 CAPTURESTATUS_FIELD_LASTREJECTTIME)
+    //A camera has only one entry, replaced on every change.
+    captureStatusCreate = fmt.Sprintf(`INSERT OR REPLACE INTO %s
+                                       (%s, %s, %s, %s, %s, %s, %s)
+                                       VALUES (?, ?, ?, ?, ?, ?, ?)`,
+                                       CAPTURESTATUS_TABLE,
+                                       CAPTURESTATUS_FIELD_CAMNAME,
+                                       CAPTURESTATUS_FIELD_REJECTEDSNAPSHOTS,
+                                       CAPTURESTATUS_FIELD_LASTREJECTREASON,
+                                       CAPTURESTATUS_FIELD_LASTREJECTTIME,
+                                       CAPTURESTATUS_FIELD_DEGRADED,
+                                       CAPTURESTATUS_FIELD_DEGRADEDREASON,
+                                       CAPTURESTATUS_FIELD_DEGRADEDTIME)
+    captureStatusGet = fmt.Sprintf("SELECT * FROM %s WHERE %s=(?)",
+                                   CAPTURESTATUS_TABLE,
+                                   CAPTURESTATUS_FIELD_CAMNAME)
+    captureStatusDelete = fmt.Sprintf("DELETE FROM %s WHERE %s=(?)",
+                                      CAPTURESTATUS_TABLE,
+                                      CAPTURESTATUS_FIELD_CAMNAME)
+)
+
+// Anonymous pointer to capture status struct, same as sqlCamera.
+type sqlCaptureStatus struct {
+    *dataSet.CameraCaptureStatus
+}
+
+func(statusObj *sqlCaptureStatus)CreateCaptureStatusTable(
+                                    conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(captureStatusSchema)
+    if err != nil {
+        log.Error("Failed to create capture status table %s", err)
+        return err
+    }
+    err = addMissingColumns(conn, CAPTURESTATUS_TABLE,
+                            captureStatusExtColumns)
+    if err != nil {
+        log.Error("Failed to update capture status table %s", err)
+        return err
+    }
+    log.Trace("Table %s created successfully", CAPTURESTATUS_TABLE)
+    return nil
+}
+
+func(statusObj *sqlCaptureStatus)InsertCaptureStatusEntry(
+                                    conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    if len(statusObj.CamName) == 0 {
+        log.Error("Cannot create capture status entry with empty camera name")
+        return appErrors.INVALID_INPUT
+    }
+    _, err = conn.Exec(captureStatusCreate, statusObj.CamName,
+                        statusObj.RejectedSnapshots,
+                        statusObj.LastRejectReason, statusObj.LastRejectTime,
+                        statusObj.Degraded, statusObj.DegradedReason,
+                        statusObj.DegradedTime)
+    if err != nil {
+        log.Error("Failed to create the capture status record %s, err :%s",
+                            statusObj.CamName, err)
+        return err
+    }
+    return nil
+}
+
+func(statusObj *sqlCaptureStatus)GetCaptureStatusEntry(conn *sqlx.DB) (
+                                    *dataSet.CameraCaptureStatus, error) {
+    var err error
+    log := logging.GetLoggerInstance()
+    rows := []dataSet.CameraCaptureStatus{}
+    err = conn.Select(&rows, captureStatusGet, statusObj.CamName)
+    if err != nil {
+        log.Error("Failed to get the capture status row for %s",
+                    statusObj.CamName)
+        return nil, err
+    }
+    if len(rows) == 0 {
+        return nil, appErrors.DATA_NOT_FOUND
+    }
+    return &rows[0], nil
+}
+
+func(statusObj *sqlCaptureStatus)DeleteCaptureStatusEntry(
+                                    conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(captureStatusDelete, statusObj.CamName)
+    if err != nil {
+        log.Error("Failed to delete capture status entry err: %s", err)
+        return err
+    }
+    return nil
+}
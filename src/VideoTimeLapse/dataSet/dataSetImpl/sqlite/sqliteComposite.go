@@ -0,0 +1,137 @@
+package sqlite
+
+import (
+    "fmt"
+    "github.com/jmoiron/sqlx"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+//Field names are lower case of the Composite struct field names.
+const (
+    COMPOSITE_TABLE = "composite"
+    COMPOSITE_FIELD_NAME = "name"
+    COMPOSITE_FIELD_DESC = "desc"
+    COMPOSITE_FIELD_CAMERAS = "cameras"
+    COMPOSITE_FIELD_LAYOUT = "layout"
+    COMPOSITE_FIELD_WIDTH = "width"
+    COMPOSITE_FIELD_HEIGHT = "height"
+)
+
+var (
+    compositeSchema = fmt.Sprintf(
+                `CREATE TABLE IF NOT EXISTS %s (%s TEXT PRIMARY KEY,
+                 %s TEXT DEFAULT "",
+                 %s TEXT NOT NULL,
+                 %s TEXT NOT NULL,
+                 %s INTEGER DEFAULT %d,
+                 %s INTEGER DEFAULT %d)`,
+                 COMPOSITE_TABLE,
+                 COMPOSITE_FIELD_NAME,
+                 COMPOSITE_FIELD_DESC,
+                 COMPOSITE_FIELD_CAMERAS,
+                 COMPOSITE_FIELD_LAYOUT,
+                 COMPOSITE_FIELD_WIDTH, dataSet.COMPOSITE_DEFAULT_WIDTH,
+                 COMPOSITE_FIELD_HEIGHT, dataSet.COMPOSITE_DEFAULT_HEIGHT)
+    compositeCreate = fmt.Sprintf(`INSERT OR REPLACE INTO %s
+                                   (%s, %s, %s, %s, %s, %s)
+                                   VALUES (?, ?, ?, ?, ?, ?)`,
+                                   COMPOSITE_TABLE,
+                                   COMPOSITE_FIELD_NAME,
+                                   COMPOSITE_FIELD_DESC,
+                                   COMPOSITE_FIELD_CAMERAS,
+                                   COMPOSITE_FIELD_LAYOUT,
+                                   COMPOSITE_FIELD_WIDTH,
+                                   COMPOSITE_FIELD_HEIGHT)
+    compositeGet = fmt.Sprintf("SELECT * FROM %s WHERE %s=(?)",
+                               COMPOSITE_TABLE,
+                               COMPOSITE_FIELD_NAME)
+    compositeGetAll = fmt.Sprintf("SELECT * FROM %s ORDER BY %s",
+                                  COMPOSITE_TABLE,
+                                  COMPOSITE_FIELD_NAME)
+    compositeDelete = fmt.Sprintf("DELETE FROM %s WHERE %s=(?)",
+                                  COMPOSITE_TABLE,
+                                  COMPOSITE_FIELD_NAME)
+)
+
+// Anonymous pointer to composite struct, same as sqlCamera.
+type sqlComposite struct {
+    *dataSet.Composite
+}
+
+func(compositeObj *sqlComposite)CreateCompositeTable(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(compositeSchema)
+    if err != nil {
+        log.Error("Failed to create composite table %s", err)
+        return err
+    }
+    log.Trace("Table %s created successfully", COMPOSITE_TABLE)
+    return nil
+}
+
+func(compositeObj *sqlComposite)InsertCompositeEntry(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    if !compositeObj.IsNameValid() {
+        log.Error("Cannot create composite with invalid name %s",
+                    compositeObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    if !dataSet.IsCompositeLayoutValid(compositeObj.Layout) ||
+        !compositeObj.IsCamerasValid() || !compositeObj.IsResolutionValid() {
+        log.Error("Cannot create composite %s, Invalid parameters",
+                    compositeObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    _, err = conn.Exec(compositeCreate, compositeObj.Name, compositeObj.Desc,
+                        compositeObj.Cameras, compositeObj.Layout,
+                        compositeObj.Width, compositeObj.Height)
+    if err != nil {
+        log.Error("Failed to create the composite record %s, err :%s",
+                            compositeObj.Name, err)
+        return err
+    }
+    return nil
+}
+
+func(compositeObj *sqlComposite)GetCompositeEntry(conn *sqlx.DB) (
+                                    *dataSet.Composite, error) {
+    var err error
+    log := logging.GetLoggerInstance()
+    rows := []dataSet.Composite{}
+    err = conn.Select(&rows, compositeGet, compositeObj.Name)
+    if err != nil {
+        log.Error("Failed to get the composite row for %s", compositeObj.Name)
+        return nil, err
+    }
+    if len(rows) == 0 {
+        return nil, appErrors.DATA_NOT_FOUND
+    }
+    return &rows[0], nil
+}
+
+func(compositeObj *sqlComposite)GetAllCompositeEntries(conn *sqlx.DB) (
+                                    []dataSet.Composite, error) {
+    var err error
+    log := logging.GetLoggerInstance()
+    rows := []dataSet.Composite{}
+    err = conn.Select(&rows, compositeGetAll)
+    if err != nil {
+        log.Error("Failed to get the composite rows")
+    }
+    return rows, err
+}
+
+func(compositeObj *sqlComposite)DeleteCompositeEntry(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(compositeDelete, compositeObj.Name)
+    if err != nil {
+        log.Error("Failed to delete composite entry err: %s", err)
+        return err
+    }
+    return nil
+}
@@ -0,0 +1,122 @@
+package sqlite
+
+import (
+    "fmt"
+    "github.com/jmoiron/sqlx"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+//Field names are lower case of the CameraCycleState struct field names.
+const (
+    CYCLESTATE_TABLE = "camera_cycle_state"
+    CYCLESTATE_FIELD_CAMNAME = "camname"
+    CYCLESTATE_FIELD_PROFILE = "profile"
+    CYCLESTATE_FIELD_CYCLEDIR = "cycledir"
+    CYCLESTATE_FIELD_CYCLESTART = "cyclestart"
+    CYCLESTATE_FIELD_NUMSNAPSHOTS = "numsnapshots"
+    CYCLESTATE_FIELD_FILEINDEX = "fileindex"
+)
+
+var (
+    cycleStateSchema = fmt.Sprintf(
+                `CREATE TABLE IF NOT EXISTS %s (%s TEXT NOT NULL,
+                 %s TEXT DEFAULT '',
+                 %s TEXT NOT NULL,
+                 %s INTEGER DEFAULT 0,
+                 %s INTEGER DEFAULT 0,
+                 %s INTEGER DEFAULT 0,
+                 PRIMARY KEY (%s, %s))`,
+                 CYCLESTATE_TABLE,
+                 CYCLESTATE_FIELD_CAMNAME,
+                 CYCLESTATE_FIELD_PROFILE,
+                 CYCLESTATE_FIELD_CYCLEDIR,
+                 CYCLESTATE_FIELD_CYCLESTART,
+                 CYCLESTATE_FIELD_NUMSNAPSHOTS,
+                 CYCLESTATE_FIELD_FILEINDEX,
+                 CYCLESTATE_FIELD_CAMNAME,
+                 CYCLESTATE_FIELD_PROFILE)
+    //A camera has only one running cycle for itself and each profile.
+    cycleStateCreate = fmt.Sprintf(`INSERT OR REPLACE INTO %s
+                                    (%s, %s, %s, %s, %s, %s)
+                                    VALUES (?, ?, ?, ?, ?, ?)`,
+                                    CYCLESTATE_TABLE,
+                                    CYCLESTATE_FIELD_CAMNAME,
+                                    CYCLESTATE_FIELD_PROFILE,
+                                    CYCLESTATE_FIELD_CYCLEDIR,
+                                    CYCLESTATE_FIELD_CYCLESTART,
+                                    CYCLESTATE_FIELD_NUMSNAPSHOTS,
+                                    CYCLESTATE_FIELD_FILEINDEX)
+    cycleStateGet = fmt.Sprintf("SELECT * FROM %s WHERE %s=(?) AND %s=(?)",
+                                CYCLESTATE_TABLE,
+                                CYCLESTATE_FIELD_CAMNAME,
+                                CYCLESTATE_FIELD_PROFILE)
+    cycleStateDelete = fmt.Sprintf("DELETE FROM %s WHERE %s=(?)",
+                                   CYCLESTATE_TABLE,
+                                   CYCLESTATE_FIELD_CAMNAME)
+)
+
+// Anonymous pointer to cycle state struct, same as sqlCamera.
+type sqlCycleState struct {
+    *dataSet.CameraCycleState
+}
+
+func(stateObj *sqlCycleState)CreateCycleStateTable(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(cycleStateSchema)
+    if err != nil {
+        log.Error("Failed to create cycle state table %s", err)
+        return err
+    }
+    log.Trace("Table %s created successfully", CYCLESTATE_TABLE)
+    return nil
+}
+
+func(stateObj *sqlCycleState)InsertCycleStateEntry(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    if len(stateObj.CamName) == 0 || len(stateObj.CycleDir) == 0 {
+        log.Error("Cannot create cycle state with empty camera name/dir")
+        return appErrors.INVALID_INPUT
+    }
+    _, err = conn.Exec(cycleStateCreate, stateObj.CamName, stateObj.Profile,
+                        stateObj.CycleDir, stateObj.CycleStart,
+                        stateObj.NumSnapshots, stateObj.FileIndex)
+    if err != nil {
+        log.Error("Failed to create the cycle state record %s, err :%s",
+                            stateObj.CamName, err)
+        return err
+    }
+    return nil
+}
+
+func(stateObj *sqlCycleState)GetCycleStateEntry(conn *sqlx.DB) (
+                                    *dataSet.CameraCycleState, error) {
+    var err error
+    log := logging.GetLoggerInstance()
+    rows := []dataSet.CameraCycleState{}
+    err = conn.Select(&rows, cycleStateGet, stateObj.CamName,
+                      stateObj.Profile)
+    if err != nil {
+        log.Error("Failed to get the cycle state row for %s",
+                    stateObj.CamName)
+        return nil, err
+    }
+    if len(rows) == 0 {
+        return nil, appErrors.DATA_NOT_FOUND
+    }
+    return &rows[0], nil
+}
+
+func(stateObj *sqlCycleState)DeleteCycleStateEntry(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(cycleStateDelete, stateObj.CamName)
+    if err != nil {
+        log.Error("Failed to delete cycle state entry err: %s", err)
+        return err
+    }
+    return nil
+}
@@ -0,0 +1,119 @@
+package sqlite
+
+import (
+    "fmt"
+    "github.com/jmoiron/sqlx"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+//Field names are lower case of the Event struct field names.
+const (
+    EVENT_TABLE = "event"
+    EVENT_FIELD_ID = "id"
+    EVENT_FIELD_TYPE = "type"
+    EVENT_FIELD_CAMNAME = "camname"
+    EVENT_FIELD_TIME = "time"
+    EVENT_FIELD_MESSAGE = "message"
+    EVENT_FIELD_EVIDENCE = "evidence"
+)
+
+var (
+    eventSchema = fmt.Sprintf(
+                `CREATE TABLE IF NOT EXISTS %s (%s TEXT PRIMARY KEY,
+                 %s TEXT NOT NULL,
+                 %s TEXT NOT NULL,
+                 %s INTEGER NOT NULL,
+                 %s TEXT DEFAULT '',
+                 %s TEXT DEFAULT '')`,
+                 EVENT_TABLE,
+                 EVENT_FIELD_ID,
+                 EVENT_FIELD_TYPE,
+                 EVENT_FIELD_CAMNAME,
+                 EVENT_FIELD_TIME,
+                 EVENT_FIELD_MESSAGE,
+                 EVENT_FIELD_EVIDENCE)
+    eventCreate = fmt.Sprintf(`INSERT OR REPLACE INTO %s
+                               (%s, %s, %s, %s, %s, %s)
+                               VALUES (?, ?, ?, ?, ?, ?)`,
+                               EVENT_TABLE,
+                               EVENT_FIELD_ID,
+                               EVENT_FIELD_TYPE,
+                               EVENT_FIELD_CAMNAME,
+                               EVENT_FIELD_TIME,
+                               EVENT_FIELD_MESSAGE,
+                               EVENT_FIELD_EVIDENCE)
+    eventGetRange = fmt.Sprintf(`SELECT * FROM %s WHERE %s=(?) AND
+                                 %s>=(?) AND %s<=(?) ORDER BY %s`,
+                                 EVENT_TABLE,
+                                 EVENT_FIELD_CAMNAME,
+                                 EVENT_FIELD_TIME,
+                                 EVENT_FIELD_TIME,
+                                 EVENT_FIELD_TIME)
+    eventDeleteAll = fmt.Sprintf("DELETE FROM %s WHERE %s=(?)",
+                                 EVENT_TABLE,
+                                 EVENT_FIELD_CAMNAME)
+)
+
+// Anonymous pointer to event struct, same as sqlCamera.
+type sqlEvent struct {
+    *dataSet.Event
+}
+
+func(eventObj *sqlEvent)CreateEventTable(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(eventSchema)
+    if err != nil {
+        log.Error("Failed to create event table %s", err)
+        return err
+    }
+    log.Trace("Table %s created successfully", EVENT_TABLE)
+    return nil
+}
+
+func(eventObj *sqlEvent)InsertEventEntry(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    if len(eventObj.Id) == 0 || len(eventObj.Type) == 0 ||
+        len(eventObj.CamName) == 0 {
+        log.Error("Cannot create event with empty id/type/camera name")
+        return appErrors.INVALID_INPUT
+    }
+    _, err = conn.Exec(eventCreate, eventObj.Id, eventObj.Type,
+                        eventObj.CamName, eventObj.Time, eventObj.Message,
+                        eventObj.Evidence)
+    if err != nil {
+        log.Error("Failed to create the event record %s, err :%s",
+                            eventObj.Id, err)
+        return err
+    }
+    return nil
+}
+
+//Return the events of the camera raised between unix time 'from' and 'to',
+// in the order of the event time.
+func(eventObj *sqlEvent)GetEventEntries(conn *sqlx.DB, from int64,
+                                    to int64) ([]dataSet.Event, error) {
+    var err error
+    log := logging.GetLoggerInstance()
+    rows := []dataSet.Event{}
+    err = conn.Select(&rows, eventGetRange, eventObj.CamName, from, to)
+    if err != nil {
+        log.Error("Failed to get the event rows for %s", eventObj.CamName)
+    }
+    return rows, err
+}
+
+//Delete all the events of the camera.
+func(eventObj *sqlEvent)DeleteAllEventEntries(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(eventDeleteAll, eventObj.CamName)
+    if err != nil {
+        log.Error("Failed to delete event entries err: %s", err)
+        return err
+    }
+    return nil
+}
@@ -11,6 +11,13 @@ import (
     "VideoTimeLapse/dataSet"
 )
 
+//Column name and its sql definition, used when a column is added to an
+// existing table.
+type sqlColumn struct {
+    name string
+    def string
+}
+
 var dbOnce sync.Once
 var sqlObj *SqliteDataStore
 
@@ -47,6 +54,43 @@ func (sqlds *SqliteDataStore)CreateDBConnection(
     return nil
 }
 
+//Add the columns that are not present in the table. sqlite doesnt support
+// 'ADD COLUMN IF NOT EXISTS', so the table info is read first to find the
+// missing columns.
+func addMissingColumns(conn *sqlx.DB, table string,
+                       columns []sqlColumn) error {
+    rows, err := conn.Queryx(fmt.Sprintf("PRAGMA table_info(%s)", table))
+    if err != nil {
+        return err
+    }
+    present := make(map[string]bool)
+    for rows.Next() {
+        col := make(map[string]interface{})
+        if err = rows.MapScan(col); err != nil {
+            rows.Close()
+            return err
+        }
+        switch name := col["name"].(type) {
+        case string:
+            present[name] = true
+        case []byte:
+            present[string(name)] = true
+        }
+    }
+    rows.Close()
+    for _, column := range columns {
+        if present[column.name] {
+            continue
+        }
+        _, err = conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s",
+                                       table, column.name, column.def))
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
 //Create all the sqlite tables for TimeLapse application.
 func (sqlds *SqliteDataStore)CreateDataStoreTables() error {
     if sqlds.DBConn == nil {
@@ -55,6 +99,36 @@ func (sqlds *SqliteDataStore)CreateDataStoreTables() error {
     camObj := new(sqlCamera)
     camObj.Camera = new(dataSet.Camera)
     camObj.CreateCameraTable(sqlds.DBConn)
+    videoObj := new(sqlVideo)
+    videoObj.Video = new(dataSet.Video)
+    videoObj.CreateVideoTable(sqlds.DBConn)
+    streamObj := new(sqlStreamInfo)
+    streamObj.CameraStreamInfo = new(dataSet.CameraStreamInfo)
+    streamObj.CreateStreamInfoTable(sqlds.DBConn)
+    stateObj := new(sqlCycleState)
+    stateObj.CameraCycleState = new(dataSet.CameraCycleState)
+    stateObj.CreateCycleStateTable(sqlds.DBConn)
+    jobObj := new(sqlJob)
+    jobObj.Job = new(dataSet.Job)
+    jobObj.CreateJobTable(sqlds.DBConn)
+    rollupObj := new(sqlRollup)
+    rollupObj.CameraRollup = new(dataSet.CameraRollup)
+    rollupObj.CreateRollupTable(sqlds.DBConn)
+    compositeObj := new(sqlComposite)
+    compositeObj.Composite = new(dataSet.Composite)
+    compositeObj.CreateCompositeTable(sqlds.DBConn)
+    snapshotObj := new(sqlSnapshot)
+    snapshotObj.Snapshot = new(dataSet.Snapshot)
+    snapshotObj.CreateSnapshotTable(sqlds.DBConn)
+    statusObj := new(sqlCaptureStatus)
+    statusObj.CameraCaptureStatus = new(dataSet.CameraCaptureStatus)
+    statusObj.CreateCaptureStatusTable(sqlds.DBConn)
+    eventObj := new(sqlEvent)
+    eventObj.Event = new(dataSet.Event)
+    eventObj.CreateEventTable(sqlds.DBConn)
+    statsObj := new(sqlSnapshotStats)
+    statsObj.SnapshotStats = new(dataSet.SnapshotStats)
+    statsObj.CreateSnapshotStatsTable(sqlds.DBConn)
     return nil
 }
 
@@ -68,7 +142,38 @@ func (sqlds *SqliteDataStore)DeleteCamera(cameraName string) error {
     camObj := new(sqlCamera)
     camObj.Camera = new(dataSet.Camera)
     camObj.Camera.Name = cameraName
-    return camObj.DeleteCameraEntry(sqlds.DBConn)
+    err := camObj.DeleteCameraEntry(sqlds.DBConn)
+    if err != nil {
+        return err
+    }
+    err = sqlds.DeleteCameraStreamInfo(cameraName)
+    if err != nil {
+        return err
+    }
+    err = sqlds.DeleteCameraCycleState(cameraName)
+    if err != nil {
+        return err
+    }
+    err = sqlds.DeleteCameraCaptureStatus(cameraName)
+    if err != nil {
+        return err
+    }
+    rollupObj := new(sqlRollup)
+    rollupObj.CameraRollup = new(dataSet.CameraRollup)
+    rollupObj.CamName = cameraName
+    err = rollupObj.DeleteAllRollupEntries(sqlds.DBConn)
+    if err != nil {
+        return err
+    }
+    err = sqlds.DeleteSnapshots(cameraName)
+    if err != nil {
+        return err
+    }
+    err = sqlds.DeleteEvents(cameraName)
+    if err != nil {
+        return err
+    }
+    return sqlds.DeleteSnapshotStats(cameraName)
 }
 
 //User allowed to update all the fields in the camera db entry except the
@@ -94,6 +199,264 @@ func(sqlds *SqliteDataStore)GetAllCameras()([]dataSet.Camera, error) {
     return dataObj, err
 }
 
+func (sqlds *SqliteDataStore)AddNewVideo(video *dataSet.Video) error {
+    videoObj := new(sqlVideo)
+    videoObj.Video = video
+    return videoObj.InsertVideoEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)DeleteVideo(camName string,
+                                         videoName string) error {
+    videoObj := new(sqlVideo)
+    videoObj.Video = new(dataSet.Video)
+    videoObj.CamName = camName
+    videoObj.Name = videoName
+    return videoObj.DeleteVideoEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)GetVideo(camName string,
+                                      videoName string) (*dataSet.Video,
+                                      error) {
+    videoObj := new(sqlVideo)
+    videoObj.Video = new(dataSet.Video)
+    videoObj.CamName = camName
+    videoObj.Name = videoName
+    return videoObj.GetVideoEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)GetAllVideos(camName string) ([]dataSet.Video,
+                                          error) {
+    videoObj := new(sqlVideo)
+    videoObj.Video = new(dataSet.Video)
+    videoObj.CamName = camName
+    return videoObj.GetAllVideoEntries(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)UpdateCameraStreamInfo(
+                                    info *dataSet.CameraStreamInfo) error {
+    streamObj := new(sqlStreamInfo)
+    streamObj.CameraStreamInfo = info
+    return streamObj.InsertStreamInfoEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)GetCameraStreamInfo(camName string) (
+                                    *dataSet.CameraStreamInfo, error) {
+    streamObj := new(sqlStreamInfo)
+    streamObj.CameraStreamInfo = new(dataSet.CameraStreamInfo)
+    streamObj.CamName = camName
+    return streamObj.GetStreamInfoEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)DeleteCameraStreamInfo(camName string) error {
+    streamObj := new(sqlStreamInfo)
+    streamObj.CameraStreamInfo = new(dataSet.CameraStreamInfo)
+    streamObj.CamName = camName
+    return streamObj.DeleteStreamInfoEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)UpdateCameraCaptureStatus(
+                                    status *dataSet.CameraCaptureStatus) error {
+    statusObj := new(sqlCaptureStatus)
+    statusObj.CameraCaptureStatus = status
+    return statusObj.InsertCaptureStatusEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)GetCameraCaptureStatus(camName string) (
+                                    *dataSet.CameraCaptureStatus, error) {
+    statusObj := new(sqlCaptureStatus)
+    statusObj.CameraCaptureStatus = new(dataSet.CameraCaptureStatus)
+    statusObj.CamName = camName
+    return statusObj.GetCaptureStatusEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)DeleteCameraCaptureStatus(camName string) error {
+    statusObj := new(sqlCaptureStatus)
+    statusObj.CameraCaptureStatus = new(dataSet.CameraCaptureStatus)
+    statusObj.CamName = camName
+    return statusObj.DeleteCaptureStatusEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)UpdateCameraCycleState(
+                                    state *dataSet.CameraCycleState) error {
+    stateObj := new(sqlCycleState)
+    stateObj.CameraCycleState = state
+    return stateObj.InsertCycleStateEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)GetCameraCycleState(camName string,
+                                    profile string) (
+                                    *dataSet.CameraCycleState, error) {
+    stateObj := new(sqlCycleState)
+    stateObj.CameraCycleState = new(dataSet.CameraCycleState)
+    stateObj.CamName = camName
+    stateObj.Profile = profile
+    return stateObj.GetCycleStateEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)DeleteCameraCycleState(camName string) error {
+    stateObj := new(sqlCycleState)
+    stateObj.CameraCycleState = new(dataSet.CameraCycleState)
+    stateObj.CamName = camName
+    return stateObj.DeleteCycleStateEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)UpdateCameraRollup(
+                                    rollup *dataSet.CameraRollup) error {
+    rollupObj := new(sqlRollup)
+    rollupObj.CameraRollup = rollup
+    return rollupObj.InsertRollupEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)GetCameraRollup(camName string,
+                        rollupName string) (*dataSet.CameraRollup, error) {
+    rollupObj := new(sqlRollup)
+    rollupObj.CameraRollup = new(dataSet.CameraRollup)
+    rollupObj.CamName = camName
+    rollupObj.Name = rollupName
+    return rollupObj.GetRollupEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)GetCameraRollups(camName string) (
+                                    []dataSet.CameraRollup, error) {
+    rollupObj := new(sqlRollup)
+    rollupObj.CameraRollup = new(dataSet.CameraRollup)
+    rollupObj.CamName = camName
+    return rollupObj.GetAllRollupEntries(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)DeleteCameraRollup(camName string,
+                                                rollupName string) error {
+    rollupObj := new(sqlRollup)
+    rollupObj.CameraRollup = new(dataSet.CameraRollup)
+    rollupObj.CamName = camName
+    rollupObj.Name = rollupName
+    return rollupObj.DeleteRollupEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)UpdateComposite(
+                                    composite *dataSet.Composite) error {
+    compositeObj := new(sqlComposite)
+    compositeObj.Composite = composite
+    return compositeObj.InsertCompositeEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)GetComposite(compositeName string) (
+                                    *dataSet.Composite, error) {
+    compositeObj := new(sqlComposite)
+    compositeObj.Composite = new(dataSet.Composite)
+    compositeObj.Name = compositeName
+    return compositeObj.GetCompositeEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)GetAllComposites() ([]dataSet.Composite, error) {
+    compositeObj := new(sqlComposite)
+    compositeObj.Composite = new(dataSet.Composite)
+    return compositeObj.GetAllCompositeEntries(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)DeleteComposite(compositeName string) error {
+    compositeObj := new(sqlComposite)
+    compositeObj.Composite = new(dataSet.Composite)
+    compositeObj.Name = compositeName
+    return compositeObj.DeleteCompositeEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)AddSnapshot(snapshot *dataSet.Snapshot) error {
+    snapshotObj := new(sqlSnapshot)
+    snapshotObj.Snapshot = snapshot
+    return snapshotObj.InsertSnapshotEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)GetSnapshots(camName string, from int64,
+                                    to int64) ([]dataSet.Snapshot, error) {
+    snapshotObj := new(sqlSnapshot)
+    snapshotObj.Snapshot = new(dataSet.Snapshot)
+    snapshotObj.CamName = camName
+    return snapshotObj.GetSnapshotEntries(sqlds.DBConn, from, to)
+}
+
+func (sqlds *SqliteDataStore)DeleteSnapshots(camName string) error {
+    snapshotObj := new(sqlSnapshot)
+    snapshotObj.Snapshot = new(dataSet.Snapshot)
+    snapshotObj.CamName = camName
+    return snapshotObj.DeleteAllSnapshotEntries(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)AddSnapshotStats(
+                                    stats *dataSet.SnapshotStats) error {
+    statsObj := new(sqlSnapshotStats)
+    statsObj.SnapshotStats = stats
+    return statsObj.InsertSnapshotStatsEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)GetSnapshotStats(camName string, from int64,
+                            to int64) ([]dataSet.SnapshotStats, error) {
+    statsObj := new(sqlSnapshotStats)
+    statsObj.SnapshotStats = new(dataSet.SnapshotStats)
+    statsObj.CamName = camName
+    return statsObj.GetSnapshotStatsEntries(sqlds.DBConn, from, to)
+}
+
+func (sqlds *SqliteDataStore)DeleteSnapshotStats(camName string) error {
+    statsObj := new(sqlSnapshotStats)
+    statsObj.SnapshotStats = new(dataSet.SnapshotStats)
+    statsObj.CamName = camName
+    return statsObj.DeleteAllSnapshotStatsEntries(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)AddEvent(event *dataSet.Event) error {
+    eventObj := new(sqlEvent)
+    eventObj.Event = event
+    return eventObj.InsertEventEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)GetEvents(camName string, from int64,
+                                    to int64) ([]dataSet.Event, error) {
+    eventObj := new(sqlEvent)
+    eventObj.Event = new(dataSet.Event)
+    eventObj.CamName = camName
+    return eventObj.GetEventEntries(sqlds.DBConn, from, to)
+}
+
+func (sqlds *SqliteDataStore)DeleteEvents(camName string) error {
+    eventObj := new(sqlEvent)
+    eventObj.Event = new(dataSet.Event)
+    eventObj.CamName = camName
+    return eventObj.DeleteAllEventEntries(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)UpdateJob(job *dataSet.Job) error {
+    jobObj := new(sqlJob)
+    jobObj.Job = job
+    return jobObj.InsertJobEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)GetJob(jobId string) (*dataSet.Job, error) {
+    jobObj := new(sqlJob)
+    jobObj.Job = new(dataSet.Job)
+    jobObj.Id = jobId
+    return jobObj.GetJobEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)GetAllJobs() ([]dataSet.Job, error) {
+    jobObj := new(sqlJob)
+    jobObj.Job = new(dataSet.Job)
+    return jobObj.GetAllJobEntries(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)DeleteJob(jobId string) error {
+    jobObj := new(sqlJob)
+    jobObj.Job = new(dataSet.Job)
+    jobObj.Id = jobId
+    return jobObj.DeleteJobEntry(sqlds.DBConn)
+}
+
+func (sqlds *SqliteDataStore)DeleteFinishedJobs(before int64) error {
+    jobObj := new(sqlJob)
+    jobObj.Job = new(dataSet.Job)
+    return jobObj.DeleteFinishedJobEntries(sqlds.DBConn, before)
+}
+
 // Only one SQL datastore object can be present in the system as connection
 //pool can be handled in side the database connection itself
 func GetsqliteDataStoreObj() *SqliteDataStore {
@@ -0,0 +1,179 @@
+package sqlite
+
+import (
+    "fmt"
+    "github.com/jmoiron/sqlx"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+//Field names are lower case of the Job struct field names.
+const (
+    JOB_TABLE = "job"
+    JOB_FIELD_ID = "id"
+    JOB_FIELD_TYPE = "type"
+    JOB_FIELD_CAMNAME = "camname"
+    JOB_FIELD_STATE = "state"
+    JOB_FIELD_PARAMS = "params"
+    JOB_FIELD_PROGRESS = "progress"
+    JOB_FIELD_ATTEMPTS = "attempts"
+    JOB_FIELD_MAXATTEMPTS = "maxattempts"
+    JOB_FIELD_ERROR = "error"
+    JOB_FIELD_CREATETIME = "createtime"
+    JOB_FIELD_STARTTIME = "starttime"
+    JOB_FIELD_ENDTIME = "endtime"
+)
+
+var (
+    jobSchema = fmt.Sprintf(
+                `CREATE TABLE IF NOT EXISTS %s (%s TEXT PRIMARY KEY,
+                 %s TEXT NOT NULL,
+                 %s TEXT,
+                 %s TEXT NOT NULL,
+                 %s TEXT,
+                 %s REAL DEFAULT 0,
+                 %s INTEGER DEFAULT 0,
+                 %s INTEGER DEFAULT 0,
+                 %s TEXT,
+                 %s INTEGER DEFAULT 0,
+                 %s INTEGER DEFAULT 0,
+                 %s INTEGER DEFAULT 0)`,
+                 JOB_TABLE,
+                 JOB_FIELD_ID,
+                 JOB_FIELD_TYPE,
+                 JOB_FIELD_CAMNAME,
+                 JOB_FIELD_STATE,
+                 JOB_FIELD_PARAMS,
+                 JOB_FIELD_PROGRESS,
+                 JOB_FIELD_ATTEMPTS,
+                 JOB_FIELD_MAXATTEMPTS,
+                 JOB_FIELD_ERROR,
+                 JOB_FIELD_CREATETIME,
+                 JOB_FIELD_STARTTIME,
+                 JOB_FIELD_ENDTIME)
+    //Every state change of a job replaces the entry.
+    jobCreate = fmt.Sprintf(`INSERT OR REPLACE INTO %s
+                             (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s)
+                             VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+                             JOB_TABLE,
+                             JOB_FIELD_ID,
+                             JOB_FIELD_TYPE,
+                             JOB_FIELD_CAMNAME,
+                             JOB_FIELD_STATE,
+                             JOB_FIELD_PARAMS,
+                             JOB_FIELD_PROGRESS,
+                             JOB_FIELD_ATTEMPTS,
+                             JOB_FIELD_MAXATTEMPTS,
+                             JOB_FIELD_ERROR,
+                             JOB_FIELD_CREATETIME,
+                             JOB_FIELD_STARTTIME,
+                             JOB_FIELD_ENDTIME)
+    jobGet = fmt.Sprintf("SELECT * FROM %s WHERE %s=(?)",
+                         JOB_TABLE,
+                         JOB_FIELD_ID)
+    jobGetAll = fmt.Sprintf("SELECT * FROM %s ORDER BY %s, %s",
+                            JOB_TABLE,
+                            JOB_FIELD_CREATETIME,
+                            JOB_FIELD_ID)
+    jobDelete = fmt.Sprintf("DELETE FROM %s WHERE %s=(?)",
+                            JOB_TABLE,
+                            JOB_FIELD_ID)
+    //Only the finished jobs are deleted by their end time.
+    jobDeleteFinished = fmt.Sprintf(`DELETE FROM %s WHERE %s IN (?, ?, ?)
+                                     AND %s < (?)`,
+                                     JOB_TABLE,
+                                     JOB_FIELD_STATE,
+                                     JOB_FIELD_ENDTIME)
+)
+
+// Anonymous pointer to job struct, same as sqlCamera.
+type sqlJob struct {
+    *dataSet.Job
+}
+
+func(jobObj *sqlJob)CreateJobTable(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(jobSchema)
+    if err != nil {
+        log.Error("Failed to create job table %s", err)
+        return err
+    }
+    log.Trace("Table %s created successfully", JOB_TABLE)
+    return nil
+}
+
+func(jobObj *sqlJob)InsertJobEntry(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    if len(jobObj.Id) == 0 || len(jobObj.Type) == 0 {
+        log.Error("Cannot create job entry with empty id/type")
+        return appErrors.INVALID_INPUT
+    }
+    if !dataSet.IsJobStateValid(jobObj.State) {
+        log.Error("Cannot create job entry %s, Invalid state %s",
+                    jobObj.Id, jobObj.State)
+        return appErrors.INVALID_INPUT
+    }
+    _, err = conn.Exec(jobCreate, jobObj.Id, jobObj.Type, jobObj.CamName,
+                        jobObj.State, jobObj.Params, jobObj.Progress,
+                        jobObj.Attempts, jobObj.MaxAttempts, jobObj.Error,
+                        jobObj.CreateTime, jobObj.StartTime, jobObj.EndTime)
+    if err != nil {
+        log.Error("Failed to create the job record %s, err :%s",
+                            jobObj.Id, err)
+        return err
+    }
+    return nil
+}
+
+func(jobObj *sqlJob)GetJobEntry(conn *sqlx.DB) (*dataSet.Job, error) {
+    var err error
+    log := logging.GetLoggerInstance()
+    rows := []dataSet.Job{}
+    err = conn.Select(&rows, jobGet, jobObj.Id)
+    if err != nil {
+        log.Error("Failed to get the job row for %s", jobObj.Id)
+        return nil, err
+    }
+    if len(rows) == 0 {
+        return nil, appErrors.DATA_NOT_FOUND
+    }
+    return &rows[0], nil
+}
+
+func(jobObj *sqlJob)GetAllJobEntries(conn *sqlx.DB) ([]dataSet.Job, error) {
+    var err error
+    log := logging.GetLoggerInstance()
+    rows := []dataSet.Job{}
+    err = conn.Select(&rows, jobGetAll)
+    if err != nil {
+        log.Error("Failed to get the job rows, err: %s", err)
+    }
+    return rows, err
+}
+
+func(jobObj *sqlJob)DeleteJobEntry(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(jobDelete, jobObj.Id)
+    if err != nil {
+        log.Error("Failed to delete job entry err: %s", err)
+        return err
+    }
+    return nil
+}
+
+func(jobObj *sqlJob)DeleteFinishedJobEntries(conn *sqlx.DB,
+                                             before int64) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(jobDeleteFinished, dataSet.JOB_SUCCEEDED,
+                        dataSet.JOB_FAILED, dataSet.JOB_CANCELLED, before)
+    if err != nil {
+        log.Error("Failed to delete finished job entries err: %s", err)
+        return err
+    }
+    return nil
+}
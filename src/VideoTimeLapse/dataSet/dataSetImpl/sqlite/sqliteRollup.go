@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+    "fmt"
+    "github.com/jmoiron/sqlx"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+//Field names are lower case of the CameraRollup struct field names.
+const (
+    ROLLUP_TABLE = "camera_rollup"
+    ROLLUP_FIELD_CAMNAME = "camname"
+    ROLLUP_FIELD_NAME = "name"
+    ROLLUP_FIELD_PERIOD = "period"
+    ROLLUP_FIELD_SOURCE = "source"
+    ROLLUP_FIELD_RUNAT = "runat"
+    ROLLUP_FIELD_SPEEDFACTOR = "speedfactor"
+    ROLLUP_FIELD_PROJECTSTART = "projectstart"
+    ROLLUP_FIELD_LASTRUN = "lastrun"
+)
+
+var (
+    //Rollup name is unique only for a camera.
+    rollupSchema = fmt.Sprintf(
+                `CREATE TABLE IF NOT EXISTS %s (%s TEXT NOT NULL,
+                 %s TEXT NOT NULL,
+                 %s TEXT NOT NULL,
+                 %s TEXT NOT NULL,
+                 %s TEXT NOT NULL,
+                 %s INTEGER DEFAULT 1,
+                 %s INTEGER DEFAULT 0,
+                 %s INTEGER DEFAULT 0,
+                 PRIMARY KEY (%s, %s))`,
+                 ROLLUP_TABLE,
+                 ROLLUP_FIELD_CAMNAME,
+                 ROLLUP_FIELD_NAME,
+                 ROLLUP_FIELD_PERIOD,
+                 ROLLUP_FIELD_SOURCE,
+                 ROLLUP_FIELD_RUNAT,
+                 ROLLUP_FIELD_SPEEDFACTOR,
+                 ROLLUP_FIELD_PROJECTSTART,
+                 ROLLUP_FIELD_LASTRUN,
+                 ROLLUP_FIELD_CAMNAME, ROLLUP_FIELD_NAME)
+    rollupCreate = fmt.Sprintf(`INSERT OR REPLACE INTO %s
+                                (%s, %s, %s, %s, %s, %s, %s, %s)
+                                VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+                                ROLLUP_TABLE,
+                                ROLLUP_FIELD_CAMNAME,
+                                ROLLUP_FIELD_NAME,
+                                ROLLUP_FIELD_PERIOD,
+                                ROLLUP_FIELD_SOURCE,
+                                ROLLUP_FIELD_RUNAT,
+                                ROLLUP_FIELD_SPEEDFACTOR,
+                                ROLLUP_FIELD_PROJECTSTART,
+                                ROLLUP_FIELD_LASTRUN)
+    rollupGet = fmt.Sprintf("SELECT * FROM %s WHERE %s=(?) AND %s=(?)",
+                            ROLLUP_TABLE,
+                            ROLLUP_FIELD_CAMNAME,
+                            ROLLUP_FIELD_NAME)
+    rollupGetAll = fmt.Sprintf("SELECT * FROM %s WHERE %s=(?) ORDER BY %s",
+                               ROLLUP_TABLE,
+                               ROLLUP_FIELD_CAMNAME,
+                               ROLLUP_FIELD_NAME)
+    rollupDelete = fmt.Sprintf("DELETE FROM %s WHERE %s=(?) AND %s=(?)",
+                               ROLLUP_TABLE,
+                               ROLLUP_FIELD_CAMNAME,
+                               ROLLUP_FIELD_NAME)
+    rollupDeleteAll = fmt.Sprintf("DELETE FROM %s WHERE %s=(?)",
+                                  ROLLUP_TABLE,
+                                  ROLLUP_FIELD_CAMNAME)
+)
+
+// Anonymous pointer to rollup struct, same as sqlCamera.
+type sqlRollup struct {
+    *dataSet.CameraRollup
+}
+
+func(rollupObj *sqlRollup)CreateRollupTable(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(rollupSchema)
+    if err != nil {
+        log.Error("Failed to create rollup table %s", err)
+        return err
+    }
+    log.Trace("Table %s created successfully", ROLLUP_TABLE)
+    return nil
+}
+
+func(rollupObj *sqlRollup)InsertRollupEntry(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    if len(rollupObj.CamName) == 0 || !rollupObj.IsNameValid() {
+        log.Error("Cannot create rollup with empty camera name/invalid name")
+        return appErrors.INVALID_INPUT
+    }
+    if !dataSet.IsRollupPeriodValid(rollupObj.Period) ||
+        !rollupObj.IsSourceValid() || !rollupObj.IsRunAtValid() ||
+        !rollupObj.IsSpeedFactorValid() {
+        log.Error("Cannot create rollup %s, Invalid parameters",
+                    rollupObj.Name)
+        return appErrors.INVALID_INPUT
+    }
+    _, err = conn.Exec(rollupCreate, rollupObj.CamName, rollupObj.Name,
+                        rollupObj.Period, rollupObj.Source, rollupObj.RunAt,
+                        rollupObj.SpeedFactor, rollupObj.ProjectStart,
+                        rollupObj.LastRun)
+    if err != nil {
+        log.Error("Failed to create the rollup record %s, err :%s",
+                            rollupObj.Name, err)
+        return err
+    }
+    return nil
+}
+
+func(rollupObj *sqlRollup)GetRollupEntry(conn *sqlx.DB) (
+                                    *dataSet.CameraRollup, error) {
+    var err error
+    log := logging.GetLoggerInstance()
+    rows := []dataSet.CameraRollup{}
+    err = conn.Select(&rows, rollupGet, rollupObj.CamName, rollupObj.Name)
+    if err != nil {
+        log.Error("Failed to get the rollup row for %s", rollupObj.Name)
+        return nil, err
+    }
+    if len(rows) == 0 {
+        return nil, appErrors.DATA_NOT_FOUND
+    }
+    return &rows[0], nil
+}
+
+func(rollupObj *sqlRollup)GetAllRollupEntries(conn *sqlx.DB) (
+                                    []dataSet.CameraRollup, error) {
+    var err error
+    log := logging.GetLoggerInstance()
+    rows := []dataSet.CameraRollup{}
+    err = conn.Select(&rows, rollupGetAll, rollupObj.CamName)
+    if err != nil {
+        log.Error("Failed to get the rollup rows for %s", rollupObj.CamName)
+    }
+    return rows, err
+}
+
+func(rollupObj *sqlRollup)DeleteRollupEntry(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(rollupDelete, rollupObj.CamName, rollupObj.Name)
+    if err != nil {
+        log.Error("Failed to delete rollup entry err: %s", err)
+        return err
+    }
+    return nil
+}
+
+//Delete all the rollups of the camera.
+func(rollupObj *sqlRollup)DeleteAllRollupEntries(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(rollupDeleteAll, rollupObj.CamName)
+    if err != nil {
+        log.Error("Failed to delete rollup entries err: %s", err)
+        return err
+    }
+    return nil
+}
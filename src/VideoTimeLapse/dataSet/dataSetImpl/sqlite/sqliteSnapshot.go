@@ -0,0 +1,119 @@
+package sqlite
+
+import (
+    "fmt"
+    "github.com/jmoiron/sqlx"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+//Field names are lower case of the Snapshot struct field names.
+const (
+    SNAPSHOT_TABLE = "snapshot"
+    SNAPSHOT_FIELD_CAMNAME = "camname"
+    SNAPSHOT_FIELD_CYCLEDIR = "cycledir"
+    SNAPSHOT_FIELD_FILE = "file"
+    SNAPSHOT_FIELD_CAPTURETIME = "capturetime"
+    SNAPSHOT_FIELD_CHANGESCORE = "changescore"
+)
+
+var (
+    snapshotSchema = fmt.Sprintf(
+                `CREATE TABLE IF NOT EXISTS %s (%s TEXT NOT NULL,
+                 %s TEXT NOT NULL,
+                 %s TEXT NOT NULL,
+                 %s INTEGER NOT NULL,
+                 %s REAL DEFAULT %d,
+                 PRIMARY KEY (%s, %s, %s))`,
+                 SNAPSHOT_TABLE,
+                 SNAPSHOT_FIELD_CAMNAME,
+                 SNAPSHOT_FIELD_CYCLEDIR,
+                 SNAPSHOT_FIELD_FILE,
+                 SNAPSHOT_FIELD_CAPTURETIME,
+                 SNAPSHOT_FIELD_CHANGESCORE, dataSet.SNAPSHOT_NO_CHANGE_SCORE,
+                 SNAPSHOT_FIELD_CAMNAME, SNAPSHOT_FIELD_CYCLEDIR,
+                 SNAPSHOT_FIELD_FILE)
+    snapshotCreate = fmt.Sprintf(`INSERT OR REPLACE INTO %s
+                                  (%s, %s, %s, %s, %s)
+                                  VALUES (?, ?, ?, ?, ?)`,
+                                  SNAPSHOT_TABLE,
+                                  SNAPSHOT_FIELD_CAMNAME,
+                                  SNAPSHOT_FIELD_CYCLEDIR,
+                                  SNAPSHOT_FIELD_FILE,
+                                  SNAPSHOT_FIELD_CAPTURETIME,
+                                  SNAPSHOT_FIELD_CHANGESCORE)
+    snapshotGetRange = fmt.Sprintf(`SELECT * FROM %s WHERE %s=(?) AND
+                                    %s>=(?) AND %s<=(?) ORDER BY %s`,
+                                    SNAPSHOT_TABLE,
+                                    SNAPSHOT_FIELD_CAMNAME,
+                                    SNAPSHOT_FIELD_CAPTURETIME,
+                                    SNAPSHOT_FIELD_CAPTURETIME,
+                                    SNAPSHOT_FIELD_CAPTURETIME)
+    snapshotDeleteAll = fmt.Sprintf("DELETE FROM %s WHERE %s=(?)",
+                                    SNAPSHOT_TABLE,
+                                    SNAPSHOT_FIELD_CAMNAME)
+)
+
+// Anonymous pointer to snapshot struct, same as sqlCamera.
+type sqlSnapshot struct {
+    *dataSet.Snapshot
+}
+
+func(snapshotObj *sqlSnapshot)CreateSnapshotTable(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(snapshotSchema)
+    if err != nil {
+        log.Error("Failed to create snapshot table %s", err)
+        return err
+    }
+    log.Trace("Table %s created successfully", SNAPSHOT_TABLE)
+    return nil
+}
+
+func(snapshotObj *sqlSnapshot)InsertSnapshotEntry(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    if len(snapshotObj.CamName) == 0 || len(snapshotObj.CycleDir) == 0 ||
+        len(snapshotObj.File) == 0 {
+        log.Error("Cannot create snapshot with empty camera/cycle/file name")
+        return appErrors.INVALID_INPUT
+    }
+    _, err = conn.Exec(snapshotCreate, snapshotObj.CamName,
+                        snapshotObj.CycleDir, snapshotObj.File,
+                        snapshotObj.CaptureTime, snapshotObj.ChangeScore)
+    if err != nil {
+        log.Error("Failed to create the snapshot record %s, err :%s",
+                            snapshotObj.File, err)
+        return err
+    }
+    return nil
+}
+
+//Return the snapshots of the camera captured between unix time 'from' and
+// 'to', in the order of the capture time.
+func(snapshotObj *sqlSnapshot)GetSnapshotEntries(conn *sqlx.DB, from int64,
+                                    to int64) ([]dataSet.Snapshot, error) {
+    var err error
+    log := logging.GetLoggerInstance()
+    rows := []dataSet.Snapshot{}
+    err = conn.Select(&rows, snapshotGetRange, snapshotObj.CamName, from, to)
+    if err != nil {
+        log.Error("Failed to get the snapshot rows for %s",
+                    snapshotObj.CamName)
+    }
+    return rows, err
+}
+
+//Delete all the snapshots of the camera.
+func(snapshotObj *sqlSnapshot)DeleteAllSnapshotEntries(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(snapshotDeleteAll, snapshotObj.CamName)
+    if err != nil {
+        log.Error("Failed to delete snapshot entries err: %s", err)
+        return err
+    }
+    return nil
+}
@@ -0,0 +1,164 @@
+package sqlite
+
+import (
+    "fmt"
+    "github.com/jmoiron/sqlx"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+//Field names are lower case of the SnapshotStats struct field names.
+const (
+    SNAPSHOT_STATS_TABLE = "snapshot_stats"
+    SNAPSHOT_STATS_FIELD_CAMNAME = "camname"
+    SNAPSHOT_STATS_FIELD_CAPTURETIME = "capturetime"
+    SNAPSHOT_STATS_FIELD_STATUS = "status"
+    SNAPSHOT_STATS_FIELD_CYCLEDIR = "cycledir"
+    SNAPSHOT_STATS_FIELD_FILE = "file"
+    SNAPSHOT_STATS_FIELD_MEANLUMA = "meanluma"
+    SNAPSHOT_STATS_FIELD_LUMADEVIATION = "lumadeviation"
+    SNAPSHOT_STATS_FIELD_LUMALOW = "lumalow"
+    SNAPSHOT_STATS_FIELD_LUMAMEDIAN = "lumamedian"
+    SNAPSHOT_STATS_FIELD_LUMAHIGH = "lumahigh"
+    SNAPSHOT_STATS_FIELD_CHANGESCORE = "changescore"
+    SNAPSHOT_STATS_FIELD_CAPTURELATENCY = "capturelatency"
+    SNAPSHOT_STATS_FIELD_PACKETS = "packets"
+    SNAPSHOT_STATS_FIELD_KEYFRAMEOFFSET = "keyframeoffset"
+)
+
+var (
+    snapshotStatsSchema = fmt.Sprintf(
+                `CREATE TABLE IF NOT EXISTS %s (%s TEXT NOT NULL,
+                 %s INTEGER NOT NULL,
+                 %s TEXT NOT NULL,
+                 %s TEXT DEFAULT '',
+                 %s TEXT DEFAULT '',
+                 %s REAL DEFAULT %d,
+                 %s REAL DEFAULT %d,
+                 %s INTEGER DEFAULT %d,
+                 %s INTEGER DEFAULT %d,
+                 %s INTEGER DEFAULT %d,
+                 %s REAL DEFAULT %d,
+                 %s INTEGER DEFAULT 0,
+                 %s INTEGER DEFAULT 0,
+                 %s INTEGER DEFAULT -1,
+                 PRIMARY KEY (%s, %s))`,
+                 SNAPSHOT_STATS_TABLE,
+                 SNAPSHOT_STATS_FIELD_CAMNAME,
+                 SNAPSHOT_STATS_FIELD_CAPTURETIME,
+                 SNAPSHOT_STATS_FIELD_STATUS,
+                 SNAPSHOT_STATS_FIELD_CYCLEDIR,
+                 SNAPSHOT_STATS_FIELD_FILE,
+                 SNAPSHOT_STATS_FIELD_MEANLUMA, dataSet.SNAPSHOT_NO_LUMA,
+                 SNAPSHOT_STATS_FIELD_LUMADEVIATION, dataSet.SNAPSHOT_NO_LUMA,
+                 SNAPSHOT_STATS_FIELD_LUMALOW, dataSet.SNAPSHOT_NO_LUMA,
+                 SNAPSHOT_STATS_FIELD_LUMAMEDIAN, dataSet.SNAPSHOT_NO_LUMA,
+                 SNAPSHOT_STATS_FIELD_LUMAHIGH, dataSet.SNAPSHOT_NO_LUMA,
+                 SNAPSHOT_STATS_FIELD_CHANGESCORE,
+                 dataSet.SNAPSHOT_NO_CHANGE_SCORE,
+                 SNAPSHOT_STATS_FIELD_CAPTURELATENCY,
+                 SNAPSHOT_STATS_FIELD_PACKETS,
+                 SNAPSHOT_STATS_FIELD_KEYFRAMEOFFSET,
+                 SNAPSHOT_STATS_FIELD_CAMNAME,
+                 SNAPSHOT_STATS_FIELD_CAPTURETIME)
+    snapshotStatsCreate = fmt.Sprintf(`INSERT OR REPLACE INTO %s
+                            (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s,
+                             %s, %s)
+                            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+                            SNAPSHOT_STATS_TABLE,
+                            SNAPSHOT_STATS_FIELD_CAMNAME,
+                            SNAPSHOT_STATS_FIELD_CAPTURETIME,
+                            SNAPSHOT_STATS_FIELD_STATUS,
+                            SNAPSHOT_STATS_FIELD_CYCLEDIR,
+                            SNAPSHOT_STATS_FIELD_FILE,
+                            SNAPSHOT_STATS_FIELD_MEANLUMA,
+                            SNAPSHOT_STATS_FIELD_LUMADEVIATION,
+                            SNAPSHOT_STATS_FIELD_LUMALOW,
+                            SNAPSHOT_STATS_FIELD_LUMAMEDIAN,
+                            SNAPSHOT_STATS_FIELD_LUMAHIGH,
+                            SNAPSHOT_STATS_FIELD_CHANGESCORE,
+                            SNAPSHOT_STATS_FIELD_CAPTURELATENCY,
+                            SNAPSHOT_STATS_FIELD_PACKETS,
+                            SNAPSHOT_STATS_FIELD_KEYFRAMEOFFSET)
+    snapshotStatsGetRange = fmt.Sprintf(`SELECT * FROM %s WHERE %s=(?) AND
+                                         %s>=(?) AND %s<=(?) ORDER BY %s`,
+                                         SNAPSHOT_STATS_TABLE,
+                                         SNAPSHOT_STATS_FIELD_CAMNAME,
+                                         SNAPSHOT_STATS_FIELD_CAPTURETIME,
+                                         SNAPSHOT_STATS_FIELD_CAPTURETIME,
+                                         SNAPSHOT_STATS_FIELD_CAPTURETIME)
+    snapshotStatsDeleteAll = fmt.Sprintf("DELETE FROM %s WHERE %s=(?)",
+                                         SNAPSHOT_STATS_TABLE,
+                                         SNAPSHOT_STATS_FIELD_CAMNAME)
+)
+
+// Anonymous pointer to snapshot stats struct, same as sqlCamera.
+type sqlSnapshotStats struct {
+    *dataSet.SnapshotStats
+}
+
+func(statsObj *sqlSnapshotStats)CreateSnapshotStatsTable(
+                                    conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(snapshotStatsSchema)
+    if err != nil {
+        log.Error("Failed to create snapshot stats table %s", err)
+        return err
+    }
+    log.Trace("Table %s created successfully", SNAPSHOT_STATS_TABLE)
+    return nil
+}
+
+func(statsObj *sqlSnapshotStats)InsertSnapshotStatsEntry(
+                                    conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    if len(statsObj.CamName) == 0 || len(statsObj.Status) == 0 {
+        log.Error("Cannot create snapshot stats with empty camera/status")
+        return appErrors.INVALID_INPUT
+    }
+    _, err = conn.Exec(snapshotStatsCreate, statsObj.CamName,
+                        statsObj.CaptureTime, statsObj.Status,
+                        statsObj.CycleDir, statsObj.File, statsObj.MeanLuma,
+                        statsObj.LumaDeviation, statsObj.LumaLow,
+                        statsObj.LumaMedian, statsObj.LumaHigh,
+                        statsObj.ChangeScore, statsObj.CaptureLatency,
+                        statsObj.Packets, statsObj.KeyframeOffset)
+    if err != nil {
+        log.Error("Failed to create the snapshot stats record of %s, err :%s",
+                            statsObj.CamName, err)
+        return err
+    }
+    return nil
+}
+
+//Return the stats of the camera captures started between unix time 'from'
+// and 'to', in the order of the capture time.
+func(statsObj *sqlSnapshotStats)GetSnapshotStatsEntries(conn *sqlx.DB,
+                                    from int64, to int64) (
+                                    []dataSet.SnapshotStats, error) {
+    var err error
+    log := logging.GetLoggerInstance()
+    rows := []dataSet.SnapshotStats{}
+    err = conn.Select(&rows, snapshotStatsGetRange, statsObj.CamName, from, to)
+    if err != nil {
+        log.Error("Failed to get the snapshot stats rows for %s",
+                    statsObj.CamName)
+    }
+    return rows, err
+}
+
+//Delete all the snapshot stats of the camera.
+func(statsObj *sqlSnapshotStats)DeleteAllSnapshotStatsEntries(
+                                    conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(snapshotStatsDeleteAll, statsObj.CamName)
+    if err != nil {
+        log.Error("Failed to delete snapshot stats entries err: %s", err)
+        return err
+    }
+    return nil
+}
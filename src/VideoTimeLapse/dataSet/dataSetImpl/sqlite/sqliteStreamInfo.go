@@ -0,0 +1,130 @@
+package sqlite
+
+import (
+    "fmt"
+    "github.com/jmoiron/sqlx"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+//Field names are lower case of the CameraStreamInfo struct field names.
+const (
+    STREAMINFO_TABLE = "camera_stream_info"
+    STREAMINFO_FIELD_CAMNAME = "camname"
+    STREAMINFO_FIELD_CODEC = "codec"
+    STREAMINFO_FIELD_PROFILE = "profile"
+    STREAMINFO_FIELD_WIDTH = "width"
+    STREAMINFO_FIELD_HEIGHT = "height"
+    STREAMINFO_FIELD_FPS = "fps"
+    STREAMINFO_FIELD_BITRATE = "bitrate"
+    STREAMINFO_FIELD_HASAUDIO = "hasaudio"
+    STREAMINFO_FIELD_UPDATETIME = "updatetime"
+)
+
+var (
+    streamInfoSchema = fmt.Sprintf(
+                `CREATE TABLE IF NOT EXISTS %s (%s TEXT PRIMARY KEY,
+                 %s TEXT,
+                 %s TEXT,
+                 %s INTEGER DEFAULT 0,
+                 %s INTEGER DEFAULT 0,
+                 %s REAL DEFAULT 0,
+                 %s INTEGER DEFAULT 0,
+                 %s BOOLEAN DEFAULT 0,
+                 %s INTEGER DEFAULT 0)`,
+                 STREAMINFO_TABLE,
+                 STREAMINFO_FIELD_CAMNAME,
+                 STREAMINFO_FIELD_CODEC,
+                 STREAMINFO_FIELD_PROFILE,
+                 STREAMINFO_FIELD_WIDTH,
+                 STREAMINFO_FIELD_HEIGHT,
+                 STREAMINFO_FIELD_FPS,
+                 STREAMINFO_FIELD_BITRATE,
+                 STREAMINFO_FIELD_HASAUDIO,
+                 STREAMINFO_FIELD_UPDATETIME)
+    //A camera has only one entry, replaced on every change.
+    streamInfoCreate = fmt.Sprintf(`INSERT OR REPLACE INTO %s
+                                    (%s, %s, %s, %s, %s, %s, %s, %s, %s)
+                                    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+                                    STREAMINFO_TABLE,
+                                    STREAMINFO_FIELD_CAMNAME,
+                                    STREAMINFO_FIELD_CODEC,
+                                    STREAMINFO_FIELD_PROFILE,
+                                    STREAMINFO_FIELD_WIDTH,
+                                    STREAMINFO_FIELD_HEIGHT,
+                                    STREAMINFO_FIELD_FPS,
+                                    STREAMINFO_FIELD_BITRATE,
+                                    STREAMINFO_FIELD_HASAUDIO,
+                                    STREAMINFO_FIELD_UPDATETIME)
+    streamInfoGet = fmt.Sprintf("SELECT * FROM %s WHERE %s=(?)",
+                                STREAMINFO_TABLE,
+                                STREAMINFO_FIELD_CAMNAME)
+    streamInfoDelete = fmt.Sprintf("DELETE FROM %s WHERE %s=(?)",
+                                   STREAMINFO_TABLE,
+                                   STREAMINFO_FIELD_CAMNAME)
+)
+
+// Anonymous pointer to stream info struct, same as sqlCamera.
+type sqlStreamInfo struct {
+    *dataSet.CameraStreamInfo
+}
+
+func(streamObj *sqlStreamInfo)CreateStreamInfoTable(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(streamInfoSchema)
+    if err != nil {
+        log.Error("Failed to create stream info table %s", err)
+        return err
+    }
+    log.Trace("Table %s created successfully", STREAMINFO_TABLE)
+    return nil
+}
+
+func(streamObj *sqlStreamInfo)InsertStreamInfoEntry(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    if len(streamObj.CamName) == 0 {
+        log.Error("Cannot create stream info entry with empty camera name")
+        return appErrors.INVALID_INPUT
+    }
+    _, err = conn.Exec(streamInfoCreate, streamObj.CamName, streamObj.Codec,
+                        streamObj.Profile, streamObj.Width, streamObj.Height,
+                        streamObj.FPS, streamObj.Bitrate, streamObj.HasAudio,
+                        streamObj.UpdateTime)
+    if err != nil {
+        log.Error("Failed to create the stream info record %s, err :%s",
+                            streamObj.CamName, err)
+        return err
+    }
+    return nil
+}
+
+func(streamObj *sqlStreamInfo)GetStreamInfoEntry(conn *sqlx.DB) (
+                                    *dataSet.CameraStreamInfo, error) {
+    var err error
+    log := logging.GetLoggerInstance()
+    rows := []dataSet.CameraStreamInfo{}
+    err = conn.Select(&rows, streamInfoGet, streamObj.CamName)
+    if err != nil {
+        log.Error("Failed to get the stream info row for %s",
+                    streamObj.CamName)
+        return nil, err
+    }
+    if len(rows) == 0 {
+        return nil, appErrors.DATA_NOT_FOUND
+    }
+    return &rows[0], nil
+}
+
+func(streamObj *sqlStreamInfo)DeleteStreamInfoEntry(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(streamInfoDelete, streamObj.CamName)
+    if err != nil {
+        log.Error("Failed to delete stream info entry err: %s", err)
+        return err
+    }
+    return nil
+}
@@ -0,0 +1,170 @@
+package sqlite
+
+import (
+    "fmt"
+    "github.com/jmoiron/sqlx"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+//Field names follow the same convention as camera table, i.e lower case of
+// the Video struct field names.
+const (
+    VIDEO_TABLE = "video"
+    VIDEO_FIELD_NAME = "name"
+    VIDEO_FIELD_CAMNAME = "camname"
+    VIDEO_FIELD_PATH = "path"
+    VIDEO_FIELD_FORMAT = "format"
+    VIDEO_FIELD_DESC = "description"
+    VIDEO_FIELD_STARTTIME = "starttime"
+    VIDEO_FIELD_ENDTIME = "endtime"
+    VIDEO_FIELD_SIZE = "size"
+    VIDEO_FIELD_POSTERPATH = "posterpath"
+    VIDEO_FIELD_THUMBCOUNT = "thumbcount"
+)
+
+//Columns added to the video table after the initial schema.
+var videoExtColumns = []sqlColumn{
+    {VIDEO_FIELD_POSTERPATH, "TEXT DEFAULT ''"},
+    {VIDEO_FIELD_THUMBCOUNT, "INTEGER DEFAULT 0"},
+}
+
+var (
+    //Video name is unique only for a camera.
+    videoSchema = fmt.Sprintf(
+                `CREATE TABLE IF NOT EXISTS %s (%s TEXT NOT NULL,
+                 %s TEXT NOT NULL,
+                 %s TEXT NOT NULL,
+                 %s TEXT,
+                 %s TEXT,
+                 %s INTEGER DEFAULT 0,
+                 %s INTEGER DEFAULT 0,
+                 %s INTEGER DEFAULT 0,
+                 PRIMARY KEY (%s, %s))`,
+                 VIDEO_TABLE,
+                 VIDEO_FIELD_NAME,
+                 VIDEO_FIELD_CAMNAME,
+                 VIDEO_FIELD_PATH,
+                 VIDEO_FIELD_FORMAT,
+                 VIDEO_FIELD_DESC,
+                 VIDEO_FIELD_STARTTIME,
+                 VIDEO_FIELD_ENDTIME,
+                 VIDEO_FIELD_SIZE,
+                 VIDEO_FIELD_CAMNAME, VIDEO_FIELD_NAME)
+    //Re-rendering a video replaces the existing entry.
+    videoCreate = fmt.Sprintf(`INSERT OR REPLACE INTO %s
+                               (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s)
+                               VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+                               VIDEO_TABLE,
+                               VIDEO_FIELD_NAME,
+                               VIDEO_FIELD_CAMNAME,
+                               VIDEO_FIELD_PATH,
+                               VIDEO_FIELD_FORMAT,
+                               VIDEO_FIELD_DESC,
+                               VIDEO_FIELD_STARTTIME,
+                               VIDEO_FIELD_ENDTIME,
+                               VIDEO_FIELD_SIZE,
+                               VIDEO_FIELD_POSTERPATH,
+                               VIDEO_FIELD_THUMBCOUNT)
+    videoGet = fmt.Sprintf("SELECT * FROM %s WHERE %s=(?) AND %s=(?)",
+                           VIDEO_TABLE,
+                           VIDEO_FIELD_CAMNAME,
+                           VIDEO_FIELD_NAME)
+    videoGetAll = fmt.Sprintf("SELECT * FROM %s WHERE %s=(?) ORDER BY %s",
+                              VIDEO_TABLE,
+                              VIDEO_FIELD_CAMNAME,
+                              VIDEO_FIELD_STARTTIME)
+    videoDelete = fmt.Sprintf("DELETE FROM %s WHERE %s=(?) AND %s=(?)",
+                              VIDEO_TABLE,
+                              VIDEO_FIELD_CAMNAME,
+                              VIDEO_FIELD_NAME)
+)
+
+// Anonymous pointer to video struct, same as sqlCamera.
+type sqlVideo struct {
+    *dataSet.Video
+}
+
+func(videoObj *sqlVideo)CreateVideoTable(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(videoSchema)
+    if err != nil {
+        log.Error("Failed to create Video table %s", err)
+        return err
+    }
+    err = addMissingColumns(conn, VIDEO_TABLE, videoExtColumns)
+    if err != nil {
+        log.Error("Failed to update Video table %s", err)
+        return err
+    }
+    log.Trace("Table %s created successfully", VIDEO_TABLE)
+    return nil
+}
+
+func(videoObj *sqlVideo)InsertVideoEntry(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    if len(videoObj.Name) == 0 || len(videoObj.CamName) == 0 ||
+        len(videoObj.Path) == 0 {
+        log.Error("Cannot create video entry with empty name/camera/path")
+        return appErrors.INVALID_INPUT
+    }
+    if !dataSet.IsVideoFormatValid(videoObj.Format) {
+        log.Error("Cannot create video entry %s, Invalid format %s",
+                    videoObj.Name, videoObj.Format)
+        return appErrors.INVALID_INPUT
+    }
+    _, err = conn.Exec(videoCreate, videoObj.Name, videoObj.CamName,
+                        videoObj.Path, videoObj.Format, videoObj.Description,
+                        videoObj.StartTime, videoObj.EndTime, videoObj.Size,
+                        videoObj.PosterPath, videoObj.ThumbCount)
+    if err != nil {
+        log.Error("Failed to create the video record %s, err :%s",
+                            videoObj.Name, err)
+        return err
+    }
+    return nil
+}
+
+func(videoObj *sqlVideo)GetVideoEntry(conn *sqlx.DB) (*dataSet.Video, error) {
+    var err error
+    log := logging.GetLoggerInstance()
+    rows := []dataSet.Video{}
+    err = conn.Select(&rows, videoGet, videoObj.CamName, videoObj.Name)
+    if err != nil {
+        log.Error("Failed to get the video row for %s", videoObj.Name)
+        return nil, err
+    }
+    if len(rows) > 1 {
+        return &rows[0], appErrors.DATA_NOT_UNIQUE_ERROR
+    }
+    if len(rows) == 0 {
+        return nil, appErrors.DATA_NOT_FOUND
+    }
+    return &rows[0], nil
+}
+
+func(videoObj *sqlVideo)GetAllVideoEntries(conn *sqlx.DB) ([]dataSet.Video,
+                                             error) {
+    var err error
+    log := logging.GetLoggerInstance()
+    rows := []dataSet.Video{}
+    err = conn.Select(&rows, videoGetAll, videoObj.CamName)
+    if err != nil {
+        log.Error("Failed to get the video rows for %s", videoObj.CamName)
+    }
+    return rows, err
+}
+
+func(videoObj *sqlVideo)DeleteVideoEntry(conn *sqlx.DB) error {
+    var err error
+    log := logging.GetLoggerInstance()
+    _, err = conn.Exec(videoDelete, videoObj.CamName, videoObj.Name)
+    if err != nil {
+        log.Error("Failed to delete video entry err: %s", err)
+        return err
+    }
+    return nil
+}
@@ -31,4 +31,67 @@ type DataSetInterface interface {
     UpdateCamera(camera *Camera) error
     GetCamera(cameraName string) (*Camera, error)
     GetAllCameras()([]Camera, error)
+
+    //APIs to interact with video catalog
+    AddNewVideo(video *Video) error
+    DeleteVideo(camName string, videoName string) error
+    GetVideo(camName string, videoName string) (*Video, error)
+    GetAllVideos(camName string) ([]Video, error)
+
+    //APIs to interact with the detected camera stream parameters
+    UpdateCameraStreamInfo(info *CameraStreamInfo) error
+    GetCameraStreamInfo(camName string) (*CameraStreamInfo, error)
+    DeleteCameraStreamInfo(camName string) error
+
+    //APIs to interact with the snapshot capture health of camera
+    UpdateCameraCaptureStatus(status *CameraCaptureStatus) error
+    GetCameraCaptureStatus(camName string) (*CameraCaptureStatus, error)
+    DeleteCameraCaptureStatus(camName string) error
+
+    //APIs to interact with the state of running timelapse cycle
+    UpdateCameraCycleState(state *CameraCycleState) error
+    GetCameraCycleState(camName string, profile string) (*CameraCycleState,
+                                                         error)
+    DeleteCameraCycleState(camName string) error
+
+    //APIs to interact with the aggregate timelapse definitions of camera
+    UpdateCameraRollup(rollup *CameraRollup) error
+    GetCameraRollup(camName string, rollupName string) (*CameraRollup, error)
+    GetCameraRollups(camName string) ([]CameraRollup, error)
+    DeleteCameraRollup(camName string, rollupName string) error
+
+    //APIs to interact with the composite timelapses of multiple cameras.
+    //Videos of a composite are in the video catalog under its name.
+    UpdateComposite(composite *Composite) error
+    GetComposite(compositeName string) (*Composite, error)
+    GetAllComposites() ([]Composite, error)
+    DeleteComposite(compositeName string) error
+
+    //APIs to interact with the metadata of the snapshots kept in cycles
+    AddSnapshot(snapshot *Snapshot) error
+    //Return the snapshots captured between unix time 'from' and 'to'.
+    GetSnapshots(camName string, from int64, to int64) ([]Snapshot, error)
+    DeleteSnapshots(camName string) error
+
+    //APIs to interact with the statistics of every snapshot capture
+    AddSnapshotStats(stats *SnapshotStats) error
+    //Return the stats of the captures started between unix time 'from' and
+    // 'to'.
+    GetSnapshotStats(camName string, from int64,
+                     to int64) ([]SnapshotStats, error)
+    DeleteSnapshotStats(camName string) error
+
+    //APIs to interact with the events raised by cameras
+    AddEvent(event *Event) error
+    //Return the events raised between unix time 'from' and 'to'.
+    GetEvents(camName string, from int64, to int64) ([]Event, error)
+    DeleteEvents(camName string) error
+
+    //APIs to interact with the background jobs
+    UpdateJob(job *Job) error
+    GetJob(jobId string) (*Job, error)
+    GetAllJobs() ([]Job, error)
+    DeleteJob(jobId string) error
+    //Delete the finished jobs that are ended before unix time 'before'.
+    DeleteFinishedJobs(before int64) error
 }
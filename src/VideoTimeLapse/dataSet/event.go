@@ -0,0 +1,33 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataSet
+
+//Types of the camera events.
+const (
+    //Camera is moved, covered or defocused.
+    EVENT_CAMERA_TAMPERED = "camera.tampered"
+)
+
+//Event raised by a camera. Events are kept until the camera is deleted.
+type Event struct {
+    Id       string `json:"Id"`
+    Type     string `json:"Type"`
+    CamName  string `json:"CamName"`
+    //Unix time the event is raised.
+    Time     int64  `json:"Time"`
+    Message  string `json:"Message"`
+    //Directory of the evidence files, empty if there are none.
+    Evidence string `json:"Evidence"`
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataSet
+
+//States of a background job.
+const (
+    JOB_QUEUED = "queued"
+    JOB_RUNNING = "running"
+    JOB_SUCCEEDED = "succeeded"
+    JOB_FAILED = "failed"
+    JOB_CANCELLED = "cancelled"
+)
+
+//Types of rendering work run as background jobs.
+const (
+    //Stitch the snapshots of a completed cycle.
+    JOB_TYPE_STITCH = "stitch"
+    //Sample the snapshots of a completed cycle from the recorded segments
+    // and stitch them.
+    JOB_TYPE_SAMPLE = "sample"
+    //Compact the stitched cycle and render its output formats.
+    JOB_TYPE_COMPACT = "compact"
+    //Render a past cycle with different output parameters.
+    JOB_TYPE_RERENDER = "rerender"
+    //Render the snapshots of the running cycle captured so far.
+    JOB_TYPE_EXPORT = "export"
+    //Build an aggregate timelapse of a camera.
+    JOB_TYPE_ROLLUP = "rollup"
+    //Render the overlapping cycles of cameras in a composite.
+    JOB_TYPE_COMPOSITE = "composite"
+)
+
+//Default number of times a failed job is run before giving up.
+const (
+    JOB_DEFAULT_MAX_ATTEMPTS = 3
+)
+
+//Structure to hold a background job. Jobs are persisted, so the queued and
+// interrupted jobs are run again after an application restart.
+type Job struct {
+    Id          string   `json:"Id"`
+    Type        string   `json:"Type"`
+    CamName     string   `json:"CamName"`
+    State       string   `json:"State"`
+    //Parameters of the job as json, specific to the job type.
+    Params      string   `json:"Params"`
+    //Completed percentage of the job.
+    Progress    float64  `json:"Progress"`
+    Attempts    uint64   `json:"Attempts"`
+    MaxAttempts uint64   `json:"MaxAttempts"`
+    //Error of the last failed attempt.
+    Error       string   `json:"Error"`
+    //Unix time the job is created, last started and finished.
+    CreateTime  int64    `json:"CreateTime"`
+    StartTime   int64    `json:"StartTime"`
+    EndTime     int64    `json:"EndTime"`
+}
+
+//Return true if the job will not be run anymore.
+func (job *Job) IsFinished() bool {
+    return job.State == JOB_SUCCEEDED || job.State == JOB_FAILED ||
+            job.State == JOB_CANCELLED
+}
+
+func IsJobStateValid(state string) bool {
+    switch state {
+    case JOB_QUEUED, JOB_RUNNING, JOB_SUCCEEDED, JOB_FAILED, JOB_CANCELLED:
+        return true
+    }
+    return false
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package dataSet
+
+import (
+    "math"
+    "encoding/json"
+)
+
+//Mode of the privacy mask region.
+const (
+    PRIVACY_MASK_BLACK = "black"
+    PRIVACY_MASK_PIXELATE = "pixelate"
+    PRIVACY_MASK_DEFAULT_MODE = PRIVACY_MASK_BLACK
+)
+
+const (
+    CAMERA_MAX_PRIVACY_MASKS = 16
+    PRIVACY_MASK_MIN_POINTS = 3
+    PRIVACY_MASK_MAX_POINTS = 64
+)
+
+//Point of the mask polygon in normalized coordinates of the frame. (0, 0) is
+// the top-left and (1, 1) is the bottom-right corner, so the mask holds for
+// any resolution of the camera stream.
+type MaskPoint struct {
+    X float64 `json:"X"`
+    Y float64 `json:"Y"`
+}
+
+//Polygon region of the camera frame hidden in every stored snapshot.
+type PrivacyMask struct {
+    //"black"/"pixelate", empty is black.
+    Mode   string      `json:"Mode"`
+    Points []MaskPoint `json:"Points"`
+}
+
+//Return the mode of the mask, the default when not set.
+func (mask *PrivacyMask) GetMode() string {
+    if len(mask.Mode) == 0 {
+        return PRIVACY_MASK_DEFAULT_MODE
+    }
+    return mask.Mode
+}
+
+func (mask *PrivacyMask) IsValid() bool {
+    if mask.GetMode() != PRIVACY_MASK_BLACK &&
+        mask.GetMode() != PRIVACY_MASK_PIXELATE {
+        return false
+    }
+    if len(mask.Points) < PRIVACY_MASK_MIN_POINTS ||
+        len(mask.Points) > PRIVACY_MASK_MAX_POINTS {
+        return false
+    }
+    for _, point := range mask.Points {
+        if math.IsNaN(point.X) || math.IsNaN(point.Y) ||
+            point.X < 0 || point.X > 1 || point.Y < 0 || point.Y > 1 {
+            return false
+        }
+    }
+    return true
+}
+
+//Return the masks in the form stored in the camera, empty for no masks.
+func EncodePrivacyMasks(masks []PrivacyMask) (string, error) {
+    if len(masks) == 0 {
+        return "", nil
+    }
+    data, err := json.Marshal(masks)
+    if err != nil {
+        return "", err
+    }
+    return string(data), nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataSet
+
+import (
+    "time"
+    "regexp"
+)
+
+//Periods of the aggregate timelapse videos.
+const (
+    ROLLUP_DAILY = "daily"
+    ROLLUP_WEEKLY = "weekly"
+    ROLLUP_MONTHLY = "monthly"
+    //Whole project till the last day, rebuilt every day.
+    ROLLUP_PROJECT = "project"
+)
+
+//Rollups built from the final timelapse videos of the camera cycles.
+const (
+    ROLLUP_SOURCE_CYCLE = "cycle"
+    //Time of the day the rollup is built after its period ends, "HH:MM".
+    ROLLUP_DEFAULT_RUN_AT = "00:05"
+    ROLLUP_RUN_AT_FORMAT = "15:04"
+)
+
+//Rollup names are used in the directory and video names.
+var rollupNameRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+//Definition of an aggregate timelapse of a camera. The rollup concatenates
+// the videos of its source in every period and speeds it up by its own speed
+// factor.
+type CameraRollup struct {
+    CamName      string  `json:"CamName"`
+    //Name of the rollup, unique for a camera.
+    Name         string  `json:"Name"`
+    Period       string  `json:"Period"`
+    //"cycle" or the name of another rollup of the camera.
+    Source       string  `json:"Source"`
+    RunAt        string  `json:"RunAt"`
+    SpeedFactor  uint64  `json:"SpeedFactor"`
+    //Unix time the project starts, only for the project rollup.
+    ProjectStart int64   `json:"ProjectStart"`
+    //Unix time the last built period ends.
+    LastRun      int64   `json:"LastRun"`
+}
+
+func IsRollupPeriodValid(period string) bool {
+    switch period {
+    case ROLLUP_DAILY, ROLLUP_WEEKLY, ROLLUP_MONTHLY, ROLLUP_PROJECT:
+        return true
+    }
+    return false
+}
+
+//Return the default source of the rollup period, daily → weekly → monthly →
+// project.
+func GetRollupDefaultSource(period string) string {
+    switch period {
+    case ROLLUP_WEEKLY:
+        return ROLLUP_DAILY
+    case ROLLUP_MONTHLY:
+        return ROLLUP_WEEKLY
+    case ROLLUP_PROJECT:
+        return ROLLUP_MONTHLY
+    }
+    return ROLLUP_SOURCE_CYCLE
+}
+
+func (rollup *CameraRollup) IsNameValid() bool {
+    return rollupNameRegex.MatchString(rollup.Name)
+}
+
+func (rollup *CameraRollup) IsSourceValid() bool {
+    if rollup.Source == ROLLUP_SOURCE_CYCLE {
+        return true
+    }
+    return rollupNameRegex.MatchString(rollup.Source) &&
+            rollup.Source != rollup.Name
+}
+
+func (rollup *CameraRollup) IsRunAtValid() bool {
+    _, err := time.Parse(ROLLUP_RUN_AT_FORMAT, rollup.RunAt)
+    return err == nil
+}
+
+func (rollup *CameraRollup) IsSpeedFactorValid() bool {
+    return rollup.SpeedFactor >= 1 &&
+            rollup.SpeedFactor <= CAMERA_MAX_SPEED_FACTOR
+}
+
+//Return the last period of the rollup that is completed at 'now', in the
+// location of 'now'.
+func (rollup *CameraRollup) GetLastPeriod(now time.Time) (time.Time,
+                                                          time.Time) {
+    today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0,
+                       now.Location())
+    switch rollup.Period {
+    case ROLLUP_WEEKLY:
+        //Weeks start on monday.
+        offset := (int(today.Weekday()) + 6) % 7
+        end := today.AddDate(0, 0, -offset)
+        return end.AddDate(0, 0, -7), end
+    case ROLLUP_MONTHLY:
+        end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0,
+                         now.Location())
+        return end.AddDate(0, -1, 0), end
+    case ROLLUP_PROJECT:
+        return time.Unix(rollup.ProjectStart, 0).In(now.Location()), today
+    }
+    return today.AddDate(0, 0, -1), today
+}
+
+//Return true and the period to build, if the rollup is due at 'now'.
+func (rollup *CameraRollup) GetDuePeriod(now time.Time) (time.Time,
+                                                         time.Time, bool) {
+    start, end := rollup.GetLastPeriod(now)
+    if end.Unix() <= rollup.LastRun {
+        return start, end, false
+    }
+    runAt, err := time.Parse(ROLLUP_RUN_AT_FORMAT, rollup.RunAt)
+    if err != nil {
+        return start, end, false
+    }
+    due := end.Add(time.Duration(runAt.Hour()) * time.Hour +
+                   time.Duration(runAt.Minute()) * time.Minute)
+    return start, end, !now.Before(due)
+}
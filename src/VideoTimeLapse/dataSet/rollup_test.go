@@ -0,0 +1,112 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataSet
+
+// Test file for validating the periods of the rollups.
+import (
+    "testing"
+    "time"
+)
+
+func TestRollupGetLastPeriod(t *testing.T) {
+    london, err := time.LoadLocation("Europe/London")
+    if err != nil {
+        t.Fatalf("Failed to load location, err: %s", err)
+    }
+    utcDate := func(year int, month time.Month, day int) time.Time {
+        return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+    }
+    //Wednesday.
+    now := time.Date(2024, 3, 13, 10, 30, 0, 0, time.UTC)
+    projectStart := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+    tests := []struct {
+        name string
+        rollup CameraRollup
+        now time.Time
+        start time.Time
+        end time.Time
+    }{
+        {"daily", CameraRollup{Period: ROLLUP_DAILY}, now,
+            utcDate(2024, 3, 12), utcDate(2024, 3, 13)},
+        {"daily at midnight", CameraRollup{Period: ROLLUP_DAILY},
+            utcDate(2024, 3, 1), utcDate(2024, 2, 29), utcDate(2024, 3, 1)},
+        {"weekly", CameraRollup{Period: ROLLUP_WEEKLY}, now,
+            utcDate(2024, 3, 4), utcDate(2024, 3, 11)},
+        {"weekly on monday", CameraRollup{Period: ROLLUP_WEEKLY},
+            utcDate(2024, 3, 11), utcDate(2024, 3, 4), utcDate(2024, 3, 11)},
+        {"weekly on sunday", CameraRollup{Period: ROLLUP_WEEKLY},
+            time.Date(2024, 3, 17, 23, 0, 0, 0, time.UTC),
+            utcDate(2024, 3, 4), utcDate(2024, 3, 11)},
+        {"weekly over dst change", CameraRollup{Period: ROLLUP_WEEKLY},
+            time.Date(2024, 4, 3, 10, 0, 0, 0, london),
+            time.Date(2024, 3, 25, 0, 0, 0, 0, london),
+            time.Date(2024, 4, 1, 0, 0, 0, 0, london)},
+        {"monthly", CameraRollup{Period: ROLLUP_MONTHLY}, now,
+            utcDate(2024, 2, 1), utcDate(2024, 3, 1)},
+        {"monthly in january", CameraRollup{Period: ROLLUP_MONTHLY},
+            utcDate(2024, 1, 20), utcDate(2023, 12, 1), utcDate(2024, 1, 1)},
+        {"project", CameraRollup{Period: ROLLUP_PROJECT,
+            ProjectStart: projectStart.Unix()}, now, projectStart,
+            utcDate(2024, 3, 13)},
+    }
+    for _, test := range tests {
+        start, end := test.rollup.GetLastPeriod(test.now)
+        if !start.Equal(test.start) || !end.Equal(test.end) {
+            t.Errorf("%s: got period %s - %s, expected %s - %s", test.name,
+                     start, end, test.start, test.end)
+        }
+    }
+}
+
+func TestRollupGetDuePeriod(t *testing.T) {
+    today := time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC)
+    daily := CameraRollup{Period: ROLLUP_DAILY,
+                          RunAt: ROLLUP_DEFAULT_RUN_AT}
+    built := daily
+    built.LastRun = today.Unix()
+    builtBefore := daily
+    builtBefore.LastRun = today.AddDate(0, 0, -1).Unix()
+    invalidRunAt := daily
+    invalidRunAt.RunAt = "25:00"
+    morning := daily
+    morning.RunAt = "06:30"
+    tests := []struct {
+        name string
+        rollup CameraRollup
+        now time.Time
+        expected bool
+    }{
+        {"before run at", daily, today.Add(4 * time.Minute), false},
+        {"at run at", daily, today.Add(5 * time.Minute), true},
+        {"after run at", daily, today.Add(10 * time.Hour), true},
+        {"period is built", built, today.Add(10 * time.Hour), false},
+        {"previous period is built", builtBefore,
+            today.Add(10 * time.Hour), true},
+        {"invalid run at", invalidRunAt, today.Add(10 * time.Hour), false},
+        {"before late run at", morning, today.Add(6 * time.Hour), false},
+        {"after late run at", morning, today.Add(7 * time.Hour), true},
+    }
+    for _, test := range tests {
+        start, end, due := test.rollup.GetDuePeriod(test.now)
+        if due != test.expected {
+            t.Errorf("%s: got due %v, expected %v", test.name, due,
+                     test.expected)
+        }
+        if !start.Equal(today.AddDate(0, 0, -1)) || !end.Equal(today) {
+            t.Errorf("%s: got period %s - %s, expected the day before %s",
+                     test.name, start, end, today)
+        }
+    }
+}
@@ -0,0 +1,32 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package dataSet
+
+//Change score of the snapshots that are not compared to a previous one, such
+// as the first snapshot of a cycle.
+const SNAPSHOT_NO_CHANGE_SCORE = -1
+
+//Metadata of a snapshot clip kept in a timelapse cycle.
+type Snapshot struct {
+    CamName     string  `json:"CamName"`
+    //Cycle directory and the file name of the snapshot clip.
+    CycleDir    string  `json:"CycleDir"`
+    File        string  `json:"File"`
+    //Unix time the snapshot is captured.
+    CaptureTime int64   `json:"CaptureTime"`
+    //Change of the scene from the previously kept snapshot in percent.
+    ChangeScore float64 `json:"ChangeScore"`
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package dataSet
+
+//Result of a snapshot capture.
+const (
+    SNAPSHOT_STATUS_KEPT = "kept"
+    //Scene is not changed in adaptive capture.
+    SNAPSHOT_STATUS_SKIPPED = "skipped"
+    //Scene is rejected by the quality check.
+    SNAPSHOT_STATUS_REJECTED = "rejected"
+    //Stream cannot be opened or the snapshot cannot be written.
+    SNAPSHOT_STATUS_FAILED = "failed"
+)
+
+//Value of the luma statistics when the scene cannot be decoded.
+const SNAPSHOT_NO_LUMA = -1
+
+//Statistics of a snapshot capture, recorded for every capture whether the
+// snapshot is kept or not.
+type SnapshotStats struct {
+    CamName        string  `json:"CamName"`
+    //Unix time the capture is started.
+    CaptureTime    int64   `json:"CaptureTime"`
+    Status         string  `json:"Status"`
+    //Cycle directory and the file name of a kept snapshot, empty otherwise.
+    CycleDir       string  `json:"CycleDir"`
+    File           string  `json:"File"`
+    //Luma of the first frame, leaving out the masked regions.
+    MeanLuma       float64 `json:"MeanLuma"`
+    LumaDeviation  float64 `json:"LumaDeviation"`
+    //5th, 50th and 95th percentile of the luma histogram.
+    LumaLow        int64   `json:"LumaLow"`
+    LumaMedian     int64   `json:"LumaMedian"`
+    LumaHigh       int64   `json:"LumaHigh"`
+    //Change of the scene from the previous capture in percent.
+    ChangeScore    float64 `json:"ChangeScore"`
+    //Milliseconds from the start of the capture to the first decoded frame.
+    CaptureLatency int64   `json:"CaptureLatency"`
+    //Video packets in the snapshot, or read to check the scene if the
+    // snapshot is not kept.
+    Packets        uint64  `json:"Packets"`
+    //Index of the first keyframe packet in the snapshot, -1 if there is none.
+    KeyframeOffset int64   `json:"KeyframeOffset"`
+}
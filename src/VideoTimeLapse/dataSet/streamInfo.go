@@ -0,0 +1,39 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataSet
+
+//Stream parameters detected from the camera on connecting to it. There is
+// only one entry for a camera, updated when the stream parameters change.
+type CameraStreamInfo struct {
+    CamName     string   `json:"CamName"`
+    Codec       string   `json:"Codec"`
+    Profile     string   `json:"Profile"`
+    Width       int64    `json:"Width"`
+    Height      int64    `json:"Height"`
+    FPS         float64  `json:"FPS"`
+    //Bitrate in bits/sec, 0 if the camera doesn't report it.
+    Bitrate     int64    `json:"Bitrate"`
+    HasAudio    bool     `json:"HasAudio"`
+    //Unix time of the last change in stream parameters.
+    UpdateTime  int64    `json:"UpdateTime"`
+}
+
+//Return true if the stream parameters are same, ignoring the update time.
+func (info *CameraStreamInfo)IsSameStream(other *CameraStreamInfo) bool {
+    return info.Codec == other.Codec && info.Profile == other.Profile &&
+            info.Width == other.Width && info.Height == other.Height &&
+            info.FPS == other.FPS && info.Bitrate == other.Bitrate &&
+            info.HasAudio == other.HasAudio
+}
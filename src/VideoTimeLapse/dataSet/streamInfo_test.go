@@ -0,0 +1,63 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataSet
+
+// Test file for validating the stream parameters of the camera.
+import (
+    "testing"
+)
+
+func TestIsSameStream(t *testing.T) {
+    stream := CameraStreamInfo{CamName: "cam1", Codec: "h264",
+        Profile: "High", Width: 1920, Height: 1080, FPS: 25,
+        Bitrate: 4000000, UpdateTime: 100}
+    tests := []struct {
+        name string
+        update func(info *CameraStreamInfo)
+        expected bool
+    }{
+        {"same stream", func(info *CameraStreamInfo) {}, true},
+        {"updated later", func(info *CameraStreamInfo) {
+            info.UpdateTime = 200
+        }, true},
+        {"codec changed", func(info *CameraStreamInfo) {
+            info.Codec = "hevc"
+        }, false},
+        {"profile changed", func(info *CameraStreamInfo) {
+            info.Profile = "Main"
+        }, false},
+        {"resolution changed", func(info *CameraStreamInfo) {
+            info.Width, info.Height = 1280, 720
+        }, false},
+        {"fps changed", func(info *CameraStreamInfo) {
+            info.FPS = 30
+        }, false},
+        {"bitrate changed", func(info *CameraStreamInfo) {
+            info.Bitrate = 2000000
+        }, false},
+        {"audio added", func(info *CameraStreamInfo) {
+            info.HasAudio = true
+        }, false},
+    }
+    for _, test := range tests {
+        other := stream
+        test.update(&other)
+        same := stream.IsSameStream(&other)
+        if same != test.expected {
+            t.Errorf("%s: got same %v, expected %v", test.name, same,
+                     test.expected)
+        }
+    }
+}
@@ -14,8 +14,49 @@
 
 package dataSet
 
+//Output formats supported for the timelapse videos.
+const (
+    VIDEO_FORMAT_MP4 = "mp4"
+    VIDEO_FORMAT_GIF = "gif"
+    VIDEO_FORMAT_WEBM = "webm"
+)
+
+//Default set of output formats for a camera.
+const (
+    CAMERA_DEFAULT_OUTPUT_FORMATS = VIDEO_FORMAT_MP4
+)
+
+//Structure to hold a video artefact created by the application. Every
+// rendered format of a timelapse is a separate video entry.
+//Name of the video is unique for a camera.
 type Video struct {
-    videoPath   string
-    description string
-    camName     string
+    Name        string   `json:"Name"`
+    CamName     string   `json:"CamName"`
+    //Absolute path of the video file in the system, never sent to the
+    // clients.
+    Path        string   `json:"-"`
+    Format      string   `json:"Format"`
+    Description string   `json:"Description"`
+    //Start and end time of the recording in unix seconds.
+    StartTime   int64    `json:"StartTime"`
+    EndTime     int64    `json:"EndTime"`
+    //Size of the video file in bytes.
+    Size        int64    `json:"Size"`
+    //Poster image of the video and number of thumbnails in the strip next
+    // to the poster. The poster path is never sent to the clients.
+    PosterPath  string   `json:"-"`
+    ThumbCount  int64    `json:"ThumbCount"`
+}
+
+//Return all the supported video formats.
+func GetVideoFormats() []string {
+    return []string{VIDEO_FORMAT_MP4, VIDEO_FORMAT_GIF, VIDEO_FORMAT_WEBM}
+}
+
+func IsVideoFormatValid(format string) bool {
+    switch format {
+    case VIDEO_FORMAT_MP4, VIDEO_FORMAT_GIF, VIDEO_FORMAT_WEBM:
+        return true
+    }
+    return false
 }
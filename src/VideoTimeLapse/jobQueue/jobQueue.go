@@ -0,0 +1,324 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobQueue
+
+import (
+    "fmt"
+    "sync"
+    "time"
+    "encoding/json"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+// Queue of the background rendering jobs. The jobs are persisted in the
+// datastore and run by a fixed number of workers in the order they are
+// submitted. The modules doing the rendering work register a handler for
+// every job type they submit.
+
+const (
+    //Finished jobs are kept in the datastore for a week.
+    JOB_HISTORY_SEC = 7 * 24 * 3600
+)
+
+//Report the completed percentage of the running job. Returns
+// appErrors.OP_CANCELLED when the job is cancelled, the handler must return
+// right away in that case.
+type JobProgress func(percent float64) error
+
+//Run the job of a specific type. The job is retried when the handler returns
+// an error, until the maximum attempts of the job.
+type JobHandler func(job *dataSet.Job, progress JobProgress) error
+
+type JobQueue struct {
+    //Lock to protect all the fields below and the job state changes.
+    lock sync.Mutex
+    //Signal the workers when a job is added to the pending list.
+    pendingCond *sync.Cond
+    pending []string
+    handlers map[string]JobHandler
+    //Running jobs and the running jobs requested to cancel.
+    running map[string]bool
+    cancelled map[string]bool
+    started bool
+}
+
+//Report the progress of the job, Also check if the job is cancelled.
+func ReportProgress(progress JobProgress, percent float64) error {
+    if progress == nil {
+        //Work is not run as a job.
+        return nil
+    }
+    return progress(percent)
+}
+
+//Register the handler to run the jobs of 'jobType'. Handlers must be
+// registered before starting the workers.
+func (queue *JobQueue)RegisterJobHandler(jobType string, handler JobHandler) {
+    queue.lock.Lock()
+    queue.handlers[jobType] = handler
+    queue.lock.Unlock()
+}
+
+//Add the job to the pending list and signal a worker.
+//MUST HOLD the queue lock before calling this function.
+func (queue *JobQueue)enqueueJob__(jobId string) {
+    queue.pending = append(queue.pending, jobId)
+    queue.pendingCond.Signal()
+}
+
+//Create a job of 'jobType' with the 'params' and queue it to run in
+// background.
+func (queue *JobQueue)SubmitJob(jobType string, camName string,
+                                params interface{}) (*dataSet.Job, error) {
+    log := logging.GetLoggerInstance()
+    data, err := json.Marshal(params)
+    if err != nil {
+        log.Error("Invalid parameters for %s job, err: %s", jobType, err)
+        return nil, appErrors.INVALID_INPUT
+    }
+    now := time.Now()
+    job := &dataSet.Job{
+        Id: fmt.Sprintf("%s-%d", jobType, now.UnixNano()),
+        Type: jobType,
+        CamName: camName,
+        State: dataSet.JOB_QUEUED,
+        Params: string(data),
+        MaxAttempts: dataSet.JOB_DEFAULT_MAX_ATTEMPTS,
+        CreateTime: now.Unix(),
+    }
+    dataObj := dataSetImpl.GetDataSetObj()
+    queue.lock.Lock()
+    defer queue.lock.Unlock()
+    err = dataObj.UpdateJob(job)
+    if err != nil {
+        log.Error("Failed to submit %s job of %s, err: %s", jobType, camName,
+                    err)
+        return nil, err
+    }
+    queue.enqueueJob__(job.Id)
+    log.Trace("Submitted job %s of %s", job.Id, camName)
+    return job, nil
+}
+
+//Cancel the queued or running job. The running job is stopped at its next
+// progress report.
+func (queue *JobQueue)CancelJob(jobId string) error {
+    log := logging.GetLoggerInstance()
+    dataObj := dataSetImpl.GetDataSetObj()
+    queue.lock.Lock()
+    defer queue.lock.Unlock()
+    job, err := dataObj.GetJob(jobId)
+    if err != nil {
+        return err
+    }
+    if job.IsFinished() {
+        return appErrors.INVALID_OP
+    }
+    if queue.running[jobId] {
+        log.Trace("Cancelling the running job %s", jobId)
+        queue.cancelled[jobId] = true
+        return nil
+    }
+    //Queued job is skipped by the workers.
+    job.State = dataSet.JOB_CANCELLED
+    job.EndTime = time.Now().Unix()
+    log.Trace("Cancelled the queued job %s", jobId)
+    return dataObj.UpdateJob(job)
+}
+
+//Delete the finished job from the datastore.
+func (queue *JobQueue)DeleteJob(jobId string) error {
+    dataObj := dataSetImpl.GetDataSetObj()
+    queue.lock.Lock()
+    defer queue.lock.Unlock()
+    job, err := dataObj.GetJob(jobId)
+    if err != nil {
+        return err
+    }
+    if !job.IsFinished() {
+        return appErrors.INVALID_OP
+    }
+    return dataObj.DeleteJob(jobId)
+}
+
+//Update the progress of the running job in datastore.
+func (queue *JobQueue)updateProgress(job *dataSet.Job, percent float64) error {
+    queue.lock.Lock()
+    defer queue.lock.Unlock()
+    if queue.cancelled[job.Id] {
+        return appErrors.OP_CANCELLED
+    }
+    if percent < job.Progress || percent > 100 {
+        return nil
+    }
+    job.Progress = percent
+    return dataSetImpl.GetDataSetObj().UpdateJob(job)
+}
+
+//Wait for a pending job and mark it running. Returns nil when the job is not
+// to be run anymore.
+func (queue *JobQueue)startNextJob() (*dataSet.Job, JobHandler) {
+    log := logging.GetLoggerInstance()
+    dataObj := dataSetImpl.GetDataSetObj()
+    queue.lock.Lock()
+    defer queue.lock.Unlock()
+    for len(queue.pending) == 0 {
+        queue.pendingCond.Wait()
+    }
+    jobId := queue.pending[0]
+    queue.pending = queue.pending[1:]
+    job, err := dataObj.GetJob(jobId)
+    if err != nil || job.State != dataSet.JOB_QUEUED {
+        //Job is cancelled/deleted while in queue.
+        return nil, nil
+    }
+    handler, ok := queue.handlers[job.Type]
+    if !ok {
+        log.Error("No handler for job %s of type %s", job.Id, job.Type)
+        job.State = dataSet.JOB_FAILED
+        job.EndTime = time.Now().Unix()
+        job.Error = fmt.Sprintf("Unknown job type %s", job.Type)
+        dataObj.UpdateJob(job)
+        return nil, nil
+    }
+    job.State = dataSet.JOB_RUNNING
+    job.StartTime = time.Now().Unix()
+    job.EndTime = 0
+    job.Attempts++
+    job.Progress = 0
+    err = dataObj.UpdateJob(job)
+    if err != nil {
+        log.Error("Failed to start job %s, err: %s", job.Id, err)
+        return nil, nil
+    }
+    queue.running[job.Id] = true
+    return job, handler
+}
+
+//Record the result of the job run. The failed job is queued again until its
+// maximum attempts.
+func (queue *JobQueue)finishJob(job *dataSet.Job, err error) {
+    log := logging.GetLoggerInstance()
+    dataObj := dataSetImpl.GetDataSetObj()
+    queue.lock.Lock()
+    defer queue.lock.Unlock()
+    cancelled := queue.cancelled[job.Id]
+    delete(queue.running, job.Id)
+    delete(queue.cancelled, job.Id)
+    job.EndTime = time.Now().Unix()
+    switch {
+    case cancelled:
+        job.State = dataSet.JOB_CANCELLED
+    case err == nil:
+        job.State = dataSet.JOB_SUCCEEDED
+        job.Progress = 100
+        job.Error = ""
+    case job.Attempts < job.MaxAttempts:
+        log.Error("Job %s failed at attempt %d, retrying, err: %s", job.Id,
+                    job.Attempts, err)
+        job.State = dataSet.JOB_QUEUED
+        job.Error = err.Error()
+        queue.enqueueJob__(job.Id)
+    default:
+        log.Error("Job %s failed, err: %s", job.Id, err)
+        job.State = dataSet.JOB_FAILED
+        job.Error = err.Error()
+    }
+    dataObj.UpdateJob(job)
+    dataObj.DeleteFinishedJobs(time.Now().Unix() - JOB_HISTORY_SEC)
+}
+
+//Worker routine to run the jobs one after other.
+func (queue *JobQueue)runJobWorker() {
+    log := logging.GetLoggerInstance()
+    for {
+        job, handler := queue.startNextJob()
+        if job == nil {
+            continue
+        }
+        log.Trace("Running job %s of %s, attempt %d", job.Id, job.CamName,
+                    job.Attempts)
+        err := handler(job, func(percent float64) error {
+            return queue.updateProgress(job, percent)
+        })
+        queue.finishJob(job, err)
+    }
+}
+
+//Start the workers to run the jobs. The jobs queued and interrupted by the
+// last application exit are queued again.
+//Workers are never added to the application waitgroup, the jobs interrupted
+// by the exit are resumed at the next start.
+func (queue *JobQueue)StartJobWorkers(numWorkers int) error {
+    log := logging.GetLoggerInstance()
+    dataObj := dataSetImpl.GetDataSetObj()
+    if numWorkers < 1 {
+        numWorkers = 1
+    }
+    queue.lock.Lock()
+    defer queue.lock.Unlock()
+    if queue.started {
+        return appErrors.INVALID_OP
+    }
+    jobs, err := dataObj.GetAllJobs()
+    if err != nil {
+        log.Error("Failed to read the jobs, err: %s", err)
+        return err
+    }
+    for i := range jobs {
+        job := &jobs[i]
+        if job.IsFinished() {
+            continue
+        }
+        if job.State == dataSet.JOB_RUNNING {
+            //Interrupted attempt is counted, so a job that brings down the
+            // application is not run forever.
+            job.State = dataSet.JOB_QUEUED
+            if job.Attempts >= job.MaxAttempts {
+                job.State = dataSet.JOB_FAILED
+                job.Error = "Interrupted by the application exit"
+                job.EndTime = time.Now().Unix()
+            }
+            dataObj.UpdateJob(job)
+            if job.IsFinished() {
+                continue
+            }
+        }
+        queue.enqueueJob__(job.Id)
+    }
+    for i := 0; i < numWorkers; i++ {
+        go queue.runJobWorker()
+    }
+    queue.started = true
+    log.Trace("Started %d job workers with %d pending jobs", numWorkers,
+                len(queue.pending))
+    return nil
+}
+
+var jobQueueOnce sync.Once
+var jobQueueObj JobQueue
+
+func GetJobQueueObj() *JobQueue {
+    jobQueueOnce.Do(func() {
+        jobQueueObj.pendingCond = sync.NewCond(&jobQueueObj.lock)
+        jobQueueObj.handlers = make(map[string]JobHandler)
+        jobQueueObj.running = make(map[string]bool)
+        jobQueueObj.cancelled = make(map[string]bool)
+    })
+    return &jobQueueObj
+}
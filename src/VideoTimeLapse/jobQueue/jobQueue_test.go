@@ -0,0 +1,212 @@
+// Copyright 2018 Sugesh Chandran
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jobQueue
+
+// Test file for validating the state changes of the background jobs.
+import (
+    "os"
+    "sync"
+    "errors"
+    "io/ioutil"
+    "testing"
+    "VideoTimeLapse/config"
+    "VideoTimeLapse/dataSet"
+    "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/logging"
+    "VideoTimeLapse/appErrors"
+)
+
+const (
+    TEST_JOB_TYPE = "test"
+    TEST_CAM_NAME = "testCam"
+)
+
+//Jobs are persisted in a datastore of the test run.
+func TestMain(m *testing.M) {
+    logger := new(logging.Logging)
+    logger.LogInitSingleton(logging.LogLeveltype(logging.Trace), "")
+    dir, err := ioutil.TempDir("", "jobQueue")
+    if err != nil {
+        os.Exit(1)
+    }
+    conf := &config.AppConfig{Dbpath: dir + "/" + config.DEFAULT_DB_NAME}
+    dataObj := dataSetImpl.GetDataSetObj()
+    if dataObj.CreateDBConnection(conf) != nil ||
+        dataObj.CreateDataStoreTables() != nil {
+        os.RemoveAll(dir)
+        os.Exit(1)
+    }
+    res := m.Run()
+    os.RemoveAll(dir)
+    os.Exit(res)
+}
+
+//Return a queue with a handler for the test jobs. The workers are not
+// started, the tests run the jobs one step at a time.
+func newTestQueue() *JobQueue {
+    queue := &JobQueue{
+        handlers: make(map[string]JobHandler),
+        running: make(map[string]bool),
+        cancelled: make(map[string]bool),
+    }
+    queue.pendingCond = sync.NewCond(&queue.lock)
+    queue.handlers[TEST_JOB_TYPE] = func(job *dataSet.Job,
+                                         progress JobProgress) error {
+        return nil
+    }
+    return queue
+}
+
+//Submit a test job and check it is queued.
+func submitTestJob(t *testing.T, queue *JobQueue,
+                   jobType string) *dataSet.Job {
+    job, err := queue.SubmitJob(jobType, TEST_CAM_NAME, nil)
+    if err != nil {
+        t.Fatalf("Failed to submit job, err: %s", err)
+    }
+    if job.State != dataSet.JOB_QUEUED {
+        t.Fatalf("Submitted job %s is %s", job.Id, job.State)
+    }
+    return job
+}
+
+//Check the state of the job in the datastore.
+func checkJobState(t *testing.T, jobId string, state string) *dataSet.Job {
+    job, err := dataSetImpl.GetDataSetObj().GetJob(jobId)
+    if err != nil {
+        t.Fatalf("Failed to read job %s, err: %s", jobId, err)
+    }
+    if job.State != state {
+        t.Fatalf("Job %s is %s, expected %s", jobId, job.State, state)
+    }
+    return job
+}
+
+func TestJobSucceeded(t *testing.T) {
+    queue := newTestQueue()
+    submitted := submitTestJob(t, queue, TEST_JOB_TYPE)
+    job, handler := queue.startNextJob()
+    if job == nil || handler == nil || job.Id != submitted.Id {
+        t.Fatalf("Queued job %s is not started", submitted.Id)
+    }
+    checkJobState(t, job.Id, dataSet.JOB_RUNNING)
+    if job.Attempts != 1 || !queue.running[job.Id] {
+        t.Errorf("Running job has %d attempts, running %t", job.Attempts,
+                 queue.running[job.Id])
+    }
+    if queue.updateProgress(job, 50) != nil || job.Progress != 50 {
+        t.Errorf("Progress of the job is %f, expected 50", job.Progress)
+    }
+    queue.finishJob(job, nil)
+    job = checkJobState(t, job.Id, dataSet.JOB_SUCCEEDED)
+    if job.Progress != 100 || queue.running[job.Id] {
+        t.Errorf("Succeeded job has progress %f, running %t", job.Progress,
+                 queue.running[job.Id])
+    }
+}
+
+func TestJobRetriedTillFailed(t *testing.T) {
+    queue := newTestQueue()
+    submitted := submitTestJob(t, queue, TEST_JOB_TYPE)
+    runErr := errors.New("Render failed")
+    for i := 1; i < dataSet.JOB_DEFAULT_MAX_ATTEMPTS; i++ {
+        job, _ := queue.startNextJob()
+        if job == nil {
+            t.Fatalf("Job %s is not started at attempt %d", submitted.Id, i)
+        }
+        queue.finishJob(job, runErr)
+        job = checkJobState(t, job.Id, dataSet.JOB_QUEUED)
+        if job.Error != runErr.Error() || len(queue.pending) != 1 {
+            t.Errorf("Retried job has error %q, %d pending", job.Error,
+                     len(queue.pending))
+        }
+    }
+    job, _ := queue.startNextJob()
+    if job == nil {
+        t.Fatalf("Job %s is not started at the last attempt", submitted.Id)
+    }
+    queue.finishJob(job, runErr)
+    checkJobState(t, job.Id, dataSet.JOB_FAILED)
+    if len(queue.pending) != 0 {
+        t.Errorf("Failed job is queued again")
+    }
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+    queue := newTestQueue()
+    submitted := submitTestJob(t, queue, TEST_JOB_TYPE)
+    if err := queue.CancelJob(submitted.Id); err != nil {
+        t.Fatalf("Failed to cancel job %s, err: %s", submitted.Id, err)
+    }
+    checkJobState(t, submitted.Id, dataSet.JOB_CANCELLED)
+    if job, _ := queue.startNextJob(); job != nil {
+        t.Errorf("Cancelled job %s is started", job.Id)
+    }
+    if err := queue.CancelJob(submitted.Id); err != appErrors.INVALID_OP {
+        t.Errorf("Cancelling a finished job: got err %v", err)
+    }
+}
+
+func TestCancelRunningJob(t *testing.T) {
+    queue := newTestQueue()
+    submitTestJob(t, queue, TEST_JOB_TYPE)
+    job, _ := queue.startNextJob()
+    if job == nil {
+        t.Fatalf("Queued job is not started")
+    }
+    if err := queue.CancelJob(job.Id); err != nil {
+        t.Fatalf("Failed to cancel job %s, err: %s", job.Id, err)
+    }
+    //Running job is stopped by its handler at the next progress report.
+    checkJobState(t, job.Id, dataSet.JOB_RUNNING)
+    err := queue.updateProgress(job, 10)
+    if err != appErrors.OP_CANCELLED {
+        t.Fatalf("Progress of the cancelled job: got err %v", err)
+    }
+    queue.finishJob(job, err)
+    checkJobState(t, job.Id, dataSet.JOB_CANCELLED)
+    if len(queue.pending) != 0 || queue.cancelled[job.Id] {
+        t.Errorf("Cancelled job is queued again")
+    }
+}
+
+func TestJobWithoutHandler(t *testing.T) {
+    queue := newTestQueue()
+    submitted := submitTestJob(t, queue, "unknown")
+    if job, _ := queue.startNextJob(); job != nil {
+        t.Errorf("Job %s without a handler is started", job.Id)
+    }
+    checkJobState(t, submitted.Id, dataSet.JOB_FAILED)
+}
+
+func TestDeleteJob(t *testing.T) {
+    queue := newTestQueue()
+    submitted := submitTestJob(t, queue, TEST_JOB_TYPE)
+    if err := queue.DeleteJob(submitted.Id); err != appErrors.INVALID_OP {
+        t.Errorf("Deleting a queued job: got err %v", err)
+    }
+    job, _ := queue.startNextJob()
+    if job == nil {
+        t.Fatalf("Queued job %s is not started", submitted.Id)
+    }
+    queue.finishJob(job, nil)
+    if err := queue.DeleteJob(job.Id); err != nil {
+        t.Errorf("Failed to delete job %s, err: %s", job.Id, err)
+    }
+    _, err := dataSetImpl.GetDataSetObj().GetJob(job.Id)
+    if err == nil {
+        t.Errorf("Deleted job %s is still in the datastore", job.Id)
+    }
+}
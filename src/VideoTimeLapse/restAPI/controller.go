@@ -15,19 +15,31 @@
 package restAPI
 
 import (
+    "fmt"
     "net/http"
     "encoding/json"
     "io"
+    "os"
     "io/ioutil"
+    "math"
+    "strconv"
+    "time"
+    "path/filepath"
     "github.com/gorilla/mux"
     "VideoTimeLapse/dataSet"
     "VideoTimeLapse/dataSet/dataSetImpl"
+    "VideoTimeLapse/CameraTimeLapse"
     "VideoTimeLapse/CameraTimeLapse/CameraThreadImpl"
+    "VideoTimeLapse/CameraTimeLapse/CameraThreadImpl/RTSPCameraImpl"
     "VideoTimeLapse/logging"
     "VideoTimeLapse/appErrors"
+    "VideoTimeLapse/jobQueue"
 )
 
-type controller struct { }
+type controller struct {
+    //Directory where the camera videos are stored.
+    videoPath string
+}
 
 func (ctrl *controller) getAllCameras(w http.ResponseWriter, r *http.Request) {
     log := logging.GetLoggerInstance()
@@ -44,7 +56,11 @@ func (ctrl *controller) getAllCameras(w http.ResponseWriter, r *http.Request) {
         w.Write([]byte("500-Server Error "+ err.Error()))
         return
     }
-    data, _ := json.Marshal(rows)
+    cameras := make([]JsonCameraOutput, 0, len(rows))
+    for i := range rows {
+        cameras = append(cameras, newJsonCameraOutput(&rows[i]))
+    }
+    data, _ := json.Marshal(cameras)
     w.Header().Set("Content-Type", "application/json; charset=UTF-8")
     w.Header().Set("Access-Control-Allow-Origin", "*")
     w.WriteHeader(http.StatusOK)
@@ -91,10 +107,20 @@ func (ctrl *controller) createCamera(w http.ResponseWriter, r *http.Request) {
     dataObj := dataSetImpl.GetDataSetObj()
     var camObj dataSet.Camera
     jsonCamObj.ReadJsonData(&camObj) //Read json data to original camera obj
+    if !ctrl.validateCamera(w, r, &camObj) {
+        return
+    }
+    if _, err = dataObj.GetComposite(camObj.Name); err == nil {
+        //Videos of cameras and composites share the catalog.
+        log.Error("Composite %s exists, cannot create camera", camObj.Name)
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
     err = dataObj.AddNewCamera(&camObj)
     if err != nil {
         log.Error("Failed to create camera entry in table err :%s", err)
-        if err == appErrors.DATA_PRESENT_IN_SYSTEM {
+        if err == appErrors.DATA_PRESENT_IN_SYSTEM ||
+            err == appErrors.INVALID_INPUT {
             w.WriteHeader(400) //Bad Request.
             return
         }
@@ -137,7 +163,13 @@ func (ctrl *controller) getCamera(w http.ResponseWriter, r *http.Request) {
         w.WriteHeader(http.StatusInternalServerError)
         return
     }
-    data, _ := json.Marshal(camObj)
+    camOut := newJsonCameraOutput(camObj)
+    //Stream info is present only once camera is connected.
+    camOut.StreamInfo, _ = dataObj.GetCameraStreamInfo(cameraId)
+    //Capture status is present only once a snapshot is rejected or the
+    // camera is tampered.
+    camOut.CaptureStatus, _ = dataObj.GetCameraCaptureStatus(cameraId)
+    data, _ := json.Marshal(camOut)
     w.Header().Set("Content-Type", "application/json; charset=UTF-8")
     w.Header().Set("Access-Control-Allow-Origin", "*")
     w.WriteHeader(http.StatusOK)
@@ -224,10 +256,14 @@ func (ctrl *controller) updateCamera(w http.ResponseWriter, r *http.Request) {
         return
     }
     jsonCamObj.ReadJsonData(camObj)
+    if !ctrl.validateCamera(w, r, camObj) {
+        return
+    }
     err = dataObj.UpdateCamera(camObj)
     if err != nil {
         log.Error("Failed to update the camera %s", err)
-        if err == appErrors.DATA_NOT_FOUND {
+        if err == appErrors.DATA_NOT_FOUND ||
+            err == appErrors.INVALID_INPUT {
             w.WriteHeader(http.StatusBadRequest)
             return
         }
@@ -239,14 +275,1056 @@ func (ctrl *controller) updateCamera(w http.ResponseWriter, r *http.Request) {
     ctrl.signalCameraThreadRunner(camObj)
 }
 
+//Return the camera or the composite that owns the videos of the request.
+func getVideoOwner(vars map[string]string) string {
+    if owner, ok := vars["composite-name"]; ok {
+        return owner
+    }
+    return vars["camera-name"]
+}
+
 func (ctrl *controller) getVideos(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := getVideoOwner(vars)
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(cameraId) == 0 {
+        log.Error("Empty camera ID , cannot find videos")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    rows, err := dataObj.GetAllVideos(cameraId)
+    if err != nil {
+        log.Error("Failed to get the videos of %s err:%s", cameraId, err)
+        w.WriteHeader(http.StatusInternalServerError)
+        w.Write([]byte("500-Server Error "+ err.Error()))
+        return
+    }
+    data, _ := json.Marshal(rows)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+    w.Write(data)
 }
 
 func (ctrl *controller) getVideo(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := getVideoOwner(vars)
+    videoId := vars["video-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(cameraId) == 0 || len(videoId) == 0 {
+        log.Error("Empty camera/video ID , cannot find it")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    videoObj, err := dataObj.GetVideo(cameraId, videoId)
+    if err != nil {
+        log.Error("Failed to get video %s of %s err:%s", videoId, cameraId,
+                    err)
+        if err == appErrors.DATA_NOT_FOUND {
+            w.WriteHeader(http.StatusNotFound)
+            return
+        }
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    data, _ := json.Marshal(videoObj)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+    w.Write(data)
+}
+
+//Remove the video file and its entry in the video catalog.
+func (ctrl *controller) removeVideo(video *dataSet.Video) error {
+    log := logging.GetLoggerInstance()
+    dataObj := dataSetImpl.GetDataSetObj()
+    err := os.Remove(video.Path)
+    if err != nil && !os.IsNotExist(err) {
+        log.Error("Failed to delete the video file %s err: %s", video.Path,
+                    err)
+        return err
+    }
+    return dataObj.DeleteVideo(video.CamName, video.Name)
 }
 
 func (ctrl *controller) deleteVideos(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := getVideoOwner(vars)
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(cameraId) == 0 {
+        log.Error("Empty camera ID , cannot delete videos")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    rows, err := dataObj.GetAllVideos(cameraId)
+    if err != nil {
+        log.Error("Failed to get the videos of %s err:%s", cameraId, err)
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    for i := range rows {
+        err = ctrl.removeVideo(&rows[i])
+        if err != nil {
+            log.Error("Failed to delete the video %s err : %s", rows[i].Name,
+                        err)
+            w.WriteHeader(http.StatusInternalServerError)
+            return
+        }
+    }
+    w.WriteHeader(http.StatusOK)
 }
 
 func (ctrl *controller) deleteVideo(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := getVideoOwner(vars)
+    videoId := vars["video-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(cameraId) == 0 || len(videoId) == 0 {
+        log.Error("Empty camera/video ID , cannot delete it")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    videoObj, err := dataObj.GetVideo(cameraId, videoId)
+    if err != nil || videoObj == nil {
+        log.Error(`Failed to retrieive the video object, cannot delete %s
+                    err : %s`, videoId, err)
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    err = ctrl.removeVideo(videoObj)
+    if err != nil {
+        log.Error("Failed to delete the video err : %s", err)
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    w.WriteHeader(http.StatusOK)
+}
+
+//Serve a file of the HLS package in 'hlsDir'.
+func (ctrl *controller) serveHLSFile(w http.ResponseWriter, r *http.Request,
+                                     hlsDir string, fileName string) {
+    log := logging.GetLoggerInstance()
+    if len(fileName) == 0 || fileName != filepath.Base(fileName) {
+        log.Error("Invalid HLS file name %s", fileName)
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    filePath := hlsDir + "/" + fileName
+    if _, err := os.Stat(filePath); err != nil {
+        log.Error("HLS file %s not found", filePath)
+        w.WriteHeader(http.StatusNotFound)
+        return
+    }
+    switch filepath.Ext(fileName) {
+    case ".m3u8":
+        w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+        //Live playlist is updated on every snapshot.
+        w.Header().Set("Cache-Control", "no-cache")
+    case ".m4s":
+        w.Header().Set("Content-Type", "video/iso.segment")
+    case ".mp4":
+        w.Header().Set("Content-Type", "video/mp4")
+    }
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    http.ServeFile(w, r, filePath)
+}
+
+func (ctrl *controller) getVideoHLS(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := vars["camera-name"]
+    videoId := vars["video-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(cameraId) == 0 || len(videoId) == 0 {
+        log.Error("Empty camera/video ID , cannot find HLS package")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    videoObj, err := dataObj.GetVideo(cameraId, videoId)
+    if err != nil {
+        log.Error("Failed to get video %s of %s err:%s", videoId, cameraId,
+                    err)
+        if err == appErrors.DATA_NOT_FOUND {
+            w.WriteHeader(http.StatusNotFound)
+            return
+        }
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    ctrl.serveHLSFile(w, r, RTSPCameraImpl.GetVideoHLSDir(videoObj.Path),
+                      vars["file-name"])
+}
+
+func (ctrl *controller) getLiveHLS(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := vars["camera-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(cameraId) == 0 {
+        log.Error("Empty camera ID , cannot find live HLS package")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    _, err := dataObj.GetCamera(cameraId)
+    if err != nil {
+        log.Error("Failed to get Camera from the server err:%s", err)
+        if err == appErrors.DATA_NOT_FOUND {
+            w.WriteHeader(http.StatusNotFound)
+            return
+        }
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    ctrl.serveHLSFile(w, r,
+                      RTSPCameraImpl.GetLiveHLSDir(ctrl.videoPath, cameraId),
+                      vars["file-name"])
+}
+
+//Serve the poster image of the video, or the thumbnail at ?index=N of the
+// thumbnail strip.
+func (ctrl *controller) getVideoThumbnail(w http.ResponseWriter,
+                                          r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := getVideoOwner(vars)
+    videoId := vars["video-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(cameraId) == 0 || len(videoId) == 0 {
+        log.Error("Empty camera/video ID , cannot find thumbnail")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    videoObj, err := dataObj.GetVideo(cameraId, videoId)
+    if err != nil {
+        log.Error("Failed to get video %s of %s err:%s", videoId, cameraId,
+                    err)
+        if err == appErrors.DATA_NOT_FOUND {
+            w.WriteHeader(http.StatusNotFound)
+            return
+        }
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    if len(videoObj.PosterPath) == 0 {
+        log.Error("No thumbnails for video %s of %s", videoId, cameraId)
+        w.WriteHeader(http.StatusNotFound)
+        return
+    }
+    imageFile := videoObj.PosterPath
+    if indexStr := r.URL.Query().Get("index"); len(indexStr) != 0 {
+        index, err := strconv.Atoi(indexStr)
+        if err != nil || index < 0 || int64(index) >= videoObj.ThumbCount {
+            log.Error("Invalid thumbnail index %s for video %s", indexStr,
+                        videoId)
+            w.WriteHeader(http.StatusBadRequest)
+            return
+        }
+        imageFile = RTSPCameraImpl.GetThumbnailFile(videoObj.PosterPath, index)
+    }
+    if _, err = os.Stat(imageFile); err != nil {
+        log.Error("Thumbnail %s not found", imageFile)
+        w.WriteHeader(http.StatusNotFound)
+        return
+    }
+    w.Header().Set("Content-Type", "image/jpeg")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    http.ServeFile(w, r, imageFile)
+}
+
+//Serve a still image from the live camera, to check the camera framing.
+func (ctrl *controller) getCameraSnapshot(w http.ResponseWriter,
+                                          r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := vars["camera-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(cameraId) == 0 {
+        log.Error("Empty camera ID , cannot capture snapshot")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    cam, err := dataObj.GetCamera(cameraId)
+    if err != nil {
+        log.Error("Failed to get Camera from the server err:%s", err)
+        if err == appErrors.DATA_NOT_FOUND {
+            w.WriteHeader(http.StatusNotFound)
+            return
+        }
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    image, err := RTSPCameraImpl.GetCameraStillImage(cam)
+    if err != nil {
+        //Camera is configured, but not reachable.
+        w.WriteHeader(http.StatusBadGateway)
+        return
+    }
+    w.Header().Set("Content-Type", "image/jpeg")
+    w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d",
+                   RTSPCameraImpl.STILL_IMAGE_CACHE_SEC))
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+    w.Write(image)
+}
+
+//Probe the camera settings in request without adding the camera.
+func (ctrl *controller) probeCamera(w http.ResponseWriter, r *http.Request) {
+    log := logging.GetLoggerInstance()
+    body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+    if err != nil {
+        log.Error("Failed to read request,")
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    if err := r.Body.Close(); err != nil {
+        log.Error("Failed to close the request.")
+    }
+    jsonCamObj := new(JsonCameraInput)
+    if err := json.Unmarshal(body, &jsonCamObj); err != nil {
+        log.Error("Failed to Unmarshal the camera input err:%s", err)
+        w.WriteHeader(422)
+        return
+    }
+    var camObj dataSet.Camera
+    jsonCamObj.ReadJsonData(&camObj)
+    if len(camObj.Ipaddr) == 0 || len(camObj.Port) == 0 {
+        log.Error("Cannot probe camera without address and port")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    report := RTSPCameraImpl.ProbeCamera(&camObj)
+    data, _ := json.Marshal(report)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+    w.Write(data)
+}
+
+//Probe the camera when the request asks to validate it with ?validate=true.
+//Returns false after writing the probe report in response, if the camera
+// settings are not usable.
+func (ctrl *controller) validateCamera(w http.ResponseWriter, r *http.Request,
+                                       camObj *dataSet.Camera) bool {
+    log := logging.GetLoggerInstance()
+    if r.URL.Query().Get("validate") != "true" {
+        return true
+    }
+    report := RTSPCameraImpl.ProbeCamera(camObj)
+    if report.IsValid() {
+        return true
+    }
+    log.Error("Camera %s validation failed, %s", camObj.Name, report.Error)
+    data, _ := json.Marshal(report)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.WriteHeader(422)
+    w.Write(data)
+    return false
+}
+
+//Check the camera video directories against the video catalog. The issues
+// are fixed with ?repair=true and the broken files are moved to quarantine
+// with ?quarantine=true. The orphaned cycles are rendered in stitch jobs, the
+// request doesn't wait for the rendering.
+func (ctrl *controller) runFsck(w http.ResponseWriter, r *http.Request) {
+    log := logging.GetLoggerInstance()
+    opts := RTSPCameraImpl.FsckOptions{
+        Repair: r.URL.Query().Get("repair") == "true",
+        Quarantine: r.URL.Query().Get("quarantine") == "true",
+    }
+    report, err := RTSPCameraImpl.CheckVideoStore(ctrl.videoPath, opts)
+    if err != nil {
+        log.Error("Failed to check the video store err: %s", err)
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    data, _ := json.Marshal(report)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+    w.Write(data)
+}
+
+//Return the response status for the error of a background action.
+func getErrorStatus(err error) int {
+    switch err {
+    case appErrors.DATA_NOT_FOUND:
+        return http.StatusNotFound
+    case appErrors.INVALID_INPUT:
+        return http.StatusBadRequest
+    case appErrors.INVALID_OP:
+        return http.StatusConflict
+    }
+    return http.StatusInternalServerError
+}
+
+//Run the action on the timelapse thread of the camera in request. The action
+// happens in background, hence 202 is returned on success.
+func (ctrl *controller) runCameraAction(w http.ResponseWriter, r *http.Request,
+                    action func(CameraTimeLapse.CameraThreadInterface) error) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := vars["camera-name"]
+    if len(cameraId) == 0 {
+        log.Error("Empty camera ID , cannot run the camera action")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    camThread, err := CameraThreadImpl.GetCameraMapObj().
+                        GetCameraThreadObjInMap(cameraId)
+    if err != nil {
+        log.Error("No timelapse thread for camera %s", cameraId)
+        w.WriteHeader(http.StatusNotFound)
+        return
+    }
+    err = action(camThread)
+    if err != nil {
+        log.Error("Failed to run action on camera %s, err: %s", cameraId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusAccepted)
+}
+
+//Render the timelapse of the snapshots so far without ending the cycle.
+func (ctrl *controller) renderCamera(w http.ResponseWriter, r *http.Request) {
+    ctrl.runCameraAction(w, r,
+                func(camThread CameraTimeLapse.CameraThreadInterface) error {
+        return camThread.RenderCameraTimelapse()
+    })
+}
+
+//Close the running cycle of the camera, render it and start a new cycle.
+func (ctrl *controller) rotateCamera(w http.ResponseWriter, r *http.Request) {
+    ctrl.runCameraAction(w, r,
+                func(camThread CameraTimeLapse.CameraThreadInterface) error {
+        return camThread.RotateCameraCycle()
+    })
+}
+
+//Re-render the cycle of a video from its kept snapshots with the output
+// parameters in request. The new video is added to the catalog once the
+// rendering job is done.
+func (ctrl *controller) rerenderVideo(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := vars["camera-name"]
+    videoId := vars["video-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(cameraId) == 0 || len(videoId) == 0 {
+        log.Error("Empty camera/video ID , cannot re-render it")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+    if err != nil {
+        log.Error("Failed to read request,")
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    if err := r.Body.Close(); err != nil {
+        log.Error("Failed to close the request.")
+    }
+    var opts RTSPCameraImpl.RerenderOptions
+    if len(body) != 0 {
+        if err := json.Unmarshal(body, &opts); err != nil {
+            log.Error("Failed to Unmarshal the re-render input err:%s", err)
+            w.WriteHeader(422)
+            return
+        }
+    }
+    cam, err := dataObj.GetCamera(cameraId)
+    if err != nil {
+        log.Error("Failed to get Camera %s err:%s", cameraId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    videoObj, err := dataObj.GetVideo(cameraId, videoId)
+    if err != nil {
+        log.Error("Failed to get video %s of %s err:%s", videoId, cameraId,
+                    err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    name, job, err := RTSPCameraImpl.RerenderVideo(cam, videoObj, opts)
+    if err != nil {
+        //Conflict when the snapshots of the cycle are not kept anymore.
+        log.Error("Failed to re-render video %s of %s err:%s", videoId,
+                    cameraId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    data, _ := json.Marshal(JsonRerenderOutput{
+                                CamName: cameraId,
+                                Name: name,
+                                JobId: job.Id,
+                            })
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusAccepted)
+    w.Write(data)
+}
+
+//List the background jobs, filtered by ?camera= and ?state= when set.
+func (ctrl *controller) getJobs(w http.ResponseWriter, r *http.Request) {
+    log := logging.GetLoggerInstance()
+    camName := r.URL.Query().Get("camera")
+    state := r.URL.Query().Get("state")
+    if len(state) != 0 && !dataSet.IsJobStateValid(state) {
+        log.Error("Invalid job state %s to list the jobs", state)
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    dataObj := dataSetImpl.GetDataSetObj()
+    rows, err := dataObj.GetAllJobs()
+    if err != nil {
+        log.Error("Failed to get the jobs err:%s", err)
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    jobs := []dataSet.Job{}
+    for _, job := range rows {
+        if (len(camName) != 0 && job.CamName != camName) ||
+            (len(state) != 0 && job.State != state) {
+            continue
+        }
+        jobs = append(jobs, job)
+    }
+    data, _ := json.Marshal(jobs)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+    w.Write(data)
+}
+
+func (ctrl *controller) getJob(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    jobId := vars["job-id"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(jobId) == 0 {
+        log.Error("Empty job ID , cannot find it")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    job, err := dataObj.GetJob(jobId)
+    if err != nil {
+        log.Error("Failed to get job %s err:%s", jobId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    data, _ := json.Marshal(job)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+    w.Write(data)
+}
+
+//Cancel the queued or running job. The finished job is deleted.
+func (ctrl *controller) deleteJob(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    jobId := vars["job-id"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(jobId) == 0 {
+        log.Error("Empty job ID , cannot delete it")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    job, err := dataObj.GetJob(jobId)
+    if err != nil {
+        log.Error("Failed to get job %s err:%s", jobId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    queue := jobQueue.GetJobQueueObj()
+    if job.IsFinished() {
+        err = queue.DeleteJob(jobId)
+    } else {
+        err = queue.CancelJob(jobId)
+    }
+    if err != nil {
+        log.Error("Failed to delete job %s err:%s", jobId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+}
+
+func (ctrl *controller) getRollups(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := vars["camera-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(cameraId) == 0 {
+        log.Error("Empty camera ID , cannot find rollups")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    if _, err := dataObj.GetCamera(cameraId); err != nil {
+        log.Error("Failed to get Camera %s err:%s", cameraId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    rows, err := dataObj.GetCameraRollups(cameraId)
+    if err != nil {
+        log.Error("Failed to get the rollups of %s err:%s", cameraId, err)
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    data, _ := json.Marshal(rows)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+    w.Write(data)
+}
+
+//Create or update the rollup of the camera. The source, run time and speed
+// factor are set to defaults when not given. The periods already built are
+// not built again.
+func (ctrl *controller) updateRollup(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := vars["camera-name"]
+    rollupId := vars["rollup-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(cameraId) == 0 || len(rollupId) == 0 {
+        log.Error("Empty camera/rollup ID , cannot update it")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+    if err != nil {
+        log.Error("Failed to read request,")
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    if err := r.Body.Close(); err != nil {
+        log.Error("Failed to close the request.")
+    }
+    var rollup dataSet.CameraRollup
+    if err := json.Unmarshal(body, &rollup); err != nil {
+        log.Error("Failed to Unmarshal the rollup input err:%s", err)
+        w.WriteHeader(422)
+        return
+    }
+    cam, err := dataObj.GetCamera(cameraId)
+    if err != nil {
+        log.Error("Failed to get Camera %s err:%s", cameraId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    rollup.CamName = cameraId
+    rollup.Name = rollupId
+    rollup.LastRun = 0
+    if existing, err := dataObj.GetCameraRollup(cameraId,
+                                                rollupId); err == nil {
+        rollup.LastRun = existing.LastRun
+    }
+    if len(rollup.Source) == 0 {
+        rollup.Source = dataSet.GetRollupDefaultSource(rollup.Period)
+    }
+    if len(rollup.RunAt) == 0 {
+        rollup.RunAt = dataSet.ROLLUP_DEFAULT_RUN_AT
+    }
+    if rollup.SpeedFactor == 0 {
+        rollup.SpeedFactor = cam.SpeedFactor
+    }
+    if rollup.SpeedFactor == 0 {
+        rollup.SpeedFactor = dataSet.CAMERA_DEFAULT_SPEED_FACTOR
+    }
+    err = dataObj.UpdateCameraRollup(&rollup)
+    if err != nil {
+        log.Error("Failed to update rollup %s of %s err:%s", rollupId,
+                    cameraId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    data, _ := json.Marshal(rollup)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+    w.Write(data)
+}
+
+//Delete the rollup of the camera. The videos built by the rollup are kept in
+// the catalog.
+func (ctrl *controller) deleteRollup(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := vars["camera-name"]
+    rollupId := vars["rollup-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(cameraId) == 0 || len(rollupId) == 0 {
+        log.Error("Empty camera/rollup ID , cannot delete it")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    if _, err := dataObj.GetCameraRollup(cameraId, rollupId); err != nil {
+        log.Error("Failed to get rollup %s of %s err:%s", rollupId, cameraId,
+                    err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    err := dataObj.DeleteCameraRollup(cameraId, rollupId)
+    if err != nil {
+        log.Error("Failed to delete rollup %s of %s err:%s", rollupId,
+                    cameraId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+}
+
+func (ctrl *controller) getAllComposites(w http.ResponseWriter,
+                                         r *http.Request) {
+    log := logging.GetLoggerInstance()
+    dataObj := dataSetImpl.GetDataSetObj()
+    rows, err := dataObj.GetAllComposites()
+    if err != nil {
+        log.Error("Failed to get the composites err:%s", err)
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    data, _ := json.Marshal(rows)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+    w.Write(data)
+}
+
+//Read the composite in the request body, with the defaults filled in.
+func (ctrl *controller) readComposite(w http.ResponseWriter,
+                                      r *http.Request) *dataSet.Composite {
+    log := logging.GetLoggerInstance()
+    body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+    if err != nil {
+        log.Error("Failed to read request,")
+        w.WriteHeader(http.StatusInternalServerError)
+        return nil
+    }
+    if err := r.Body.Close(); err != nil {
+        log.Error("Failed to close the request.")
+    }
+    composite := new(dataSet.Composite)
+    if err := json.Unmarshal(body, composite); err != nil {
+        log.Error("Failed to Unmarshal the composite input err:%s", err)
+        w.WriteHeader(422)
+        return nil
+    }
+    if composite.Width == 0 {
+        composite.Width = dataSet.COMPOSITE_DEFAULT_WIDTH
+    }
+    if composite.Height == 0 {
+        composite.Height = dataSet.COMPOSITE_DEFAULT_HEIGHT
+    }
+    return composite
+}
+
+//Store the composite after checking its member cameras.
+func (ctrl *controller) storeComposite(composite *dataSet.Composite) error {
+    log := logging.GetLoggerInstance()
+    dataObj := dataSetImpl.GetDataSetObj()
+    for _, camName := range composite.GetCameras() {
+        if _, err := dataObj.GetCamera(camName); err != nil {
+            log.Error("Camera %s of composite %s not found", camName,
+                        composite.Name)
+            return appErrors.INVALID_INPUT
+        }
+    }
+    return dataObj.UpdateComposite(composite)
+}
+
+func (ctrl *controller) createComposite(w http.ResponseWriter,
+                                        r *http.Request) {
+    log := logging.GetLoggerInstance()
+    composite := ctrl.readComposite(w, r)
+    if composite == nil {
+        return
+    }
+    dataObj := dataSetImpl.GetDataSetObj()
+    if _, err := dataObj.GetComposite(composite.Name); err == nil {
+        log.Error("Composite %s exists already", composite.Name)
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    if _, err := dataObj.GetCamera(composite.Name); err == nil {
+        //Videos of cameras and composites share the catalog.
+        log.Error("Camera %s exists, cannot create composite", composite.Name)
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    err := ctrl.storeComposite(composite)
+    if err != nil {
+        log.Error("Failed to create composite %s err:%s", composite.Name, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    data, _ := json.Marshal(composite)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusCreated)
+    w.Write(data)
+}
+
+func (ctrl *controller) getComposite(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    compositeId := vars["composite-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(compositeId) == 0 {
+        log.Error("Empty composite ID , cannot find it")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    composite, err := dataObj.GetComposite(compositeId)
+    if err != nil {
+        log.Error("Failed to get composite %s err:%s", compositeId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    data, _ := json.Marshal(composite)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+    w.Write(data)
+}
+
+//Replace the definition of the composite. The videos rendered already are
+// left as is.
+func (ctrl *controller) updateComposite(w http.ResponseWriter,
+                                        r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    compositeId := vars["composite-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(compositeId) == 0 {
+        log.Error("Empty composite ID , cannot update it")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    composite := ctrl.readComposite(w, r)
+    if composite == nil {
+        return
+    }
+    if len(composite.Name) != 0 && composite.Name != compositeId {
+        log.Error("Composite name %s doesn't match %s", composite.Name,
+                    compositeId)
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    composite.Name = compositeId
+    if _, err := dataObj.GetComposite(compositeId); err != nil {
+        log.Error("Failed to get composite %s err:%s", compositeId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    err := ctrl.storeComposite(composite)
+    if err != nil {
+        log.Error("Failed to update composite %s err:%s", compositeId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    data, _ := json.Marshal(composite)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+    w.Write(data)
+}
+
+//Delete the composite. Its videos are kept in the catalog same as the videos
+// of a deleted camera.
+func (ctrl *controller) deleteComposite(w http.ResponseWriter,
+                                        r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    compositeId := vars["composite-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(compositeId) == 0 {
+        log.Error("Empty composite ID , cannot delete it")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    if _, err := dataObj.GetComposite(compositeId); err != nil {
+        log.Error("Failed to get composite %s err:%s", compositeId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    err := dataObj.DeleteComposite(compositeId)
+    if err != nil {
+        log.Error("Failed to delete composite %s err:%s", compositeId, err)
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+}
+
+//Return the unix time range in ?from= and ?to=. The range is open on the
+// side that is not set.
+func readTimeRange(r *http.Request) (int64, int64, error) {
+    var err error
+    from := int64(0)
+    to := int64(math.MaxInt64)
+    if fromStr := r.URL.Query().Get("from"); len(fromStr) != 0 {
+        from, err = strconv.ParseInt(fromStr, 10, 64)
+        if err != nil {
+            return 0, 0, err
+        }
+    }
+    if toStr := r.URL.Query().Get("to"); len(toStr) != 0 {
+        to, err = strconv.ParseInt(toStr, 10, 64)
+        if err != nil {
+            return 0, 0, err
+        }
+    }
+    if from > to {
+        return 0, 0, appErrors.INVALID_INPUT
+    }
+    return from, to, nil
+}
+
+//List the events of the camera raised in ?from= and ?to= unix time range.
+func (ctrl *controller) getCameraEvents(w http.ResponseWriter,
+                                        r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := vars["camera-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(cameraId) == 0 {
+        log.Error("Empty camera ID , cannot find events")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    from, to, err := readTimeRange(r)
+    if err != nil {
+        log.Error("Invalid time range to list the events of %s", cameraId)
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    if _, err = dataObj.GetCamera(cameraId); err != nil {
+        log.Error("Failed to get Camera %s err:%s", cameraId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    rows, err := dataObj.GetEvents(cameraId, from, to)
+    if err != nil {
+        log.Error("Failed to get the events of %s err:%s", cameraId, err)
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    data, _ := json.Marshal(rows)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+    w.Write(data)
+}
+
+//List the stats of the camera captures started in ?from= and ?to= unix time
+// range, as a time series in the order of the capture time.
+func (ctrl *controller) getCameraStats(w http.ResponseWriter,
+                                       r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := vars["camera-name"]
+    dataObj := dataSetImpl.GetDataSetObj()
+    if len(cameraId) == 0 {
+        log.Error("Empty camera ID , cannot find stats")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    from, to, err := readTimeRange(r)
+    if err != nil {
+        log.Error("Invalid time range to list the stats of %s", cameraId)
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    if _, err = dataObj.GetCamera(cameraId); err != nil {
+        log.Error("Failed to get Camera %s err:%s", cameraId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    rows, err := dataObj.GetSnapshotStats(cameraId, from, to)
+    if err != nil {
+        log.Error("Failed to get the stats of %s err:%s", cameraId, err)
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    data, _ := json.Marshal(rows)
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusOK)
+    w.Write(data)
+}
+
+//Return the seconds in query parameter 'key', either as a plain number or as
+// a duration like 10s. 'defaultSec' is returned when the parameter is not set.
+func readSeconds(r *http.Request, key string,
+                 defaultSec uint64) (uint64, error) {
+    value := r.URL.Query().Get(key)
+    if len(value) == 0 {
+        return defaultSec, nil
+    }
+    if sec, err := strconv.ParseUint(value, 10, 64); err == nil {
+        return sec, nil
+    }
+    duration, err := time.ParseDuration(value)
+    if err != nil || duration < 0 {
+        return 0, appErrors.INVALID_INPUT
+    }
+    return uint64(duration / time.Second), nil
+}
+
+//Capture a clip of the camera from ?pre= before till ?post= after now. The
+// clip is added to the videos of the camera once it is written, hence 202 is
+// returned with the name of the clip.
+func (ctrl *controller) captureCameraClip(w http.ResponseWriter,
+                                          r *http.Request) {
+    vars := mux.Vars(r)
+    log := logging.GetLoggerInstance()
+    cameraId := vars["camera-name"]
+    if len(cameraId) == 0 {
+        log.Error("Empty camera ID , cannot capture clip")
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    pre, err := readSeconds(r, "pre", RTSPCameraImpl.CLIP_DEFAULT_PRE_SEC)
+    if err != nil {
+        log.Error("Invalid pre trigger time for the clip of %s", cameraId)
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    post, err := readSeconds(r, "post", RTSPCameraImpl.CLIP_DEFAULT_POST_SEC)
+    if err != nil {
+        log.Error("Invalid post trigger time for the clip of %s", cameraId)
+        w.WriteHeader(http.StatusBadRequest)
+        return
+    }
+    camThread, err := CameraThreadImpl.GetCameraMapObj().
+                        GetCameraThreadObjInMap(cameraId)
+    if err != nil {
+        log.Error("No timelapse thread for camera %s", cameraId)
+        w.WriteHeader(http.StatusNotFound)
+        return
+    }
+    name, err := camThread.CaptureCameraClip(pre, post)
+    if err != nil {
+        log.Error("Failed to capture clip of %s, err: %s", cameraId, err)
+        w.WriteHeader(getErrorStatus(err))
+        return
+    }
+    data, _ := json.Marshal(JsonClipOutput{
+                                CamName: cameraId,
+                                Name: name,
+                            })
+    w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    w.WriteHeader(http.StatusAccepted)
+    w.Write(data)
 }
@@ -41,6 +41,88 @@ type JsonCameraInput struct {
     Pwd *string                  `json:"Pwd"`
     VideoLenSec uint64           `json:"VideoLenSec"`
     SnapInterval uint64          `json:"VideoSnapInterval"`
+    SnapshotPkts uint64          `json:"SnapshotPkts"`
+    //'0' is a valid input for below fields, it switch the clip length to
+    // packets and the compaction to speed factor respectively.
+    SnapshotSec *uint64          `json:"SnapshotSec"`
+    SpeedFactor uint64           `json:"SpeedFactor"`
+    OutputLenSec *uint64         `json:"OutputLenSec"`
+    OutputFormats *string        `json:"OutputFormats"`
+    EnableHLS *bool              `json:"EnableHLS"`
+    ResumePolicy string          `json:"ResumePolicy"`
+    //'0' is valid and deletes the snapshots right after stitching.
+    KeepSnapshotDays *uint64     `json:"KeepSnapshotDays"`
+    OverlayTimestamp *bool       `json:"OverlayTimestamp"`
+    OverlayCamName *bool         `json:"OverlayCamName"`
+    //Empty caption and timezone are valid, they remove the caption and use
+    // the timezone of the camera respectively.
+    OverlayCaption *string       `json:"OverlayCaption"`
+    OverlayPosition string       `json:"OverlayPosition"`
+    OverlayFontSize uint64       `json:"OverlayFontSize"`
+    OverlayTimezone *string      `json:"OverlayTimezone"`
+    //Empty list removes all the masks.
+    PrivacyMasks *[]dataSet.PrivacyMask `json:"PrivacyMasks"`
+    CaptureMode string           `json:"CaptureMode"`
+    ChangeThreshold uint64       `json:"ChangeThreshold"`
+    //'0' is valid and removes the maximum interval of adaptive capture.
+    MaxSnapInterval *uint64      `json:"MaxSnapInterval"`
+    QualityCheck *bool           `json:"QualityCheck"`
+    MinMeanLuma uint64           `json:"MinMeanLuma"`
+    MaxMeanLuma uint64           `json:"MaxMeanLuma"`
+    MinLumaDeviation uint64      `json:"MinLumaDeviation"`
+    //'0' is valid and disables the retry of rejected snapshots.
+    QualityRetries *uint64       `json:"QualityRetries"`
+    Deflicker *bool              `json:"Deflicker"`
+    DeflickerWindow uint64       `json:"DeflickerWindow"`
+    TamperDetection *bool        `json:"TamperDetection"`
+    TamperThreshold uint64       `json:"TamperThreshold"`
+    Recording *bool              `json:"Recording"`
+    SegmentSec uint64            `json:"SegmentSec"`
+    SegmentRetentionHours uint64 `json:"SegmentRetentionHours"`
+    //'0' is valid and disables the clip buffer.
+    ClipBufferSec *uint64        `json:"ClipBufferSec"`
+    //Empty list removes all the profiles.
+    Profiles *[]dataSet.CaptureProfile `json:"Profiles"`
+    //Empty is the local time of the system.
+    Timezone *string             `json:"Timezone"`
+    CycleAlign string            `json:"CycleAlign"`
+}
+
+//Camera returned by the REST API, along with the stream parameters detected
+// from the camera.
+type JsonCameraOutput struct {
+    *dataSet.Camera
+    //Masks and profiles are returned as lists, same as the input.
+    PrivacyMasks []dataSet.PrivacyMask `json:"PrivacyMasks"`
+    Profiles []dataSet.CaptureProfile `json:"Profiles"`
+    StreamInfo *dataSet.CameraStreamInfo `json:"StreamInfo,omitempty"`
+    CaptureStatus *dataSet.CameraCaptureStatus `json:"CaptureStatus,omitempty"`
+}
+
+//Return the camera in the form returned by the REST API.
+func newJsonCameraOutput(camObj *dataSet.Camera) JsonCameraOutput {
+    camOut := JsonCameraOutput{Camera: camObj}
+    camOut.PrivacyMasks, _ = camObj.GetPrivacyMasks()
+    if camOut.PrivacyMasks == nil {
+        camOut.PrivacyMasks = []dataSet.PrivacyMask{}
+    }
+    camOut.Profiles, _ = camObj.GetProfiles()
+    if camOut.Profiles == nil {
+        camOut.Profiles = []dataSet.CaptureProfile{}
+    }
+    return camOut
+}
+
+//Video that is being re-rendered in background and the job rendering it.
+type JsonRerenderOutput struct {
+    CamName string `json:"CamName"`
+    Name    string `json:"Name"`
+    JobId   string `json:"JobId"`
+}
+
+type JsonClipOutput struct {
+    CamName string `json:"CamName"`
+    Name    string `json:"Name"`
 }
 
 //Allocate memory to all the string fields that needed for the json structure.
@@ -88,4 +170,109 @@ func (jsonCam *JsonCameraInput)ReadJsonData(camRowOut *dataSet.Camera) {
     if jsonCam.SnapInterval != 0 {
         camRowOut.SnapInterval = jsonCam.SnapInterval
     }
+    if jsonCam.SnapshotPkts != 0 {
+        camRowOut.SnapshotPkts = jsonCam.SnapshotPkts
+    }
+    if jsonCam.SnapshotSec != nil {
+        camRowOut.SnapshotSec = *jsonCam.SnapshotSec
+    }
+    if jsonCam.SpeedFactor != 0 {
+        camRowOut.SpeedFactor = jsonCam.SpeedFactor
+    }
+    if jsonCam.OutputLenSec != nil {
+        camRowOut.OutputLenSec = *jsonCam.OutputLenSec
+    }
+    if jsonCam.OutputFormats != nil {
+        camRowOut.OutputFormats = *jsonCam.OutputFormats
+    }
+    if jsonCam.EnableHLS != nil {
+        camRowOut.EnableHLS = *jsonCam.EnableHLS
+    }
+    if len(jsonCam.ResumePolicy) != 0 {
+        camRowOut.ResumePolicy = jsonCam.ResumePolicy
+    }
+    if jsonCam.KeepSnapshotDays != nil {
+        camRowOut.KeepSnapshotDays = *jsonCam.KeepSnapshotDays
+    }
+    if jsonCam.OverlayTimestamp != nil {
+        camRowOut.OverlayTimestamp = *jsonCam.OverlayTimestamp
+    }
+    if jsonCam.OverlayCamName != nil {
+        camRowOut.OverlayCamName = *jsonCam.OverlayCamName
+    }
+    if jsonCam.OverlayCaption != nil {
+        camRowOut.OverlayCaption = *jsonCam.OverlayCaption
+    }
+    if len(jsonCam.OverlayPosition) != 0 {
+        camRowOut.OverlayPosition = jsonCam.OverlayPosition
+    }
+    if jsonCam.OverlayFontSize != 0 {
+        camRowOut.OverlayFontSize = jsonCam.OverlayFontSize
+    }
+    if jsonCam.OverlayTimezone != nil {
+        camRowOut.OverlayTimezone = *jsonCam.OverlayTimezone
+    }
+    if jsonCam.PrivacyMasks != nil {
+        //Masks decoded from the JSON input are always encoded back.
+        camRowOut.PrivacyMasks, _ = dataSet.EncodePrivacyMasks(
+                                                    *jsonCam.PrivacyMasks)
+    }
+    if len(jsonCam.CaptureMode) != 0 {
+        camRowOut.CaptureMode = jsonCam.CaptureMode
+    }
+    if jsonCam.ChangeThreshold != 0 {
+        camRowOut.ChangeThreshold = jsonCam.ChangeThreshold
+    }
+    if jsonCam.MaxSnapInterval != nil {
+        camRowOut.MaxSnapInterval = *jsonCam.MaxSnapInterval
+    }
+    if jsonCam.QualityCheck != nil {
+        camRowOut.QualityCheck = *jsonCam.QualityCheck
+    }
+    if jsonCam.MinMeanLuma != 0 {
+        camRowOut.MinMeanLuma = jsonCam.MinMeanLuma
+    }
+    if jsonCam.MaxMeanLuma != 0 {
+        camRowOut.MaxMeanLuma = jsonCam.MaxMeanLuma
+    }
+    if jsonCam.MinLumaDeviation != 0 {
+        camRowOut.MinLumaDeviation = jsonCam.MinLumaDeviation
+    }
+    if jsonCam.QualityRetries != nil {
+        camRowOut.QualityRetries = *jsonCam.QualityRetries
+    }
+    if jsonCam.Deflicker != nil {
+        camRowOut.Deflicker = *jsonCam.Deflicker
+    }
+    if jsonCam.DeflickerWindow != 0 {
+        camRowOut.DeflickerWindow = jsonCam.DeflickerWindow
+    }
+    if jsonCam.TamperDetection != nil {
+        camRowOut.TamperDetection = *jsonCam.TamperDetection
+    }
+    if jsonCam.TamperThreshold != 0 {
+        camRowOut.TamperThreshold = jsonCam.TamperThreshold
+    }
+    if jsonCam.Recording != nil {
+        camRowOut.Recording = *jsonCam.Recording
+    }
+    if jsonCam.SegmentSec != 0 {
+        camRowOut.SegmentSec = jsonCam.SegmentSec
+    }
+    if jsonCam.SegmentRetentionHours != 0 {
+        camRowOut.SegmentRetentionHours = jsonCam.SegmentRetentionHours
+    }
+    if jsonCam.ClipBufferSec != nil {
+        camRowOut.ClipBufferSec = *jsonCam.ClipBufferSec
+    }
+    if jsonCam.Profiles != nil {
+        camRowOut.Profiles, _ = dataSet.EncodeCaptureProfiles(
+                                                    *jsonCam.Profiles)
+    }
+    if jsonCam.Timezone != nil {
+        camRowOut.Timezone = *jsonCam.Timezone
+    }
+    if len(jsonCam.CycleAlign) != 0 {
+        camRowOut.CycleAlign = jsonCam.CycleAlign
+    }
 }
@@ -26,6 +26,8 @@ import (
 type RestAPI struct {
     listenIp string
     listenPort   string
+    //Directory where the camera videos are stored.
+    videoPath string
 }
 
 // The rest handler thread to manage rest APIs.
@@ -62,6 +64,7 @@ func (handler *RestAPI)startHTTPServer() error {
     allowedMethods := handlers.AllowedMethods(
                 []string{"GET", "POST", "DELETE", "PUT", "PATCH"})
     routerObj := new(Routes)
+    routerObj.controller = &controller{videoPath: handler.videoPath}
     router = routerObj.NewRouter()
     syncObj.AddRoutineInWaitGroup()
     //Start rest handler thread, that internally call server listen thread.
@@ -73,10 +76,12 @@ func (handler *RestAPI)startHTTPServer() error {
 // The main handler function for handling http rest request. Normally they are
 // running in seperate goroutine.
 func (handler *RestAPI)RestAPIMainHandler(listenIp string,
-                                              listenPort string) error {
+                                              listenPort string,
+                                              videoPath string) error {
     var err error
     handler.listenIp = listenIp
     handler.listenPort = listenPort
+    handler.videoPath = videoPath
     err = handler.startHTTPServer()
     if err != nil {
         log := logging.GetLoggerInstance()
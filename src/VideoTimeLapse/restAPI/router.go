@@ -34,7 +34,7 @@ type Routes struct {
 }
 
 func (routeObj *Routes) CreateAllRoutes() {
-    routeObj.entries = make([]routeEntry, 9)
+    routeObj.entries = make([]routeEntry, 37)
     routeObj.entries[0] = routeEntry{
                             "getAllCameras",
                             "GET",
@@ -81,12 +81,160 @@ func (routeObj *Routes) CreateAllRoutes() {
                             "DELETE",
                             "/cameras/{camera-name}/videos/{video-name}",
                             routeObj.controller.deleteVideo}
+    //HLS package of videos and the running timelapse.
+    routeObj.entries[9] = routeEntry{
+                            "getVideoHLS",
+                            "GET",
+                            "/cameras/{camera-name}/videos/{video-name}/hls/{file-name}",
+                            routeObj.controller.getVideoHLS}
+    routeObj.entries[10] = routeEntry{
+                            "getLiveHLS",
+                            "GET",
+                            "/cameras/{camera-name}/live/hls/{file-name}",
+                            routeObj.controller.getLiveHLS}
+    routeObj.entries[11] = routeEntry{
+                            "getVideoThumbnail",
+                            "GET",
+                            "/cameras/{camera-name}/videos/{video-name}/thumbnail",
+                            routeObj.controller.getVideoThumbnail}
+    //Still image from the live camera.
+    routeObj.entries[12] = routeEntry{
+                            "getCameraSnapshot",
+                            "GET",
+                            "/cameras/{camera-name}/snapshot.jpg",
+                            routeObj.controller.getCameraSnapshot}
+    routeObj.entries[13] = routeEntry{
+                            "probeCamera",
+                            "POST",
+                            "/cameras/probe",
+                            routeObj.controller.probeCamera}
+    //Maintenance routes
+    routeObj.entries[14] = routeEntry{
+                            "runFsck",
+                            "POST",
+                            "/admin/fsck",
+                            routeObj.controller.runFsck}
+    routeObj.entries[15] = routeEntry{
+                            "renderCamera",
+                            "POST",
+                            "/cameras/{camera-name}/actions/render",
+                            routeObj.controller.renderCamera}
+    routeObj.entries[16] = routeEntry{
+                            "rotateCamera",
+                            "POST",
+                            "/cameras/{camera-name}/actions/rotate",
+                            routeObj.controller.rotateCamera}
+    routeObj.entries[17] = routeEntry{
+                            "rerenderVideo",
+                            "POST",
+                            "/cameras/{camera-name}/videos/{video-name}/rerender",
+                            routeObj.controller.rerenderVideo}
+    routeObj.entries[18] = routeEntry{
+                            "getJobs",
+                            "GET",
+                            "/jobs",
+                            routeObj.controller.getJobs}
+    routeObj.entries[19] = routeEntry{
+                            "getJob",
+                            "GET",
+                            "/jobs/{job-id}",
+                            routeObj.controller.getJob}
+    routeObj.entries[20] = routeEntry{
+                            "deleteJob",
+                            "DELETE",
+                            "/jobs/{job-id}",
+                            routeObj.controller.deleteJob}
+    routeObj.entries[21] = routeEntry{
+                            "getRollups",
+                            "GET",
+                            "/cameras/{camera-name}/rollups",
+                            routeObj.controller.getRollups}
+    routeObj.entries[22] = routeEntry{
+                            "updateRollup",
+                            "PUT",
+                            "/cameras/{camera-name}/rollups/{rollup-name}",
+                            routeObj.controller.updateRollup}
+    routeObj.entries[23] = routeEntry{
+                            "deleteRollup",
+                            "DELETE",
+                            "/cameras/{camera-name}/rollups/{rollup-name}",
+                            routeObj.controller.deleteRollup}
+    routeObj.entries[24] = routeEntry{
+                            "getAllComposites",
+                            "GET",
+                            "/composites",
+                            routeObj.controller.getAllComposites}
+    routeObj.entries[25] = routeEntry{
+                            "createComposite",
+                            "POST",
+                            "/composites",
+                            routeObj.controller.createComposite}
+    routeObj.entries[26] = routeEntry{
+                            "getComposite",
+                            "GET",
+                            "/composites/{composite-name}",
+                            routeObj.controller.getComposite}
+    routeObj.entries[27] = routeEntry{
+                            "updateComposite",
+                            "PUT",
+                            "/composites/{composite-name}",
+                            routeObj.controller.updateComposite}
+    routeObj.entries[28] = routeEntry{
+                            "deleteComposite",
+                            "DELETE",
+                            "/composites/{composite-name}",
+                            routeObj.controller.deleteComposite}
+    //Videos of the composites are served by the camera video handlers.
+    routeObj.entries[29] = routeEntry{
+                            "getCompositeVideos",
+                            "GET",
+                            "/composites/{composite-name}/videos",
+                            routeObj.controller.getVideos}
+    routeObj.entries[30] = routeEntry{
+                            "deleteCompositeVideos",
+                            "DELETE",
+                            "/composites/{composite-name}/videos",
+                            routeObj.controller.deleteVideos}
+    routeObj.entries[31] = routeEntry{
+                            "getCompositeVideo",
+                            "GET",
+                            "/composites/{composite-name}/videos/{video-name}",
+                            routeObj.controller.getVideo}
+    routeObj.entries[32] = routeEntry{
+                            "deleteCompositeVideo",
+                            "DELETE",
+                            "/composites/{composite-name}/videos/{video-name}",
+                            routeObj.controller.deleteVideo}
+    routeObj.entries[33] = routeEntry{
+                            "getCompositeVideoThumbnail",
+                            "GET",
+                            "/composites/{composite-name}/videos/{video-name}/thumbnail",
+                            routeObj.controller.getVideoThumbnail}
+    routeObj.entries[34] = routeEntry{
+                            "getCameraEvents",
+                            "GET",
+                            "/cameras/{camera-name}/events",
+                            routeObj.controller.getCameraEvents}
+    routeObj.entries[35] = routeEntry{
+                            "getCameraStats",
+                            "GET",
+                            "/cameras/{camera-name}/stats",
+                            routeObj.controller.getCameraStats}
+    routeObj.entries[36] = routeEntry{
+                            "captureCameraClip",
+                            "POST",
+                            "/cameras/{camera-name}/clips",
+                            routeObj.controller.captureCameraClip}
 }
 
 // NewRouter function configures a new router to the API
 func (routeObj *Routes)NewRouter() *mux.Router {
     log := logging.GetLoggerInstance()
     router := mux.NewRouter().StrictSlash(true)
+    //Controller must be present before binding its handlers to the routes.
+    if routeObj.controller == nil {
+        routeObj.controller = new(controller)
+    }
     routeObj.CreateAllRoutes()
     for _, route := range routeObj.entries {
         var handler http.Handler
@@ -98,6 +246,5 @@ func (routeObj *Routes)NewRouter() *mux.Router {
          Handler(handler)
         log.Trace("Created route for %s", route.Name)
     }
-    routeObj.controller = new(controller)
     return router
 }
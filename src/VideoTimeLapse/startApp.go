@@ -17,14 +17,17 @@ package main
 import (
     "fmt"
     "os"
+    "encoding/json"
     "syscall"
     "os/signal"
     "VideoTimeLapse/config"
     "VideoTimeLapse/sys"
     "VideoTimeLapse/logging"
     "VideoTimeLapse/restAPI"
+    "VideoTimeLapse/jobQueue"
     "VideoTimeLapse/dataSet/dataSetImpl"
     "VideoTimeLapse/CameraTimeLapse/CameraThreadImpl"
+    "VideoTimeLapse/CameraTimeLapse/CameraThreadImpl/RTSPCameraImpl"
 )
 
 func startLoggerService(configObj *config.AppConfig) {
@@ -47,13 +50,24 @@ func setupDBService(configObj *config.AppConfig) error {
 
 func setupRESTService(configObj *config.AppConfig) error {
     resthandler := new(restAPI.RestAPI)
-    err := resthandler.RestAPIMainHandler(configObj.Ip, configObj.Port)
+    err := resthandler.RestAPIMainHandler(configObj.Ip, configObj.Port,
+                                          configObj.VideoPath)
     if err != nil {
         return err
     }
     return nil
 }
 
+func setupJobService(configObj *config.AppConfig) error {
+    RTSPCameraImpl.RegisterJobHandlers(configObj)
+    err := jobQueue.GetJobQueueObj().StartJobWorkers(configObj.JobWorkers)
+    if err != nil {
+        return err
+    }
+    RTSPCameraImpl.StartRollupScheduler()
+    return nil
+}
+
 func setupCameraTimeLapseService(configObj *config.AppConfig) error {
     camThreadRunner := CameraThreadImpl.GetCameraThreadRunner()
     camThreadRunner.CamThreadRunnerMain(configObj)
@@ -61,6 +75,24 @@ func setupCameraTimeLapseService(configObj *config.AppConfig) error {
     return err
 }
 
+//Run the consistency check of the video store and print the report. The job
+// workers are not started for the command, so the orphaned cycles are
+// rendered inline.
+func runFsckCommand(configObj *config.AppConfig) error {
+    opts := RTSPCameraImpl.FsckOptions{
+        Repair: configObj.FsckRepair,
+        Quarantine: configObj.FsckQuarantine,
+        Inline: true,
+    }
+    report, err := RTSPCameraImpl.CheckVideoStore(configObj.VideoPath, opts)
+    if err != nil {
+        return err
+    }
+    data, _ := json.MarshalIndent(report, "", "    ")
+    fmt.Println(string(data))
+    return nil
+}
+
 func main() {
     var err error
     configObj := new(config.AppConfig)
@@ -77,6 +109,20 @@ func main() {
         panic("DB init error")
     }
 
+    if configObj.Command == config.CMD_FSCK {
+        err = runFsckCommand(configObj)
+        if err != nil {
+            log.Error("Failed to check the video store, err: %s", err)
+            os.Exit(1)
+        }
+        return
+    }
+
+    err = setupJobService(configObj)
+    if err != nil {
+        log.Error("Failed to start the job service, err: %s", err)
+        panic("Cannot start job service.")
+    }
     err = setupCameraTimeLapseService(configObj)
     if err != nil {
         log.Error("Failed to start cameraThreadRunner module")